@@ -41,6 +41,8 @@ func main() {
 			collateralCmd,
 			ubiTaskCmd,
 			contractCmd,
+			configCmd,
+			modelCacheCmd,
 		},
 		Before: func(c *cli.Context) error {
 			cpRepoPath, err := homedir.Expand(c.String(FlagRepo.Name))
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/swanchain/go-computing-provider/conf"
+	"github.com/swanchain/go-computing-provider/internal/computing"
+	"github.com/urfave/cli/v2"
+	"os"
+	"time"
+)
+
+var modelCacheCmd = &cli.Command{
+	Name:  "model-cache",
+	Usage: "Manage the models cached on the nodes",
+	Subcommands: []*cli.Command{
+		modelCacheList,
+		modelCachePrefetch,
+		modelCachePurge,
+	},
+	Before: func(cctx *cli.Context) error {
+		cpRepoPath, ok := os.LookupEnv("CP_PATH")
+		if !ok {
+			return fmt.Errorf("missing CP_PATH env, please set export CP_PATH=<YOUR CP_PATH>")
+		}
+		if err := conf.InitConfig(cpRepoPath, false); err != nil {
+			return fmt.Errorf("load config file failed, error: %+v", err)
+		}
+		if !conf.GetConfig().MODEL_CACHE.Enable {
+			return fmt.Errorf("the model cache is off, set [MODEL_CACHE].Enable = true in the config.toml")
+		}
+		return nil
+	},
+}
+
+var modelCacheList = &cli.Command{
+	Name:  "list",
+	Usage: "List the cached models, the least recently used first",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "node",
+			Usage: "only the models cached on the node",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		entries, err := computing.NewModelCacheService().GetModelCacheList(cctx.String("node"))
+		if err != nil {
+			return fmt.Errorf("get the model cache failed, error: %+v", err)
+		}
+
+		var cacheData [][]string
+		var total int64
+		for _, entry := range entries {
+			total += entry.Size
+			cacheData = append(cacheData, []string{entry.NodeName, entry.Source, entry.CacheKey[:16],
+				fmt.Sprintf("%.2f GiB", float64(entry.Size)/1024/1024/1024), time.Unix(entry.LastUsed, 0).Format("2006-01-02 15:04:05")})
+		}
+
+		header := []string{"NODE", "SOURCE", "KEY", "SIZE", "LAST USED"}
+		NewVisualTable(header, cacheData, []RowColor{}).Generate(true)
+		fmt.Printf("%d models, %.2f GiB, at most %d GiB per node\n", len(entries), float64(total)/1024/1024/1024, conf.GetConfig().MODEL_CACHE.MaxSize)
+		return nil
+	},
+}
+
+var modelCachePrefetch = &cli.Command{
+	Name:      "prefetch",
+	Usage:     "Download a model into the cache of the nodes, so that the spaces using it deploy without downloading it",
+	ArgsUsage: "[url | hf:<model id>]",
+	Flags: []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:  "node",
+			Usage: "the nodes caching the model, all the ready nodes by default",
+		},
+		&cli.StringFlag{
+			Name:  "sha256",
+			Usage: "the sha256 of a model file, it is checked after the download",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if cctx.NArg() != 1 {
+			return fmt.Errorf("incorrect number of arguments, got %d, missing args: url or hf:<model id>", cctx.NArg())
+		}
+		model, err := computing.ParseModelSource(cctx.Args().First(), cctx.String("sha256"))
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Fetching %s, this can take a while for a large model\n", model)
+		if err = computing.PrefetchModel(context.Background(), model, cctx.StringSlice("node")); err != nil {
+			return err
+		}
+		fmt.Printf("%s is cached\n", model)
+		return nil
+	},
+}
+
+var modelCachePurge = &cli.Command{
+	Name:      "purge",
+	Usage:     "Remove models from the cache, the models in use are kept",
+	ArgsUsage: "[url | hf:<model id>]",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "node",
+			Usage: "only purge the cache of the node",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if cctx.NArg() > 1 {
+			return fmt.Errorf("incorrect number of arguments, got %d, at most one model is purged, or all of them", cctx.NArg())
+		}
+		removed, kept, err := computing.PurgeModelCache(context.Background(), cctx.String("node"), cctx.Args().First())
+		for _, entry := range removed {
+			fmt.Printf("removed %s from node %s\n", entry.Source, entry.NodeName)
+		}
+		for _, entry := range kept {
+			fmt.Printf("kept %s on node %s, it is in use\n", entry.Source, entry.NodeName)
+		}
+		if err != nil {
+			return err
+		}
+		if len(removed) == 0 && len(kept) == 0 {
+			fmt.Println("No model to purge")
+		} else {
+			fmt.Printf("Purged %d models\n", len(removed))
+		}
+		return nil
+	},
+}
@@ -5,19 +5,19 @@ import (
 	"fmt"
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
-	"github.com/gin-contrib/pprof"
 	"github.com/gin-gonic/gin"
 	"github.com/itsjamie/gin-cors"
 	"github.com/olekukonko/tablewriter"
 	"github.com/swanchain/go-computing-provider/conf"
 	"github.com/swanchain/go-computing-provider/internal/computing"
+	"github.com/swanchain/go-computing-provider/internal/contract"
 	account2 "github.com/swanchain/go-computing-provider/internal/contract/account"
 	"github.com/swanchain/go-computing-provider/internal/contract/ecp"
 	"github.com/swanchain/go-computing-provider/internal/contract/fcp"
 	"github.com/swanchain/go-computing-provider/internal/contract/token"
 	"github.com/swanchain/go-computing-provider/internal/initializer"
+	"github.com/swanchain/go-computing-provider/internal/metrics"
 	"github.com/swanchain/go-computing-provider/internal/models"
 	"github.com/swanchain/go-computing-provider/util"
 	"github.com/swanchain/go-computing-provider/wallet"
@@ -43,18 +43,19 @@ var runCmd = &cli.Command{
 		initializer.ProjectInit(cpRepoPath)
 
 		r := gin.Default()
-		r.Use(cors.Middleware(cors.Config{
+		r.Use(computing.ExceptAdminApi(cors.Middleware(cors.Config{
 			Origins:         "*",
 			Methods:         "GET, PUT, POST, DELETE",
 			RequestHeaders:  "Origin, Authorization, Content-Type",
 			ExposedHeaders:  "",
 			MaxAge:          50 * time.Second,
 			ValidateHeaders: false,
-		}))
-		pprof.Register(r)
+		})))
+		r.GET("/metrics", metrics.Handler())
 
 		v1 := r.Group("/api/v1")
 		cpManager(v1.Group("/computing"))
+		computing.RegisterAdminRoutes(r.Group(computing.AdminApiPath), computing.UbiRuntimeK8s)
 
 		shutdownChan := make(chan struct{})
 		httpStopper, err := util.ServeHttp(r, "cp-api", ":"+strconv.Itoa(conf.GetConfig().API.Port), true)
@@ -107,15 +108,10 @@ var infoCmd = &cli.Command{
 			count, _ = k8sService.GetDeploymentActiveCount()
 		}
 
-		chainRpc, err := conf.GetRpcByNetWorkName()
+		client, err := contract.GetEthClient()
 		if err != nil {
 			return err
 		}
-		client, err := ethclient.Dial(chainRpc)
-		if err != nil {
-			return err
-		}
-		defer client.Close()
 
 		var netWork = ""
 		chainId, err := client.ChainID(context.Background())
@@ -247,6 +243,7 @@ var stateCmd = &cli.Command{
 	Subcommands: []*cli.Command{
 		stateInfoCmd,
 		taskInfoCmd,
+		rpcStatusCmd,
 	},
 	Before: func(c *cli.Context) error {
 		cpRepoPath, _ := os.LookupEnv("CP_PATH")
@@ -262,15 +259,10 @@ var stateInfoCmd = &cli.Command{
 	Usage:     "Print computing-provider chain info",
 	ArgsUsage: "[cp_account_contract_address]",
 	Action: func(cctx *cli.Context) error {
-		chainRpc, err := conf.GetRpcByNetWorkName()
+		client, err := contract.GetEthClient()
 		if err != nil {
 			return err
 		}
-		client, err := ethclient.Dial(chainRpc)
-		if err != nil {
-			return err
-		}
-		defer client.Close()
 
 		var fcpCollateralBalance = "0.0000"
 		var fcpEscrowBalance = "0.0000"
@@ -380,11 +372,6 @@ var taskInfoCmd = &cli.Command{
 			return fmt.Errorf("the task contract address is required")
 		}
 
-		chainRpc, err := conf.GetRpcByNetWorkName()
-		if err != nil {
-			return err
-		}
-
 		taskInfo, err := computing.GetTaskInfoOnChain(taskContract)
 		if err != nil {
 			return fmt.Errorf("get task info on the chain failed, error: %v", err)
@@ -418,9 +405,8 @@ var taskInfoCmd = &cli.Command{
 		}
 
 		if taskInfo.RewardTx != "" {
-			client, err := ethclient.Dial(chainRpc)
+			client, err := contract.GetEthClient()
 			if err == nil {
-				defer client.Close()
 				receipt, err := client.TransactionReceipt(context.Background(), common.HexToHash(taskInfo.RewardTx))
 				if err == nil {
 					contractAbi, err := abi.JSON(strings.NewReader(token.TokenMetaData.ABI))
@@ -472,6 +458,40 @@ var taskInfoCmd = &cli.Command{
 	},
 }
 
+var rpcStatusCmd = &cli.Command{
+	Name:  "rpc",
+	Usage: "Print the health of the SWAN_CHAIN_RPC endpoints and the active one",
+	Action: func(cctx *cli.Context) error {
+		pool, err := contract.GetRpcPool()
+		if err != nil {
+			return err
+		}
+
+		var taskData [][]string
+		var rowColorList []RowColor
+		for i, endpoint := range pool.Status() {
+			var active, health = "", "healthy"
+			if endpoint.Active {
+				active = "*"
+				rowColorList = append(rowColorList, RowColor{
+					row:    i,
+					column: []int{0},
+					color:  []tablewriter.Colors{{tablewriter.Bold, tablewriter.FgGreenColor}},
+				})
+			}
+			if !endpoint.Healthy {
+				health = endpoint.Error
+			}
+			taskData = append(taskData, []string{active + endpoint.Url, strconv.FormatUint(endpoint.Height, 10),
+				endpoint.Latency.Round(time.Millisecond).String(), health})
+		}
+
+		header := []string{"URL", "BLOCK HEIGHT", "LATENCY", "STATUS"}
+		NewVisualTable(header, taskData, rowColorList).Generate(true)
+		return nil
+	},
+}
+
 var initCmd = &cli.Command{
 	Name:  "init",
 	Usage: "Initialize a new cp",
@@ -940,15 +960,10 @@ var contractCmd = &cli.Command{
 					return fmt.Errorf("load config file failed, error: %+v", err)
 				}
 
-				chainRpc, err := conf.GetRpcByNetWorkName()
+				client, err := contract.GetEthClient()
 				if err != nil {
 					return err
 				}
-				client, err := ethclient.Dial(chainRpc)
-				if err != nil {
-					return err
-				}
-				defer client.Close()
 
 				var netWork = ""
 				chainId, err := client.ChainID(context.Background())
@@ -987,22 +1002,54 @@ var contractCmd = &cli.Command{
 	},
 }
 
+var configCmd = &cli.Command{
+	Name:  "config",
+	Usage: "Manage the config.toml of CP",
+	Subcommands: []*cli.Command{
+		configValidateCmd,
+	},
+}
+
+var configValidateCmd = &cli.Command{
+	Name:  "validate",
+	Usage: "Check the config.toml offline and print every problem found",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "ecp",
+			Usage: "Check the config for `ubi daemon` (ECP) instead of `run` (FCP)",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		cpRepoPath, _ := os.LookupEnv("CP_PATH")
+		errs, err := conf.ValidateConfig(cpRepoPath, cctx.Bool("ecp"))
+		if err != nil {
+			return err
+		}
+		if len(errs) == 0 {
+			fmt.Println("The config is valid")
+			return nil
+		}
+
+		var taskData [][]string
+		for _, e := range errs {
+			taskData = append(taskData, []string{e.Field, e.Message, e.Suggestion})
+		}
+		header := []string{"FIELD", "PROBLEM", "SUGGESTION"}
+		NewVisualTable(header, taskData, []RowColor{}).Generate(true)
+		return fmt.Errorf("found %d problems in the config", len(errs))
+	},
+}
+
 func isValidWalletAddress(address string) bool {
 	re := regexp.MustCompile(`^0x[0-9a-fA-F]{40}$`)
 	return re.MatchString(address)
 }
 
 func checkWalletAddress(walletAddress string, msg string) error {
-	chainUrl, err := conf.GetRpcByNetWorkName()
-	if err != nil {
-		return err
-	}
-
-	client, err := ethclient.Dial(chainUrl)
+	client, err := contract.GetEthClient()
 	if err != nil {
 		return err
 	}
-	defer client.Close()
 
 	checkOwnerAddress := common.HexToAddress(walletAddress)
 	bytecode, err := client.CodeAt(context.Background(), checkOwnerAddress, nil)
@@ -7,9 +7,11 @@ import (
 	"github.com/swanchain/go-computing-provider/conf"
 	"github.com/swanchain/go-computing-provider/constants"
 	"github.com/swanchain/go-computing-provider/internal/computing"
+	"github.com/swanchain/go-computing-provider/internal/models"
 	"github.com/urfave/cli/v2"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -21,6 +23,7 @@ var taskCmd = &cli.Command{
 		taskList,
 		taskDetail,
 		taskDelete,
+		taskOutbox,
 	},
 }
 
@@ -66,7 +69,7 @@ var taskList = &cli.Command{
 
 			if fullFlag {
 				taskData = append(taskData,
-					[]string{job.TaskUuid, job.ResourceType, job.WalletAddress, fullSpaceUuid, job.Name, status, expireTime})
+					[]string{job.TaskUuid, job.ResourceType, job.WalletAddress, fullSpaceUuid, job.Name, status, job.Health, expireTime})
 			} else {
 				var walletAddress string
 				if len(job.WalletAddress) > 0 {
@@ -84,7 +87,7 @@ var taskList = &cli.Command{
 				}
 
 				taskData = append(taskData,
-					[]string{taskUuid, job.ResourceType, walletAddress, spaceUuid, job.Name, status, expireTime})
+					[]string{taskUuid, job.ResourceType, walletAddress, spaceUuid, job.Name, status, job.Health, expireTime})
 			}
 
 			var rowColor []tablewriter.Colors
@@ -103,7 +106,7 @@ var taskList = &cli.Command{
 			})
 		}
 
-		header := []string{"TASK UUID", "TASK TYPE", "WALLET ADDRESS", "SPACE UUID", "SPACE NAME", "STATUS", "EXPIRE TIME"}
+		header := []string{"TASK UUID", "TASK TYPE", "WALLET ADDRESS", "SPACE UUID", "SPACE NAME", "STATUS", "HEALTH", "EXPIRE TIME"}
 		NewVisualTable(header, taskData, rowColorList).Generate(true)
 		return nil
 	},
@@ -145,6 +148,7 @@ var taskDetail = &cli.Command{
 		taskData = append(taskData, []string{"SPACE URL:", job.RealUrl})
 		taskData = append(taskData, []string{"HARDWARE:", job.Hardware})
 		taskData = append(taskData, []string{"STATUS:", status})
+		taskData = append(taskData, []string{"HEALTH:", job.Health})
 
 		var rowColor []tablewriter.Colors
 		if status == "Pending" {
@@ -207,3 +211,58 @@ var taskDelete = &cli.Command{
 		return nil
 	},
 }
+
+var taskOutbox = &cli.Command{
+	Name:  "outbox",
+	Usage: "List the job events not delivered to the orchestrator yet",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "dead",
+			Usage: "only the events given up after their last attempt",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		cpRepoPath, ok := os.LookupEnv("CP_PATH")
+		if !ok {
+			return fmt.Errorf("missing CP_PATH env, please set export CP_PATH=<YOUR CP_PATH>")
+		}
+		if err := conf.InitConfig(cpRepoPath, false); err != nil {
+			return fmt.Errorf("load config file failed, error: %+v", err)
+		}
+
+		events, err := computing.NewHubEventService().GetUndeliveredHubEvents()
+		if err != nil {
+			return fmt.Errorf("get hub events failed, error: %+v", err)
+		}
+
+		var eventData [][]string
+		var rowColorList []RowColor
+		for _, event := range events {
+			status := "pending"
+			nextAttempt := time.Unix(event.NextAttempt, 0).Format("2006-01-02 15:04:05")
+			if event.Status == models.HUB_EVENT_DEAD_STATUS {
+				status = "dead"
+				nextAttempt = "-"
+			} else if cctx.Bool("dead") {
+				continue
+			}
+
+			eventData = append(eventData, []string{strconv.FormatInt(event.Id, 10), event.JobUuid, event.EventType,
+				event.Value, status, strconv.Itoa(event.Attempts), nextAttempt, event.LastError})
+
+			rowColor := []tablewriter.Colors{{tablewriter.Bold, tablewriter.FgYellowColor}}
+			if status == "dead" {
+				rowColor = []tablewriter.Colors{{tablewriter.Bold, tablewriter.FgRedColor}}
+			}
+			rowColorList = append(rowColorList, RowColor{
+				row:    len(eventData) - 1,
+				column: []int{4},
+				color:  rowColor,
+			})
+		}
+
+		header := []string{"ID", "JOB UUID", "EVENT", "VALUE", "STATUS", "ATTEMPTS", "NEXT ATTEMPT", "LAST ERROR"}
+		NewVisualTable(header, eventData, rowColorList).Generate(true)
+		return nil
+	},
+}
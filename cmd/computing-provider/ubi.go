@@ -4,14 +4,16 @@ import (
 	_ "embed"
 	"fmt"
 	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
-	"github.com/gin-contrib/pprof"
 	"github.com/gin-gonic/gin"
 	cors "github.com/itsjamie/gin-cors"
 	"github.com/olekukonko/tablewriter"
 	"github.com/swanchain/go-computing-provider/conf"
 	"github.com/swanchain/go-computing-provider/internal/computing"
+	"github.com/swanchain/go-computing-provider/internal/contract"
+	"github.com/swanchain/go-computing-provider/internal/metrics"
 	"github.com/swanchain/go-computing-provider/internal/models"
 	"github.com/swanchain/go-computing-provider/util"
+	"github.com/swanchain/go-computing-provider/wallet"
 	"github.com/urfave/cli/v2"
 	"os"
 	"strconv"
@@ -163,20 +165,37 @@ var daemonCmd = &cli.Command{
 		if err := conf.InitConfig(cpRepoPath, true); err != nil {
 			logs.GetLogger().Fatal(err)
 		}
+		if errs := conf.GetConfig().Validate(true); len(errs) > 0 {
+			logs.GetLogger().Fatalf("invalid config, run `computing-provider config validate --ecp` for the details:\n%v", errs)
+		}
+		if err := conf.WatchConfig(cpRepoPath, true); err != nil {
+			logs.GetLogger().Warnf("the config file is not watched, send SIGHUP to reload it, error: %v", err)
+		}
+		if migrated, err := wallet.UnlockKeystore(cctx.Context); err != nil {
+			logs.GetLogger().Fatalf("unlock keystore failed, error: %v", err)
+		} else if migrated > 0 {
+			logs.GetLogger().Infof("encrypted %d plaintext keys in the keystore", migrated)
+		}
 
 		computing.SyncCpAccountInfo()
 		computing.CronTaskForEcp()
+		computing.StartUbiTaskQueue(computing.UbiRuntimeDocker)
+		workerSigner, err := computing.GetWorkerSigner()
+		if err != nil {
+			logs.GetLogger().Warnf("the stuck proof transactions are sped up once the worker has sent a transaction, error: %v", err)
+		}
+		contract.StartTxTracker(workerSigner)
 
 		r := gin.Default()
-		r.Use(cors.Middleware(cors.Config{
+		r.Use(computing.ExceptAdminApi(cors.Middleware(cors.Config{
 			Origins:         "*",
 			Methods:         "GET, PUT, POST, DELETE",
 			RequestHeaders:  "Origin, Authorization, Content-Type",
 			ExposedHeaders:  "",
 			MaxAge:          50 * time.Second,
 			ValidateHeaders: false,
-		}))
-		pprof.Register(r)
+		})))
+		r.GET("/metrics", metrics.Handler())
 
 		v1 := r.Group("/api/v1")
 		router := v1.Group("/computing")
@@ -184,6 +203,7 @@ var daemonCmd = &cli.Command{
 		router.GET("/cp", computing.GetCpResource)
 		router.POST("/cp/ubi", computing.DoUbiTaskForDocker)
 		router.POST("/cp/docker/receive/ubi", computing.ReceiveUbiProofForDocker)
+		computing.RegisterAdminRoutes(r.Group(computing.AdminApiPath), computing.UbiRuntimeDocker)
 
 		shutdownChan := make(chan struct{})
 		httpStopper, err := util.ServeHttp(r, "cp-api", ":"+strconv.Itoa(conf.GetConfig().API.Port), false)
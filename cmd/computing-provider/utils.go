@@ -2,18 +2,17 @@ package main
 
 import (
 	"context"
-	"crypto/ecdsa"
 	"fmt"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/swanchain/go-computing-provider/conf"
 	"github.com/swanchain/go-computing-provider/internal/computing"
+	"github.com/swanchain/go-computing-provider/internal/contract"
 	account2 "github.com/swanchain/go-computing-provider/internal/contract/account"
 	"github.com/swanchain/go-computing-provider/internal/models"
 	"github.com/swanchain/go-computing-provider/wallet"
-	"math/big"
 	"os"
 	"path/filepath"
 	"strings"
@@ -21,59 +20,30 @@ import (
 )
 
 func createAccount(cpRepoPath, ownerAddress, beneficiaryAddress string, workerAddress string, taskTypes []uint8) error {
-	chainUrl, err := conf.GetRpcByNetWorkName()
-	if err != nil {
-		return fmt.Errorf("get rpc url failed, error: %v", err)
-	}
-
 	localWallet, err := wallet.SetupWallet(wallet.WalletRepo)
 	if err != nil {
 		return fmt.Errorf("setup wallet failed, error: %v", err)
 	}
 
-	ki, err := localWallet.FindKey(ownerAddress)
-	if err != nil || ki == nil {
-		return fmt.Errorf("the address: %s, private key %v", ownerAddress, wallet.ErrKeyInfoNotFound)
-	}
-
-	client, err := ethclient.Dial(chainUrl)
-	if err != nil {
-		return fmt.Errorf("dial rpc connect failed, error: %v", err)
-	}
-	defer client.Close()
-
-	privateKey, err := crypto.HexToECDSA(ki.PrivateKey)
+	signer, err := localWallet.GetSigner(context.Background(), ownerAddress)
 	if err != nil {
-		return err
-	}
-
-	publicKey := privateKey.Public()
-	publicKeyECDSA, ok := publicKey.(*ecdsa.PublicKey)
-	if !ok {
-		return fmt.Errorf("failed to cast public key to ECDSA")
+		return fmt.Errorf("the address: %s, get signer failed, error: %v", ownerAddress, err)
 	}
 
-	publicAddress := crypto.PubkeyToAddress(*publicKeyECDSA)
-	nonce, err := client.PendingNonceAt(context.Background(), publicAddress)
+	client, err := contract.GetEthClient()
 	if err != nil {
-		return err
+		return fmt.Errorf("dial rpc connect failed, error: %v", err)
 	}
 
-	suggestGasPrice, err := client.SuggestGasPrice(context.Background())
+	chainId, _ := client.ChainID(context.Background())
+	auth, err := contract.NewTransactOpts(signer, chainId)
 	if err != nil {
 		return err
 	}
 
-	chainId, _ := client.ChainID(context.Background())
-	auth, err := bind.NewKeyedTransactorWithChainID(privateKey, chainId)
-	if err != nil {
+	if err = contract.SetGasFees(client, auth); err != nil {
 		return err
 	}
-
-	auth.Nonce = big.NewInt(int64(nonce))
-	suggestGasPrice = suggestGasPrice.Mul(suggestGasPrice, big.NewInt(3))
-	suggestGasPrice = suggestGasPrice.Div(suggestGasPrice, big.NewInt(2))
-	auth.GasFeeCap = suggestGasPrice
 	auth.Context = context.Background()
 
 	nodeID := computing.GetNodeId(cpRepoPath)
@@ -83,8 +53,12 @@ func createAccount(cpRepoPath, ownerAddress, beneficiaryAddress string, workerAd
 		return fmt.Errorf("the multi-address field needs to be configured, by modify config file or computing-provider init")
 	}
 
-	contractAddress, tx, _, err := account2.DeployAccount(auth, client, nodeID, []string{multiAddresses}, common.HexToAddress(beneficiaryAddress),
-		common.HexToAddress(workerAddress), common.HexToAddress(conf.GetConfig().CONTRACT.Register), taskTypes)
+	var contractAddress common.Address
+	tx, err := contract.SendTx(client, auth, 0, func(opts *bind.TransactOpts) (tx *types.Transaction, err error) {
+		contractAddress, tx, _, err = account2.DeployAccount(opts, client, nodeID, []string{multiAddresses}, common.HexToAddress(beneficiaryAddress),
+			common.HexToAddress(workerAddress), common.HexToAddress(conf.GetConfig().CONTRACT.Register), taskTypes)
+		return tx, err
+	})
 	if err != nil {
 		return fmt.Errorf("deploy cp account contract failed, error: %v", err)
 	}
@@ -116,28 +90,22 @@ func createAccount(cpRepoPath, ownerAddress, beneficiaryAddress string, workerAd
 }
 
 func getVerifyAccountClient(ownerAddress string) (*ethclient.Client, *account2.CpStub, error) {
-	chainUrl, err := conf.GetRpcByNetWorkName()
-	if err != nil {
-		return nil, nil, fmt.Errorf("get rpc url failed, error: %v", err)
-	}
-
 	localWallet, err := wallet.SetupWallet(wallet.WalletRepo)
 	if err != nil {
 		return nil, nil, fmt.Errorf("setup wallet failed, error: %v", err)
 	}
 
-	ki, err := localWallet.FindKey(ownerAddress)
-	if err != nil || ki == nil {
-		return nil, nil, fmt.Errorf("the address: %s, private key %v", ownerAddress, wallet.ErrKeyInfoNotFound)
+	signer, err := localWallet.GetSigner(context.Background(), ownerAddress)
+	if err != nil {
+		return nil, nil, fmt.Errorf("the address: %s, get signer failed, error: %v", ownerAddress, err)
 	}
 
-	client, err := ethclient.Dial(chainUrl)
+	client, err := contract.GetEthClient()
 	if err != nil {
-		client.Close()
 		return nil, nil, fmt.Errorf("dial rpc connect failed, error: %v", err)
 	}
 
-	cpStub, err := account2.NewAccountStub(client, account2.WithCpPrivateKey(ki.PrivateKey))
+	cpStub, err := account2.NewAccountStub(client, account2.WithCpSigner(signer))
 	if err != nil {
 		client.Close()
 		return nil, nil, err
@@ -26,6 +26,7 @@ var walletCmd = &cli.Command{
 		walletSign,
 		walletVerify,
 		walletSend,
+		walletChangePassphrase,
 	},
 	Before: func(c *cli.Context) error {
 		if c.Args().Present() {
@@ -86,6 +87,12 @@ var walletExport = &cli.Command{
 	Name:      "export",
 	Usage:     "Export keys",
 	ArgsUsage: "[address]",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "keyfile",
+			Usage: "Export the encrypted keyfile instead of the raw private key",
+		},
+	},
 	Action: func(cctx *cli.Context) error {
 		ctx := reqContext(cctx)
 		localWallet, err := wallet.SetupWallet(wallet.WalletRepo)
@@ -98,8 +105,13 @@ var walletExport = &cli.Command{
 		}
 
 		addr := cctx.Args().First()
-		if err != nil {
-			return err
+		if cctx.Bool("keyfile") {
+			keyJson, err := localWallet.WalletExportKeyFile(ctx, addr)
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(keyJson))
+			return nil
 		}
 
 		ki, err := localWallet.WalletExport(ctx, addr)
@@ -115,7 +127,7 @@ var walletExport = &cli.Command{
 var walletImport = &cli.Command{
 	Name:      "import",
 	Usage:     "Import keys",
-	ArgsUsage: "[<path> (optional, will read from stdin if omitted)]",
+	ArgsUsage: "[<path> (optional, a private key or an encrypted keyfile, will read from stdin if omitted)]",
 	Flags:     []cli.Flag{},
 	Action: func(cctx *cli.Context) error {
 		ctx := reqContext(cctx)
@@ -142,11 +154,21 @@ var walletImport = &cli.Command{
 			inpdata = fdata
 		}
 
-		var ki wallet.KeyInfo
-
-		ki.PrivateKey = strings.TrimSuffix(string(inpdata), "\n")
+		var ki = new(wallet.KeyInfo)
+		if wallet.IsKeyFile(inpdata) {
+			keyPassphrase, err := wallet.PromptPassphrase("Enter keyfile passphrase: ", false)
+			if err != nil {
+				return err
+			}
+			ki, err = wallet.KeyInfoFromKeyFile(inpdata, keyPassphrase)
+			if err != nil {
+				return err
+			}
+		} else {
+			ki.PrivateKey = strings.TrimSuffix(string(inpdata), "\n")
+		}
 
-		addr, err := localWallet.WalletImport(ctx, &ki)
+		addr, err := localWallet.WalletImport(ctx, ki)
 		if err != nil {
 			return err
 		}
@@ -295,6 +317,23 @@ var walletSend = &cli.Command{
 	},
 }
 
+var walletChangePassphrase = &cli.Command{
+	Name:  "change-passphrase",
+	Usage: "Change the keystore passphrase, keys stored in plaintext by older versions are encrypted as well",
+	Action: func(cctx *cli.Context) error {
+		ctx := reqContext(cctx)
+		localWallet, err := wallet.SetupWallet(wallet.WalletRepo)
+		if err != nil {
+			return err
+		}
+		if err = localWallet.WalletChangePassphrase(ctx); err != nil {
+			return err
+		}
+		fmt.Println("keystore passphrase changed successfully")
+		return nil
+	},
+}
+
 var collateralCmd = &cli.Command{
 	Name:      "collateral",
 	Usage:     "Manage the collateral amount",
@@ -10,20 +10,37 @@ import (
 	"path"
 	"path/filepath"
 	"strings"
+	"sync"
 )
 
-var config *ComputeNode
+var (
+	config   *ComputeNode
+	configLk sync.RWMutex
+)
 
 // ComputeNode is a compute node config
 type ComputeNode struct {
-	API      API
-	UBI      UBI
-	LOG      LOG
-	HUB      HUB
-	MCS      MCS
-	Registry Registry
-	RPC      RPC
-	CONTRACT CONTRACT `toml:"CONTRACT,omitempty"`
+	API          API
+	UBI          UBI
+	LOG          LOG
+	HUB          HUB
+	MCS          MCS
+	Registry     Registry
+	RPC          RPC
+	SIGNER       SIGNER       `toml:"SIGNER,omitempty"`
+	GAS          GAS          `toml:"GAS,omitempty"`
+	ADMIN        ADMIN        `toml:"ADMIN,omitempty"`
+	QUOTA        QUOTA        `toml:"QUOTA,omitempty"`
+	SCHEDULER    SCHEDULER    `toml:"SCHEDULER,omitempty"`
+	GPU          GPU          `toml:"GPU,omitempty"`
+	STORAGE      STORAGE      `toml:"STORAGE,omitempty"`
+	MODEL_CACHE  MODEL_CACHE  `toml:"MODEL_CACHE,omitempty"`
+	BUILD        BUILD        `toml:"BUILD,omitempty"`
+	IMAGE_POLICY IMAGE_POLICY `toml:"IMAGE_POLICY,omitempty"`
+	SECURITY     SECURITY     `toml:"SECURITY,omitempty"`
+	RUNTIME      RUNTIME      `toml:"RUNTIME,omitempty"`
+	EGRESS       EGRESS       `toml:"EGRESS,omitempty"`
+	CONTRACT     CONTRACT     `toml:"CONTRACT,omitempty"`
 }
 
 type API struct {
@@ -36,6 +53,7 @@ type API struct {
 }
 type UBI struct {
 	UbiEnginePk string
+	TaskWorkers int // The number of ubi tasks started at the same time, 0 means the default of 4
 }
 
 type LOG struct {
@@ -49,6 +67,7 @@ type HUB struct {
 	BalanceThreshold float64
 	OrchestratorPk   string
 	VerifySign       bool
+	EventUrl         string // The endpoint of the orchestrator the job events are posted to, the events are not recorded when empty
 }
 
 type MCS struct {
@@ -64,7 +83,127 @@ type Registry struct {
 }
 
 type RPC struct {
-	SwanChainRpc string `toml:"SWAN_CHAIN_RPC"`
+	SwanChainRpc RpcList `toml:"SWAN_CHAIN_RPC"`
+}
+
+// RpcList holds the chain RPC endpoints, SWAN_CHAIN_RPC takes a single url, a comma separated list or an array of them
+type RpcList []string
+
+func (l *RpcList) UnmarshalTOML(data interface{}) error {
+	var urls []string
+	switch v := data.(type) {
+	case string:
+		urls = strings.Split(v, ",")
+	case []interface{}:
+		for _, item := range v {
+			url, ok := item.(string)
+			if !ok {
+				return fmt.Errorf("SWAN_CHAIN_RPC must be a list of strings, got %v", item)
+			}
+			urls = append(urls, url)
+		}
+	default:
+		return fmt.Errorf("SWAN_CHAIN_RPC must be a string or a list of strings, got %T", data)
+	}
+
+	*l = nil
+	for _, url := range urls {
+		if url = strings.TrimSpace(url); url != "" {
+			*l = append(*l, url)
+		}
+	}
+	return nil
+}
+
+type SIGNER struct {
+	Url    string // The JSON-RPC endpoint of an external signer such as clef, empty means keys are in the local keystore
+	Method string // account_signTransaction (clef, default) or eth_signTransaction
+}
+
+type GAS struct {
+	MaxFeePerGas         float64 // The highest fee per gas paid for a transaction in gwei, transactions are refused above it, 0 means no cap
+	MaxPriorityFeePerGas float64 // The highest tip per gas paid to the block producer in gwei, 0 means no cap
+}
+
+type ADMIN struct {
+	Tokens   []string // The bearer tokens accepted by the admin API, the admin API is off when neither Tokens nor ClientCa is set
+	ClientCa string   // A CA certificate file, clients presenting a certificate signed by it are accepted by the admin API (mTLS)
+}
+
+type QUOTA struct {
+	Enable    bool      // Create a ResourceQuota and a LimitRange in the namespace of every wallet deploying spaces, the wallets on API.WalletBlackList get a quota of nothing
+	Default   QuotaTier // The limits of the wallets not on API.WalletWhiteList
+	WhiteList QuotaTier // The limits of the wallets on API.WalletWhiteList
+}
+
+type SCHEDULER struct {
+	Strategy string // How a node is chosen for a space or an ubi task: binpack (default), spread or gpu-affinity
+}
+
+type GPU struct {
+	Resources map[string]string // The extended resource of the gpus by vendor, the vendor is the first word of the gpu product name, NVIDIA and AMD are built in
+}
+
+type STORAGE struct {
+	StorageClass string // The StorageClass of the persistent volumes of the spaces, empty means the default StorageClass of the cluster
+}
+
+type MODEL_CACHE struct {
+	Enable  bool   // Cache the models of the spaces on the nodes, a model is downloaded once per node and mounted read-only into the pods
+	Path    string // The directory of the cache on every node
+	MaxSize int64  // The size of the cache on a node in GiB, the least recently used models not in use are evicted above it
+	Image   string // The image downloading the models into the cache, it needs python 3
+}
+
+type BUILD struct {
+	Cpu       int64 // The cpu cores of the builder of the space images, shared by the builds running at once
+	Memory    int64 // The memory of the builder in GiB
+	Timeout   int64 // The time limit of the build of an image in minutes
+	CacheSize int64 // The build cache kept in GiB, the layers and cache mounts least recently used are pruned above it
+}
+
+type IMAGE_POLICY struct {
+	Enable              bool     // Check the images of the spaces before they are deployed, a space whose image breaks a rule fails with the reason
+	AllowedRegistries   []string // The registries the images may come from, e.g. "docker.io", empty allows all of them but the denied ones
+	DeniedRegistries    []string // The registries the images may not come from
+	DeniedDigests       []string // The digests of the images refused, e.g. "sha256:<64 hex digits>", an image id or a manifest digest
+	MaxSize             int64    // The size of an image in GiB, 0 means no limit
+	DenyPrivilegedPorts bool     // Refuse the images exposing a port below 1024
+	DenyRootUser        bool     // Refuse the images running as root
+	VulnDbPath          string   // The trivy cache directory holding a mirror of the vulnerability database, the images are scanned offline against it, empty means no scan
+	VulnSeverities      []string // The severities of the vulnerabilities refused, e.g. ["CRITICAL", "HIGH"], empty means CRITICAL
+}
+
+type SECURITY struct {
+	Profile       string              // The hardening of the space pods and the UBI jobs: "privileged" applies none, "baseline" or "restricted", empty means baseline
+	Exceptions    map[string][]string // The exceptions a deploy.yaml may request under each profile: "run-as-root", "writable-root-fs" or a capability such as "SYS_PTRACE"
+	UbiExceptions []string            // The exceptions granted to the UBI jobs
+}
+
+type RUNTIME struct {
+	Default   string // The RuntimeClass of the spaces of the wallets not on API.WalletWhiteList, e.g. "gvisor" or "kata", empty means the default runtime of the cluster
+	WhiteList string // The RuntimeClass of the spaces of the wallets on API.WalletWhiteList
+}
+
+type EGRESS struct {
+	Enable        bool     // Apply a NetworkPolicy to the namespace of every wallet, the spaces may only reach the DNS of the cluster and the destinations allowed
+	AllowedCidrs  []string // The networks the spaces may reach, e.g. ["0.0.0.0/0"]
+	AllowedPorts  []int32  // The ports the spaces may reach on AllowedCidrs, e.g. [80, 443], empty means all of them
+	BlockedCidrs  []string // The networks the spaces may never reach, even when approved, e.g. the cloud metadata endpoints and the private networks
+	Dns           []string // The DNS servers the spaces may query besides the DNS of the cluster, e.g. ["1.1.1.1/32"]
+	ApprovedCidrs []string // The networks beyond AllowedCidrs a deploy.yaml may request egress to, a space requesting another one fails to deploy
+	Bandwidth     string   // The egress bandwidth of a space pod, e.g. "100M", set by the kubernetes.io/egress-bandwidth annotation for the bandwidth CNI plugin, empty means no limit
+}
+
+// QuotaTier holds the limits of one wallet over all its spaces, 0 means no limit
+type QuotaTier struct {
+	Spaces        int64 // The number of spaces
+	Cpu           int64 // The cpu cores
+	Memory        int64 // The memory in GiB
+	Storage       int64 // The ephemeral storage in GiB
+	Gpu           int64 // The gpus
+	Volumes       int64 // The persistent volumes
+	VolumeStorage int64 // The size of the persistent volumes in GiB
 }
 
 type CONTRACT struct {
@@ -74,33 +213,57 @@ type CONTRACT struct {
 	ZkCollateral string `toml:"ZK_COLLATERAL_CONTRACT"`
 }
 
+// GetRpcByNetWorkName returns the first of the configured chain RPC endpoints
 func GetRpcByNetWorkName() (string, error) {
-	if len(strings.TrimSpace(GetConfig().RPC.SwanChainRpc)) == 0 {
-		return "", fmt.Errorf("You need to set SWAN_CHAIN_RPC in the configuration file")
+	rpcList, err := GetRpcList()
+	if err != nil {
+		return "", err
+	}
+	return rpcList[0], nil
+}
+
+// GetRpcList returns all the configured chain RPC endpoints, in the order of preference
+func GetRpcList() ([]string, error) {
+	if len(GetConfig().RPC.SwanChainRpc) == 0 {
+		return nil, fmt.Errorf("You need to set SWAN_CHAIN_RPC in the configuration file")
 	}
 	return GetConfig().RPC.SwanChainRpc, nil
 }
 
 func InitConfig(cpRepoPath string, standalone bool) error {
+	cfg, missingFields, err := loadConfig(cpRepoPath, standalone)
+	if err != nil {
+		return err
+	}
+	if len(missingFields) > 0 {
+		log.Fatal("Required fields not given:\n", missingFields)
+	}
+	setConfig(cfg)
+	return nil
+}
+
+// loadConfig decodes the config file, missingFields are the required fields that are not given
+func loadConfig(cpRepoPath string, standalone bool) (cfg *ComputeNode, missingFields ConfigErrors, err error) {
 	configFile := filepath.Join(cpRepoPath, "config.toml")
 
 	if _, err := os.Stat(configFile); err != nil {
-		return fmt.Errorf("not found %s repo, "+
+		return nil, nil, fmt.Errorf("not found %s repo, "+
 			"please use `computing-provider init` to initialize the repo ", cpRepoPath)
 	}
 
-	metaData, err := toml.DecodeFile(configFile, &config)
+	cfg = new(ComputeNode)
+	metaData, err := toml.DecodeFile(configFile, cfg)
 	if err != nil {
-		return fmt.Errorf("failed load config file, path: %s, error: %w", configFile, err)
+		return nil, nil, fmt.Errorf("failed load config file, path: %s, error: %w", configFile, err)
 	}
 
+	fields := requiredFields
 	if standalone {
-		if !requiredFieldsAreGivenForSeparate(metaData) {
-			log.Fatal("Required fields not given")
-		}
-	} else {
-		if !requiredFieldsAreGiven(metaData) {
-			log.Fatal("Required fields not given")
+		fields = requiredFieldsForSeparate
+	}
+	for _, v := range fields {
+		if !metaData.IsDefined(v...) {
+			missingFields.add(strings.Join(v, "."), "is required but not given", "add it to the config.toml, see config.toml.sample")
 		}
 	}
 
@@ -108,78 +271,66 @@ func InitConfig(cpRepoPath string, standalone bool) error {
 	for _, nc := range networkConfig {
 		ncCopy := nc
 		if ncCopy.Network == build.NetWorkTag {
-			config.CONTRACT.SwanToken = ncCopy.Config.SwanTokenContract
-			config.CONTRACT.Collateral = ncCopy.Config.OrchestratorCollateralContract
-			config.CONTRACT.Register = ncCopy.Config.RegisterCpContract
-			config.CONTRACT.ZkCollateral = ncCopy.Config.ZkCollateralContract
+			cfg.CONTRACT.SwanToken = ncCopy.Config.SwanTokenContract
+			cfg.CONTRACT.Collateral = ncCopy.Config.OrchestratorCollateralContract
+			cfg.CONTRACT.Register = ncCopy.Config.RegisterCpContract
+			cfg.CONTRACT.ZkCollateral = ncCopy.Config.ZkCollateralContract
 		}
 	}
-	return nil
+	return cfg, missingFields, nil
+}
+
+func setConfig(cfg *ComputeNode) {
+	configLk.Lock()
+	defer configLk.Unlock()
+	config = cfg
 }
 
 func GetConfig() *ComputeNode {
+	configLk.RLock()
+	defer configLk.RUnlock()
 	return config
 }
 
-func requiredFieldsAreGiven(metaData toml.MetaData) bool {
-	requiredFields := [][]string{
-		{"API"},
-		{"LOG"},
-		{"UBI"},
-		{"HUB"},
-		{"MCS"},
-		{"Registry"},
-		{"RPC"},
+var requiredFields = [][]string{
+	{"API"},
+	{"LOG"},
+	{"UBI"},
+	{"HUB"},
+	{"MCS"},
+	{"Registry"},
+	{"RPC"},
 
-		{"API", "MultiAddress"},
-		{"API", "Domain"},
-		{"API", "NodeName"},
+	{"API", "MultiAddress"},
+	{"API", "Domain"},
+	{"API", "NodeName"},
 
-		{"LOG", "CrtFile"},
-		{"LOG", "KeyFile"},
+	{"LOG", "CrtFile"},
+	{"LOG", "KeyFile"},
 
-		{"UBI", "UbiEnginePk"},
+	{"UBI", "UbiEnginePk"},
 
-		{"HUB", "ServerUrl"},
-		{"HUB", "AccessToken"},
+	{"HUB", "ServerUrl"},
+	{"HUB", "AccessToken"},
 
-		{"MCS", "ApiKey"},
-		{"MCS", "BucketName"},
-		{"MCS", "Network"},
-
-		{"RPC", "SWAN_CHAIN_RPC"},
-	}
-
-	for _, v := range requiredFields {
-		if !metaData.IsDefined(v...) {
-			log.Fatal("Required fields ", v)
-		}
-	}
+	{"MCS", "ApiKey"},
+	{"MCS", "BucketName"},
+	{"MCS", "Network"},
 
-	return true
+	{"RPC", "SWAN_CHAIN_RPC"},
 }
 
-func requiredFieldsAreGivenForSeparate(metaData toml.MetaData) bool {
-	requiredFields := [][]string{
-		{"API"},
-		{"UBI"},
-		{"RPC"},
-
-		{"API", "MultiAddress"},
-		{"API", "NodeName"},
+var requiredFieldsForSeparate = [][]string{
+	{"API"},
+	{"UBI"},
+	{"RPC"},
 
-		{"UBI", "UbiEnginePk"},
+	{"API", "MultiAddress"},
+	{"API", "NodeName"},
 
-		{"RPC", "SWAN_CHAIN_RPC"},
-	}
-
-	for _, v := range requiredFields {
-		if !metaData.IsDefined(v...) {
-			log.Fatal("Required fields ", v)
-		}
-	}
+	{"UBI", "UbiEnginePk"},
 
-	return true
+	{"RPC", "SWAN_CHAIN_RPC"},
 }
 
 func GenerateAndUpdateConfigFile(cpRepoPath string, multiAddress, nodeName string, port int) error {
@@ -207,7 +358,7 @@ func GenerateAndUpdateConfigFile(cpRepoPath string, multiAddress, nodeName strin
 				defaultComputeNode.HUB.ServerUrl = ncCopy.Config.OrchestratorUrl
 				defaultComputeNode.HUB.OrchestratorPk = ncCopy.Config.OrchestratorPk
 
-				defaultComputeNode.RPC.SwanChainRpc = ncCopy.Config.ChainRpc
+				defaultComputeNode.RPC.SwanChainRpc = RpcList{ncCopy.Config.ChainRpc}
 			}
 		}
 
@@ -269,6 +420,7 @@ func generateDefaultConfig() ComputeNode {
 		},
 		UBI: UBI{
 			UbiEnginePk: "",
+			TaskWorkers: 4,
 		},
 		LOG: LOG{
 			CrtFile: "",
@@ -292,7 +444,74 @@ func generateDefaultConfig() ComputeNode {
 			Password:      "",
 		},
 		RPC: RPC{
-			SwanChainRpc: "",
+			SwanChainRpc: RpcList{},
+		},
+		SIGNER: SIGNER{
+			Url:    "",
+			Method: "account_signTransaction",
+		},
+		GAS: GAS{
+			MaxFeePerGas:         0,
+			MaxPriorityFeePerGas: 0,
+		},
+		ADMIN: ADMIN{
+			Tokens:   []string{},
+			ClientCa: "",
+		},
+		QUOTA: QUOTA{
+			Enable:    false,
+			Default:   QuotaTier{Spaces: 2, Cpu: 8, Memory: 32, Storage: 100, Gpu: 1},
+			WhiteList: QuotaTier{Spaces: 10, Cpu: 32, Memory: 128, Storage: 500, Gpu: 4},
+		},
+		SCHEDULER: SCHEDULER{
+			Strategy: "binpack",
+		},
+		GPU: GPU{
+			Resources: map[string]string{"NVIDIA": "nvidia.com/gpu", "AMD": "amd.com/gpu"},
+		},
+		STORAGE: STORAGE{
+			StorageClass: "",
+		},
+		MODEL_CACHE: MODEL_CACHE{
+			Enable:  false,
+			Path:    "/var/lib/swan/model-cache",
+			MaxSize: 200,
+			Image:   "python:3.11-slim",
+		},
+		BUILD: BUILD{
+			Cpu:       4,
+			Memory:    8,
+			Timeout:   30,
+			CacheSize: 50,
+		},
+		IMAGE_POLICY: IMAGE_POLICY{
+			Enable:              false,
+			AllowedRegistries:   []string{},
+			DeniedRegistries:    []string{},
+			DeniedDigests:       []string{},
+			MaxSize:             0,
+			DenyPrivilegedPorts: false,
+			DenyRootUser:        false,
+			VulnDbPath:          "",
+			VulnSeverities:      []string{"CRITICAL"},
+		},
+		SECURITY: SECURITY{
+			Profile:       "baseline",
+			Exceptions:    map[string][]string{"baseline": {}, "restricted": {"writable-root-fs"}},
+			UbiExceptions: []string{"run-as-root", "writable-root-fs"},
+		},
+		RUNTIME: RUNTIME{
+			Default:   "",
+			WhiteList: "",
+		},
+		EGRESS: EGRESS{
+			Enable:        false,
+			AllowedCidrs:  []string{"0.0.0.0/0"},
+			AllowedPorts:  []int32{},
+			BlockedCidrs:  []string{"169.254.0.0/16", "100.100.100.200/32", "10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16"},
+			Dns:           []string{},
+			ApprovedCidrs: []string{},
+			Bandwidth:     "",
 		},
 		CONTRACT: CONTRACT{
 			SwanToken:    "",
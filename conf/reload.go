@@ -0,0 +1,190 @@
+package conf
+
+import (
+	"fmt"
+	"github.com/filswan/go-swan-lib/logs"
+	"github.com/fsnotify/fsnotify"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// reloadDelay lets an editor finish writing the config file before it is read again
+const reloadDelay = time.Second
+
+// staticFields are read once when the computing provider starts, a reload that changes one of them is refused
+var staticFields = []string{
+	"API.Port",
+	"API.MultiAddress",
+	"LOG.CrtFile",
+	"LOG.KeyFile",
+	"UBI.TaskWorkers",
+	"ADMIN.ClientCa",
+}
+
+// secretFields are not written to the log when they change
+var secretFields = map[string]bool{
+	"HUB.AccessToken":   true,
+	"MCS.ApiKey":        true,
+	"Registry.Password": true,
+	"ADMIN.Tokens":      true,
+}
+
+var (
+	reloadLk    sync.Mutex
+	reloadHooks []func(old, cfg *ComputeNode)
+)
+
+type configChange struct {
+	Field string
+	Old   interface{}
+	New   interface{}
+}
+
+func (c configChange) String() string {
+	if secretFields[c.Field] {
+		return fmt.Sprintf("%s: changed", c.Field)
+	}
+	return fmt.Sprintf("%s: %v -> %v", c.Field, c.Old, c.New)
+}
+
+// OnReload registers hook to be called after a reload replaced the config old with cfg
+func OnReload(hook func(old, cfg *ComputeNode)) {
+	reloadLk.Lock()
+	defer reloadLk.Unlock()
+	reloadHooks = append(reloadHooks, hook)
+}
+
+// ReloadConfig reads the config file again and replaces the running config with it, it returns the fields that
+// changed. Unlike InitConfig it keeps the running config when the new one is not valid or changes one of the
+// staticFields.
+func ReloadConfig(cpRepoPath string, standalone bool) ([]string, error) {
+	reloadLk.Lock()
+	defer reloadLk.Unlock()
+
+	cfg, missingFields, err := loadConfig(cpRepoPath, standalone)
+	if err != nil {
+		return nil, err
+	}
+	if len(missingFields) > 0 {
+		return nil, missingFields
+	}
+	if errs := cfg.Validate(standalone); len(errs) > 0 {
+		return nil, errs
+	}
+
+	old := GetConfig()
+	changes := diffConfig(old, cfg)
+	for _, change := range changes {
+		for _, field := range staticFields {
+			if change.Field == field {
+				return nil, fmt.Errorf("%s can not be changed at runtime, restart the computing provider to apply it", field)
+			}
+		}
+	}
+	if len(changes) == 0 {
+		return nil, nil
+	}
+
+	setConfig(cfg)
+	for _, hook := range reloadHooks {
+		hook(old, cfg)
+	}
+
+	var changed []string
+	for _, change := range changes {
+		changed = append(changed, change.String())
+		logs.GetLogger().Infof("config reloaded, %s", change)
+	}
+	return changed, nil
+}
+
+// WatchConfig reloads the config when the config file is written or the process receives SIGHUP. SIGHUP is
+// handled even when the returned error says the file can not be watched.
+func WatchConfig(cpRepoPath string, standalone bool) error {
+	configFile := filepath.Join(cpRepoPath, "config.toml")
+
+	var events <-chan fsnotify.Event
+	var watchErrors <-chan error
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		err = fmt.Errorf("create config watcher failed, error: %v", err)
+	} else if err = watcher.Add(cpRepoPath); err != nil {
+		// editors often replace the file instead of writing it, so the directory is watched
+		watcher.Close()
+		err = fmt.Errorf("watch %s failed, error: %v", cpRepoPath, err)
+	} else {
+		events, watchErrors = watcher.Events, watcher.Errors
+	}
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGHUP)
+
+	go func() {
+		reload := func(reason string) {
+			changes, err := ReloadConfig(cpRepoPath, standalone)
+			if err != nil {
+				logs.GetLogger().Errorf("reload config on %s failed, the running config is kept, error: %v", reason, err)
+				return
+			}
+			if len(changes) == 0 {
+				logs.GetLogger().Infof("reload config on %s, nothing changed", reason)
+			}
+		}
+
+		timer := time.NewTimer(reloadDelay)
+		timer.Stop()
+		for {
+			select {
+			case event := <-events:
+				if filepath.Clean(event.Name) == configFile && event.Has(fsnotify.Write|fsnotify.Create|fsnotify.Rename) {
+					timer.Reset(reloadDelay)
+				}
+			case err := <-watchErrors:
+				logs.GetLogger().Warnf("config watcher error: %v", err)
+			case <-timer.C:
+				reload("file change")
+			case <-sigCh:
+				reload("SIGHUP")
+			}
+		}
+	}()
+	return err
+}
+
+// diffConfig lists the fields of the config sections that differ between old and cfg, named SECTION.Key
+func diffConfig(old, cfg *ComputeNode) []configChange {
+	if old == nil {
+		old = new(ComputeNode)
+	}
+	var changes []configChange
+	oldValue, newValue := reflect.ValueOf(*old), reflect.ValueOf(*cfg)
+	for i := 0; i < oldValue.NumField(); i++ {
+		section := oldValue.Type().Field(i)
+		oldSection, newSection := oldValue.Field(i), newValue.Field(i)
+		for j := 0; j < oldSection.NumField(); j++ {
+			a, b := oldSection.Field(j).Interface(), newSection.Field(j).Interface()
+			if reflect.DeepEqual(a, b) {
+				continue
+			}
+			changes = append(changes, configChange{
+				Field: tomlKey(section) + "." + tomlKey(oldSection.Type().Field(j)),
+				Old:   a,
+				New:   b,
+			})
+		}
+	}
+	return changes
+}
+
+func tomlKey(field reflect.StructField) string {
+	if name, _, _ := strings.Cut(field.Tag.Get("toml"), ","); name != "" {
+		return name
+	}
+	return field.Name
+}
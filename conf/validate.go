@@ -0,0 +1,455 @@
+package conf
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/multiformats/go-multiaddr"
+	"net/netip"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+)
+
+// minAdminTokenLength keeps the admin tokens from being guessed
+const minAdminTokenLength = 16
+
+// ConfigError is a problem with one field of the config, Field is the path of the field in the config file
+type ConfigError struct {
+	Field      string
+	Message    string
+	Suggestion string
+}
+
+func (e ConfigError) Error() string {
+	if e.Suggestion == "" {
+		return fmt.Sprintf("%s: %s", e.Field, e.Message)
+	}
+	return fmt.Sprintf("%s: %s (%s)", e.Field, e.Message, e.Suggestion)
+}
+
+// ConfigErrors are all the problems found in a config
+type ConfigErrors []ConfigError
+
+func (errs ConfigErrors) Error() string {
+	var lines []string
+	for _, e := range errs {
+		lines = append(lines, e.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (errs *ConfigErrors) add(field, message, suggestion string) {
+	*errs = append(*errs, ConfigError{Field: field, Message: message, Suggestion: suggestion})
+}
+
+func (errs ConfigErrors) has(field string) bool {
+	for _, e := range errs {
+		if e.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateConfig checks the config file of the repo without starting anything, standalone is the mode of
+// `ubi daemon` (ECP), otherwise the config is checked for `run` (FCP)
+func ValidateConfig(cpRepoPath string, standalone bool) (ConfigErrors, error) {
+	cfg, errs, err := loadConfig(cpRepoPath, standalone)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range cfg.Validate(standalone) {
+		if !errs.has(e.Field) {
+			errs = append(errs, e)
+		}
+	}
+	return errs, nil
+}
+
+// Validate checks the values of the config, it returns every problem found
+func (cfg *ComputeNode) Validate(standalone bool) ConfigErrors {
+	var errs ConfigErrors
+
+	if cfg.API.Port <= 0 || cfg.API.Port > 65535 {
+		errs.add("API.Port", fmt.Sprintf("%d is not a valid port", cfg.API.Port), "use a port between 1 and 65535, e.g. 8085")
+	}
+	validateMultiAddress(&errs, cfg.API.MultiAddress)
+	if strings.TrimSpace(cfg.API.NodeName) == "" || strings.Contains(cfg.API.NodeName, "<") {
+		errs.add("API.NodeName", "is not set", "set a name for the node, e.g. the hostname")
+	}
+	if !standalone {
+		validateDomain(&errs, cfg.API.Domain)
+	}
+	validateUrl(&errs, "API.WalletWhiteList", cfg.API.WalletWhiteList, false)
+	validateUrl(&errs, "API.WalletBlackList", cfg.API.WalletBlackList, false)
+
+	validateAddress(&errs, "UBI.UbiEnginePk", cfg.UBI.UbiEnginePk, true)
+	if cfg.UBI.TaskWorkers < 0 {
+		errs.add("UBI.TaskWorkers", fmt.Sprintf("%d is negative", cfg.UBI.TaskWorkers), "use 0 for the default of 4 workers")
+	}
+
+	if !standalone {
+		validateKeyPair(&errs, cfg.LOG.CrtFile, cfg.LOG.KeyFile)
+
+		validateUrl(&errs, "HUB.ServerUrl", cfg.HUB.ServerUrl, true)
+		if strings.TrimSpace(cfg.HUB.AccessToken) == "" {
+			errs.add("HUB.AccessToken", "is empty", "copy the access token of the CP from the dashboard of the orchestrator")
+		}
+		validateAddress(&errs, "HUB.OrchestratorPk", cfg.HUB.OrchestratorPk, cfg.HUB.VerifySign)
+		validateUrl(&errs, "HUB.EventUrl", cfg.HUB.EventUrl, false)
+
+		if strings.TrimSpace(cfg.MCS.ApiKey) == "" {
+			errs.add("MCS.ApiKey", "is empty", "create an api key on https://www.multichain.storage")
+		}
+		if strings.TrimSpace(cfg.MCS.BucketName) == "" {
+			errs.add("MCS.BucketName", "is empty", "create a bucket on https://www.multichain.storage")
+		}
+		if cfg.MCS.Network != "polygon.mainnet" && cfg.MCS.Network != "polygon.mumbai" {
+			errs.add("MCS.Network", fmt.Sprintf("unknown network %q", cfg.MCS.Network), `use "polygon.mainnet"`)
+		}
+	}
+	if cfg.HUB.BalanceThreshold < 0 {
+		errs.add("HUB.BalanceThreshold", fmt.Sprintf("%v is negative", cfg.HUB.BalanceThreshold), "use the lowest SWANC collateral balance to accept tasks with, e.g. 0.1")
+	}
+
+	if (cfg.Registry.UserName == "") != (cfg.Registry.Password == "") {
+		errs.add("Registry.Password", "UserName and Password must be set together", "set both of them, or neither for a registry without login")
+	}
+	if cfg.Registry.ServerAddress == "" && cfg.Registry.UserName != "" {
+		errs.add("Registry.ServerAddress", "is empty while UserName is set", "set the address of the registry, e.g. registry.example.com:5000")
+	}
+
+	if len(cfg.RPC.SwanChainRpc) == 0 {
+		errs.add("RPC.SWAN_CHAIN_RPC", "no endpoint given", `e.g. ["https://mainnet-rpc01.swanchain.io"]`)
+	}
+	for _, rpc := range cfg.RPC.SwanChainRpc {
+		validateUrl(&errs, "RPC.SWAN_CHAIN_RPC", rpc, true)
+	}
+
+	validateUrl(&errs, "SIGNER.Url", cfg.SIGNER.Url, false)
+	if cfg.SIGNER.Method != "" && cfg.SIGNER.Method != "account_signTransaction" && cfg.SIGNER.Method != "eth_signTransaction" {
+		errs.add("SIGNER.Method", fmt.Sprintf("unknown method %q", cfg.SIGNER.Method), `use "account_signTransaction" for clef or "eth_signTransaction"`)
+	}
+
+	if cfg.GAS.MaxFeePerGas < 0 {
+		errs.add("GAS.MaxFeePerGas", fmt.Sprintf("%v is negative", cfg.GAS.MaxFeePerGas), "use 0 for no cap")
+	}
+	if cfg.GAS.MaxPriorityFeePerGas < 0 {
+		errs.add("GAS.MaxPriorityFeePerGas", fmt.Sprintf("%v is negative", cfg.GAS.MaxPriorityFeePerGas), "use 0 for no cap")
+	}
+	if cfg.GAS.MaxFeePerGas > 0 && cfg.GAS.MaxPriorityFeePerGas > cfg.GAS.MaxFeePerGas {
+		errs.add("GAS.MaxPriorityFeePerGas", "is higher than MaxFeePerGas", "the tip is part of the fee, keep it at most MaxFeePerGas")
+	}
+
+	for _, t := range []struct {
+		name string
+		tier QuotaTier
+	}{{"QUOTA.Default", cfg.QUOTA.Default}, {"QUOTA.WhiteList", cfg.QUOTA.WhiteList}} {
+		name, tier := t.name, t.tier
+		if tier.Spaces < 0 || tier.Cpu < 0 || tier.Memory < 0 || tier.Storage < 0 || tier.Gpu < 0 || tier.Volumes < 0 || tier.VolumeStorage < 0 {
+			errs.add(name, "has a negative limit", "use 0 for no limit")
+		}
+	}
+
+	switch cfg.SCHEDULER.Strategy {
+	case "", "binpack", "spread", "gpu-affinity":
+	default:
+		errs.add("SCHEDULER.Strategy", fmt.Sprintf("unknown strategy %q", cfg.SCHEDULER.Strategy), `use "binpack", "spread" or "gpu-affinity"`)
+	}
+
+	var vendors []string
+	for vendor := range cfg.GPU.Resources {
+		vendors = append(vendors, vendor)
+	}
+	sort.Strings(vendors)
+	for _, vendor := range vendors {
+		field := "GPU.Resources." + vendor
+		if strings.TrimSpace(vendor) == "" || strings.ContainsAny(vendor, " \t") {
+			errs.add(field, "the vendor is not a single word", `use the first word of the gpu product name, e.g. "AMD"`)
+		}
+		if name := cfg.GPU.Resources[vendor]; !validExtendedResource(name) {
+			errs.add(field, fmt.Sprintf("%q is not an extended resource name", name), `use a name like "amd.com/gpu"`)
+		}
+	}
+
+	if class := cfg.STORAGE.StorageClass; class != "" && !validObjectName(class) {
+		errs.add("STORAGE.StorageClass", fmt.Sprintf("%q is not a StorageClass name", class), "use a name listed by `kubectl get storageclass`, or leave it empty for the default one")
+	}
+
+	for _, tier := range []struct {
+		field, class string
+	}{{"RUNTIME.Default", cfg.RUNTIME.Default}, {"RUNTIME.WhiteList", cfg.RUNTIME.WhiteList}} {
+		if tier.class != "" && !validObjectName(tier.class) {
+			errs.add(tier.field, fmt.Sprintf("%q is not a RuntimeClass name", tier.class), "use a name listed by `kubectl get runtimeclass`, or leave it empty for the default runtime")
+		}
+	}
+
+	if cfg.EGRESS.Enable {
+		validateEgress(&errs, cfg.EGRESS)
+	}
+
+	if cfg.MODEL_CACHE.Enable {
+		if !strings.HasPrefix(cfg.MODEL_CACHE.Path, "/") || strings.Trim(cfg.MODEL_CACHE.Path, "/") == "" {
+			errs.add("MODEL_CACHE.Path", fmt.Sprintf("%q is not an absolute directory", cfg.MODEL_CACHE.Path), `use a directory on the disk of the nodes, e.g. "/var/lib/swan/model-cache"`)
+		}
+		if cfg.MODEL_CACHE.MaxSize <= 0 {
+			errs.add("MODEL_CACHE.MaxSize", "must be positive", "set the size of the cache on a node in GiB")
+		}
+		if cfg.MODEL_CACHE.Image == "" {
+			errs.add("MODEL_CACHE.Image", "is empty", `use an image with python 3, e.g. "python:3.11-slim"`)
+		}
+	}
+
+	for _, limit := range []struct {
+		field string
+		value int64
+		hint  string
+	}{
+		{"BUILD.Cpu", cfg.BUILD.Cpu, "use 0 for the default of 4 cores"},
+		{"BUILD.Memory", cfg.BUILD.Memory, "use 0 for the default of 8 GiB"},
+		{"BUILD.Timeout", cfg.BUILD.Timeout, "use 0 for the default of 30 minutes"},
+		{"BUILD.CacheSize", cfg.BUILD.CacheSize, "use 0 for the default of 50 GiB"},
+	} {
+		if limit.value < 0 {
+			errs.add(limit.field, fmt.Sprintf("%d is negative", limit.value), limit.hint)
+		}
+	}
+
+	if cfg.IMAGE_POLICY.Enable {
+		validateImagePolicy(&errs, cfg.IMAGE_POLICY)
+	}
+
+	validateSecurity(&errs, cfg.SECURITY)
+
+	for _, token := range cfg.ADMIN.Tokens {
+		if len(token) < minAdminTokenLength {
+			errs.add("ADMIN.Tokens", fmt.Sprintf("a token is shorter than %d characters", minAdminTokenLength), "generate one with `openssl rand -hex 32`")
+			break
+		}
+	}
+	if cfg.ADMIN.ClientCa != "" {
+		if standalone {
+			errs.add("ADMIN.ClientCa", "client certificates need TLS, which `ubi daemon` does not serve", "use ADMIN.Tokens instead")
+		} else if caCert, err := os.ReadFile(cfg.ADMIN.ClientCa); err != nil {
+			errs.add("ADMIN.ClientCa", err.Error(), "set the path of the PEM file of the CA")
+		} else if !x509.NewCertPool().AppendCertsFromPEM(caCert) {
+			errs.add("ADMIN.ClientCa", "no certificate found in the file", "the file must hold PEM encoded certificates")
+		}
+	}
+	return errs
+}
+
+func validateMultiAddress(errs *ConfigErrors, multiAddress string) {
+	const field = "API.MultiAddress"
+	const suggestion = "use /ip4/<PUBLIC_IP>/tcp/<PORT> with the public ip and port of the CP, e.g. /ip4/203.0.113.10/tcp/8085"
+	if strings.TrimSpace(multiAddress) == "" || strings.Contains(multiAddress, "<") || strings.Contains(multiAddress, "PUBLIC") {
+		errs.add(field, "is not set", suggestion)
+		return
+	}
+	addr, err := multiaddr.NewMultiaddr(multiAddress)
+	if err != nil {
+		errs.add(field, err.Error(), suggestion)
+		return
+	}
+	if _, err = addr.ValueForProtocol(multiaddr.P_TCP); err != nil {
+		errs.add(field, "has no tcp port", suggestion)
+	}
+}
+
+func validateDomain(errs *ConfigErrors, domain string) {
+	const field = "API.Domain"
+	if strings.TrimSpace(domain) == "" {
+		errs.add(field, "is empty", "set the wildcard domain of the CP without the *, e.g. .example.com")
+		return
+	}
+	if strings.Contains(domain, "://") || strings.ContainsAny(domain, "/:* ") {
+		errs.add(field, fmt.Sprintf("%q is not a domain name", domain), "use the domain only, without scheme, port, path or *, e.g. .example.com")
+	}
+}
+
+// validateUrl checks that rawUrl is an http(s) url, an empty rawUrl is allowed unless required
+func validateUrl(errs *ConfigErrors, field, rawUrl string, required bool) {
+	if strings.TrimSpace(rawUrl) == "" {
+		if required {
+			errs.add(field, "is empty", "set an http or https url")
+		}
+		return
+	}
+	u, err := url.Parse(rawUrl)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs.add(field, fmt.Sprintf("%q is not an http or https url", rawUrl), "e.g. https://example.com/path")
+	}
+}
+
+// validateAddress checks a public key given as address, the signatures are checked against its checksum form
+func validateAddress(errs *ConfigErrors, field, address string, required bool) {
+	if address == "" {
+		if required {
+			errs.add(field, "is empty", "set the address the signatures are verified with")
+		}
+		return
+	}
+	if !common.IsHexAddress(address) {
+		errs.add(field, fmt.Sprintf("%q is not an address", address), "use a 0x prefixed address of 40 hex characters")
+		return
+	}
+	if checksum := common.HexToAddress(address).Hex(); checksum != address {
+		errs.add(field, "is not in the checksum form, so no signature matches it", "use "+checksum)
+	}
+}
+
+// validExtendedResource tells if the name is a domain prefixed resource name such as amd.com/gpu
+func validExtendedResource(name string) bool {
+	domain, resourceName, ok := strings.Cut(name, "/")
+	return ok && strings.Contains(domain, ".") && resourceName != "" && !strings.ContainsAny(name, " \t")
+}
+
+func validateImagePolicy(errs *ConfigErrors, policy IMAGE_POLICY) {
+	for _, registries := range []struct {
+		field string
+		hosts []string
+	}{
+		{"IMAGE_POLICY.AllowedRegistries", policy.AllowedRegistries},
+		{"IMAGE_POLICY.DeniedRegistries", policy.DeniedRegistries},
+	} {
+		for _, registry := range registries.hosts {
+			if registry == "" || strings.ContainsAny(registry, "/ \t") {
+				errs.add(registries.field, fmt.Sprintf("%q is not a registry", registry), `use the host of the registry, e.g. "docker.io" or "registry.example.com:5000"`)
+			}
+		}
+	}
+	for _, digest := range policy.DeniedDigests {
+		if !validDigest(digest) {
+			errs.add("IMAGE_POLICY.DeniedDigests", fmt.Sprintf("%q is not a digest", digest), `use "sha256:" followed by 64 hex digits`)
+		}
+	}
+	if policy.MaxSize < 0 {
+		errs.add("IMAGE_POLICY.MaxSize", fmt.Sprintf("%d is negative", policy.MaxSize), "use 0 for no limit")
+	}
+	if policy.VulnDbPath != "" && !strings.HasPrefix(policy.VulnDbPath, "/") {
+		errs.add("IMAGE_POLICY.VulnDbPath", fmt.Sprintf("%q is not an absolute directory", policy.VulnDbPath), "use the trivy cache directory holding the mirrored database, or leave it empty for no scan")
+	}
+	for _, severity := range policy.VulnSeverities {
+		switch severity {
+		case "UNKNOWN", "LOW", "MEDIUM", "HIGH", "CRITICAL":
+		default:
+			errs.add("IMAGE_POLICY.VulnSeverities", fmt.Sprintf("unknown severity %q", severity), `use "UNKNOWN", "LOW", "MEDIUM", "HIGH" or "CRITICAL"`)
+		}
+	}
+}
+
+func validateSecurity(errs *ConfigErrors, security SECURITY) {
+	const profiles = `use "privileged", "baseline" or "restricted"`
+	switch security.Profile {
+	case "", "privileged", "baseline", "restricted":
+	default:
+		errs.add("SECURITY.Profile", fmt.Sprintf("unknown profile %q", security.Profile), profiles)
+	}
+
+	var names []string
+	for profile := range security.Exceptions {
+		names = append(names, profile)
+	}
+	sort.Strings(names)
+	for _, profile := range names {
+		field := "SECURITY.Exceptions." + profile
+		switch profile {
+		case "privileged", "baseline", "restricted":
+		default:
+			errs.add(field, fmt.Sprintf("unknown profile %q", profile), profiles)
+		}
+		validateExceptions(errs, field, security.Exceptions[profile])
+	}
+	validateExceptions(errs, "SECURITY.UbiExceptions", security.UbiExceptions)
+}
+
+func validateExceptions(errs *ConfigErrors, field string, exceptions []string) {
+	for _, exception := range exceptions {
+		if exception == "run-as-root" || exception == "writable-root-fs" {
+			continue
+		}
+		if exception == "" || exception == "ALL" || strings.Trim(exception, "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_") != "" {
+			errs.add(field, fmt.Sprintf("unknown exception %q", exception), `use "run-as-root", "writable-root-fs" or a capability without CAP_, e.g. "SYS_PTRACE"`)
+		}
+	}
+}
+
+func validateEgress(errs *ConfigErrors, egress EGRESS) {
+	for _, cidrs := range []struct {
+		field string
+		cidrs []string
+	}{
+		{"EGRESS.AllowedCidrs", egress.AllowedCidrs},
+		{"EGRESS.BlockedCidrs", egress.BlockedCidrs},
+		{"EGRESS.Dns", egress.Dns},
+		{"EGRESS.ApprovedCidrs", egress.ApprovedCidrs},
+	} {
+		for _, cidr := range cidrs.cidrs {
+			if _, err := netip.ParsePrefix(cidr); err != nil {
+				errs.add(cidrs.field, fmt.Sprintf("%q is not a CIDR", cidr), `use a network such as "203.0.113.0/24", or "/32" for one address`)
+			}
+		}
+	}
+	for _, port := range egress.AllowedPorts {
+		if port < 1 || port > 65535 {
+			errs.add("EGRESS.AllowedPorts", fmt.Sprintf("port %d is out of range", port), "use ports from 1 to 65535")
+		}
+	}
+	if egress.Bandwidth != "" && !validBandwidth(egress.Bandwidth) {
+		errs.add("EGRESS.Bandwidth", fmt.Sprintf("%q is not a bandwidth", egress.Bandwidth), `use bits per second with an optional K, M or G suffix, e.g. "100M"`)
+	}
+}
+
+// validBandwidth tells if the bandwidth is a positive number of bits per second with an optional K, M or G suffix
+func validBandwidth(bandwidth string) bool {
+	digits := strings.TrimRight(bandwidth, "KMG")
+	if len(bandwidth)-len(digits) > 1 || digits == "" || digits[0] == '0' {
+		return false
+	}
+	return strings.Trim(digits, "0123456789") == ""
+}
+
+// validDigest tells if the digest is a sha256 digest: "sha256:" and 64 lowercase hex digits
+func validDigest(digest string) bool {
+	hexDigits, ok := strings.CutPrefix(digest, "sha256:")
+	if !ok || len(hexDigits) != 64 {
+		return false
+	}
+	return strings.Trim(hexDigits, "0123456789abcdef") == ""
+}
+
+// validObjectName tells if the name is a k8s object name: lowercase letters, digits, '-' and '.'
+func validObjectName(name string) bool {
+	if len(name) > 253 || strings.Trim(name, "-.") != name {
+		return false
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+func validateKeyPair(errs *ConfigErrors, crtFile, keyFile string) {
+	var missing bool
+	for _, f := range [][3]string{{"LOG.CrtFile", crtFile, "certificate"}, {"LOG.KeyFile", keyFile, "private key"}} {
+		field, file := f[0], f[1]
+		if strings.TrimSpace(file) == "" {
+			errs.add(field, "is empty", fmt.Sprintf("set the path of the %s file of the domain", f[2]))
+			missing = true
+		} else if _, err := os.Stat(file); err != nil {
+			errs.add(field, err.Error(), "check the path, it must be readable by the computing provider")
+			missing = true
+		}
+	}
+	if missing {
+		return
+	}
+	if _, err := tls.LoadX509KeyPair(crtFile, keyFile); err != nil {
+		errs.add("LOG.KeyFile", err.Error(), "the key must be the private key of the certificate in CrtFile")
+	}
+}
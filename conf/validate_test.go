@@ -0,0 +1,69 @@
+package conf
+
+import (
+	"testing"
+)
+
+func validTestConfig() *ComputeNode {
+	return &ComputeNode{
+		API: API{
+			Port:         8085,
+			MultiAddress: "/ip4/203.0.113.10/tcp/8085",
+			NodeName:     "node-1",
+		},
+		UBI: UBI{UbiEnginePk: "0x594A4c9A3E4c3C9f5f1D2f1a1BD6A6b3c5E7F8a9"},
+		RPC: RPC{SwanChainRpc: RpcList{"https://mainnet-rpc01.swanchain.io"}},
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if errs := validTestConfig().Validate(true); len(errs) > 0 {
+		t.Fatalf("expected the base config to be valid, got:\n%v", errs)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		modify func(cfg *ComputeNode)
+		field  string
+	}{
+		{"port out of range", func(cfg *ComputeNode) { cfg.API.Port = 70000 }, "API.Port"},
+		{"placeholder multi address", func(cfg *ComputeNode) { cfg.API.MultiAddress = "/ip4/<PUBLIC_IP>/tcp/8085" }, "API.MultiAddress"},
+		{"multi address without port", func(cfg *ComputeNode) { cfg.API.MultiAddress = "/ip4/203.0.113.10" }, "API.MultiAddress"},
+		{"engine pk not checksummed", func(cfg *ComputeNode) { cfg.UBI.UbiEnginePk = "0x594a4c9a3e4c3c9f5f1d2f1a1bd6a6b3c5e7f8a9" }, "UBI.UbiEnginePk"},
+		{"negative task workers", func(cfg *ComputeNode) { cfg.UBI.TaskWorkers = -1 }, "UBI.TaskWorkers"},
+		{"no rpc", func(cfg *ComputeNode) { cfg.RPC.SwanChainRpc = nil }, "RPC.SWAN_CHAIN_RPC"},
+		{"websocket rpc", func(cfg *ComputeNode) { cfg.RPC.SwanChainRpc = RpcList{"wss://rpc.example.com"} }, "RPC.SWAN_CHAIN_RPC"},
+		{"unknown signer method", func(cfg *ComputeNode) { cfg.SIGNER.Method = "personal_sign" }, "SIGNER.Method"},
+		{"tip above fee cap", func(cfg *ComputeNode) { cfg.GAS.MaxFeePerGas, cfg.GAS.MaxPriorityFeePerGas = 10, 20 }, "GAS.MaxPriorityFeePerGas"},
+		{"registry password alone", func(cfg *ComputeNode) { cfg.Registry.Password = "secret" }, "Registry.Password"},
+		{"negative quota", func(cfg *ComputeNode) { cfg.QUOTA.Default.Cpu = -1 }, "QUOTA.Default"},
+		{"unknown strategy", func(cfg *ComputeNode) { cfg.SCHEDULER.Strategy = "random" }, "SCHEDULER.Strategy"},
+		{"invalid storage class", func(cfg *ComputeNode) { cfg.STORAGE.StorageClass = "Fast_SSD" }, "STORAGE.StorageClass"},
+		{"invalid runtime class", func(cfg *ComputeNode) { cfg.RUNTIME.WhiteList = "-gvisor" }, "RUNTIME.WhiteList"},
+		{"egress cidr", func(cfg *ComputeNode) { cfg.EGRESS = EGRESS{Enable: true, AllowedCidrs: []string{"10.0.0.0"}} }, "EGRESS.AllowedCidrs"},
+		{"egress bandwidth", func(cfg *ComputeNode) { cfg.EGRESS = EGRESS{Enable: true, Bandwidth: "0M"} }, "EGRESS.Bandwidth"},
+		{"unknown security profile", func(cfg *ComputeNode) { cfg.SECURITY.Profile = "strict" }, "SECURITY.Profile"},
+		{"unknown exception", func(cfg *ComputeNode) { cfg.SECURITY.UbiExceptions = []string{"ALL"} }, "SECURITY.UbiExceptions"},
+		{"short admin token", func(cfg *ComputeNode) { cfg.ADMIN.Tokens = []string{"short"} }, "ADMIN.Tokens"},
+		{"client ca without tls", func(cfg *ComputeNode) { cfg.ADMIN.ClientCa = "/etc/cp/ca.pem" }, "ADMIN.ClientCa"},
+	} {
+		cfg := validTestConfig()
+		tc.modify(cfg)
+		errs := cfg.Validate(true)
+		if !errs.has(tc.field) {
+			t.Errorf("%s: expected an error on %s, got: %v", tc.name, tc.field, errs)
+		}
+		if len(errs) != 1 {
+			t.Errorf("%s: expected only the error on %s, got:\n%v", tc.name, tc.field, errs)
+		}
+	}
+}
+
+func TestValidate_NotStandalone(t *testing.T) {
+	errs := validTestConfig().Validate(false)
+	for _, field := range []string{"API.Domain", "HUB.ServerUrl", "HUB.AccessToken", "MCS.ApiKey", "MCS.BucketName", "MCS.Network"} {
+		if !errs.has(field) {
+			t.Errorf("expected an error on %s for `run`, got: %v", field, errs)
+		}
+	}
+}
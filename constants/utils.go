@@ -16,6 +16,11 @@ const K8S_CONTAINER_NAME_PREFIX = "pod-"
 const K8S_INGRESS_NAME_PREFIX = "ing-"
 const K8S_SERVICE_NAME_PREFIX = "svc-"
 const K8S_DEPLOY_NAME_PREFIX = "deploy-"
+const K8S_PVC_NAME_PREFIX = "pvc-"
+const K8S_QUOTA_NAME = "space-quota"
+const K8S_LIMIT_RANGE_NAME = "space-limits"
+const K8S_NETWORK_POLICY_NAME = "space-egress"
+const K8S_NETWORK_POLICY_NAME_PREFIX = "egress-"
 
 const CPU_AMD = "AMD"
 const CPU_INTEL = "INTEL"
@@ -0,0 +1,184 @@
+package computing
+
+import (
+	"crypto/subtle"
+	"github.com/filswan/go-swan-lib/logs"
+	"github.com/gin-contrib/pprof"
+	"github.com/gin-gonic/gin"
+	"github.com/swanchain/go-computing-provider/conf"
+	"github.com/swanchain/go-computing-provider/constants"
+	"github.com/swanchain/go-computing-provider/internal/models"
+	"github.com/swanchain/go-computing-provider/util"
+	"github.com/swanchain/go-computing-provider/wallet"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// AdminApiPath is the path of the admin api, the group given to RegisterAdminRoutes
+const AdminApiPath = "/api/v1/admin"
+
+// RegisterAdminRoutes serves the operations of the CLI and the pprof handlers to remote callers authenticated
+// by AdminAuth. runtime is UbiRuntimeK8s for `run`, which also manages the space jobs, or UbiRuntimeDocker for
+// `ubi daemon`. Nothing is served while no admin credential is configured.
+func RegisterAdminRoutes(router *gin.RouterGroup, runtime string) {
+	admin := conf.GetConfig().ADMIN
+	if len(admin.Tokens) == 0 && admin.ClientCa == "" {
+		logs.GetLogger().Infof("the admin api is disabled, set [ADMIN].Tokens or [ADMIN].ClientCa to enable it")
+		return
+	}
+
+	router.Use(AdminAuth())
+	if runtime == UbiRuntimeK8s {
+		router.GET("/jobs", AdminListJobs)
+		router.DELETE("/jobs/:task_uuid", AdminDeleteJob)
+	}
+	router.GET("/ubi/tasks", AdminListUbiTasks)
+	router.GET("/wallets", AdminWalletBalances)
+	router.POST("/config/reload", func(c *gin.Context) {
+		adminReloadConfig(c, runtime == UbiRuntimeDocker)
+	})
+	pprof.RouteRegister(router, "debug/pprof")
+}
+
+// ExceptAdminApi runs the middleware for the requests outside the admin api. The CORS of the public api must not
+// apply to the admin api, any web page could call it with the credentials of the browser otherwise.
+func ExceptAdminApi(middleware gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if path := c.Request.URL.Path; path == AdminApiPath || strings.HasPrefix(path, AdminApiPath+"/") {
+			return
+		}
+		middleware(c)
+	}
+}
+
+// AdminAuth lets through the requests with one of the [ADMIN].Tokens as bearer token, or with a client certificate
+// signed by [ADMIN].ClientCa. The config is read on every request, so that reloaded tokens apply at once.
+func AdminAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if adminAuthorized(conf.GetConfig().ADMIN, c.Request) {
+			c.Next()
+			return
+		}
+		logs.GetLogger().Warnf("admin api: unauthorized request from %s, %s %s", c.ClientIP(), c.Request.Method, c.Request.URL.Path)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, util.CreateErrorResponse(util.UnauthorizedError))
+	}
+}
+
+func adminAuthorized(admin conf.ADMIN, req *http.Request) bool {
+	if admin.ClientCa != "" && req.TLS != nil && len(req.TLS.VerifiedChains) > 0 {
+		return true
+	}
+
+	token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return false
+	}
+	for _, t := range admin.Tokens {
+		if t != "" && subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+type adminJob struct {
+	*models.JobEntity
+	Status string `json:"status"`
+}
+
+func AdminListJobs(c *gin.Context) {
+	list, err := NewJobService().GetJobList()
+	if err != nil {
+		logs.GetLogger().Errorf("get jobs failed, error: %+v", err)
+		c.JSON(http.StatusInternalServerError, util.CreateErrorResponse(util.FoundJobEntityError))
+		return
+	}
+
+	k8sService := NewK8sService()
+	var jobs []adminJob
+	for _, job := range list {
+		status, err := k8sService.GetDeploymentStatus(job.WalletAddress, job.SpaceUuid)
+		if err != nil {
+			logs.GetLogger().Warnf("failed get job status: %s, error: %+v", job.JobUuid, err)
+		}
+		jobs = append(jobs, adminJob{JobEntity: job, Status: status})
+	}
+	c.JSON(http.StatusOK, util.CreateSuccessResponse(jobs))
+}
+
+func AdminDeleteJob(c *gin.Context) {
+	taskUuid := strings.ToLower(c.Param("task_uuid"))
+	jobEntity, err := NewJobService().GetJobEntityByTaskUuid(taskUuid)
+	if err != nil {
+		logs.GetLogger().Errorf("Failed get job from db, taskUuid: %s, error: %+v", taskUuid, err)
+		c.JSON(http.StatusNotFound, util.CreateErrorResponse(util.NotFoundJobEntityError))
+		return
+	}
+
+	if jobEntity.WalletAddress != "" {
+		k8sNameSpace := constants.K8S_NAMESPACE_NAME_PREFIX + strings.ToLower(jobEntity.WalletAddress)
+		if err = deleteJob(k8sNameSpace, jobEntity.SpaceUuid, ""); err != nil {
+			c.JSON(http.StatusInternalServerError, util.CreateErrorResponse(util.DeleteJobError, err.Error()))
+			return
+		}
+	}
+	if err = NewJobService().DeleteJobEntityBySpaceUuId(jobEntity.SpaceUuid); err != nil {
+		c.JSON(http.StatusInternalServerError, util.CreateErrorResponse(util.DeleteJobError, err.Error()))
+		return
+	}
+	logs.GetLogger().Infof("admin api: task_uuid: %s, space_uuid: %s, the space service deleted", taskUuid, jobEntity.SpaceUuid)
+	c.JSON(http.StatusOK, util.CreateSuccessResponse("deleted success"))
+}
+
+// AdminListUbiTasks lists the ubi tasks like `ubi list`: the succeeded ones, or all of them with ?show_failed=true,
+// ?tail=N keeps the last N tasks
+func AdminListUbiTasks(c *gin.Context) {
+	tailNum, _ := strconv.Atoi(c.Query("tail"))
+
+	var taskList []*models.TaskEntity
+	var err error
+	if showFailed, _ := strconv.ParseBool(c.Query("show_failed")); showFailed {
+		taskList, err = NewTaskService().GetAllTask(tailNum)
+	} else {
+		taskList, err = NewTaskService().GetTaskList(models.TASK_SUCCESS_STATUS, tailNum)
+	}
+	if err != nil {
+		logs.GetLogger().Errorf("failed get ubi task, error: %+v", err)
+		c.JSON(http.StatusInternalServerError, util.CreateErrorResponse(util.FoundTaskEntityError))
+		return
+	}
+	c.JSON(http.StatusOK, util.CreateSuccessResponse(taskList))
+}
+
+// AdminWalletBalances returns the balances of the keystore addresses in sETH, or in SWANC with ?contract=true
+func AdminWalletBalances(c *gin.Context) {
+	contractFlag, _ := strconv.ParseBool(c.Query("contract"))
+
+	localWallet, err := wallet.SetupWallet(wallet.WalletRepo)
+	if err != nil {
+		logs.GetLogger().Errorf("setup wallet failed, error: %v", err)
+		c.JSON(http.StatusInternalServerError, util.CreateErrorResponse(util.GetWalletBalanceError, err.Error()))
+		return
+	}
+	balances, err := localWallet.WalletBalances(c.Request.Context(), contractFlag)
+	if err != nil {
+		logs.GetLogger().Errorf("get wallet balances failed, error: %v", err)
+		c.JSON(http.StatusInternalServerError, util.CreateErrorResponse(util.GetWalletBalanceError, err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, util.CreateSuccessResponse(balances))
+}
+
+func adminReloadConfig(c *gin.Context, standalone bool) {
+	cpRepoPath, _ := os.LookupEnv("CP_PATH")
+	changes, err := conf.ReloadConfig(cpRepoPath, standalone)
+	if err != nil {
+		logs.GetLogger().Errorf("reload config failed, error: %v", err)
+		c.JSON(http.StatusInternalServerError, util.CreateErrorResponse(util.ReloadConfigError, err.Error()))
+		return
+	}
+	logs.GetLogger().Infof("admin api: the config reloaded, %d fields changed", len(changes))
+	c.JSON(http.StatusOK, util.CreateSuccessResponse(changes))
+}
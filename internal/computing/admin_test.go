@@ -0,0 +1,70 @@
+package computing
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/swanchain/go-computing-provider/conf"
+)
+
+func TestAdminAuthorized(t *testing.T) {
+	admin := conf.ADMIN{Tokens: []string{"", "secret"}}
+	for _, tc := range []struct {
+		name          string
+		authorization string
+		authorized    bool
+	}{
+		{"missing token", "", false},
+		{"empty token", "Bearer ", false},
+		{"wrong token", "Bearer other", false},
+		{"token without scheme", "secret", false},
+		{"valid token", "Bearer secret", true},
+	} {
+		req := httptest.NewRequest(http.MethodGet, AdminApiPath+"/wallets", nil)
+		if tc.authorization != "" {
+			req.Header.Set("Authorization", tc.authorization)
+		}
+		if authorized := adminAuthorized(admin, req); authorized != tc.authorized {
+			t.Errorf("%s: expected authorized %t, got %t", tc.name, tc.authorized, authorized)
+		}
+	}
+}
+
+func TestAdminAuthorized_ClientCertificate(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, AdminApiPath+"/wallets", nil)
+	req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{}}}}
+
+	if adminAuthorized(conf.ADMIN{Tokens: []string{"secret"}}, req) {
+		t.Fatal("expected a client certificate to be refused without ClientCa")
+	}
+	if !adminAuthorized(conf.ADMIN{ClientCa: "ca.pem"}, req) {
+		t.Fatal("expected a verified client certificate to be authorized")
+	}
+}
+
+func TestExceptAdminApi(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ExceptAdminApi(func(c *gin.Context) {
+		c.Header("Access-Control-Allow-Origin", "*")
+	}))
+	router.GET("/api/v1/computing/cp", func(c *gin.Context) {})
+	router.GET(AdminApiPath+"/wallets", func(c *gin.Context) {})
+
+	for path, cors := range map[string]bool{
+		"/api/v1/computing/cp":    true,
+		AdminApiPath + "/wallets": false,
+	} {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Origin", "https://example.com")
+		router.ServeHTTP(recorder, req)
+		if allowed := recorder.Header().Get("Access-Control-Allow-Origin") != ""; allowed != cors {
+			t.Errorf("%s: expected cors %t, got %t", path, cors, allowed)
+		}
+	}
+}
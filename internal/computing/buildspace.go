@@ -1,18 +1,20 @@
 package computing
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
 	"github.com/swanchain/go-computing-provider/conf"
 	"github.com/swanchain/go-computing-provider/internal/models"
 	"io"
-	"log"
+	"io/fs"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
-	"time"
 )
 
 var NotFoundError = errors.New("not found resource")
@@ -21,54 +23,50 @@ const (
 	yamlDeployName = "deploy.yaml"
 	ymlDeployName  = "deploy.yml"
 	modelSetName   = "model-setting.json"
+
+	// spaceImageTagLength is the hex digits of the hash of the space files in the tag of its image
+	spaceImageTagLength = 16
 )
 
-func BuildSpaceTaskImage(spaceUuid string, files []models.SpaceFile) (bool, string, string, string, string, error) {
-	var err error
+// spaceBuildFolder is where the files of the spaces are downloaded to
+func spaceBuildFolder() string {
 	cpRepoPath, _ := os.LookupEnv("CP_PATH")
-	buildFolder := filepath.Join(cpRepoPath, "build")
-	if len(files) > 0 {
-		var containsYaml bool
-		var yamlName string
-		var modelsSettingFileName string
-
-		var fileNames []string
-		for _, file := range files {
-			fileNames = append(fileNames, file.Name)
-			dirPath := filepath.Dir(file.Name)
-			if err = os.MkdirAll(filepath.Join(buildFolder, dirPath), os.ModePerm); err != nil {
-				return false, "", "", "", "", err
-			}
-			if err = downloadFile(filepath.Join(buildFolder, file.Name), file.URL); err != nil {
-				return false, "", "", "", "", fmt.Errorf("error downloading file: %w", err)
-			}
-
-			if strings.HasSuffix(strings.ToLower(file.Name), yamlDeployName) ||
-				strings.HasSuffix(strings.ToLower(file.Name), ymlDeployName) {
-				containsYaml = true
-				yamlName = file.Name
-			}
-			if strings.EqualFold(file.Name, modelSetName) {
-				modelsSettingFileName = file.Name
-			}
-		}
-		prefix := commonPrefix(fileNames)
-		imagePath := filepath.Join(buildFolder, prefix)
+	return filepath.Join(cpRepoPath, "build")
+}
 
-		var modelsSettingFilePath string
-		var yamlPath string
-		if modelsSettingFileName != "" {
-			modelsSettingFilePath = filepath.Join(buildFolder, modelsSettingFileName)
+// spaceSourceLayout is the folder of the downloaded files of a space, and its deploy yaml or model setting file
+// if it has one
+func spaceSourceLayout(files []models.SpaceFile) (imagePath, yamlPath, modelsSettingFile string) {
+	buildFolder := spaceBuildFolder()
+	var fileNames []string
+	for _, file := range files {
+		fileNames = append(fileNames, file.Name)
+		if strings.HasSuffix(strings.ToLower(file.Name), yamlDeployName) ||
+			strings.HasSuffix(strings.ToLower(file.Name), ymlDeployName) {
+			yamlPath = filepath.Join(buildFolder, file.Name)
 		}
-		if yamlName != "" {
-			yamlPath = filepath.Join(buildFolder, yamlName)
+		if strings.EqualFold(file.Name, modelSetName) {
+			modelsSettingFile = filepath.Join(buildFolder, file.Name)
 		}
+	}
+	return filepath.Join(buildFolder, commonPrefix(fileNames)), yamlPath, modelsSettingFile
+}
 
-		return containsYaml, yamlPath, imagePath, modelsSettingFilePath, "", nil
-	} else {
+func downloadSpaceFiles(ctx context.Context, spaceUuid string, files []models.SpaceFile) error {
+	if len(files) == 0 {
 		logs.GetLogger().Warnf("Space %s is not found.", spaceUuid)
+		return NotFoundError
 	}
-	return false, "", "", "", "", NotFoundError
+	buildFolder := spaceBuildFolder()
+	for _, file := range files {
+		if err := os.MkdirAll(filepath.Join(buildFolder, filepath.Dir(file.Name)), os.ModePerm); err != nil {
+			return err
+		}
+		if err := downloadFile(ctx, filepath.Join(buildFolder, file.Name), file.URL); err != nil {
+			return fmt.Errorf("error downloading file: %w", err)
+		}
+	}
+	return nil
 }
 
 func commonPrefix(strs []string) string {
@@ -90,38 +88,63 @@ func commonPrefix(strs []string) string {
 	return prefix
 }
 
-func BuildImagesByDockerfile(jobUuid, spaceUuid, spaceName, imagePath string) (string, string) {
-	updateJobStatus(jobUuid, models.DEPLOY_BUILD_IMAGE)
+// spaceImageName is the image of the space built from the files with the given hash
+func spaceImageName(spaceUuid, spaceName, sourceHash string) string {
 	spaceFlag := spaceName + spaceUuid[strings.LastIndex(spaceUuid, "-"):]
-	imageName := fmt.Sprintf("lagrange/%s:%d", spaceFlag, time.Now().Unix())
+	imageName := fmt.Sprintf("lagrange/%s:%s", spaceFlag, sourceHash[:spaceImageTagLength])
 	if conf.GetConfig().Registry.ServerAddress != "" {
-		imageName = fmt.Sprintf("%s/%s:%d",
-			strings.TrimSpace(conf.GetConfig().Registry.ServerAddress), spaceFlag, time.Now().Unix())
+		imageName = fmt.Sprintf("%s/%s:%s",
+			strings.TrimSpace(conf.GetConfig().Registry.ServerAddress), spaceFlag, sourceHash[:spaceImageTagLength])
 	}
-	imageName = strings.ToLower(imageName)
-	dockerfilePath := filepath.Join(imagePath, "Dockerfile")
-	if dockerfilePath == "" {
-		dockerfilePath = filepath.Join(imagePath, "dockerfile")
-	}
-	log.Printf("Image path: %s", imagePath)
+	return strings.ToLower(imageName)
+}
 
-	dockerService := NewDockerService()
-	if err := dockerService.BuildImage(imagePath, imageName); err != nil {
-		logs.GetLogger().Errorf("Error building Docker image: %v", err)
-		return "", ""
-	}
+// spaceSourceHash is the sha256 of the downloaded files of the space, their paths and contents, so that the files
+// of a space redeployed unchanged give the same image tag. The build log left in the folder is not a file of the
+// space.
+func spaceSourceHash(imagePath string) (string, error) {
+	hash := sha256.New()
+	err := filepath.WalkDir(imagePath, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(imagePath, path)
+		if err != nil {
+			return err
+		}
+		if !entry.Type().IsRegular() || relPath == BuildFileName {
+			return nil
+		}
 
-	if conf.GetConfig().Registry.ServerAddress != "" {
-		updateJobStatus(jobUuid, models.DEPLOY_PUSH_IMAGE)
-		if err := dockerService.PushImage(imageName); err != nil {
-			logs.GetLogger().Errorf("Error Docker push image: %v", err)
-			return "", ""
+		file, err := os.Open(path)
+		if err != nil {
+			return err
 		}
+		defer file.Close()
+		info, err := file.Stat()
+		if err != nil {
+			return err
+		}
+		// the path and size delimit the content of each file
+		fmt.Fprintf(hash, "%s\x00%d\x00", filepath.ToSlash(relPath), info.Size())
+		_, err = io.Copy(hash, file)
+		return err
+	})
+	if err != nil {
+		return "", err
 	}
-	return imageName, dockerfilePath
+	return hex.EncodeToString(hash.Sum(nil)), nil
 }
 
-func downloadFile(filepath string, url string) error {
+func spaceDockerfilePath(imagePath string) string {
+	dockerfilePath := filepath.Join(imagePath, "Dockerfile")
+	if _, err := os.Stat(dockerfilePath); err != nil {
+		dockerfilePath = filepath.Join(imagePath, "dockerfile")
+	}
+	return dockerfilePath
+}
+
+func downloadFile(ctx context.Context, filepath string, url string) error {
 	out, err := os.Create(filepath)
 	if err != nil {
 		return err
@@ -133,7 +156,11 @@ func downloadFile(filepath string, url string) error {
 		}
 	}(out)
 
-	resp, err := http.Get(url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return err
 	}
@@ -15,6 +15,7 @@ import (
 	"github.com/swanchain/go-computing-provider/conf"
 	"github.com/swanchain/go-computing-provider/constants"
 	"github.com/swanchain/go-computing-provider/internal/contract"
+	"github.com/swanchain/go-computing-provider/internal/metrics"
 	"github.com/swanchain/go-computing-provider/internal/models"
 	"github.com/swanchain/go-computing-provider/util"
 	"io"
@@ -98,19 +99,30 @@ func ReceiveJob(c *gin.Context) {
 		return
 	}
 
-	available, gpuProductName, err := checkResourceAvailableForSpace(spaceDetail.Data.Space.ActiveOrder.Config.Description)
+	placement, err := checkResourceAvailableForSpace(spaceDetail.Data.Owner.PublicAddress, spaceDetail.Data.Space.ActiveOrder.Config.Description)
 	if err != nil {
 		logs.GetLogger().Errorf("check job resource failed, error: %+v", err)
 		c.JSON(http.StatusInternalServerError, util.CreateErrorResponse(util.CheckResourcesError))
 		return
 	}
 
-	if !available {
+	if placement == nil {
 		logs.GetLogger().Warnf(" task id: %s, name: %s, not found a resources available", jobData.TaskUUID, jobData.Name)
 		c.JSON(http.StatusInternalServerError, util.CreateErrorResponse(util.NoAvailableResourcesError))
 		return
 	}
 
+	if err = checkSpaceQuota(spaceDetail.Data.Owner.PublicAddress, spaceDetail.Data.Space.Uuid, spaceDetail.Data.Space.ActiveOrder.Config.Description, nil); err != nil {
+		if _, ok := err.(*QuotaExceededError); ok {
+			logs.GetLogger().Warnf("task id: %s, name: %s, %v", jobData.TaskUUID, jobData.Name, err)
+			c.JSON(http.StatusBadRequest, util.CreateErrorResponse(util.QuotaExceededError, err.Error()))
+			return
+		}
+		logs.GetLogger().Errorf("check job quota failed, error: %+v", err)
+		c.JSON(http.StatusInternalServerError, util.CreateErrorResponse(util.CheckResourcesError))
+		return
+	}
+
 	var hostName string
 	var logHost string
 	prefixStr := generateString(10)
@@ -151,6 +163,10 @@ func ReceiveJob(c *gin.Context) {
 		jobEntity.Duration = jobData.Duration
 		jobEntity.JobUuid = jobData.UUID
 		jobEntity.DeployStatus = models.DEPLOY_RECEIVE_JOB
+		metrics.ObserveDeployStage(jobEntity.JobUuid, models.DEPLOY_RECEIVE_JOB)
+		jobEntity.DeployState = models.DEPLOY_STATE_DEPLOYING
+		jobEntity.NodeName = placement.NodeName
+		jobEntity.GpuProductName = placement.GpuProductName
 		jobEntity.CreateTime = time.Now().Unix()
 		jobEntity.ExpireTime = time.Now().Unix() + int64(jobData.Duration)
 		err = NewJobService().SaveJobEntity(jobEntity)
@@ -166,7 +182,7 @@ func ReceiveJob(c *gin.Context) {
 			logs.GetLogger().Infof("jobuuid: %s successfully uploaded to MCS", jobData.UUID)
 		}()
 
-		DeploySpaceTask(jobData.JobSourceURI, hostName, jobData.Duration, jobData.UUID, jobData.TaskUUID, gpuProductName)
+		DeploySpaceTask(jobData.UUID)
 	}()
 
 	c.JSON(http.StatusOK, util.CreateSuccessResponse(jobData))
@@ -220,7 +236,11 @@ func submitJob(jobData *models.JobData) error {
 		resultMcsUrl = *gatewayUrl + "/ipfs/" + mcsOssFile.PayloadCid
 		break
 	}
-	return NewJobService().UpdateJobResultUrlByJobUuid(jobData.UUID, resultMcsUrl)
+	if err = NewJobService().UpdateJobResultUrlByJobUuid(jobData.UUID, resultMcsUrl); err != nil {
+		return err
+	}
+	recordHubEvent(jobData.UUID, models.HUB_EVENT_RESULT_URL, resultMcsUrl, "")
+	return nil
 }
 
 func RedeployJob(c *gin.Context) {
@@ -240,14 +260,14 @@ func RedeployJob(c *gin.Context) {
 		return
 	}
 
-	available, gpuProductName, err := checkResourceAvailableForSpace(spaceDetail.Data.Space.ActiveOrder.Config.Description)
+	placement, err := checkResourceAvailableForSpace(spaceDetail.Data.Owner.PublicAddress, spaceDetail.Data.Space.ActiveOrder.Config.Description)
 	if err != nil {
 		logs.GetLogger().Errorf("check job resource failed, error: %+v", err)
 		c.JSON(http.StatusInternalServerError, util.CreateErrorResponse(util.CheckResourcesError))
 		return
 	}
 
-	if !available {
+	if placement == nil {
 		logs.GetLogger().Warnf(" task id: %s, name: %s, not found a resources available", jobData.TaskUUID, jobData.Name)
 		c.JSON(http.StatusInternalServerError, util.CreateErrorResponse(util.NoAvailableResourcesError))
 		return
@@ -307,10 +327,17 @@ func RedeployJob(c *gin.Context) {
 		jobEntity.BuildLog = jobData.BuildLog
 		jobEntity.ContainerLog = jobData.ContainerLog
 		jobEntity.Duration = jobData.Duration
+		jobEntity.JobUuid = jobData.UUID
 		jobEntity.DeployStatus = models.DEPLOY_RECEIVE_JOB
+		metrics.ObserveDeployStage(jobData.UUID, models.DEPLOY_RECEIVE_JOB)
+		jobEntity.DeployState = models.DEPLOY_STATE_DEPLOYING
+		jobEntity.NodeName = placement.NodeName
+		jobEntity.GpuProductName = placement.GpuProductName
 		jobEntity.CreateTime = time.Now().Unix()
 		jobEntity.ExpireTime = time.Now().Unix() + int64(jobData.Duration)
-		NewJobService().SaveJobEntity(jobEntity)
+		if err = NewJobService().SaveJobEntity(jobEntity); err != nil {
+			logs.GetLogger().Errorf("spaceUuid: %s, save job to db failed, error: %+v", spaceUuid, err)
+		}
 
 		go func() {
 			if err = submitJob(&jobData); err != nil {
@@ -321,7 +348,7 @@ func RedeployJob(c *gin.Context) {
 			logs.GetLogger().Infof("jobuuid: %s successfully uploaded to MCS", jobData.UUID)
 		}()
 
-		DeploySpaceTask(jobData.JobSourceURI, hostName, jobData.Duration, jobData.UUID, jobData.TaskUUID, gpuProductName)
+		DeploySpaceTask(jobData.UUID)
 	}()
 
 	c.JSON(http.StatusOK, util.CreateSuccessResponse(jobData))
@@ -508,11 +535,17 @@ func GetJobStatus(c *gin.Context) {
 	var jobResult struct {
 		JobUuid      string `json:"job_uuid"`
 		JobStatus    string `json:"job_status"`
+		JobState     string `json:"job_state,omitempty"`
+		JobHealth    string `json:"job_health,omitempty"`
 		JobResultUrl string `json:"job_result_url"`
+		Error        string `json:"error,omitempty"`
 	}
 	jobResult.JobUuid = jobEntity.JobUuid
 	jobResult.JobStatus = models.GetDeployStatusStr(jobEntity.DeployStatus)
+	jobResult.JobState = jobEntity.DeployState
+	jobResult.JobHealth = jobEntity.Health
 	jobResult.JobResultUrl = jobEntity.ResultUrl
+	jobResult.Error = jobEntity.Error
 
 	c.JSON(http.StatusOK, util.CreateSuccessResponse(jobResult))
 }
@@ -784,86 +817,34 @@ func handleConnection(conn *websocket.Conn, jobDetail models.JobEntity, logType
 	}
 }
 
-func DeploySpaceTask(jobSourceURI, hostName string, duration int, jobUuid string, taskUuid string, gpuProductName string) string {
+// DeploySpaceTask deploys the space of a saved job through the stages of spaceDeployStages
+func DeploySpaceTask(jobUuid string) {
 	updateJobStatus(jobUuid, models.DEPLOY_UPLOAD_RESULT)
 
-	var success bool
-	var spaceUuid string
-	var walletAddress string
-	defer func() {
-		if !success {
-			k8sNameSpace := constants.K8S_NAMESPACE_NAME_PREFIX + strings.ToLower(walletAddress)
-			deleteJob(k8sNameSpace, spaceUuid, "deploy space failed")
-			NewJobService().DeleteJobEntityBySpaceUuId(spaceUuid)
-		}
-
-		if err := recover(); err != nil {
-			logs.GetLogger().Errorf("deploy space task painc, error: %+v", err)
-			return
-		}
-	}()
-
-	spaceDetail, err := getSpaceDetail(jobSourceURI)
-	if err != nil {
-		logs.GetLogger().Errorln(err)
-		return ""
-	}
-
-	walletAddress = spaceDetail.Data.Owner.PublicAddress
-	spaceName := spaceDetail.Data.Space.Name
-	spaceUuid = strings.ToLower(spaceDetail.Data.Space.Uuid)
-	spaceHardware := spaceDetail.Data.Space.ActiveOrder.Config
-
-	logs.GetLogger().Infof("uuid: %s, spaceName: %s, hardwareName: %s", spaceUuid, spaceName, spaceHardware.Description)
-	if len(spaceHardware.Description) == 0 {
-		return ""
-	}
-
-	var job = new(models.JobEntity)
-	job.WalletAddress = walletAddress
-	job.Name = spaceName
-	job.SpaceUuid = spaceDetail.Data.Space.Uuid
-	job.Hardware = spaceHardware.Description
-	job.SpaceType = 0
-	if err = NewJobService().UpdateJobEntityBySpaceUuid(job); err != nil {
-		logs.GetLogger().Errorf("update job info failed, error: %v", err)
-		return ""
+	job, err := NewJobService().GetJobEntityByJobUuid(jobUuid)
+	if err != nil || job.JobUuid == "" {
+		logs.GetLogger().Errorf("jobUuid: %s, get job failed, error: %v", jobUuid, err)
+		return
 	}
+	runSpaceDeployment(&job)
+}
 
-	deploy := NewDeploy(jobUuid, hostName, walletAddress, spaceHardware.Description, int64(duration), taskUuid, constants.SPACE_TYPE_PUBLIC)
-	deploy.WithSpaceInfo(spaceUuid, spaceName)
-	deploy.WithGpuProductName(gpuProductName)
-
-	updateJobStatus(jobUuid, models.DEPLOY_DOWNLOAD_SOURCE)
-	containsYaml, yamlPath, imagePath, modelsSettingFile, _, err := BuildSpaceTaskImage(spaceUuid, spaceDetail.Data.Files)
-	if err != nil {
-		logs.GetLogger().Error(err)
-		return ""
+// deleteJob removes the space of an expired or cancelled job from k8s, with its persistent volumes
+func deleteJob(namespace, spaceUuid string, msg string) error {
+	if err := deleteSpaceWorkload(namespace, spaceUuid, msg); err != nil {
+		return err
 	}
-
-	deploy.WithSpacePath(imagePath)
-	if len(modelsSettingFile) > 0 {
-		err := deploy.WithModelSettingFile(modelsSettingFile).ModelInferenceToK8s()
-		if err != nil {
-			logs.GetLogger().Error(err)
-			return ""
+	if namespace != "" {
+		if err := NewK8sService().DeletePersistentVolumeClaims(context.TODO(), namespace, spaceUuid); err != nil && !errors.IsNotFound(err) {
+			logs.GetLogger().Errorf("Failed delete persistent volume claims, spaceUuid: %s, error: %+v", spaceUuid, err)
+			return err
 		}
-		success = true
-		return hostName
 	}
-
-	if containsYaml {
-		deploy.WithYamlInfo(yamlPath).YamlToK8s()
-	} else {
-		imageName, dockerfilePath := BuildImagesByDockerfile(jobUuid, spaceUuid, spaceName, imagePath)
-		deploy.WithDockerfile(imageName, dockerfilePath).DockerfileToK8s()
-	}
-	success = true
-
-	return hostName
+	return nil
 }
 
-func deleteJob(namespace, spaceUuid string, msg string) error {
+// deleteSpaceWorkload removes the ingress, service and pods of the space, its persistent volumes are kept
+func deleteSpaceWorkload(namespace, spaceUuid string, msg string) error {
 	deployName := constants.K8S_DEPLOY_NAME_PREFIX + spaceUuid
 	serviceName := constants.K8S_SERVICE_NAME_PREFIX + spaceUuid
 	ingressName := constants.K8S_INGRESS_NAME_PREFIX + spaceUuid
@@ -881,6 +862,12 @@ func deleteJob(namespace, spaceUuid string, msg string) error {
 			return err
 		}
 
+		networkPolicyName := constants.K8S_NETWORK_POLICY_NAME_PREFIX + spaceUuid
+		if err := k8sService.DeleteNetworkPolicy(context.TODO(), namespace, networkPolicyName); err != nil && !errors.IsNotFound(err) {
+			logs.GetLogger().Errorf("Failed delete network policy, networkPolicyName: %s, error: %+v", networkPolicyName, err)
+			return err
+		}
+
 		dockerService := NewDockerService()
 		deployImageIds, err := k8sService.GetDeploymentImages(context.TODO(), namespace, deployName)
 		if err != nil && !errors.IsNotFound(err) {
@@ -950,22 +937,15 @@ func downloadModelUrl(namespace, spaceUuid, serviceIp string, podCmd []string) {
 	}
 }
 
-func updateJobStatus(jobUuid string, jobStatus int, url ...string) {
-	go func() {
-		if len(url) > 0 {
-			deployingChan <- models.Job{
-				Uuid:   jobUuid,
-				Status: jobStatus,
-				Url:    url[0],
-			}
-		} else {
-			deployingChan <- models.Job{
-				Uuid:   jobUuid,
-				Status: jobStatus,
-				Url:    "",
-			}
-		}
-	}()
+func updateJobStatus(jobUuid string, jobStatus int) {
+	metrics.ObserveDeployStage(jobUuid, jobStatus)
+	var job = new(models.JobEntity)
+	job.JobUuid = jobUuid
+	job.DeployStatus = jobStatus
+	if err := NewJobService().UpdateJobEntityByJobUuid(job); err != nil {
+		logs.GetLogger().Errorf("update job info by jobUuid failed, error: %v", err)
+	}
+	recordHubEvent(jobUuid, models.HUB_EVENT_JOB_STATUS, models.GetDeployStatusStr(jobStatus), "")
 }
 
 func getSpaceDetail(jobSourceURI string) (models.SpaceJSON, error) {
@@ -985,137 +965,111 @@ func getSpaceDetail(jobSourceURI string) (models.SpaceJSON, error) {
 	return spaceJson, nil
 }
 
-func checkResourceAvailableForSpace(configDescription string) (bool, string, error) {
+// checkResourceAvailableForSpace returns the placement of the space on the node chosen by SCHEDULER.Strategy,
+// or nil when no node has the resources. The space needs the overhead of the RuntimeClass of its wallet besides
+// its hardware, on a node supporting the RuntimeClass.
+func checkResourceAvailableForSpace(walletAddress, configDescription string) (*Placement, error) {
 	taskType, hardwareDetail := getHardwareDetail(configDescription)
-	k8sService := NewK8sService()
-
-	activePods, err := k8sService.GetAllActivePod(context.TODO())
+	nodes, err := getNodeCapacities()
 	if err != nil {
-		return false, "", err
+		return nil, err
 	}
-
-	nodes, err := k8sService.k8sClient.CoreV1().Nodes().List(context.TODO(), metaV1.ListOptions{})
+	runtimeClass, err := spaceRuntimeClass(context.TODO(), walletAddress)
 	if err != nil {
-		return false, "", err
+		return nil, err
 	}
-
-	nodeGpuSummary, err := k8sService.GetNodeGpuSummary(context.TODO())
+	scheduler, err := newConfiguredScheduler()
 	if err != nil {
-		logs.GetLogger().Errorf("Failed collect k8s gpu, error: %+v", err)
-		return false, "", err
-	}
-
-	for _, node := range nodes.Items {
-		nodeGpu, remainderResource, _ := GetNodeResource(activePods, &node)
-		remainderCpu := remainderResource[ResourceCpu]
-		remainderMemory := float64(remainderResource[ResourceMem] / 1024 / 1024 / 1024)
-		remainderStorage := float64(remainderResource[ResourceStorage] / 1024 / 1024 / 1024)
-
-		needCpu := hardwareDetail.Cpu.Quantity
-		needMemory := float64(hardwareDetail.Memory.Quantity)
-		needStorage := float64(hardwareDetail.Storage.Quantity)
-		logs.GetLogger().Infof("checkResourceAvailableForSpace: needCpu: %d, needMemory: %.2f, needStorage: %.2f", needCpu, needMemory, needStorage)
-		logs.GetLogger().Infof("checkResourceAvailableForSpace: remainingCpu: %d, remainingMemory: %.2f, remainingStorage: %.2f", remainderCpu, remainderMemory, remainderStorage)
-		if needCpu <= remainderCpu && needMemory <= remainderMemory && needStorage <= remainderStorage {
-			if taskType == "CPU" {
-				return true, "", nil
-			} else if taskType == "GPU" {
-				var usedCount int64 = 0
-				gpuName := strings.ToUpper(strings.ReplaceAll(hardwareDetail.Gpu.Unit, " ", "-"))
-				logs.GetLogger().Infof("gpuName: %s, nodeGpu: %+v, nodeGpuSummary: %+v", gpuName, nodeGpu, nodeGpuSummary)
-				var gpuProductName = ""
-				for name, count := range nodeGpu {
-					if strings.Contains(strings.ToUpper(name), gpuName) {
-						usedCount = count
-						gpuProductName = strings.ReplaceAll(strings.ToUpper(name), " ", "-")
-						break
-					}
-				}
-
-				for gName, gCount := range nodeGpuSummary[node.Name] {
-					if strings.Contains(strings.ToUpper(gName), gpuName) {
-						gpuProductName = strings.ReplaceAll(strings.ToUpper(gName), " ", "-")
-						if usedCount+hardwareDetail.Gpu.Quantity <= gCount {
-							return true, gpuProductName, nil
-						}
-					}
-				}
-				continue
-			}
-		}
+		return nil, err
 	}
-	return false, "", nil
+
+	req := PlacementRequest{
+		Cpu:     hardwareDetail.Cpu.Quantity,
+		Memory:  hardwareDetail.Memory.Quantity * 1024 * 1024 * 1024,
+		Storage: hardwareDetail.Storage.Quantity * 1024 * 1024 * 1024,
+	}
+	if taskType == "GPU" {
+		req.Gpu = hardwareDetail.Gpu.Quantity
+		req.GpuName = strings.ReplaceAll(hardwareDetail.Gpu.Unit, " ", "-")
+		req.GpuResource = hardwareDetail.GpuResource
+	}
+	return scheduler.Place(withRuntimeOverhead(req, runtimeOverhead(runtimeClass)), runtimeClassNodes(nodes, runtimeClass)), nil
 }
 
+// checkResourceAvailableForUbi returns the node chosen by SCHEDULER.Strategy for the ubi task and its cpu
+// architecture, the node is "" when no node has the resources
 func checkResourceAvailableForUbi(taskType int, gpuName string, resource *models.TaskResource) (string, string, int64, int64, int64, error) {
-	k8sService := NewK8sService()
-	activePods, err := k8sService.GetAllActivePod(context.TODO())
-	if err != nil {
-		return "", "", 0, 0, 0, err
+	needCpu, _ := strconv.ParseInt(resource.CPU, 10, 64)
+	var needMemory, needStorage float64
+	if len(strings.Split(strings.TrimSpace(resource.Memory), " ")) > 0 {
+		needMemory, _ = strconv.ParseFloat(strings.Split(strings.TrimSpace(resource.Memory), " ")[0], 64)
+	}
+	if len(strings.Split(strings.TrimSpace(resource.Storage), " ")) > 0 {
+		needStorage, _ = strconv.ParseFloat(strings.Split(strings.TrimSpace(resource.Storage), " ")[0], 64)
+	}
+	if taskType == 1 && gpuName == "" {
+		return "", "", needCpu, int64(needMemory), int64(needStorage), nil
 	}
 
-	nodes, err := k8sService.k8sClient.CoreV1().Nodes().List(context.TODO(), metaV1.ListOptions{})
+	nodes, err := getNodeCapacities()
 	if err != nil {
 		return "", "", 0, 0, 0, err
 	}
-
-	nodeGpuSummary, err := k8sService.GetNodeGpuSummary(context.TODO())
+	scheduler, err := newConfiguredScheduler()
 	if err != nil {
-		logs.GetLogger().Errorf("Failed collect k8s gpu, error: %+v", err)
 		return "", "", 0, 0, 0, err
 	}
 
-	needCpu, _ := strconv.ParseInt(resource.CPU, 10, 64)
-	var needMemory, needStorage float64
-	if len(strings.Split(strings.TrimSpace(resource.Memory), " ")) > 0 {
-		needMemory, err = strconv.ParseFloat(strings.Split(strings.TrimSpace(resource.Memory), " ")[0], 64)
-
+	req := PlacementRequest{
+		Cpu:     needCpu,
+		Memory:  int64(needMemory) * 1024 * 1024 * 1024,
+		Storage: int64(needStorage) * 1024 * 1024 * 1024,
 	}
-	if len(strings.Split(strings.TrimSpace(resource.Storage), " ")) > 0 {
-		needStorage, err = strconv.ParseFloat(strings.Split(strings.TrimSpace(resource.Storage), " ")[0], 64)
+	if taskType == 1 {
+		req.Gpu = 1
+		req.GpuName = strings.ReplaceAll(gpuName, " ", "-")
+		req.GpuExact = true
+		_, req.GpuResource = parseGpuResource(resource.GPU)
+	}
+	placement := scheduler.Place(req, nodes)
+	if placement == nil {
+		return "", "", needCpu, int64(needMemory), int64(needStorage), nil
 	}
 
-	var nodeName, architecture string
-	for _, node := range nodes.Items {
+	var architecture string
+	for _, node := range nodes {
+		if node.Name != placement.NodeName {
+			continue
+		}
 		if _, ok := node.Labels[constants.CPU_INTEL]; ok {
 			architecture = constants.CPU_INTEL
 		}
 		if _, ok := node.Labels[constants.CPU_AMD]; ok {
 			architecture = constants.CPU_AMD
 		}
+	}
+	return placement.NodeName, architecture, needCpu, int64(needMemory), int64(needStorage), nil
+}
 
-		nodeGpu, remainderResource, _ := GetNodeResource(activePods, &node)
-		remainderCpu := remainderResource[ResourceCpu]
-		remainderMemory := float64(remainderResource[ResourceMem] / 1024 / 1024 / 1024)
-		remainderStorage := float64(remainderResource[ResourceStorage] / 1024 / 1024 / 1024)
-
-		logs.GetLogger().Infof("checkResourceAvailableForUbi: needCpu: %d, needMemory: %.2f, needStorage: %.2f", needCpu, needMemory, needStorage)
-		logs.GetLogger().Infof("checkResourceAvailableForUbi: remainingCpu: %d, remainingMemory: %.2f, remainingStorage: %.2f", remainderCpu, remainderMemory, remainderStorage)
-		if needCpu <= remainderCpu && needMemory <= remainderMemory && needStorage <= remainderStorage {
-			nodeName = node.Name
-			if taskType == 0 {
-				return nodeName, architecture, needCpu, int64(needMemory), int64(needStorage), nil
-			} else if taskType == 1 {
-				if gpuName == "" {
-					nodeName = ""
-					continue
-				}
-				gpuName = strings.ReplaceAll(gpuName, " ", "-")
-				logs.GetLogger().Infof("needGpuName: %s, nodeGpu: %+v, nodeGpuSummary: %+v", gpuName, nodeGpu, nodeGpuSummary)
-				usedCount, ok := nodeGpu[gpuName]
-				if !ok {
-					usedCount = 0
-				}
-
-				if usedCount+1 <= nodeGpuSummary[node.Name][gpuName] {
-					return nodeName, architecture, needCpu, int64(needMemory), int64(needStorage), nil
-				}
-				nodeName = ""
-				continue
-			}
-		}
+// getNodeCapacities returns the capacity of all the nodes of the cluster
+func getNodeCapacities() ([]NodeCapacity, error) {
+	k8sService := NewK8sService()
+	activePods, err := k8sService.GetAllActivePod(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+
+	nodes, err := k8sService.k8sClient.CoreV1().Nodes().List(context.TODO(), metaV1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	nodeGpuSummary, err := k8sService.GetNodeGpuSummary(context.TODO())
+	if err != nil {
+		logs.GetLogger().Errorf("Failed collect k8s gpu, error: %+v", err)
+		return nil, err
 	}
-	return nodeName, architecture, needCpu, int64(needMemory), int64(needStorage), nil
+	return nodeCapacities(nodes.Items, activePods, nodeGpuSummary), nil
 }
 
 func generateString(length int) string {
@@ -1265,6 +1219,8 @@ func verifySignatureForHub(pubKStr string, message string, signedMessage string)
 	return pubKStr == crypto.PubkeyToAddress(*sigPublicKeyECDSA).String(), nil
 }
 
+// convertGpuName turns the gpu of RUST_GPU_TOOLS_CUSTOM_GPU into the product name of the resource-exporter: the
+// NVIDIA names drop the Tesla, GeForce and RTX words, the names of the other vendors are used in upper case
 func convertGpuName(name string) string {
 	if strings.TrimSpace(name) == "" {
 		return ""
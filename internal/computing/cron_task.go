@@ -4,12 +4,13 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
 	"github.com/robfig/cron/v3"
 	"github.com/swanchain/go-computing-provider/conf"
 	"github.com/swanchain/go-computing-provider/constants"
+	"github.com/swanchain/go-computing-provider/internal/contract"
 	"github.com/swanchain/go-computing-provider/internal/contract/fcp"
+	"github.com/swanchain/go-computing-provider/internal/metrics"
 	"github.com/swanchain/go-computing-provider/internal/models"
 	"io"
 	"k8s.io/apimachinery/pkg/api/errors"
@@ -17,13 +18,9 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 )
 
-var deployingChan = make(chan models.Job)
-var TaskMap sync.Map
-
 type CronTask struct {
 	nodeId       string
 	ownerAddress string
@@ -47,7 +44,7 @@ func NewCronTask(nodeId string) *CronTask {
 
 func (task *CronTask) RunTask() {
 	addNodeLabel()
-	checkJobStatus()
+	ResumeSpaceDeployments()
 	task.checkCollateralBalance()
 	task.cleanAbnormalDeployment()
 	task.setFailedUbiTaskStatus()
@@ -55,26 +52,10 @@ func (task *CronTask) RunTask() {
 	task.updateUbiTaskReward()
 	task.reportClusterResourceToHub()
 	task.watchExpiredTask()
-}
-
-func checkJobStatus() {
-	go func() {
-		for {
-			select {
-			case job := <-deployingChan:
-				TaskMap.Store(job.Uuid, &job)
-			case <-time.After(3 * time.Second):
-				TaskMap.Range(func(key, value any) bool {
-					jobUuid := key.(string)
-					job := value.(*models.Job)
-					if reportJobStatus(jobUuid, job.Status) && job.Status == models.DEPLOY_TO_K8S {
-						TaskMap.Delete(jobUuid)
-					}
-					return true
-				})
-			}
-		}
-	}()
+	task.syncNamespaceQuota()
+	task.syncNamespaceEgress()
+	task.deliverHubEvents()
+	task.watchSpaceHealth()
 }
 
 func (task *CronTask) reportClusterResourceToHub() {
@@ -93,6 +74,7 @@ func (task *CronTask) reportClusterResourceToHub() {
 			return
 		}
 		checkClusterProviderStatus(statisticalSources)
+		recordNodeResourceMetrics(statisticalSources)
 	})
 	c.Start()
 }
@@ -129,6 +111,32 @@ func (task *CronTask) watchNameSpaceForDeleted() {
 	c.Start()
 }
 
+func (task *CronTask) syncNamespaceQuota() {
+	c := cron.New(cron.WithSeconds())
+	c.AddFunc("0 0/10 * * * ?", func() {
+		defer func() {
+			if err := recover(); err != nil {
+				logs.GetLogger().Errorf("syncNamespaceQuota catch panic error: %+v", err)
+			}
+		}()
+		syncNamespaceQuota()
+	})
+	c.Start()
+}
+
+func (task *CronTask) syncNamespaceEgress() {
+	c := cron.New(cron.WithSeconds())
+	c.AddFunc("0 5/10 * * * ?", func() {
+		defer func() {
+			if err := recover(); err != nil {
+				logs.GetLogger().Errorf("syncNamespaceEgress catch panic error: %+v", err)
+			}
+		}()
+		syncNamespaceEgress()
+	})
+	c.Start()
+}
+
 func (task *CronTask) watchExpiredTask() {
 	c := cron.New(cron.WithSeconds())
 	c.AddFunc("0 0/5 * * * ?", func() {
@@ -180,7 +188,16 @@ func (task *CronTask) watchExpiredTask() {
 			}
 
 			if _, err = NewK8sService().k8sClient.AppsV1().Deployments(job.NameSpace).Get(context.TODO(), job.K8sDeployName, metav1.GetOptions{}); err != nil && errors.IsNotFound(err) {
-				if time.Now().Sub(time.Unix(job.CreateTime, 0)).Hours() > 2 {
+				// a long build may still be deploying, it fails by the timeouts of its stages
+				if job.DeployState != models.DEPLOY_STATE_DEPLOYING && time.Now().Sub(time.Unix(job.CreateTime, 0)).Hours() > 2 {
+					// a failed space keeps its volumes until then
+					if job.WalletAddress != "" {
+						namespace := constants.K8S_NAMESPACE_NAME_PREFIX + strings.ToLower(job.WalletAddress)
+						if err = NewK8sService().DeletePersistentVolumeClaims(context.TODO(), namespace, strings.ToLower(job.SpaceUuid)); err != nil && !errors.IsNotFound(err) {
+							logs.GetLogger().Errorf("Failed delete persistent volume claims, spaceUuid: %s, error: %+v", job.SpaceUuid, err)
+							continue
+						}
+					}
 					deleteSpaceIds = append(deleteSpaceIds, job.SpaceUuid)
 					continue
 				}
@@ -251,6 +268,7 @@ func (task *CronTask) checkCollateralBalance() {
 			logs.GetLogger().Errorf("parse collateral balance failed, error: %+v", err)
 			return
 		}
+		metrics.CollateralBalance.WithLabelValues("fcp").Set(floatResult)
 
 		if floatResult <= conf.GetConfig().HUB.BalanceThreshold {
 			logs.GetLogger().Warnf("No sufficient collateral Balance, the current collateral balance is: %0.3f. Please run: computing-provider collateral [fromWalletAddress] [amount]", floatResult)
@@ -329,6 +347,11 @@ func (task *CronTask) setFailedUbiTaskStatus() {
 		for _, entity := range taskList {
 			ubiTask := entity
 
+			// the task queue still works on the recent tasks
+			if (ubiTask.Status == models.TASK_RECEIVED_STATUS || ubiTask.Status == models.TASK_RUNNING_STATUS) && ubiTask.CreateTime >= oneHourAgo {
+				continue
+			}
+
 			if ubiTask.CreateTime < oneHourAgo {
 				JobName := strings.ToLower(models.UbiTaskTypeStr(ubiTask.Type)) + "-" + strconv.Itoa(int(ubiTask.Id))
 				k8sNameSpace := "ubi-task-" + strconv.Itoa(int(ubiTask.Id))
@@ -339,6 +362,9 @@ func (task *CronTask) setFailedUbiTaskStatus() {
 			if ubiTask.TxHash != "" {
 				ubiTask.Status = models.TASK_SUCCESS_STATUS
 			} else {
+				if entity.Status != models.TASK_FAILED_STATUS {
+					countUbiTaskFailed(&ubiTask, metrics.ReasonTimeout)
+				}
 				ubiTask.Status = models.TASK_FAILED_STATUS
 			}
 
@@ -449,27 +475,12 @@ func checkTaskStatusByHub(taskUuid, nodeId string) (string, error) {
 	return taskStatus.Status, nil
 }
 
-func reportJobStatus(jobUuid string, deployStatus int) bool {
-	var job = new(models.JobEntity)
-	job.JobUuid = jobUuid
-	job.DeployStatus = deployStatus
-	if err := NewJobService().UpdateJobEntityByJobUuid(job); err != nil {
-		logs.GetLogger().Errorf("update job info by jobUuid failed, error: %v", err)
-	}
-	return true
-}
-
 func checkFcpCollateralBalance() (string, error) {
 
-	chainRpc, err := conf.GetRpcByNetWorkName()
-	if err != nil {
-		return "", err
-	}
-	client, err := ethclient.Dial(chainRpc)
+	client, err := contract.GetEthClient()
 	if err != nil {
 		return "", err
 	}
-	defer client.Close()
 
 	fcpCollateralStub, err := fcp.NewCollateralStub(client)
 	if err != nil {
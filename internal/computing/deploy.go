@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
+	"github.com/swanchain/go-computing-provider/conf"
 	"github.com/swanchain/go-computing-provider/constants"
 	"github.com/swanchain/go-computing-provider/internal/models"
 	"github.com/swanchain/go-computing-provider/internal/yaml"
@@ -16,6 +17,7 @@ import (
 	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -41,6 +43,9 @@ type Deploy struct {
 	hardwareDesc      string
 	taskUuid          string
 	gpuProductName    string
+	nodeName          string
+	imageDigests      map[string]string
+	ctx               context.Context
 
 	spaceType string
 }
@@ -89,6 +94,25 @@ func (d *Deploy) WithGpuProductName(gpuProductName string) *Deploy {
 	return d
 }
 
+// WithNodeName pins the space to the node chosen by the scheduler
+func (d *Deploy) WithNodeName(nodeName string) *Deploy {
+	d.nodeName = nodeName
+	return d
+}
+
+// WithContext bounds the k8s calls of the deployment, e.g. by the timeout of the deploy stage
+func (d *Deploy) WithContext(ctx context.Context) *Deploy {
+	d.ctx = ctx
+	return d
+}
+
+func (d *Deploy) context() context.Context {
+	if d.ctx == nil {
+		return context.TODO()
+	}
+	return d.ctx
+}
+
 func (d *Deploy) WithYamlInfo(yamlPath string) *Deploy {
 	d.yamlPath = yamlPath
 	return d
@@ -110,26 +134,75 @@ func (d *Deploy) WithModelSettingFile(modelsSettingFile string) *Deploy {
 	return d
 }
 
-func (d *Deploy) DockerfileToK8s() {
+// WithImageDigests deploys the images checked by the image policy by the digests they were checked with
+func (d *Deploy) WithImageDigests(imageDigests map[string]string) *Deploy {
+	d.imageDigests = imageDigests
+	return d
+}
+
+// pinnedImage is the image pinned to the digest it was checked with, or the image itself when it was not checked
+// or has no digest in a registry
+func (d *Deploy) pinnedImage(imageName string) string {
+	if pinned, ok := d.imageDigests[imageName]; ok {
+		return pinned
+	}
+	return imageName
+}
+
+// modelInference is the model of a model space, with the task and the framework its image is built for
+type modelInference struct {
+	ModelId   string `json:"model_id"`
+	Task      string `json:"task"`
+	Framework string `json:"framework"`
+}
+
+// inferenceModelPath is where the scripts building the images of the model spaces are installed
+func inferenceModelPath() string {
+	cpPath, _ := os.LookupEnv("CP_PATH")
+	return filepath.Join(cpPath, "inference-model")
+}
+
+// readModelInference reads the model of the setting file of a model space, and its task and framework from the hub
+func readModelInference(modelsSettingFile string) (modelInference, error) {
+	var modelSetting struct {
+		ModelId string `json:"model_id"`
+	}
+	modelData, _ := os.ReadFile(modelsSettingFile)
+	err := json.Unmarshal(modelData, &modelSetting)
+	if err != nil {
+		logs.GetLogger().Errorf("convert model_id out to json failed, error: %+v", err)
+		return modelInference{}, err
+	}
+
+	modelInfoOut, err := util.RunPythonScript(filepath.Join(inferenceModelPath(), "/scripts/hf_client.py"), "model_info", modelSetting.ModelId)
+	if err != nil {
+		logs.GetLogger().Errorf("exec model_info cmd failed, error: %+v", err)
+		return modelInference{}, err
+	}
+
+	var modelInfo modelInference
+	err = json.Unmarshal([]byte(modelInfoOut), &modelInfo)
+	if err != nil {
+		logs.GetLogger().Errorf("convert model_info out to json failed, error: %+v", err)
+		return modelInference{}, err
+	}
+	return modelInfo, nil
+}
+
+func (d *Deploy) DockerfileToK8s() error {
 	exposedPort, err := ExtractExposedPort(d.dockerfilePath)
 	if err != nil {
-		logs.GetLogger().Infof("Failed to extract exposed port: %v", err)
-		return
+		return fmt.Errorf("failed to extract exposed port, error: %v", err)
 	}
 	containerPort, err := strconv.ParseInt(exposedPort, 10, 64)
 	if err != nil {
-		logs.GetLogger().Errorf("Failed to convert exposed port: %v", err)
-		return
+		return fmt.Errorf("failed to convert exposed port, error: %v", err)
 	}
 
-	deleteJob(d.k8sNameSpace, d.spaceUuid, "start deploying new space service and delete previous service")
-
 	if err := d.deployNamespace(); err != nil {
-		logs.GetLogger().Error(err)
-		return
+		return err
 	}
 
-	k8sService := NewK8sService()
 	deployment := &appV1.Deployment{
 		TypeMeta: metaV1.TypeMeta{
 			Kind:       "Deployment",
@@ -152,54 +225,71 @@ func (d *Deploy) DockerfileToK8s() {
 
 				Spec: coreV1.PodSpec{
 					NodeSelector: generateLabel(d.gpuProductName),
+					Affinity:     d.createAffinity(),
 					Containers: []coreV1.Container{{
 						Name:            constants.K8S_CONTAINER_NAME_PREFIX + d.spaceUuid,
-						Image:           d.image,
+						Image:           d.pinnedImage(d.image),
 						ImagePullPolicy: coreV1.PullIfNotPresent,
 						Ports: []coreV1.ContainerPort{{
 							ContainerPort: int32(containerPort),
 						}},
-						Env:       d.createEnv(),
-						Resources: d.createResources(),
+						Env:            d.createEnv(),
+						Resources:      d.createResources(),
+						ReadinessProbe: spaceReadinessProbe(int32(containerPort)),
+						StartupProbe:   spaceStartupProbe(int32(containerPort)),
+						LivenessProbe:  spaceLivenessProbe(int32(containerPort)),
 					}},
 				},
 			},
 		}}
-	createDeployment, err := k8sService.CreateDeployment(context.TODO(), d.k8sNameSpace, deployment)
+	profile := securityProfile()
+	hardenPodSpec(&deployment.Spec.Template.Spec, profile, profileExceptions(profile, constants.K8S_CONTAINER_NAME_PREFIX+d.spaceUuid), nil)
+	if err = d.applyRuntimeClass(&deployment.Spec.Template.Spec); err != nil {
+		return err
+	}
+	withEgressBandwidth(&deployment.Spec.Template)
+	createDeployment, _, err := d.rolloutDeployment(deployment, int32(containerPort))
 	if err != nil {
-		logs.GetLogger().Error(err)
-		return
+		return err
 	}
 	d.DeployName = createDeployment.GetName()
 	updateJobStatus(d.jobUuid, models.DEPLOY_PULL_IMAGE)
 	logs.GetLogger().Infof("Created deployment: %s", createDeployment.GetName())
-
-	if _, err := d.deployK8sResource(int32(containerPort)); err != nil {
-		logs.GetLogger().Error(err)
-		return
-	}
-	updateJobStatus(d.jobUuid, models.DEPLOY_TO_K8S, "https://"+d.hostName)
+	updateJobStatus(d.jobUuid, models.DEPLOY_TO_K8S)
 
 	d.watchContainerRunningTime()
-	return
+	return nil
 }
 
-func (d *Deploy) YamlToK8s() {
+func (d *Deploy) YamlToK8s() error {
 	containerResources, err := yaml.HandlerYaml(d.yamlPath)
 	if err != nil {
-		logs.GetLogger().Error(err)
-		return
+		return err
 	}
 
-	deleteJob(d.k8sNameSpace, d.spaceUuid, "start deploying new space service and delete previous service")
-
 	if err := d.deployNamespace(); err != nil {
-		logs.GetLogger().Error(err)
-		return
+		return err
 	}
 
+	profile := securityProfile()
 	k8sService := NewK8sService()
 	for _, cr := range containerResources {
+		exceptions := make(map[string][]string)
+		var egress []yaml.EgressResource
+		for _, service := range append(slices.Clone(cr.Depends), cr) {
+			if err = checkExceptions(profile, service.Name, service.Exceptions); err != nil {
+				return err
+			}
+			exceptions[d.spaceUuid+"-"+service.Name] = service.Exceptions
+
+			if conf.GetConfig().EGRESS.Enable {
+				if err = checkEgress(conf.GetConfig().EGRESS, service.Name, service.Egress); err != nil {
+					return err
+				}
+			}
+			egress = append(egress, service.Egress...)
+		}
+
 		for i, envVar := range cr.Env {
 			if strings.Contains(envVar.Name, "NEXTAUTH_URL") {
 				cr.Env[i].Value = "https://" + d.hostName
@@ -211,10 +301,9 @@ func (d *Deploy) YamlToK8s() {
 		var volumes []coreV1.Volume
 		if cr.VolumeMounts.Path != "" {
 			fileNameWithoutExt := filepath.Base(cr.VolumeMounts.Name[:len(cr.VolumeMounts.Name)-len(filepath.Ext(cr.VolumeMounts.Name))])
-			configMap, err := k8sService.CreateConfigMap(context.TODO(), d.k8sNameSpace, d.spaceUuid, filepath.Dir(d.yamlPath), cr.VolumeMounts.Name)
+			configMap, err := k8sService.CreateConfigMap(d.context(), d.k8sNameSpace, d.spaceUuid, filepath.Dir(d.yamlPath), cr.VolumeMounts.Name)
 			if err != nil {
-				logs.GetLogger().Error(err)
-				return
+				return err
 			}
 			configName := configMap.GetName()
 			volumes = []coreV1.Volume{
@@ -239,27 +328,50 @@ func (d *Deploy) YamlToK8s() {
 
 		var containers []coreV1.Container
 		for _, depend := range cr.Depends {
-			var handler = new(coreV1.ExecAction)
-			handler.Command = depend.ReadyCmd
 			containers = append(containers, coreV1.Container{
 				Name:            d.spaceUuid + "-" + depend.Name,
-				Image:           depend.ImageName,
+				Image:           d.pinnedImage(depend.ImageName),
 				Command:         depend.Command,
 				Args:            depend.Args,
 				Env:             depend.Env,
 				Ports:           depend.Ports,
 				ImagePullPolicy: coreV1.PullIfNotPresent,
 				Resources:       coreV1.ResourceRequirements{},
-				ReadinessProbe: &coreV1.Probe{
-					ProbeHandler: coreV1.ProbeHandler{
-						Exec: handler,
-					},
-					InitialDelaySeconds: 5,
-					PeriodSeconds:       5,
-				},
+				ReadinessProbe:  depend.ReadinessProbe,
+				LivenessProbe:   depend.LivenessProbe,
+				VolumeMounts:    volumeMounts(depend.Volumes),
 			})
 		}
 
+		spaceVolumes := spaceVolumes(append(cr.Depends, cr)...)
+		if err = checkVolumeStorage(spaceVolumes, d.hardwareResource); err != nil {
+			return err
+		}
+		// the volumes are only known once the space is downloaded, the quota is checked again with them
+		if err = checkSpaceQuota(d.walletAddress, d.spaceUuid, d.hardwareDesc, spaceVolumes); err != nil {
+			return err
+		}
+		claimVolumes, err := d.applyVolumes(spaceVolumes)
+		if err != nil {
+			return err
+		}
+		volumes = append(volumes, claimVolumes...)
+
+		// the cached models are mounted, the others are downloaded into the space once it runs
+		var cached cachedModels
+		var downloads []yaml.ModelResource
+		var downloadDirs []string
+		for _, res := range cr.Models {
+			entry := d.cacheModel(res.Url, res.Sha256)
+			if entry == nil {
+				downloads = append(downloads, res)
+				downloadDirs = append(downloadDirs, res.Dir)
+				continue
+			}
+			cached.add(entry, coreV1.VolumeMount{MountPath: filepath.Join(res.Dir, res.Name), SubPath: modelCacheFile})
+		}
+		volumes = append(volumes, cached.volumes...)
+
 		cr.Env = append(cr.Env, []coreV1.EnvVar{
 			{
 				Name:  "wallet_address",
@@ -281,14 +393,16 @@ func (d *Deploy) YamlToK8s() {
 
 		containers = append(containers, coreV1.Container{
 			Name:            d.spaceUuid + "-" + cr.Name,
-			Image:           cr.ImageName,
+			Image:           d.pinnedImage(cr.ImageName),
 			Command:         cr.Command,
 			Args:            cr.Args,
 			Env:             cr.Env,
 			Ports:           cr.Ports,
 			ImagePullPolicy: coreV1.PullIfNotPresent,
 			Resources:       d.createResources(),
-			VolumeMounts:    volumeMount,
+			VolumeMounts:    append(append(volumeMount, volumeMounts(cr.Volumes)...), cached.mounts...),
+			ReadinessProbe:  cr.ReadinessProbe,
+			LivenessProbe:   cr.LivenessProbe,
 		})
 
 		deployment := &appV1.Deployment{
@@ -307,76 +421,54 @@ func (d *Deploy) YamlToK8s() {
 				},
 				Template: coreV1.PodTemplateSpec{
 					ObjectMeta: metaV1.ObjectMeta{
-						Labels:    map[string]string{"lad_app": d.spaceUuid},
-						Namespace: d.k8sNameSpace,
+						Labels:      map[string]string{"lad_app": d.spaceUuid},
+						Namespace:   d.k8sNameSpace,
+						Annotations: volumeAnnotations(spaceVolumes),
 					},
 					Spec: coreV1.PodSpec{
-						NodeSelector: generateLabel(d.gpuProductName),
+						NodeSelector: cached.withNodeSelector(generateLabel(d.gpuProductName)),
+						Affinity:     d.createAffinity(),
 						Containers:   containers,
 						Volumes:      volumes,
 					},
 				},
 			}}
+		hardenPodSpec(&deployment.Spec.Template.Spec, profile, exceptions, map[string][]string{d.spaceUuid + "-" + cr.Name: downloadDirs})
+		if err = d.applyRuntimeClass(&deployment.Spec.Template.Spec); err != nil {
+			return err
+		}
+		withEgressBandwidth(&deployment.Spec.Template)
+		if err = d.applySpaceEgress(egress); err != nil {
+			return err
+		}
 
-		createDeployment, err := k8sService.CreateDeployment(context.TODO(), d.k8sNameSpace, deployment)
+		createDeployment, serviceHost, err := d.rolloutDeployment(deployment, cr.Ports[0].ContainerPort)
 		if err != nil {
-			logs.GetLogger().Error(err)
-			return
+			return err
 		}
 		d.DeployName = createDeployment.GetName()
 		updateJobStatus(d.jobUuid, models.DEPLOY_PULL_IMAGE)
+		updateJobStatus(d.jobUuid, models.DEPLOY_TO_K8S)
 
-		serviceHost, err := d.deployK8sResource(cr.Ports[0].ContainerPort)
-		if err != nil {
-			logs.GetLogger().Error(err)
-			return
-		}
-
-		updateJobStatus(d.jobUuid, models.DEPLOY_TO_K8S, "https://"+d.hostName)
-
-		if len(cr.Models) > 0 {
-			for _, res := range cr.Models {
+		if len(downloads) > 0 {
+			for _, res := range downloads {
 				go func(res yaml.ModelResource) {
-					downloadModelUrl(d.k8sNameSpace, d.spaceUuid, serviceHost, []string{"wget", res.Url, "-O", filepath.Join(res.Dir, res.Name)})
+					downloadModelUrl(d.k8sNameSpace, d.spaceUuid, serviceHost, modelDownloadCmd(res))
 				}(res)
 			}
 		}
 		d.watchContainerRunningTime()
 	}
+	return nil
 }
 
 func (d *Deploy) ModelInferenceToK8s() error {
-	var modelSetting struct {
-		ModelId string `json:"model_id"`
-	}
-	modelData, _ := os.ReadFile(d.modelsSettingFile)
-	err := json.Unmarshal(modelData, &modelSetting)
+	modelInfo, err := readModelInference(d.modelsSettingFile)
 	if err != nil {
-		logs.GetLogger().Errorf("convert model_id out to json failed, error: %+v", err)
-		return err
-	}
-
-	cpPath, _ := os.LookupEnv("CP_PATH")
-	basePath := filepath.Join(cpPath, "inference-model")
-
-	modelInfoOut, err := util.RunPythonScript(filepath.Join(basePath, "/scripts/hf_client.py"), "model_info", modelSetting.ModelId)
-	if err != nil {
-		logs.GetLogger().Errorf("exec model_info cmd failed, error: %+v", err)
 		return err
 	}
+	basePath := inferenceModelPath()
 
-	var modelInfo struct {
-		ModelId   string `json:"model_id"`
-		Task      string `json:"task"`
-		Framework string `json:"framework"`
-	}
-	err = json.Unmarshal([]byte(modelInfoOut), &modelInfo)
-	if err != nil {
-		logs.GetLogger().Errorf("convert model_info out to json failed, error: %+v", err)
-		return err
-	}
-
-	deleteJob(d.k8sNameSpace, d.spaceUuid, "start deploying new space service and delete previous service")
 	imageName := "lagrange/" + modelInfo.Framework + ":v1.0"
 
 	logFile := filepath.Join(d.SpacePath, BuildFileName)
@@ -402,12 +494,22 @@ func (d *Deploy) ModelInferenceToK8s() error {
 
 	d.image = imageName
 
+	// a cached model is read from the hub cache mounted in the space, without a download
+	var cached cachedModels
+	if entry := d.cacheModel("hf:"+modelInfo.ModelId, ""); entry != nil {
+		cached.add(entry, coreV1.VolumeMount{MountPath: modelCacheMountPath})
+		modelEnvs = append(modelEnvs, []coreV1.EnvVar{
+			{Name: "HF_HUB_CACHE", Value: modelCacheMountPath},
+			{Name: "TRANSFORMERS_CACHE", Value: modelCacheMountPath},
+			{Name: "HF_HUB_OFFLINE", Value: "1"},
+		}...)
+	}
+
 	if err := d.deployNamespace(); err != nil {
 		logs.GetLogger().Error(err)
 		return err
 	}
 
-	k8sService := NewK8sService()
 	deployment := &appV1.Deployment{
 		TypeMeta: metaV1.TypeMeta{
 			Kind:       "Deployment",
@@ -429,21 +531,31 @@ func (d *Deploy) ModelInferenceToK8s() error {
 				},
 
 				Spec: coreV1.PodSpec{
-					NodeSelector: generateLabel(d.gpuProductName),
+					NodeSelector: cached.withNodeSelector(generateLabel(d.gpuProductName)),
+					Affinity:     d.createAffinity(),
 					Containers: []coreV1.Container{{
 						Name:            constants.K8S_CONTAINER_NAME_PREFIX + d.spaceUuid,
-						Image:           d.image,
+						Image:           d.pinnedImage(d.image),
 						ImagePullPolicy: coreV1.PullIfNotPresent,
 						Ports: []coreV1.ContainerPort{{
 							ContainerPort: int32(80),
 						}},
-						Env: d.createEnv(modelEnvs...),
+						Env:          d.createEnv(modelEnvs...),
+						VolumeMounts: cached.mounts,
 						//Resources: d.createResources(),
 					}},
+					Volumes: cached.volumes,
 				},
 			},
 		}}
-	createDeployment, err := k8sService.CreateDeployment(context.TODO(), d.k8sNameSpace, deployment)
+	profile := securityProfile()
+	hardenPodSpec(&deployment.Spec.Template.Spec, profile, profileExceptions(profile, constants.K8S_CONTAINER_NAME_PREFIX+d.spaceUuid), nil)
+	if err := d.applyRuntimeClass(&deployment.Spec.Template.Spec); err != nil {
+		logs.GetLogger().Error(err)
+		return err
+	}
+	withEgressBandwidth(&deployment.Spec.Template)
+	createDeployment, _, err := d.rolloutDeployment(deployment, int32(80))
 	if err != nil {
 		logs.GetLogger().Error(err)
 		return err
@@ -451,11 +563,6 @@ func (d *Deploy) ModelInferenceToK8s() error {
 	d.DeployName = createDeployment.GetName()
 	updateJobStatus(d.jobUuid, models.DEPLOY_PULL_IMAGE)
 	logs.GetLogger().Infof("Created deployment: %s", createDeployment.GetObjectMeta().GetName())
-
-	if _, err := d.deployK8sResource(int32(80)); err != nil {
-		logs.GetLogger().Error(err)
-		return err
-	}
 	updateJobStatus(d.jobUuid, models.DEPLOY_TO_K8S)
 	d.watchContainerRunningTime()
 	return nil
@@ -463,29 +570,40 @@ func (d *Deploy) ModelInferenceToK8s() error {
 
 func (d *Deploy) deployNamespace() error {
 	k8sService := NewK8sService()
-	if _, err := k8sService.GetNameSpace(context.TODO(), d.k8sNameSpace, metaV1.GetOptions{}); err != nil {
+	if _, err := k8sService.GetNameSpace(d.context(), d.k8sNameSpace, metaV1.GetOptions{}); err != nil {
 		if errors.IsNotFound(err) {
+			labels := spaceNamespaceLabels()
+			labels["lab-ns"] = strings.ToLower(d.walletAddress)
 			namespace := &coreV1.Namespace{
 				ObjectMeta: metaV1.ObjectMeta{
-					Name: d.k8sNameSpace,
-					Labels: map[string]string{
-						"lab-ns": strings.ToLower(d.walletAddress),
-					},
+					Name:   d.k8sNameSpace,
+					Labels: labels,
 				},
 			}
-			_, err = k8sService.CreateNameSpace(context.TODO(), namespace, metaV1.CreateOptions{})
+			_, err = k8sService.CreateNameSpace(d.context(), namespace, metaV1.CreateOptions{})
 			if err != nil {
 				return fmt.Errorf("failed create namespace, error: %w", err)
 			}
-
-			//networkPolicy, err := k8sService.CreateNetworkPolicy(context.TODO(), k8sNameSpace)
-			//if err != nil {
-			//	return fmt.Errorf("failed create networkPolicy, error: %w", err)
-			//}
-			//logs.GetLogger().Infof("create networkPolicy successfully, networkPolicyName: %s", networkPolicy.Name)
 		} else {
 			return err
 		}
+	} else if err = k8sService.LabelNameSpace(d.context(), d.k8sNameSpace, spaceNamespaceLabels()); err != nil {
+		return fmt.Errorf("failed label namespace, error: %w", err)
+	}
+
+	if err := applyNamespaceEgress(d.context(), d.k8sNameSpace); err != nil {
+		return err
+	}
+
+	if conf.GetConfig().QUOTA.Enable {
+		tiers, err := loadQuotaTiers()
+		if err == nil {
+			err = applyNamespaceQuota(tiers, d.k8sNameSpace, strings.ToLower(d.walletAddress))
+		}
+		if err != nil {
+			// the quota was checked when the job was received, the sync of the cron task retries it
+			logs.GetLogger().Errorf("namespace: %s, apply quota failed, error: %v", d.k8sNameSpace, err)
+		}
 	}
 	return nil
 }
@@ -514,47 +632,71 @@ func (d *Deploy) createEnv(envs ...coreV1.EnvVar) []coreV1.EnvVar {
 	return defaultEnv
 }
 
-func (d *Deploy) createResources() coreV1.ResourceRequirements {
+// createAffinity requires the node chosen by the scheduler, so that the space runs where its resources were
+// counted and not on another node that happens to fit
+func (d *Deploy) createAffinity() *coreV1.Affinity {
+	if d.nodeName == "" {
+		return nil
+	}
+	return &coreV1.Affinity{
+		NodeAffinity: &coreV1.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &coreV1.NodeSelector{
+				NodeSelectorTerms: []coreV1.NodeSelectorTerm{{
+					MatchFields: []coreV1.NodeSelectorRequirement{{
+						Key:      "metadata.name",
+						Operator: coreV1.NodeSelectorOpIn,
+						Values:   []string{d.nodeName},
+					}},
+				}},
+			},
+		},
+	}
+}
 
-	memQuantity, err := resource.ParseQuantity(fmt.Sprintf("%d%s", d.hardwareResource.Memory.Quantity, d.hardwareResource.Memory.Unit))
+func (d *Deploy) createResources() coreV1.ResourceRequirements {
+	resources, err := hardwareResourceList(d.hardwareResource)
 	if err != nil {
-		logs.GetLogger().Error("get memory failed, error: %+v", err)
+		logs.GetLogger().Error(err)
 		return coreV1.ResourceRequirements{}
 	}
 
-	storageQuantity, err := resource.ParseQuantity(fmt.Sprintf("%d%s", d.hardwareResource.Storage.Quantity, d.hardwareResource.Storage.Unit))
+	return coreV1.ResourceRequirements{
+		Limits:   resources,
+		Requests: resources.DeepCopy(),
+	}
+}
+
+// hardwareResourceList is the resources of a container with the hardware, its requests and limits are the same
+func hardwareResourceList(hardware models.Resource) (coreV1.ResourceList, error) {
+	memQuantity, err := resource.ParseQuantity(fmt.Sprintf("%d%s", hardware.Memory.Quantity, hardware.Memory.Unit))
 	if err != nil {
-		logs.GetLogger().Error("get storage failed, error: %+v", err)
-		return coreV1.ResourceRequirements{}
+		return nil, fmt.Errorf("get memory failed, error: %+v", err)
 	}
 
-	return coreV1.ResourceRequirements{
-		Limits: coreV1.ResourceList{
-			coreV1.ResourceCPU:              *resource.NewQuantity(d.hardwareResource.Cpu.Quantity, resource.DecimalSI),
-			coreV1.ResourceMemory:           memQuantity,
-			coreV1.ResourceEphemeralStorage: storageQuantity,
-			"nvidia.com/gpu":                resource.MustParse(fmt.Sprintf("%d", d.hardwareResource.Gpu.Quantity)),
-		},
-		Requests: coreV1.ResourceList{
-			coreV1.ResourceCPU:              *resource.NewQuantity(d.hardwareResource.Cpu.Quantity, resource.DecimalSI),
-			coreV1.ResourceMemory:           memQuantity,
-			coreV1.ResourceEphemeralStorage: storageQuantity,
-			"nvidia.com/gpu":                resource.MustParse(fmt.Sprintf("%d", d.hardwareResource.Gpu.Quantity)),
-		},
+	storageQuantity, err := resource.ParseQuantity(fmt.Sprintf("%d%s", hardware.Storage.Quantity, hardware.Storage.Unit))
+	if err != nil {
+		return nil, fmt.Errorf("get storage failed, error: %+v", err)
 	}
+
+	return coreV1.ResourceList{
+		coreV1.ResourceCPU:              *resource.NewQuantity(hardware.Cpu.Quantity, resource.DecimalSI),
+		coreV1.ResourceMemory:           memQuantity,
+		coreV1.ResourceEphemeralStorage: storageQuantity,
+		gpuResourceOf(hardware):         *resource.NewQuantity(hardware.Gpu.Quantity, resource.DecimalSI),
+	}, nil
 }
 
 func (d *Deploy) deployK8sResource(containerPort int32) (string, error) {
 	k8sService := NewK8sService()
 
-	createService, err := k8sService.CreateService(context.TODO(), d.k8sNameSpace, d.spaceUuid, containerPort)
+	createService, err := k8sService.ApplyService(d.context(), d.k8sNameSpace, d.spaceUuid, containerPort)
 	if err != nil {
 		return "", fmt.Errorf("failed creata service, error: %w", err)
 	}
 
 	serviceHost := fmt.Sprintf("http://%s:%d", createService.Spec.ClusterIP, createService.Spec.Ports[0].Port)
 
-	_, err = k8sService.CreateIngress(context.TODO(), d.k8sNameSpace, d.spaceUuid, d.hostName, containerPort)
+	_, err = k8sService.ApplyIngress(d.context(), d.k8sNameSpace, d.spaceUuid, d.hostName, containerPort)
 	if err != nil {
 		return "", fmt.Errorf("failed creata ingress, error: %w", err)
 	}
@@ -589,8 +731,9 @@ func getHardwareDetail(description string) (string, models.Resource) {
 	} else {
 		taskType = "GPU"
 		hardwareResource.Gpu.Quantity = 1
-		oldName := strings.TrimSpace(confSplits[0])
-		hardwareResource.Gpu.Unit = strings.ReplaceAll(oldName, "Nvidia", "NVIDIA")
+		oldName, gpuResource := parseGpuResource(confSplits[0])
+		hardwareResource.Gpu.Unit = normalizeGpuVendor(oldName)
+		hardwareResource.GpuResource = gpuResource
 
 		hardwareResource.Storage.Quantity = 30
 	}
@@ -623,7 +766,9 @@ func getHardwareDetailForPrivate(cpu, memory, storage int, gpuModel string, gpuN
 	hardwareResource.Storage.Unit = "Gi"
 
 	hardwareResource.Gpu.Quantity = int64(gpuNum)
-	hardwareResource.Gpu.Unit = strings.ReplaceAll(gpuModel, "Nvidia", "NVIDIA")
+	gpuName, gpuResource := parseGpuResource(gpuModel)
+	hardwareResource.Gpu.Unit = normalizeGpuVendor(gpuName)
+	hardwareResource.GpuResource = gpuResource
 	if len(strings.TrimSpace(gpuModel)) == 0 {
 		taskType = "CPU"
 	} else {
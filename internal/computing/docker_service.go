@@ -1,7 +1,6 @@
 package computing
 
 import (
-	"archive/tar"
 	"bufio"
 	"bytes"
 	"context"
@@ -15,6 +14,8 @@ import (
 	"github.com/docker/docker/api/types/registry"
 	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
 	"github.com/swanchain/go-computing-provider/build"
+	"github.com/swanchain/go-computing-provider/internal/db"
+	"github.com/swanchain/go-computing-provider/internal/models"
 	"io"
 	"log"
 	"os"
@@ -169,63 +170,42 @@ func RemoveContainerIfExists(imageName string) error {
 	return nil
 }
 
-func (ds *DockerService) BuildImage(buildPath, imageName string) error {
-	// Create a buffer
-	buf := new(bytes.Buffer)
-	tw := tar.NewWriter(buf)
-	defer tw.Close()
-
-	filepath.Walk(buildPath, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		header, err := tar.FileInfoHeader(info, info.Name())
-		if err != nil {
-			return err
-		}
-		relPath, err := filepath.Rel(buildPath, path)
-		if err != nil {
-			return err
-		}
-		header.Name = relPath
-		if err := tw.WriteHeader(header); err != nil {
-			return err
-		}
-		if !info.IsDir() {
-			file, err := os.Open(path)
-			if err != nil {
-				return err
-			}
-			defer file.Close()
-			if _, err := io.Copy(tw, file); err != nil {
-				return err
-			}
-		}
-		return nil
-	})
-
-	dockerFileTarReader := bytes.NewReader(buf.Bytes())
-	buildResponse, err := ds.c.ImageBuild(context.Background(), dockerFileTarReader, types.ImageBuildOptions{
-		Context: dockerFileTarReader,
-		Tags:    []string{imageName},
-	})
+// BuildImage builds the image in the space builder, with BuildKit so that the Dockerfile can use cache mounts and
+// the layers of the earlier builds are reused. The build is bounded by the limits of [BUILD].
+func (ds *DockerService) BuildImage(ctx context.Context, buildPath, imageName string) error {
+	release, err := acquireSpaceBuilder(ctx)
 	if err != nil {
 		return err
 	}
-	defer buildResponse.Body.Close()
+	defer release()
+	timeout := time.Duration(spaceBuildLimits(conf.GetConfig().BUILD).Timeout) * time.Minute
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
 
-	logFile, err := os.Create(filepath.Join(buildPath, BuildFileName))
+	// the log is written next to the build context and moved into it once the build is over, so that it is not
+	// part of the context
+	logPath := filepath.Join(buildPath, BuildFileName)
+	os.Remove(logPath)
+	logFile, err := os.CreateTemp(filepath.Dir(buildPath), "."+filepath.Base(buildPath)+"-*.log")
 	if err != nil {
 		return err
 	}
-	defer logFile.Close()
-
-	logWriters := []io.Writer{logFile, os.Stdout}
-	multiWriter := io.MultiWriter(logWriters...)
-
-	_, err = io.Copy(multiWriter, buildResponse.Body)
-	if err != nil {
-		return err
+	defer func() {
+		logFile.Close()
+		if err := os.Rename(logFile.Name(), logPath); err != nil {
+			os.Remove(logFile.Name())
+		}
+	}()
+
+	cmd := exec.CommandContext(ctx, "docker", "buildx", "build", "--builder", spaceBuilderName, "--load",
+		"--progress", "plain", "--file", spaceDockerfilePath(buildPath), "--tag", imageName, buildPath)
+	cmd.Stdout = io.MultiWriter(logFile, os.Stdout)
+	cmd.Stderr = cmd.Stdout
+	if err = cmd.Run(); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("the build of image %s took more than %v, see %s", imageName, timeout, logPath)
+		}
+		return fmt.Errorf("image %s is not built, see %s, error: %v", imageName, logPath, err)
 	}
 	return nil
 }
@@ -237,8 +217,8 @@ type ErrorLine struct {
 	} `json:"errorDetail"`
 }
 
-func (ds *DockerService) PushImage(imagesName string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*6000)
+func (ds *DockerService) PushImage(ctx context.Context, imagesName string) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Second*6000)
 	defer cancel()
 
 	var authConfig = registry.AuthConfig{
@@ -266,7 +246,7 @@ func (ds *DockerService) PushImage(imagesName string) error {
 		}
 		time.Sleep(2 * time.Second)
 	}
-	return nil
+	return err
 }
 
 func printOut(rd io.Reader) error {
@@ -339,6 +319,10 @@ func (ds *DockerService) CleanResource() {
 	for _, imageName := range imagesToKeep {
 		keepSet[imageName] = true
 	}
+	// the images of the spaces are kept for their redeploys, they are removed with the deployments
+	for _, imageName := range spaceJobImages() {
+		keepSet[imageName] = true
+	}
 
 	allImages, err := ds.c.ImageList(context.Background(), image.ListOptions{})
 	if err != nil {
@@ -361,6 +345,28 @@ func (ds *DockerService) CleanResource() {
 	danglingFilters.Add("dangling", "true")
 	ds.c.ImagesPrune(ctx, danglingFilters)
 	ds.c.ContainersPrune(ctx, filters.NewArgs())
+	pruneBuildCache()
+}
+
+// spaceJobImages is the images of the spaces being deployed or running
+func spaceJobImages() []string {
+	if db.NewDbService() == nil {
+		return nil
+	}
+	var images []string
+	for _, deployState := range []string{models.DEPLOY_STATE_DEPLOYING, models.DEPLOY_STATE_DEPLOYED} {
+		jobs, err := NewJobService().GetJobListByDeployState(deployState)
+		if err != nil {
+			logs.GetLogger().Errorf("get the %s jobs failed, error: %v", deployState, err)
+			continue
+		}
+		for _, job := range jobs {
+			if job.ImageName != "" {
+				images = append(images, job.ImageName)
+			}
+		}
+	}
+	return images
 }
 
 func (ds *DockerService) PullImage(imageName string) error {
@@ -450,6 +456,12 @@ func (ds *DockerService) GetContainerLogStream(containerName string) (io.ReadClo
 	})
 }
 
+// InspectImage returns the local image, with its size, digests and config
+func (ds *DockerService) InspectImage(ctx context.Context, imageName string) (types.ImageInspect, error) {
+	inspect, _, err := ds.c.ImageInspectWithRaw(ctx, imageName)
+	return inspect, err
+}
+
 func (ds *DockerService) checkImageExists(imageName string) bool {
 	filterArgs := filters.NewArgs()
 	filterArgs.Add("reference", imageName)
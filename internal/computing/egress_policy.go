@@ -0,0 +1,203 @@
+package computing
+
+import (
+	"context"
+	"fmt"
+	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
+	"github.com/swanchain/go-computing-provider/conf"
+	"github.com/swanchain/go-computing-provider/constants"
+	"github.com/swanchain/go-computing-provider/internal/yaml"
+	coreV1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"net/netip"
+	"strings"
+)
+
+// egressBandwidthAnnotation limits the egress of a pod with the bandwidth CNI plugin
+const egressBandwidthAnnotation = "kubernetes.io/egress-bandwidth"
+
+// checkEgress returns an error naming the egress requested by a service outside EGRESS.ApprovedCidrs
+func checkEgress(egress conf.EGRESS, service string, requests []yaml.EgressResource) error {
+	var denied []string
+	for _, request := range requests {
+		if !cidrsContain(egress.ApprovedCidrs, request.Cidr) {
+			denied = append(denied, request.Cidr.String())
+		}
+	}
+	if len(denied) > 0 {
+		return fmt.Errorf("service %s requests egress to %s, not approved by the computing provider", service, strings.Join(denied, ", "))
+	}
+	return nil
+}
+
+// cidrsContain tells if the network is within one of the cidrs
+func cidrsContain(cidrs []string, network netip.Prefix) bool {
+	for _, cidr := range cidrs {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err == nil && prefix.Bits() <= network.Bits() && prefix.Contains(network.Addr()) {
+			return true
+		}
+	}
+	return false
+}
+
+// ipBlockPeers is the peers of the cidrs without the blocked networks, a cidr within a blocked network is left out
+func ipBlockPeers(cidrs, blocked []string) []networkingv1.NetworkPolicyPeer {
+	var peers []networkingv1.NetworkPolicyPeer
+	for _, cidr := range cidrs {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil || cidrsContain(blocked, prefix) {
+			continue
+		}
+		prefix = prefix.Masked()
+		ipBlock := &networkingv1.IPBlock{CIDR: prefix.String()}
+		for _, b := range blocked {
+			if except, err := netip.ParsePrefix(b); err == nil && except.Bits() > prefix.Bits() && prefix.Contains(except.Addr()) {
+				ipBlock.Except = append(ipBlock.Except, except.Masked().String())
+			}
+		}
+		peers = append(peers, networkingv1.NetworkPolicyPeer{IPBlock: ipBlock})
+	}
+	return peers
+}
+
+// policyPorts is the ports over TCP and UDP, nil for all the ports
+func policyPorts(ports ...int32) []networkingv1.NetworkPolicyPort {
+	var policyPorts []networkingv1.NetworkPolicyPort
+	for _, port := range ports {
+		for _, protocol := range []coreV1.Protocol{coreV1.ProtocolTCP, coreV1.ProtocolUDP} {
+			protocol, port := protocol, intstr.FromInt32(port)
+			policyPorts = append(policyPorts, networkingv1.NetworkPolicyPort{Protocol: &protocol, Port: &port})
+		}
+	}
+	return policyPorts
+}
+
+// namespaceEgressRules is the egress of every space: the DNS of the cluster and EGRESS.Dns, and AllowedCidrs on
+// AllowedPorts but the blocked networks
+func namespaceEgressRules(egress conf.EGRESS) []networkingv1.NetworkPolicyEgressRule {
+	rules := []networkingv1.NetworkPolicyEgressRule{{
+		To: []networkingv1.NetworkPolicyPeer{{
+			NamespaceSelector: &metaV1.LabelSelector{MatchLabels: map[string]string{"kubernetes.io/metadata.name": "kube-system"}},
+			PodSelector:       &metaV1.LabelSelector{MatchLabels: map[string]string{"k8s-app": "kube-dns"}},
+		}},
+		Ports: policyPorts(53),
+	}}
+	// the DNS servers are listed by the operator, e.g. a NodeLocal DNSCache on a link-local address
+	if peers := ipBlockPeers(egress.Dns, nil); len(peers) > 0 {
+		rules = append(rules, networkingv1.NetworkPolicyEgressRule{To: peers, Ports: policyPorts(53)})
+	}
+	if peers := ipBlockPeers(egress.AllowedCidrs, egress.BlockedCidrs); len(peers) > 0 {
+		rules = append(rules, networkingv1.NetworkPolicyEgressRule{To: peers, Ports: policyPorts(egress.AllowedPorts...)})
+	}
+	return rules
+}
+
+// spaceEgressRules is the egress approved for a space beyond the namespace policy, the blocked networks stay blocked
+func spaceEgressRules(egress conf.EGRESS, requests []yaml.EgressResource) []networkingv1.NetworkPolicyEgressRule {
+	var rules []networkingv1.NetworkPolicyEgressRule
+	for _, request := range requests {
+		if peers := ipBlockPeers([]string{request.Cidr.String()}, egress.BlockedCidrs); len(peers) > 0 {
+			rules = append(rules, networkingv1.NetworkPolicyEgressRule{To: peers, Ports: policyPorts(request.Ports...)})
+		}
+	}
+	return rules
+}
+
+// newEgressPolicy is an egress policy of the pods matching the labels, the policies of a pod add up
+func newEgressPolicy(namespace, name string, podLabels map[string]string, rules []networkingv1.NetworkPolicyEgressRule) *networkingv1.NetworkPolicy {
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metaV1.LabelSelector{MatchLabels: podLabels},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress},
+			Egress:      rules,
+		},
+	}
+}
+
+// applyNamespaceEgress creates or updates the egress policy of the namespace of a wallet, and removes the egress
+// policies of the namespace and its spaces when EGRESS is disabled
+func applyNamespaceEgress(ctx context.Context, namespace string) error {
+	k8sService := NewK8sService()
+	egress := conf.GetConfig().EGRESS
+	if !egress.Enable {
+		networkPolicies, err := k8sService.ListNetworkPolicies(ctx, namespace)
+		if err != nil {
+			return fmt.Errorf("list network policies failed, namespace: %s, error: %v", namespace, err)
+		}
+		for _, networkPolicy := range networkPolicies {
+			if networkPolicy.Name != constants.K8S_NETWORK_POLICY_NAME && !strings.HasPrefix(networkPolicy.Name, constants.K8S_NETWORK_POLICY_NAME_PREFIX) {
+				continue
+			}
+			if err = k8sService.DeleteNetworkPolicy(ctx, namespace, networkPolicy.Name); err != nil && !errors.IsNotFound(err) {
+				return fmt.Errorf("delete network policy failed, namespace: %s, name: %s, error: %v", namespace, networkPolicy.Name, err)
+			}
+		}
+		return nil
+	}
+
+	networkPolicy := newEgressPolicy(namespace, constants.K8S_NETWORK_POLICY_NAME, nil, namespaceEgressRules(egress))
+	if err := k8sService.ApplyNetworkPolicy(ctx, networkPolicy); err != nil {
+		return fmt.Errorf("apply network policy failed, namespace: %s, error: %v", namespace, err)
+	}
+	return nil
+}
+
+// applySpaceEgress creates or updates the egress policy of the extra egress the space requests, and removes it when
+// the space requests none
+func (d *Deploy) applySpaceEgress(requests []yaml.EgressResource) error {
+	k8sService := NewK8sService()
+	name := constants.K8S_NETWORK_POLICY_NAME_PREFIX + d.spaceUuid
+	egress := conf.GetConfig().EGRESS
+	rules := spaceEgressRules(egress, requests)
+	if !egress.Enable || len(rules) == 0 {
+		if err := k8sService.DeleteNetworkPolicy(d.context(), d.k8sNameSpace, name); err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("delete network policy failed, name: %s, error: %v", name, err)
+		}
+		return nil
+	}
+
+	networkPolicy := newEgressPolicy(d.k8sNameSpace, name, map[string]string{"lad_app": d.spaceUuid}, rules)
+	if err := k8sService.ApplyNetworkPolicy(d.context(), networkPolicy); err != nil {
+		return fmt.Errorf("apply network policy failed, name: %s, error: %v", name, err)
+	}
+	logs.GetLogger().Infof("space_uuid: %s, approved egress to %d networks", d.spaceUuid, len(rules))
+	return nil
+}
+
+// withEgressBandwidth limits the egress bandwidth of the pods of the template to EGRESS.Bandwidth
+func withEgressBandwidth(template *coreV1.PodTemplateSpec) {
+	egress := conf.GetConfig().EGRESS
+	if !egress.Enable || egress.Bandwidth == "" {
+		return
+	}
+	if template.Annotations == nil {
+		template.Annotations = make(map[string]string)
+	}
+	template.Annotations[egressBandwidthAnnotation] = egress.Bandwidth
+}
+
+// syncNamespaceEgress applies EGRESS to the namespaces of all the wallets and to the model-cache namespace, so
+// that changes of the config reach the spaces that are already deployed
+func syncNamespaceEgress() {
+	namespaces, err := NewK8sService().ListNamespace(context.TODO())
+	if err != nil {
+		logs.GetLogger().Errorf("Failed get all namespace, error: %+v", err)
+		return
+	}
+	for _, namespace := range namespaces {
+		if !strings.HasPrefix(namespace, constants.K8S_NAMESPACE_NAME_PREFIX) && namespace != modelCacheNamespace {
+			continue
+		}
+		if err = applyNamespaceEgress(context.TODO(), namespace); err != nil {
+			logs.GetLogger().Errorf("sync namespace egress failed, error: %v", err)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package computing
+
+import (
+	"net/netip"
+	"slices"
+	"testing"
+
+	"github.com/swanchain/go-computing-provider/conf"
+	"github.com/swanchain/go-computing-provider/internal/yaml"
+)
+
+func TestCheckEgress(t *testing.T) {
+	egress := conf.EGRESS{ApprovedCidrs: []string{"203.0.113.0/24"}}
+	approved := []yaml.EgressResource{{Cidr: netip.MustParsePrefix("203.0.113.10/32"), Ports: []int32{5432}}}
+	if err := checkEgress(egress, "app", approved); err != nil {
+		t.Errorf("expected a network within the approved ones to be approved, got %v", err)
+	}
+	for _, cidr := range []string{"203.0.0.0/16", "198.51.100.0/24"} {
+		if err := checkEgress(egress, "app", []yaml.EgressResource{{Cidr: netip.MustParsePrefix(cidr)}}); err == nil {
+			t.Errorf("expected egress to %s to be denied", cidr)
+		}
+	}
+}
+
+func TestIpBlockPeers(t *testing.T) {
+	blocked := []string{"169.254.0.0/16", "10.0.0.0/8"}
+	peers := ipBlockPeers([]string{"0.0.0.0/0", "10.1.0.0/16", "203.0.113.7/24"}, blocked)
+	if len(peers) != 2 {
+		t.Fatalf("expected the network within a blocked one to be left out, got %d peers", len(peers))
+	}
+	if peers[0].IPBlock.CIDR != "0.0.0.0/0" || !slices.Equal(peers[0].IPBlock.Except, blocked) {
+		t.Errorf("expected 0.0.0.0/0 except the blocked networks, got %+v", peers[0].IPBlock)
+	}
+	if peers[1].IPBlock.CIDR != "203.0.113.0/24" || len(peers[1].IPBlock.Except) != 0 {
+		t.Errorf("expected the masked network without except, got %+v", peers[1].IPBlock)
+	}
+}
+
+func TestNamespaceEgressRules(t *testing.T) {
+	egress := conf.EGRESS{
+		AllowedCidrs: []string{"0.0.0.0/0"},
+		AllowedPorts: []int32{80, 443},
+		BlockedCidrs: []string{"169.254.0.0/16"},
+		Dns:          []string{"169.254.20.10/32"},
+	}
+	rules := namespaceEgressRules(egress)
+	if len(rules) != 3 {
+		t.Fatalf("expected the cluster DNS, the DNS servers and the allowed networks, got %d rules", len(rules))
+	}
+	if rules[0].To[0].PodSelector.MatchLabels["k8s-app"] != "kube-dns" || rules[0].Ports[0].Port.IntVal != 53 {
+		t.Errorf("expected the DNS of the cluster on port 53, got %+v", rules[0])
+	}
+	if rules[1].To[0].IPBlock.CIDR != "169.254.20.10/32" {
+		t.Errorf("expected the DNS server to be reachable though blocked, got %+v", rules[1].To)
+	}
+	if len(rules[2].Ports) != 4 || rules[2].Ports[2].Port.IntVal != 443 {
+		t.Errorf("expected ports 80 and 443 over TCP and UDP, got %+v", rules[2].Ports)
+	}
+
+	if rules = namespaceEgressRules(conf.EGRESS{}); len(rules) != 1 {
+		t.Errorf("expected only the DNS of the cluster without allowed networks, got %d rules", len(rules))
+	}
+}
+
+func TestSpaceEgressRules(t *testing.T) {
+	egress := conf.EGRESS{BlockedCidrs: []string{"169.254.169.254/32"}}
+	rules := spaceEgressRules(egress, []yaml.EgressResource{
+		{Cidr: netip.MustParsePrefix("169.254.169.254/32")},
+		{Cidr: netip.MustParsePrefix("198.51.100.0/24"), Ports: []int32{5432}},
+	})
+	if len(rules) != 1 || rules[0].To[0].IPBlock.CIDR != "198.51.100.0/24" || len(rules[0].Ports) != 2 {
+		t.Errorf("expected only the network not blocked on port 5432, got %+v", rules)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/swanchain/go-computing-provider/internal/db"
 	"github.com/swanchain/go-computing-provider/internal/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"time"
 )
 
@@ -61,6 +62,15 @@ func (taskServ TaskService) GetTaskEntity(taskId int64) (*models.TaskEntity, err
 	return &taskEntity, err
 }
 
+// GetUnfinishedTaskList returns the tasks still received or running, the oldest first
+func (taskServ TaskService) GetUnfinishedTaskList() (list []*models.TaskEntity, err error) {
+	err = taskServ.Where("status in (?,?)", models.TASK_RECEIVED_STATUS, models.TASK_RUNNING_STATUS).Order("create_time").Find(&list).Error
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
 func (taskServ TaskService) GetTaskListNoReward() (list []*models.TaskEntity, err error) {
 	err = taskServ.Where("status=? and reward_status=?", models.TASK_SUCCESS_STATUS, models.REWARD_UNCLAIMED).Find(&list).Error
 	if err != nil {
@@ -85,6 +95,11 @@ func (jobServ JobService) UpdateJobEntityByJobUuid(job *models.JobEntity) (err e
 	return jobServ.Where("job_uuid=?", job.JobUuid).Updates(job).Error
 }
 
+// UpdateJobColumnsByJobUuid updates the columns even to their zero value, which Updates with a struct skips
+func (jobServ JobService) UpdateJobColumnsByJobUuid(jobUuid string, columns map[string]any) (err error) {
+	return jobServ.Model(&models.JobEntity{}).Where("job_uuid=?", jobUuid).Updates(columns).Error
+}
+
 func (jobServ JobService) UpdateJobResultUrlByJobUuid(jobUuid string, resultUrl string) (err error) {
 	return jobServ.Model(&models.JobEntity{}).Where("job_uuid=?", jobUuid).Update("result_url", resultUrl).Error
 }
@@ -116,6 +131,11 @@ func (jobServ JobService) GetJobList() (list []*models.JobEntity, err error) {
 	return
 }
 
+func (jobServ JobService) GetJobListByDeployState(deployState string) (list []*models.JobEntity, err error) {
+	err = jobServ.Model(&models.JobEntity{}).Where("deploy_state=?", deployState).Find(&list).Error
+	return
+}
+
 func (jobServ JobService) DeleteJobs(spaceIds []string) (err error) {
 	return jobServ.Where("space_uuid in ?", spaceIds).Delete(&models.JobEntity{}).Error
 }
@@ -139,6 +159,77 @@ func (cpServ CpInfoService) UpdateCpInfoByNodeId(cp *models.CpInfoEntity) (err e
 	return cpServ.Where("node_id =?", cp.NodeId).Updates(cp).Error
 }
 
+type HubEventService struct {
+	*gorm.DB
+}
+
+// SaveHubEvent adds the event to the outbox, an event with the same key is only kept once
+func (eventServ HubEventService) SaveHubEvent(event *models.HubEventEntity) (err error) {
+	return eventServ.Clauses(clause.OnConflict{DoNothing: true}).Create(event).Error
+}
+
+func (eventServ HubEventService) UpdateHubEvent(event *models.HubEventEntity) (err error) {
+	return eventServ.Save(event).Error
+}
+
+// GetPendingHubEvents returns the events due for delivery at now, the oldest first. The events of a job waiting
+// for the retry of an earlier event are left out, so that the events of a job are delivered in order.
+func (eventServ HubEventService) GetPendingHubEvents(now int64, limit int) (list []*models.HubEventEntity, err error) {
+	waiting := eventServ.Model(&models.HubEventEntity{}).Select("job_uuid").
+		Where("status=? and next_attempt>?", models.HUB_EVENT_PENDING_STATUS, now)
+	err = eventServ.Model(&models.HubEventEntity{}).
+		Where("status=? and next_attempt<=? and job_uuid not in (?)", models.HUB_EVENT_PENDING_STATUS, now, waiting).
+		Order("id").Limit(limit).Find(&list).Error
+	return
+}
+
+// GetUndeliveredHubEvents returns the pending and the dead events, the oldest first
+func (eventServ HubEventService) GetUndeliveredHubEvents() (list []*models.HubEventEntity, err error) {
+	err = eventServ.Model(&models.HubEventEntity{}).Where("status<>?", models.HUB_EVENT_DELIVERED_STATUS).
+		Order("id").Find(&list).Error
+	return
+}
+
+func (eventServ HubEventService) DeleteDeliveredHubEvents(before int64) (err error) {
+	return eventServ.Where("status=? and update_time<?", models.HUB_EVENT_DELIVERED_STATUS, before).
+		Delete(&models.HubEventEntity{}).Error
+}
+
+type ModelCacheService struct {
+	*gorm.DB
+}
+
+func (cacheServ ModelCacheService) SaveModelCache(entry *models.ModelCacheEntity) (err error) {
+	return cacheServ.Save(entry).Error
+}
+
+func (cacheServ ModelCacheService) GetModelCache(nodeName, cacheKey string) (*models.ModelCacheEntity, error) {
+	var entry models.ModelCacheEntity
+	err := cacheServ.Where("node_name=? and cache_key=?", nodeName, cacheKey).First(&entry).Error
+	return &entry, err
+}
+
+// GetModelCacheList returns the models cached on the node, or on all the nodes for an empty node name, the least
+// recently used first
+func (cacheServ ModelCacheService) GetModelCacheList(nodeName string) (list []*models.ModelCacheEntity, err error) {
+	query := cacheServ.Model(&models.ModelCacheEntity{})
+	if nodeName != "" {
+		query = query.Where("node_name=?", nodeName)
+	}
+	err = query.Order("last_used").Find(&list).Error
+	return
+}
+
+func (cacheServ ModelCacheService) TouchModelCache(id int64, lastUsed int64) (err error) {
+	return cacheServ.Model(&models.ModelCacheEntity{}).Where("id=?", id).Update("last_used", lastUsed).Error
+}
+
+func (cacheServ ModelCacheService) DeleteModelCache(id int64) (err error) {
+	return cacheServ.Where("id=?", id).Delete(&models.ModelCacheEntity{}).Error
+}
+
 var taskSet = wire.NewSet(db.NewDbService, wire.Struct(new(TaskService), "*"))
 var jobSet = wire.NewSet(db.NewDbService, wire.Struct(new(JobService), "*"))
 var cpInfoSet = wire.NewSet(db.NewDbService, wire.Struct(new(CpInfoService), "*"))
+var hubEventSet = wire.NewSet(db.NewDbService, wire.Struct(new(HubEventService), "*"))
+var modelCacheSet = wire.NewSet(db.NewDbService, wire.Struct(new(ModelCacheService), "*"))
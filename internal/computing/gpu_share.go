@@ -0,0 +1,139 @@
+package computing
+
+import (
+	"github.com/swanchain/go-computing-provider/internal/models"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sort"
+	"strings"
+)
+
+// The gpu resource classes advertised by the nvidia device plugin. A gpu partitioned by MIG (mixed strategy) is
+// advertised as one resource per profile, e.g. nvidia.com/mig-1g.10gb, and a gpu shared by time-slicing with
+// renameByDefault as nvidia.com/gpu.shared, so the tasks asking for them can share one card.
+const (
+	GpuResourceName       = "nvidia.com/gpu"
+	SharedGpuResourceName = "nvidia.com/gpu.shared"
+	MigResourcePrefix     = "nvidia.com/mig-"
+
+	// gpuProductLabel is the gpu model label of the nodes set by the gpu-feature-discovery
+	gpuProductLabel = "nvidia.com/gpu.product"
+)
+
+// isSharedGpuResource tells if the resource is a MIG profile or a time-sliced gpu
+func isSharedGpuResource(name string) bool {
+	return name == SharedGpuResourceName || (strings.HasPrefix(name, MigResourcePrefix) && len(name) > len(MigResourcePrefix))
+}
+
+// parseGpuResource splits a gpu description into the gpu model and its resource class:
+//
+//	NVIDIA A100                -> NVIDIA A100, nvidia.com/gpu
+//	NVIDIA A100 MIG 1g.10gb    -> NVIDIA A100, nvidia.com/mig-1g.10gb
+//	NVIDIA A100 Shared         -> NVIDIA A100, nvidia.com/gpu.shared
+//
+// The resource names themselves are accepted too, e.g. "mig-1g.10gb" or "nvidia.com/gpu.shared" with no model.
+func parseGpuResource(description string) (string, string) {
+	fields := strings.Fields(description)
+	for i, field := range fields {
+		model := strings.Join(fields[:i], " ")
+		lower := strings.ToLower(field)
+		switch {
+		case lower == "shared" || lower == SharedGpuResourceName || lower == "gpu.shared":
+			return model, SharedGpuResourceName
+		case lower == "mig" && i+1 < len(fields):
+			return model, MigResourcePrefix + strings.ToLower(fields[i+1])
+		case strings.HasPrefix(lower, MigResourcePrefix) && len(lower) > len(MigResourcePrefix):
+			return model, lower
+		case strings.HasPrefix(lower, "mig-") && len(lower) > len("mig-"):
+			return model, "nvidia.com/" + lower
+		}
+	}
+	return strings.TrimSpace(description), GpuResourceName
+}
+
+// gpuResourceOf is the gpu resource class of the hardware, the whole gpu of its vendor unless it asks for a
+// shared gpu
+func gpuResourceOf(hardware models.Resource) corev1.ResourceName {
+	if isSharedGpuResource(hardware.GpuResource) {
+		return corev1.ResourceName(hardware.GpuResource)
+	}
+	return gpuVendorResource(hardware.Gpu.Unit)
+}
+
+// gpuResourceInPod is the count of the gpu resource requested by the containers of the pod
+func gpuResourceInPod(pod *corev1.Pod, name corev1.ResourceName) (count int64) {
+	for _, container := range pod.Spec.Containers {
+		if val, ok := container.Resources.Requests[name]; ok {
+			count += val.Value()
+		}
+	}
+	return count
+}
+
+// sharedGpus is the MIG profiles and time-sliced gpus the node advertises, with the count in use by the pods
+func sharedGpus(node *corev1.Node, pods []corev1.Pod) []models.SharedGpu {
+	var shared []models.SharedGpu
+	for name, quantity := range node.Status.Allocatable {
+		if !isSharedGpuResource(string(name)) || quantity.Value() <= 0 {
+			continue
+		}
+		var used int64
+		for i := range pods {
+			used += gpuResourceInPod(&pods[i], name)
+		}
+		free := quantity.Value() - used
+		if free < 0 {
+			free = 0
+		}
+		shared = append(shared, models.SharedGpu{
+			ResourceName: string(name),
+			ProductName:  node.Labels[gpuProductLabel],
+			Total:        quantity.Value(),
+			Used:         used,
+			Free:         free,
+		})
+	}
+	sort.Slice(shared, func(i, j int) bool {
+		return shared[i].ResourceName < shared[j].ResourceName
+	})
+	return shared
+}
+
+// wholeGpus caps the gpus of the node found by the resource-exporter to the whole gpus the device plugins
+// advertise: a gpu partitioned by MIG is no longer a nvidia.com/gpu, it is only usable through its profiles.
+// The node without a device plugin is left as it is.
+func wholeGpus(node *corev1.Node, gpuSummary map[string]int64) map[string]int64 {
+	var allocatable resource.Quantity
+	var found bool
+	for _, name := range gpuResourceNames() {
+		if quantity, ok := node.Status.Allocatable[name]; ok {
+			allocatable.Add(quantity)
+			found = true
+		}
+	}
+	if !found {
+		return gpuSummary
+	}
+
+	var names []string
+	var total int64
+	capped := make(map[string]int64, len(gpuSummary))
+	for name, count := range gpuSummary {
+		names = append(names, name)
+		total += count
+		capped[name] = count
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(names)))
+	for _, name := range names {
+		if total <= allocatable.Value() {
+			break
+		}
+		partitioned := total - allocatable.Value()
+		if partitioned > capped[name] {
+			partitioned = capped[name]
+		}
+		capped[name] -= partitioned
+		total -= partitioned
+	}
+	return capped
+}
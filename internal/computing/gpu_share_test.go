@@ -0,0 +1,74 @@
+package computing
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestParseGpuResource(t *testing.T) {
+	for _, tc := range []struct {
+		description, model, resource string
+	}{
+		{"NVIDIA A100", "NVIDIA A100", GpuResourceName},
+		{" NVIDIA A100 MIG 1g.10gb ", "NVIDIA A100", "nvidia.com/mig-1g.10gb"},
+		{"NVIDIA A100 Shared", "NVIDIA A100", SharedGpuResourceName},
+		{"mig-2g.20gb", "", "nvidia.com/mig-2g.20gb"},
+		{"nvidia.com/gpu.shared", "", SharedGpuResourceName},
+		{"", "", GpuResourceName},
+	} {
+		model, name := parseGpuResource(tc.description)
+		if model != tc.model || name != tc.resource {
+			t.Errorf("parseGpuResource(%q) = %q, %q, expected %q, %q", tc.description, model, name, tc.model, tc.resource)
+		}
+	}
+}
+
+// newMigTestNode has 2 A100 of which 1 is partitioned into 7 MIG 1g.10gb
+func newMigTestNode() corev1.Node {
+	node := newTestNode("mig", 64, 256, nil)
+	node.Status.Allocatable[GpuResourceName] = *resource.NewQuantity(1, resource.DecimalSI)
+	node.Status.Allocatable["nvidia.com/mig-1g.10gb"] = *resource.NewQuantity(7, resource.DecimalSI)
+	return node
+}
+
+func TestWholeGpus(t *testing.T) {
+	node := newMigTestNode()
+	if gpus := wholeGpus(&node, map[string]int64{"NVIDIA-A100-PCIE-40GB": 2}); gpus["NVIDIA-A100-PCIE-40GB"] != 1 {
+		t.Fatalf("expected the partitioned A100 not to be a whole gpu, got %v", gpus)
+	}
+
+	plain := newTestNode("plain", 8, 32, nil)
+	if gpus := wholeGpus(&plain, map[string]int64{"NVIDIA-A100-PCIE-40GB": 2}); gpus["NVIDIA-A100-PCIE-40GB"] != 2 {
+		t.Fatalf("expected a node without the device plugin to keep its gpus, got %v", gpus)
+	}
+}
+
+func TestScheduler_SharedGpu(t *testing.T) {
+	node := newMigTestNode()
+	pods := []corev1.Pod{newTestPod("mig", 1, 1, "", 0)}
+	pods[0].Spec.Containers[0].Resources.Requests["nvidia.com/mig-1g.10gb"] = *resource.NewQuantity(6, resource.DecimalSI)
+	nodes := nodeCapacities([]corev1.Node{node}, pods, map[string]map[string]int64{"mig": {"NVIDIA-A100-PCIE-40GB": 2}})
+
+	if mig := nodes[0].SharedGpus["nvidia.com/mig-1g.10gb"]; mig.Total != 7 || mig.Used != 6 {
+		t.Fatalf("expected 6 of 7 MIG 1g.10gb used, got %+v", mig)
+	}
+
+	scheduler, _ := NewScheduler(StrategyGpuAffinity)
+	req := PlacementRequest{Cpu: 2, Gpu: 1, GpuName: "NVIDIA-A100", GpuResource: "nvidia.com/mig-1g.10gb"}
+	placement := scheduler.Place(req, nodes)
+	if placement == nil || placement.GpuProductName != "NVIDIA-A100-PCIE-40GB" {
+		t.Fatalf("expected a MIG 1g.10gb of the A100, got %+v", placement)
+	}
+
+	req.Gpu = 2
+	if placement = scheduler.Place(req, nodes); placement != nil {
+		t.Fatalf("expected 2 MIG 1g.10gb not to fit, got %+v", placement)
+	}
+
+	req = PlacementRequest{Cpu: 2, Gpu: 1, GpuResource: SharedGpuResourceName}
+	if placement = scheduler.Place(req, nodes); placement != nil {
+		t.Fatalf("expected no shared gpu on the node, got %+v", placement)
+	}
+}
@@ -0,0 +1,103 @@
+package computing
+
+import (
+	"github.com/swanchain/go-computing-provider/conf"
+	"github.com/swanchain/go-computing-provider/internal/models"
+	corev1 "k8s.io/api/core/v1"
+	"sort"
+	"strings"
+)
+
+// The gpu vendors, named by the first word of the gpu product name reported by the resource-exporter
+const (
+	GpuVendorNvidia = "NVIDIA"
+	GpuVendorAmd    = "AMD"
+
+	AmdGpuResourceName = "amd.com/gpu"
+)
+
+// defaultGpuResources is the extended resource of the whole gpus of the built in vendors, GPU.Resources adds
+// other vendors or overrides them
+var defaultGpuResources = map[string]string{
+	GpuVendorNvidia: GpuResourceName,
+	GpuVendorAmd:    AmdGpuResourceName,
+}
+
+// gpuResources is the extended resource of the whole gpus by vendor
+func gpuResources() map[string]string {
+	resources := make(map[string]string, len(defaultGpuResources))
+	for vendor, name := range defaultGpuResources {
+		resources[vendor] = name
+	}
+	if cfg := conf.GetConfig(); cfg != nil {
+		for vendor, name := range cfg.GPU.Resources {
+			resources[strings.ToUpper(strings.TrimSpace(vendor))] = name
+		}
+	}
+	return resources
+}
+
+// gpuResourceNames is the extended resources of the whole gpus of all the vendors, in order
+func gpuResourceNames() []corev1.ResourceName {
+	seen := make(map[string]bool)
+	var names []corev1.ResourceName
+	for _, name := range gpuResources() {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, corev1.ResourceName(name))
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return names[i] < names[j]
+	})
+	return names
+}
+
+// gpuVendor is the vendor of a gpu product name, the GeForce cards without the vendor are NVIDIA
+func gpuVendor(productName string) string {
+	fields := strings.Fields(strings.ReplaceAll(productName, "-", " "))
+	if len(fields) == 0 {
+		return ""
+	}
+	vendor := strings.ToUpper(fields[0])
+	if vendor == "GEFORCE" || vendor == "TESLA" {
+		return GpuVendorNvidia
+	}
+	return vendor
+}
+
+// gpuVendorResource is the extended resource of the whole gpus of the product, nvidia.com/gpu when the vendor
+// is not known
+func gpuVendorResource(productName string) corev1.ResourceName {
+	if name, ok := gpuResources()[gpuVendor(productName)]; ok {
+		return corev1.ResourceName(name)
+	}
+	return GpuResourceName
+}
+
+// normalizeGpuVendor writes the vendor of a gpu product name in upper case, e.g. Nvidia A100 is NVIDIA A100
+func normalizeGpuVendor(productName string) string {
+	vendor, rest, _ := strings.Cut(productName, " ")
+	if _, ok := gpuResources()[strings.ToUpper(vendor)]; ok {
+		vendor = strings.ToUpper(vendor)
+	}
+	if rest == "" {
+		return vendor
+	}
+	return vendor + " " + rest
+}
+
+// describeGpuVendors fills the vendor and the extended resource of the gpus, and the gpu runtime of the node
+// from the cuda or rocm version, so that the hub can tell the gpus of the vendors apart
+func describeGpuVendors(gpu *models.Gpu) {
+	switch {
+	case gpu.CudaVersion != "":
+		gpu.Runtime, gpu.RuntimeVersion = "cuda", gpu.CudaVersion
+	case gpu.RocmVersion != "":
+		gpu.Runtime, gpu.RuntimeVersion = "rocm", gpu.RocmVersion
+	}
+	for i := range gpu.Details {
+		gpu.Details[i].Vendor = gpuVendor(gpu.Details[i].ProductName)
+		gpu.Details[i].ResourceName = string(gpuVendorResource(gpu.Details[i].ProductName))
+	}
+}
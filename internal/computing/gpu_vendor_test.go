@@ -0,0 +1,79 @@
+package computing
+
+import (
+	"testing"
+
+	"github.com/swanchain/go-computing-provider/internal/models"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestGpuVendorResource(t *testing.T) {
+	for _, tc := range []struct {
+		productName, vendor string
+		resource            corev1.ResourceName
+	}{
+		{"NVIDIA A100-PCIE-40GB", GpuVendorNvidia, GpuResourceName},
+		{"NVIDIA-GeForce-RTX-4090", GpuVendorNvidia, GpuResourceName},
+		{"GeForce RTX 3080", GpuVendorNvidia, GpuResourceName},
+		{"AMD Instinct MI210", GpuVendorAmd, AmdGpuResourceName},
+		{"amd-radeon-rx-7900-xtx", GpuVendorAmd, AmdGpuResourceName},
+		{"Unknown Accelerator", "UNKNOWN", GpuResourceName},
+	} {
+		if vendor := gpuVendor(tc.productName); vendor != tc.vendor {
+			t.Errorf("gpuVendor(%q) = %q, expected %q", tc.productName, vendor, tc.vendor)
+		}
+		if name := gpuVendorResource(tc.productName); name != tc.resource {
+			t.Errorf("gpuVendorResource(%q) = %q, expected %q", tc.productName, name, tc.resource)
+		}
+	}
+}
+
+func TestHardwareResourceList_Amd(t *testing.T) {
+	_, hardware := getHardwareDetailForPrivate(8, 32, 100, "Amd Instinct MI210", 2)
+	if hardware.Gpu.Unit != "AMD Instinct MI210" {
+		t.Fatalf("expected the vendor in upper case, got %q", hardware.Gpu.Unit)
+	}
+	resources, err := hardwareResourceList(hardware)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gpu := resources[AmdGpuResourceName]; gpu.Value() != 2 {
+		t.Fatalf("expected 2 amd.com/gpu, got %v", resources)
+	}
+	if _, ok := resources[GpuResourceName]; ok {
+		t.Fatalf("expected no nvidia.com/gpu, got %v", resources)
+	}
+}
+
+func TestGpuAccounting_MixedVendors(t *testing.T) {
+	node := newTestNode("mixed", 64, 256, nil)
+	node.Status.Allocatable[GpuResourceName] = *resource.NewQuantity(2, resource.DecimalSI)
+	node.Status.Allocatable[AmdGpuResourceName] = *resource.NewQuantity(2, resource.DecimalSI)
+
+	pod := newTestPod("mixed", 4, 16, "AMD-Instinct-MI210", 0)
+	pod.Spec.Containers[0].Resources.Requests[AmdGpuResourceName] = *resource.NewQuantity(1, resource.DecimalSI)
+
+	summary := map[string]int64{"NVIDIA-A100-PCIE-40GB": 2, "AMD-Instinct-MI210": 2}
+	nodes := nodeCapacities([]corev1.Node{node}, []corev1.Pod{pod}, map[string]map[string]int64{"mixed": summary})
+	if mi210 := nodes[0].Gpus["AMD-Instinct-MI210"]; mi210.Total != 2 || mi210.Used != 1 {
+		t.Fatalf("expected 1 of 2 MI210 used, got %+v", mi210)
+	}
+	if a100 := nodes[0].Gpus["NVIDIA-A100-PCIE-40GB"]; a100.Total != 2 || a100.Used != 0 {
+		t.Fatalf("expected 2 free A100, got %+v", a100)
+	}
+}
+
+func TestDescribeGpuVendors(t *testing.T) {
+	gpu := models.Gpu{
+		RocmVersion: "6.0.2",
+		Details:     []models.GpuDetail{{ProductName: "AMD Instinct MI210"}},
+	}
+	describeGpuVendors(&gpu)
+	if gpu.Runtime != "rocm" || gpu.RuntimeVersion != "6.0.2" {
+		t.Fatalf("expected the rocm runtime, got %q %q", gpu.Runtime, gpu.RuntimeVersion)
+	}
+	if detail := gpu.Details[0]; detail.Vendor != GpuVendorAmd || detail.ResourceName != AmdGpuResourceName {
+		t.Fatalf("expected an AMD gpu of amd.com/gpu, got %+v", detail)
+	}
+}
@@ -0,0 +1,198 @@
+package computing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
+	"github.com/robfig/cron/v3"
+	"github.com/swanchain/go-computing-provider/conf"
+	"github.com/swanchain/go-computing-provider/internal/db"
+	"github.com/swanchain/go-computing-provider/internal/models"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	// hubEventMaxAttempts is the deliveries of an event before it is dead-lettered
+	hubEventMaxAttempts = 20
+	// hubEventRetryDelay is the wait after the first failed delivery, it doubles with each attempt up to
+	// hubEventMaxRetryDelay
+	hubEventRetryDelay    = 10 * time.Second
+	hubEventMaxRetryDelay = time.Hour
+	// hubEventBatch is the events delivered by a round of the cron task
+	hubEventBatch = 100
+	// hubEventRetention is how long the delivered events are kept
+	hubEventRetention = 7 * 24 * time.Hour
+)
+
+// hubEvent is the body of an event sent to the orchestrator
+type hubEvent struct {
+	EventKey  string `json:"event_key"`
+	NodeId    string `json:"node_id"`
+	JobUuid   string `json:"job_uuid"`
+	TaskUuid  string `json:"task_uuid"`
+	EventType string `json:"event_type"`
+	Value     string `json:"value"`
+	Error     string `json:"error,omitempty"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// recordHubEvent adds an event of the job to the outbox, it is sent to HUB.EventUrl by the cron task
+func recordHubEvent(jobUuid, eventType, value, errMsg string) {
+	if conf.GetConfig() == nil || conf.GetConfig().HUB.EventUrl == "" || db.NewDbService() == nil {
+		return
+	}
+
+	var taskUuid string
+	if job, err := NewJobService().GetJobEntityByJobUuid(jobUuid); err == nil {
+		taskUuid = job.TaskUuid
+	}
+	recordTime := time.Now()
+	now := recordTime.Unix()
+	event := &models.HubEventEntity{
+		EventKey:    hubEventKey(jobUuid, eventType, value, recordTime.UnixNano()),
+		JobUuid:     jobUuid,
+		TaskUuid:    taskUuid,
+		EventType:   eventType,
+		Value:       value,
+		Error:       errMsg,
+		Status:      models.HUB_EVENT_PENDING_STATUS,
+		NextAttempt: now,
+		CreateTime:  now,
+		UpdateTime:  now,
+	}
+	if err := NewHubEventService().SaveHubEvent(event); err != nil {
+		logs.GetLogger().Errorf("jobUuid: %s, save %s event failed, error: %v", jobUuid, eventType, err)
+	}
+}
+
+func (task *CronTask) deliverHubEvents() {
+	c := cron.New(cron.WithSeconds())
+	c.AddFunc("0/10 * * * * ?", func() {
+		defer func() {
+			if err := recover(); err != nil {
+				logs.GetLogger().Errorf("deliverHubEvents catch panic error: %+v", err)
+			}
+		}()
+
+		if conf.GetConfig().HUB.EventUrl == "" {
+			return
+		}
+		now := time.Now()
+		eventService := NewHubEventService()
+		events, err := eventService.GetPendingHubEvents(now.Unix(), hubEventBatch)
+		if err != nil {
+			logs.GetLogger().Errorf("get pending hub events failed, error: %v", err)
+			return
+		}
+
+		// the events of a job are delivered in order, the later events of a job whose delivery fails wait for it
+		waiting := make(map[string]bool)
+		for _, event := range events {
+			if waiting[event.JobUuid] {
+				continue
+			}
+
+			event.Attempts++
+			event.UpdateTime = now.Unix()
+			retry, err := sendHubEvent(task.nodeId, event)
+			switch {
+			case err == nil:
+				event.Status = models.HUB_EVENT_DELIVERED_STATUS
+				event.LastError = ""
+			case !retry || event.Attempts >= hubEventMaxAttempts:
+				event.Status = models.HUB_EVENT_DEAD_STATUS
+				event.LastError = err.Error()
+				logs.GetLogger().Errorf("jobUuid: %s, %s event is dead after %d attempts, error: %v", event.JobUuid, event.EventType, event.Attempts, err)
+			default:
+				event.NextAttempt = now.Add(hubEventBackoff(event.Attempts)).Unix()
+				event.LastError = err.Error()
+				waiting[event.JobUuid] = true
+			}
+			if err = eventService.UpdateHubEvent(event); err != nil {
+				logs.GetLogger().Errorf("jobUuid: %s, update %s event failed, error: %v", event.JobUuid, event.EventType, err)
+			}
+		}
+
+		if err = eventService.DeleteDeliveredHubEvents(now.Add(-hubEventRetention).Unix()); err != nil {
+			logs.GetLogger().Errorf("delete delivered hub events failed, error: %v", err)
+		}
+	})
+	c.Start()
+}
+
+// sendHubEvent posts the event to the orchestrator, it tells if a failed delivery is worth retrying
+func sendHubEvent(nodeId string, event *models.HubEventEntity) (bool, error) {
+	body, err := json.Marshal(hubEvent{
+		EventKey:  event.EventKey,
+		NodeId:    nodeId,
+		JobUuid:   event.JobUuid,
+		TaskUuid:  event.TaskUuid,
+		EventType: event.EventType,
+		Value:     event.Value,
+		Error:     event.Error,
+		Timestamp: event.CreateTime,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, conf.GetConfig().HUB.EventUrl, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", event.EventKey)
+	req.Header.Set("Authorization", "Bearer "+conf.GetConfig().HUB.AccessToken)
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	retry, delivered := hubEventOutcome(resp.StatusCode)
+	if delivered {
+		return false, nil
+	}
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return retry, fmt.Errorf("orchestrator response status: %d, body: %s", resp.StatusCode, string(respBody))
+}
+
+// hubEventOutcome tells from the status code of the orchestrator if the event is delivered, and if not whether
+// to retry it: a conflict is an event the orchestrator has already seen, and only an event the orchestrator
+// refuses as malformed is not retried. The other errors, e.g. an expired access token or an endpoint that is
+// not deployed yet, are retried until the event is dead.
+func hubEventOutcome(statusCode int) (retry bool, delivered bool) {
+	switch statusCode {
+	case http.StatusConflict:
+		return false, true
+	case http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity:
+		return false, false
+	}
+	if statusCode >= 200 && statusCode < 300 {
+		return false, true
+	}
+	return true, false
+}
+
+// hubEventKey is the idempotency key of an event, the sequence tells apart the events of a job that repeat, e.g.
+// a job entering a status again when it is redeployed
+func hubEventKey(jobUuid, eventType, value string, sequence int64) string {
+	return fmt.Sprintf("%s:%s:%s:%d", jobUuid, eventType, value, sequence)
+}
+
+// hubEventBackoff is the wait before the next delivery of an event after the given failed attempts
+func hubEventBackoff(attempts int) time.Duration {
+	delay := hubEventRetryDelay
+	for i := 1; i < attempts && delay < hubEventMaxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > hubEventMaxRetryDelay {
+		delay = hubEventMaxRetryDelay
+	}
+	return delay
+}
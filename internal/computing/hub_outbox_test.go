@@ -0,0 +1,89 @@
+package computing
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/swanchain/go-computing-provider/internal/db"
+	"github.com/swanchain/go-computing-provider/internal/models"
+)
+
+func TestHubEventOutcome(t *testing.T) {
+	for _, tc := range []struct {
+		statusCode       int
+		retry, delivered bool
+	}{
+		{http.StatusOK, false, true},
+		{http.StatusAccepted, false, true},
+		{http.StatusConflict, false, true},
+		{http.StatusBadRequest, false, false},
+		{http.StatusUnprocessableEntity, false, false},
+		{http.StatusUnauthorized, true, false},
+		{http.StatusNotFound, true, false},
+		{http.StatusTooManyRequests, true, false},
+		{http.StatusRequestTimeout, true, false},
+		{http.StatusBadGateway, true, false},
+	} {
+		retry, delivered := hubEventOutcome(tc.statusCode)
+		if retry != tc.retry || delivered != tc.delivered {
+			t.Errorf("status %d: got retry %t delivered %t, expected retry %t delivered %t",
+				tc.statusCode, retry, delivered, tc.retry, tc.delivered)
+		}
+	}
+}
+
+func TestHubEventBackoff(t *testing.T) {
+	for attempts, expected := range map[int]time.Duration{
+		1:  10 * time.Second,
+		2:  20 * time.Second,
+		4:  80 * time.Second,
+		10: time.Hour,
+		50: time.Hour,
+	} {
+		if delay := hubEventBackoff(attempts); delay != expected {
+			t.Errorf("hubEventBackoff(%d) = %v, expected %v", attempts, delay, expected)
+		}
+	}
+}
+
+func TestGetPendingHubEvents(t *testing.T) {
+	db.InitDb(t.TempDir())
+	eventService := NewHubEventService()
+	now := time.Now().Unix()
+	for i, event := range []*models.HubEventEntity{
+		{JobUuid: "job-1", NextAttempt: now - 10},
+		{JobUuid: "job-2", NextAttempt: now + 60},
+		// the later event of job-2 waits for the retry of the first one
+		{JobUuid: "job-2", NextAttempt: now},
+		{JobUuid: "job-3", NextAttempt: now, Status: models.HUB_EVENT_DEAD_STATUS},
+		{JobUuid: "job-4", NextAttempt: now},
+	} {
+		event.EventKey = hubEventKey(event.JobUuid, models.HUB_EVENT_JOB_STATUS, "running", int64(i))
+		if event.Status == 0 {
+			event.Status = models.HUB_EVENT_PENDING_STATUS
+		}
+		if err := eventService.SaveHubEvent(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	events, err := eventService.GetPendingHubEvents(now, hubEventBatch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var jobs []string
+	for _, event := range events {
+		jobs = append(jobs, event.JobUuid)
+	}
+	if len(jobs) != 2 || jobs[0] != "job-1" || jobs[1] != "job-4" {
+		t.Fatalf("expected the due events of job-1 and job-4, got %v", jobs)
+	}
+}
+
+func TestHubEventKey(t *testing.T) {
+	// a job entering the same status again is a new event
+	if hubEventKey("job-1", models.HUB_EVENT_JOB_STATUS, "running", 1) == hubEventKey("job-1", models.HUB_EVENT_JOB_STATUS, "running", 2) {
+		t.Fatal("expected the sequence to tell the events apart")
+	}
+}
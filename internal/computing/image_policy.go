@@ -0,0 +1,353 @@
+package computing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/distribution/reference"
+	"github.com/docker/docker/api/types"
+	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
+	"github.com/swanchain/go-computing-provider/conf"
+	"github.com/swanchain/go-computing-provider/internal/models"
+	"github.com/swanchain/go-computing-provider/internal/yaml"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// errImageRejected is the error of a space whose image breaks the image policy of the computing provider
+var errImageRejected = errors.New("the image is rejected by the image policy")
+
+// maxReportedVulnerabilities is the vulnerabilities named in the reason of a rejected image
+const maxReportedVulnerabilities = 5
+
+// policyImage is an image of the space checked by the image policy
+type policyImage struct {
+	name string
+	// base is an image the space image is built from, only where it comes from is checked
+	base bool
+	// built is the image built from the space, it is in the registry of the computing provider
+	built bool
+	// ports are the container ports the space declares for the image
+	ports []int32
+}
+
+// skipImageCheck skips the check without a policy
+func (sd *spaceDeployment) skipImageCheck() bool {
+	return !conf.GetConfig().IMAGE_POLICY.Enable
+}
+
+// checkImages checks the images of the space against the image policy, a rejected image is reported to the
+// orchestrator with the reason and fails the deployment. The images the space runs are saved pinned to the digest
+// they were checked with, so that a tag moved in the registry afterwards is not deployed.
+func (sd *spaceDeployment) checkImages(ctx context.Context) error {
+	images, err := sd.policyImages()
+	if err != nil {
+		return err
+	}
+
+	policy := conf.GetConfig().IMAGE_POLICY
+	imageDigests := make(map[string]string)
+	for _, image := range images {
+		reason, pinned, err := checkPolicyImage(ctx, policy, image)
+		if err != nil {
+			return fmt.Errorf("check image %s, error: %v", image.name, err)
+		}
+		if reason != "" {
+			logs.GetLogger().Warnf("jobUuid: %s, image %s is rejected, reason: %s", sd.job.JobUuid, image.name, reason)
+			recordHubEvent(sd.job.JobUuid, models.HUB_EVENT_IMAGE_REJECTED, image.name, reason)
+			return fmt.Errorf("%w, image: %s, reason: %s", errImageRejected, image.name, reason)
+		}
+		if !image.base && pinned != "" {
+			imageDigests[image.name] = pinned
+		}
+	}
+
+	data, err := json.Marshal(imageDigests)
+	if err != nil {
+		return err
+	}
+	sd.job.ImageDigests = string(data)
+	sd.save(map[string]any{"image_digests": sd.job.ImageDigests})
+	return nil
+}
+
+// imageDigests is the images of the job pinned to their digests by the image policy, none when it did not check them
+func (sd *spaceDeployment) imageDigests() map[string]string {
+	imageDigests := make(map[string]string)
+	if sd.job.ImageDigests == "" || sd.skipImageCheck() {
+		return imageDigests
+	}
+	if err := json.Unmarshal([]byte(sd.job.ImageDigests), &imageDigests); err != nil {
+		logs.GetLogger().Errorf("jobUuid: %s, parse the image digests failed, error: %v", sd.job.JobUuid, err)
+	}
+	return imageDigests
+}
+
+// policyImages is the images of a yaml space, the base images and the built image of a Dockerfile space, or the
+// base images of the image a model space is built into while deploying
+func (sd *spaceDeployment) policyImages() ([]policyImage, error) {
+	var images []policyImage
+	if sd.modelsSettingFile != "" {
+		modelInfo, err := readModelInference(sd.modelsSettingFile)
+		if err != nil {
+			return nil, err
+		}
+		dockerfile, err := os.ReadFile(filepath.Join(inferenceModelPath(), "docker_images", modelInfo.Framework, "Dockerfile"))
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range dockerfileBaseImages(dockerfile) {
+			images = append(images, policyImage{name: name, base: true})
+		}
+		return images, nil
+	}
+	if sd.yamlPath != "" {
+		containerResources, err := yaml.HandlerYaml(sd.yamlPath)
+		if err != nil {
+			return nil, err
+		}
+		for _, cr := range containerResources {
+			for _, container := range append(slices.Clone(cr.Depends), cr) {
+				image := policyImage{name: container.ImageName}
+				for _, port := range container.Ports {
+					image.ports = append(image.ports, port.ContainerPort)
+				}
+				images = append(images, image)
+			}
+		}
+		return images, nil
+	}
+
+	dockerfile, err := os.ReadFile(spaceDockerfilePath(sd.imagePath))
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range dockerfileBaseImages(dockerfile) {
+		images = append(images, policyImage{name: name, base: true})
+	}
+	return append(images, policyImage{name: sd.job.ImageName, built: true}), nil
+}
+
+// checkPolicyImage returns why the policy rejects the image, empty when the image is accepted, and the image pinned
+// to the digest it was checked with. The image is pulled to be inspected.
+func checkPolicyImage(ctx context.Context, policy conf.IMAGE_POLICY, image policyImage) (string, string, error) {
+	if !image.built {
+		if reason := checkImageRegistry(policy, image.name); reason != "" {
+			return reason, "", nil
+		}
+	}
+
+	dockerService := NewDockerService()
+	if err := dockerService.PullImage(image.name); err != nil {
+		return "", "", err
+	}
+	inspect, err := dockerService.InspectImage(ctx, image.name)
+	if err != nil {
+		return "", "", err
+	}
+	pinned := pinnedImageName(image.name, inspect.RepoDigests)
+	if reason := checkImageDigest(policy, inspect); reason != "" {
+		return reason, pinned, nil
+	}
+	if image.base {
+		return "", pinned, nil
+	}
+	if reason := checkImageConfig(policy, inspect, image.ports); reason != "" {
+		return reason, pinned, nil
+	}
+
+	if policy.VulnDbPath == "" {
+		return "", pinned, nil
+	}
+	vulnerabilities, err := scanImageVulnerabilities(ctx, policy, image.name)
+	if err != nil {
+		return "", "", err
+	}
+	if len(vulnerabilities) > 0 {
+		named := vulnerabilities[:min(len(vulnerabilities), maxReportedVulnerabilities)]
+		return fmt.Sprintf("%d vulnerabilities of severity %s, e.g. %s", len(vulnerabilities),
+			strings.Join(vulnSeverities(policy), ","), strings.Join(named, ", ")), pinned, nil
+	}
+	return "", pinned, nil
+}
+
+// pinnedImageName is the image as <name>@<digest> by the repo digest of its repository, empty when the image has
+// none, e.g. an image built here and not pushed
+func pinnedImageName(imageName string, repoDigests []string) string {
+	named, err := reference.ParseNormalizedNamed(imageName)
+	if err != nil {
+		return ""
+	}
+	for _, repoDigest := range repoDigests {
+		digested, err := reference.ParseNormalizedNamed(repoDigest)
+		if err != nil || digested.Name() != named.Name() {
+			continue
+		}
+		if canonical, ok := digested.(reference.Canonical); ok {
+			return reference.FamiliarString(canonical)
+		}
+	}
+	return ""
+}
+
+// checkImageRegistry returns why the registry of the image is refused, empty when it is allowed
+func checkImageRegistry(policy conf.IMAGE_POLICY, imageName string) string {
+	named, err := reference.ParseNormalizedNamed(imageName)
+	if err != nil {
+		return fmt.Sprintf("%q is not an image reference", imageName)
+	}
+	registry := strings.ToLower(reference.Domain(named))
+	matches := func(registries []string) bool {
+		return slices.ContainsFunc(registries, func(r string) bool { return strings.EqualFold(r, registry) })
+	}
+	if matches(policy.DeniedRegistries) {
+		return fmt.Sprintf("the registry %s is denied", registry)
+	}
+	if len(policy.AllowedRegistries) > 0 && !matches(policy.AllowedRegistries) {
+		return fmt.Sprintf("the registry %s is not allowed", registry)
+	}
+	return ""
+}
+
+// checkImageDigest returns why the image is refused by its id or manifest digest, empty when it is not denied
+func checkImageDigest(policy conf.IMAGE_POLICY, inspect types.ImageInspect) string {
+	digests := []string{inspect.ID}
+	for _, repoDigest := range inspect.RepoDigests {
+		if _, digest, ok := strings.Cut(repoDigest, "@"); ok {
+			digests = append(digests, digest)
+		}
+	}
+	for _, digest := range digests {
+		if slices.Contains(policy.DeniedDigests, digest) {
+			return fmt.Sprintf("the digest %s is denied", digest)
+		}
+	}
+	return ""
+}
+
+// checkImageConfig returns why the size, ports or user of the image are refused, empty when they are accepted
+func checkImageConfig(policy conf.IMAGE_POLICY, inspect types.ImageInspect, ports []int32) string {
+	if policy.MaxSize > 0 && inspect.Size > policy.MaxSize*1024*1024*1024 {
+		return fmt.Sprintf("the image is %.2f GiB, more than %d GiB", float64(inspect.Size)/1024/1024/1024, policy.MaxSize)
+	}
+
+	if inspect.Config == nil {
+		return ""
+	}
+	if policy.DenyPrivilegedPorts {
+		for port := range inspect.Config.ExposedPorts {
+			ports = append(ports, int32(port.Int()))
+		}
+		for _, port := range ports {
+			if port > 0 && port < 1024 {
+				return fmt.Sprintf("the port %d is privileged", port)
+			}
+		}
+	}
+	if policy.DenyRootUser && isRootUser(inspect.Config.User) {
+		return "the image runs as root"
+	}
+	return ""
+}
+
+// isRootUser tells if the USER of an image is root, an image without USER runs as root
+func isRootUser(user string) bool {
+	name, _, _ := strings.Cut(user, ":")
+	return name == "" || name == "root" || name == "0"
+}
+
+// dockerfileBaseImages is the images the Dockerfile builds from, without the earlier stages of a multi-stage build
+// and scratch. The build args of a FROM take the defaults declared before the first FROM.
+func dockerfileBaseImages(dockerfile []byte) []string {
+	args := make(map[string]string)
+	stages := map[string]bool{"scratch": true}
+	var images []string
+	var fromSeen bool
+	for _, line := range strings.Split(string(dockerfile), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		instruction := strings.ToUpper(fields[0])
+		if instruction == "ARG" && !fromSeen {
+			name, value, _ := strings.Cut(fields[1], "=")
+			args[name] = strings.Trim(value, `"'`)
+			continue
+		}
+		if instruction != "FROM" {
+			continue
+		}
+		fromSeen = true
+
+		fields = fields[1:]
+		for len(fields) > 0 && strings.HasPrefix(fields[0], "--") {
+			fields = fields[1:]
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		image := os.Expand(fields[0], func(name string) string { return args[name] })
+		if !stages[strings.ToLower(image)] && !slices.Contains(images, image) {
+			images = append(images, image)
+		}
+		if len(fields) == 3 && strings.EqualFold(fields[1], "AS") {
+			stages[strings.ToLower(fields[2])] = true
+		}
+	}
+	return images
+}
+
+// trivyReport is the part of the json report of trivy listing the vulnerabilities found
+type trivyReport struct {
+	Results []struct {
+		Vulnerabilities []struct {
+			VulnerabilityID string
+			PkgName         string
+			Severity        string
+		}
+	}
+}
+
+func vulnSeverities(policy conf.IMAGE_POLICY) []string {
+	if len(policy.VulnSeverities) == 0 {
+		return []string{"CRITICAL"}
+	}
+	return policy.VulnSeverities
+}
+
+// scanImageVulnerabilities scans the local image with trivy against the mirrored vulnerability database, offline.
+// It returns the vulnerabilities found of the severities refused.
+func scanImageVulnerabilities(ctx context.Context, policy conf.IMAGE_POLICY, imageName string) ([]string, error) {
+	cmd := exec.CommandContext(ctx, "trivy", "image", "--quiet", "--format", "json", "--scanners", "vuln",
+		"--cache-dir", policy.VulnDbPath, "--skip-db-update", "--skip-java-db-update", "--offline-scan",
+		"--severity", strings.Join(vulnSeverities(policy), ","), imageName)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("trivy scan failed, output: %s, error: %v", strings.TrimSpace(stderr.String()), err)
+	}
+	return parseTrivyReport(output)
+}
+
+// parseTrivyReport lists the vulnerabilities of the report, each once, as "<id> (<package>)"
+func parseTrivyReport(output []byte) ([]string, error) {
+	var report trivyReport
+	if err := json.Unmarshal(output, &report); err != nil {
+		return nil, fmt.Errorf("parse the trivy report, error: %v", err)
+	}
+	var vulnerabilities []string
+	for _, result := range report.Results {
+		for _, vulnerability := range result.Vulnerabilities {
+			found := fmt.Sprintf("%s (%s)", vulnerability.VulnerabilityID, vulnerability.PkgName)
+			if !slices.Contains(vulnerabilities, found) {
+				vulnerabilities = append(vulnerabilities, found)
+			}
+		}
+	}
+	return vulnerabilities, nil
+}
@@ -0,0 +1,136 @@
+package computing
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/go-connections/nat"
+	"github.com/swanchain/go-computing-provider/conf"
+)
+
+func TestDockerfileBaseImages(t *testing.T) {
+	dockerfile := `ARG PYTHON=3.11
+FROM --platform=linux/amd64 python:${PYTHON}-slim AS builder
+RUN pip wheel -r requirements.txt
+ARG PYTHON=3.12
+FROM ghcr.io/acme/runtime:1.0
+COPY --from=builder /wheels /wheels
+from builder AS test
+FROM scratch
+`
+	images := dockerfileBaseImages([]byte(dockerfile))
+	if !slices.Equal(images, []string{"python:3.11-slim", "ghcr.io/acme/runtime:1.0"}) {
+		t.Errorf("expected the two base images, got %v", images)
+	}
+}
+
+func TestCheckImageRegistry(t *testing.T) {
+	policy := conf.IMAGE_POLICY{AllowedRegistries: []string{"docker.io", "GHCR.io"}, DeniedRegistries: []string{"quay.io"}}
+	for image, allowed := range map[string]bool{
+		"python:3.11":                  true,
+		"ghcr.io/acme/app:1.0":         true,
+		"quay.io/acme/app:1.0":         false,
+		"registry.example.com/app:1.0": false,
+		"Invalid Image":                false,
+	} {
+		if reason := checkImageRegistry(policy, image); (reason == "") != allowed {
+			t.Errorf("expected %s allowed %v, got reason %q", image, allowed, reason)
+		}
+	}
+
+	if reason := checkImageRegistry(conf.IMAGE_POLICY{}, "registry.example.com/app:1.0"); reason != "" {
+		t.Errorf("expected all the registries allowed without a rule, got %q", reason)
+	}
+}
+
+func TestCheckImageDigest(t *testing.T) {
+	denied := "sha256:" + strings.Repeat("a", 64)
+	policy := conf.IMAGE_POLICY{DeniedDigests: []string{denied}}
+	if reason := checkImageDigest(policy, types.ImageInspect{ID: denied}); reason == "" {
+		t.Error("expected the image id to be denied")
+	}
+	if reason := checkImageDigest(policy, types.ImageInspect{ID: "sha256:" + strings.Repeat("b", 64), RepoDigests: []string{"python@" + denied}}); reason == "" {
+		t.Error("expected the manifest digest to be denied")
+	}
+	if reason := checkImageDigest(policy, types.ImageInspect{ID: "sha256:" + strings.Repeat("b", 64)}); reason != "" {
+		t.Errorf("expected another image to be accepted, got %q", reason)
+	}
+}
+
+func TestPinnedImageName(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("a", 64)
+	for _, tc := range []struct {
+		name        string
+		imageName   string
+		repoDigests []string
+		expected    string
+	}{
+		{"docker hub", "python:3.11", []string{"python@" + digest}, "python@" + digest},
+		{"registry", "registry.example.com/team/app:v1", []string{"registry.example.com/team/app@" + digest}, "registry.example.com/team/app@" + digest},
+		{"other repository", "python:3.11", []string{"registry.example.com/python@" + digest}, ""},
+		{"not pushed", "space-01:abc", nil, ""},
+	} {
+		if pinned := pinnedImageName(tc.imageName, tc.repoDigests); pinned != tc.expected {
+			t.Errorf("%s: expected %q, got %q", tc.name, tc.expected, pinned)
+		}
+	}
+}
+
+func TestCheckImageConfig(t *testing.T) {
+	policy := conf.IMAGE_POLICY{MaxSize: 2, DenyPrivilegedPorts: true, DenyRootUser: true}
+	image := func(size int64, user string, ports ...nat.Port) types.ImageInspect {
+		exposed := nat.PortSet{}
+		for _, port := range ports {
+			exposed[port] = struct{}{}
+		}
+		return types.ImageInspect{Size: size, Config: &container.Config{User: user, ExposedPorts: exposed}}
+	}
+
+	if reason := checkImageConfig(policy, image(1<<30, "app", "8080/tcp"), []int32{7860}); reason != "" {
+		t.Errorf("expected the image to be accepted, got %q", reason)
+	}
+	for name, inspect := range map[string]types.ImageInspect{
+		"too large":        image(3<<30, "app"),
+		"privileged port":  image(1<<30, "app", "80/tcp"),
+		"root user":        image(1<<30, ""),
+		"root uid":         image(1<<30, "0:0"),
+		"named root group": image(1<<30, "root:app"),
+	} {
+		if reason := checkImageConfig(policy, inspect, nil); reason == "" {
+			t.Errorf("expected the image %s to be rejected", name)
+		}
+	}
+	if reason := checkImageConfig(policy, image(1<<30, "1000"), []int32{443}); reason == "" {
+		t.Error("expected the privileged port declared by the space to be rejected")
+	}
+	if reason := checkImageConfig(conf.IMAGE_POLICY{}, image(3<<30, "", "80/tcp"), nil); reason != "" {
+		t.Errorf("expected the image to be accepted without a rule, got %q", reason)
+	}
+}
+
+func TestParseTrivyReport(t *testing.T) {
+	report := `{"Results": [
+		{"Target": "python:3.11 (debian 12.5)", "Vulnerabilities": [
+			{"VulnerabilityID": "CVE-2024-0001", "PkgName": "openssl", "Severity": "CRITICAL"},
+			{"VulnerabilityID": "CVE-2024-0001", "PkgName": "openssl", "Severity": "CRITICAL"}
+		]},
+		{"Target": "Python", "Vulnerabilities": [
+			{"VulnerabilityID": "CVE-2024-0002", "PkgName": "requests", "Severity": "CRITICAL"}
+		]},
+		{"Target": "Node.js"}
+	]}`
+	vulnerabilities, err := parseTrivyReport([]byte(report))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(vulnerabilities, []string{"CVE-2024-0001 (openssl)", "CVE-2024-0002 (requests)"}) {
+		t.Errorf("expected the two vulnerabilities once, got %v", vulnerabilities)
+	}
+
+	if _, err = parseTrivyReport([]byte("trivy: command failed")); err == nil {
+		t.Error("expected an output that is not a report to fail")
+	}
+}
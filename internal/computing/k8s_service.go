@@ -22,9 +22,11 @@ import (
 
 	appV1 "k8s.io/api/apps/v1"
 	coreV1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
 
 	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
 	networkingv1 "k8s.io/api/networking/v1"
+	nodeV1 "k8s.io/api/node/v1"
 	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
@@ -77,6 +79,45 @@ func (s *K8sService) CreateDeployment(ctx context.Context, nameSpace string, dep
 	return s.k8sClient.AppsV1().Deployments(nameSpace).Create(ctx, deploy, metaV1.CreateOptions{})
 }
 
+func (s *K8sService) GetDeployment(ctx context.Context, namespace, deploymentName string) (*appV1.Deployment, error) {
+	return s.k8sClient.AppsV1().Deployments(namespace).Get(ctx, deploymentName, metaV1.GetOptions{})
+}
+
+func (s *K8sService) UpdateDeployment(ctx context.Context, namespace string, deploy *appV1.Deployment) (*appV1.Deployment, error) {
+	return s.k8sClient.AppsV1().Deployments(namespace).Update(ctx, deploy, metaV1.UpdateOptions{})
+}
+
+// WaitForDeploymentRollout waits until all the replicas of the deployment run its latest revision and are ready
+func (s *K8sService) WaitForDeploymentRollout(ctx context.Context, namespace, deploymentName string) error {
+	return wait.PollUntilContextCancel(ctx, 5*time.Second, true, func(ctx context.Context) (bool, error) {
+		deployment, err := s.GetDeployment(ctx, namespace, deploymentName)
+		if err != nil {
+			return false, err
+		}
+		return deploymentRolledOut(deployment)
+	})
+}
+
+// deploymentRolledOut tells if the rollout of the deployment is complete, as kubectl rollout status does. A
+// rollout past the progress deadline of the deployment is an error.
+func deploymentRolledOut(deployment *appV1.Deployment) (bool, error) {
+	if deployment.Generation > deployment.Status.ObservedGeneration {
+		return false, nil
+	}
+	for _, condition := range deployment.Status.Conditions {
+		if condition.Type == appV1.DeploymentProgressing && condition.Reason == "ProgressDeadlineExceeded" {
+			return false, fmt.Errorf("deployment %s exceeded its progress deadline: %s", deployment.Name, condition.Message)
+		}
+	}
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+	status := deployment.Status
+	return status.UpdatedReplicas >= replicas && status.Replicas <= status.UpdatedReplicas &&
+		status.AvailableReplicas >= status.UpdatedReplicas, nil
+}
+
 func (s *K8sService) DeleteDeployment(ctx context.Context, namespace, deploymentName string) error {
 	return s.k8sClient.AppsV1().Deployments(namespace).Delete(ctx, deploymentName, metaV1.DeleteOptions{})
 }
@@ -127,7 +168,27 @@ func (s *K8sService) GetServiceByName(ctx context.Context, namespace, serviceNam
 }
 
 func (s *K8sService) CreateService(ctx context.Context, nameSpace, spaceUuid string, containerPort int32) (result *coreV1.Service, err error) {
-	service := &coreV1.Service{
+	return s.k8sClient.CoreV1().Services(nameSpace).Create(ctx, spaceService(nameSpace, spaceUuid, containerPort), metaV1.CreateOptions{})
+}
+
+// ApplyService creates the service of the space, or points the existing one to the new container port
+func (s *K8sService) ApplyService(ctx context.Context, nameSpace, spaceUuid string, containerPort int32) (*coreV1.Service, error) {
+	services := s.k8sClient.CoreV1().Services(nameSpace)
+	service := spaceService(nameSpace, spaceUuid, containerPort)
+	current, err := services.Get(ctx, service.Name, metaV1.GetOptions{})
+	if err != nil {
+		if !k8sErrors.IsNotFound(err) {
+			return nil, err
+		}
+		return services.Create(ctx, service, metaV1.CreateOptions{})
+	}
+	current.Spec.Ports = service.Spec.Ports
+	current.Spec.Selector = service.Spec.Selector
+	return services.Update(ctx, current, metaV1.UpdateOptions{})
+}
+
+func spaceService(nameSpace, spaceUuid string, containerPort int32) *coreV1.Service {
+	return &coreV1.Service{
 		TypeMeta: metaV1.TypeMeta{
 			Kind:       "Service",
 			APIVersion: "v1",
@@ -148,7 +209,6 @@ func (s *K8sService) CreateService(ctx context.Context, nameSpace, spaceUuid str
 			},
 		},
 	}
-	return s.k8sClient.CoreV1().Services(nameSpace).Create(ctx, service, metaV1.CreateOptions{})
 }
 
 func (s *K8sService) CreateServiceByNodePort(ctx context.Context, nameSpace, taskUuid string, containerPort int32) (result *coreV1.Service, err error) {
@@ -182,8 +242,28 @@ func (s *K8sService) DeleteService(ctx context.Context, namespace, serviceName s
 }
 
 func (s *K8sService) CreateIngress(ctx context.Context, k8sNameSpace, spaceUuid, hostName string, port int32) (*networkingv1.Ingress, error) {
+	return s.k8sClient.NetworkingV1().Ingresses(k8sNameSpace).Create(ctx, spaceIngress(spaceUuid, hostName, port), metaV1.CreateOptions{})
+}
+
+// ApplyIngress creates the ingress of the space, or points the existing one to the new port of the service
+func (s *K8sService) ApplyIngress(ctx context.Context, k8sNameSpace, spaceUuid, hostName string, port int32) (*networkingv1.Ingress, error) {
+	ingresses := s.k8sClient.NetworkingV1().Ingresses(k8sNameSpace)
+	ingress := spaceIngress(spaceUuid, hostName, port)
+	current, err := ingresses.Get(ctx, ingress.Name, metaV1.GetOptions{})
+	if err != nil {
+		if !k8sErrors.IsNotFound(err) {
+			return nil, err
+		}
+		return ingresses.Create(ctx, ingress, metaV1.CreateOptions{})
+	}
+	current.Annotations = ingress.Annotations
+	current.Spec = ingress.Spec
+	return ingresses.Update(ctx, current, metaV1.UpdateOptions{})
+}
+
+func spaceIngress(spaceUuid, hostName string, port int32) *networkingv1.Ingress {
 	var ingressClassName = "nginx"
-	ingress := &networkingv1.Ingress{
+	return &networkingv1.Ingress{
 		ObjectMeta: metaV1.ObjectMeta{
 			Name: constants.K8S_INGRESS_NAME_PREFIX + spaceUuid,
 			Annotations: map[string]string{
@@ -217,8 +297,6 @@ func (s *K8sService) CreateIngress(ctx context.Context, k8sNameSpace, spaceUuid,
 			},
 		},
 	}
-
-	return s.k8sClient.NetworkingV1().Ingresses(k8sNameSpace).Create(ctx, ingress, metaV1.CreateOptions{})
 }
 
 func (s *K8sService) DeleteIngress(ctx context.Context, nameSpace, ingressName string) error {
@@ -243,7 +321,42 @@ func (s *K8sService) CreateConfigMap(ctx context.Context, k8sNameSpace, spaceUui
 			configName: string(iniData),
 		},
 	}
-	return s.k8sClient.CoreV1().ConfigMaps(k8sNameSpace).Create(ctx, configMap, metaV1.CreateOptions{})
+	// a redeployed space updates the config map of the running one
+	current, err := s.k8sClient.CoreV1().ConfigMaps(k8sNameSpace).Get(ctx, configMap.Name, metaV1.GetOptions{})
+	if err != nil {
+		if !k8sErrors.IsNotFound(err) {
+			return nil, err
+		}
+		return s.k8sClient.CoreV1().ConfigMaps(k8sNameSpace).Create(ctx, configMap, metaV1.CreateOptions{})
+	}
+	current.Data = configMap.Data
+	return s.k8sClient.CoreV1().ConfigMaps(k8sNameSpace).Update(ctx, current, metaV1.UpdateOptions{})
+}
+
+// ApplyPersistentVolumeClaim creates the claim, or expands the existing one to a larger size, a claim is never
+// shrunk so its data is kept
+func (s *K8sService) ApplyPersistentVolumeClaim(ctx context.Context, namespace string, claim *coreV1.PersistentVolumeClaim) (*coreV1.PersistentVolumeClaim, error) {
+	current, err := s.k8sClient.CoreV1().PersistentVolumeClaims(namespace).Get(ctx, claim.Name, metaV1.GetOptions{})
+	if err != nil {
+		if !k8sErrors.IsNotFound(err) {
+			return nil, err
+		}
+		return s.k8sClient.CoreV1().PersistentVolumeClaims(namespace).Create(ctx, claim, metaV1.CreateOptions{})
+	}
+
+	size := claim.Spec.Resources.Requests[coreV1.ResourceStorage]
+	currentSize := current.Spec.Resources.Requests[coreV1.ResourceStorage]
+	if size.Cmp(currentSize) <= 0 {
+		return current, nil
+	}
+	current.Spec.Resources.Requests[coreV1.ResourceStorage] = size
+	return s.k8sClient.CoreV1().PersistentVolumeClaims(namespace).Update(ctx, current, metaV1.UpdateOptions{})
+}
+
+func (s *K8sService) DeletePersistentVolumeClaims(ctx context.Context, namespace, spaceUuid string) error {
+	return s.k8sClient.CoreV1().PersistentVolumeClaims(namespace).DeleteCollection(ctx, metaV1.DeleteOptions{}, metaV1.ListOptions{
+		LabelSelector: fmt.Sprintf("lad_app=%s", spaceUuid),
+	})
 }
 
 func (s *K8sService) GetPods(namespace, spaceUuid string) (bool, error) {
@@ -264,37 +377,131 @@ func (s *K8sService) GetPods(namespace, spaceUuid string) (bool, error) {
 	return false, nil
 }
 
-func (s *K8sService) CreateNetworkPolicy(ctx context.Context, namespace string) (*networkingv1.NetworkPolicy, error) {
-	networkPolicy := &networkingv1.NetworkPolicy{
-		ObjectMeta: metaV1.ObjectMeta{
-			Name:      namespace + "-" + generateString(4),
-			Namespace: namespace,
-		},
-		Spec: networkingv1.NetworkPolicySpec{
-			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
-			Ingress: []networkingv1.NetworkPolicyIngressRule{
-				{
-					From: []networkingv1.NetworkPolicyPeer{
-						{
-							NamespaceSelector: &metaV1.LabelSelector{
-								MatchLabels: map[string]string{
-									"kubernetes.io/metadata.name": "ingress-nginx",
-								},
-							},
-						},
-					},
-				},
-			},
-		},
+// ApplyNetworkPolicy creates the network policy, or updates the existing network policy of the same name
+func (s *K8sService) ApplyNetworkPolicy(ctx context.Context, networkPolicy *networkingv1.NetworkPolicy) error {
+	networkPolicies := s.k8sClient.NetworkingV1().NetworkPolicies(networkPolicy.Namespace)
+	current, err := networkPolicies.Get(ctx, networkPolicy.Name, metaV1.GetOptions{})
+	if err != nil {
+		if !k8sErrors.IsNotFound(err) {
+			return err
+		}
+		_, err = networkPolicies.Create(ctx, networkPolicy, metaV1.CreateOptions{})
+		return err
+	}
+	current.Spec = networkPolicy.Spec
+	_, err = networkPolicies.Update(ctx, current, metaV1.UpdateOptions{})
+	return err
+}
+
+func (s *K8sService) ListNetworkPolicies(ctx context.Context, namespace string) ([]networkingv1.NetworkPolicy, error) {
+	networkPolicies, err := s.k8sClient.NetworkingV1().NetworkPolicies(namespace).List(ctx, metaV1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return networkPolicies.Items, nil
+}
+
+func (s *K8sService) DeleteNetworkPolicy(ctx context.Context, namespace, name string) error {
+	return s.k8sClient.NetworkingV1().NetworkPolicies(namespace).Delete(ctx, name, metaV1.DeleteOptions{})
+}
+
+func (s *K8sService) GetRuntimeClass(ctx context.Context, name string) (*nodeV1.RuntimeClass, error) {
+	return s.k8sClient.NodeV1().RuntimeClasses().Get(ctx, name, metaV1.GetOptions{})
+}
+
+func (s *K8sService) GetResourceQuota(ctx context.Context, namespace, name string) (*coreV1.ResourceQuota, error) {
+	return s.k8sClient.CoreV1().ResourceQuotas(namespace).Get(ctx, name, metaV1.GetOptions{})
+}
+
+// ApplyResourceQuota creates the quota, or updates the existing quota of the same name
+func (s *K8sService) ApplyResourceQuota(ctx context.Context, quota *coreV1.ResourceQuota) error {
+	quotas := s.k8sClient.CoreV1().ResourceQuotas(quota.Namespace)
+	current, err := quotas.Get(ctx, quota.Name, metaV1.GetOptions{})
+	if err != nil {
+		if !k8sErrors.IsNotFound(err) {
+			return err
+		}
+		_, err = quotas.Create(ctx, quota, metaV1.CreateOptions{})
+		return err
+	}
+	current.Spec = quota.Spec
+	_, err = quotas.Update(ctx, current, metaV1.UpdateOptions{})
+	return err
+}
+
+// ListResourceQuotas returns the quotas of the name in all the namespaces
+func (s *K8sService) ListResourceQuotas(ctx context.Context, name string) ([]coreV1.ResourceQuota, error) {
+	quotas, err := s.k8sClient.CoreV1().ResourceQuotas("").List(ctx, metaV1.ListOptions{FieldSelector: "metadata.name=" + name})
+	if err != nil {
+		return nil, err
+	}
+	return quotas.Items, nil
+}
+
+func (s *K8sService) DeleteResourceQuota(ctx context.Context, namespace, name string) error {
+	return s.k8sClient.CoreV1().ResourceQuotas(namespace).Delete(ctx, name, metaV1.DeleteOptions{})
+}
+
+// ApplyLimitRange creates the limit range, or updates the existing limit range of the same name
+func (s *K8sService) ApplyLimitRange(ctx context.Context, limitRange *coreV1.LimitRange) error {
+	limitRanges := s.k8sClient.CoreV1().LimitRanges(limitRange.Namespace)
+	current, err := limitRanges.Get(ctx, limitRange.Name, metaV1.GetOptions{})
+	if err != nil {
+		if !k8sErrors.IsNotFound(err) {
+			return err
+		}
+		_, err = limitRanges.Create(ctx, limitRange, metaV1.CreateOptions{})
+		return err
+	}
+	current.Spec = limitRange.Spec
+	_, err = limitRanges.Update(ctx, current, metaV1.UpdateOptions{})
+	return err
+}
+
+// ListLimitRanges returns the limit ranges of the name in all the namespaces
+func (s *K8sService) ListLimitRanges(ctx context.Context, name string) ([]coreV1.LimitRange, error) {
+	limitRanges, err := s.k8sClient.CoreV1().LimitRanges("").List(ctx, metaV1.ListOptions{FieldSelector: "metadata.name=" + name})
+	if err != nil {
+		return nil, err
 	}
+	return limitRanges.Items, nil
+}
 
-	return s.k8sClient.NetworkingV1().NetworkPolicies(namespace).Create(ctx, networkPolicy, metaV1.CreateOptions{})
+func (s *K8sService) DeleteLimitRange(ctx context.Context, namespace, name string) error {
+	return s.k8sClient.CoreV1().LimitRanges(namespace).Delete(ctx, name, metaV1.DeleteOptions{})
 }
 
 func (s *K8sService) CreateNameSpace(ctx context.Context, nameSpace *coreV1.Namespace, opts metaV1.CreateOptions) (result *coreV1.Namespace, err error) {
 	return s.k8sClient.CoreV1().Namespaces().Create(ctx, nameSpace, opts)
 }
 
+// LabelNameSpace sets the labels on the namespace, the other labels are kept
+func (s *K8sService) LabelNameSpace(ctx context.Context, nameSpace string, labels map[string]string) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		namespace, err := s.k8sClient.CoreV1().Namespaces().Get(ctx, nameSpace, metaV1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		changed := false
+		for key, value := range labels {
+			if namespace.Labels[key] != value {
+				changed = true
+			}
+		}
+		if !changed {
+			return nil
+		}
+		if namespace.Labels == nil {
+			namespace.Labels = make(map[string]string)
+		}
+		for key, value := range labels {
+			namespace.Labels[key] = value
+		}
+		_, err = s.k8sClient.CoreV1().Namespaces().Update(ctx, namespace, metaV1.UpdateOptions{})
+		return err
+	})
+}
+
 func (s *K8sService) GetNameSpace(ctx context.Context, nameSpace string, opts metaV1.GetOptions) (result *coreV1.Namespace, err error) {
 	return s.k8sClient.CoreV1().Namespaces().Get(ctx, nameSpace, opts)
 }
@@ -368,10 +575,16 @@ func (s *K8sService) StatisticalSources(ctx context.Context) ([]*models.NodeReso
 					}
 				}
 
+				gpuSummary := make(map[string]int64)
+				for name, info := range collectGpu {
+					gpuSummary[name] = int64(info.count)
+				}
+				gpuSummary = wholeGpus(&node, gpuSummary)
+
 				for name, info := range collectGpu {
 					runCount := int(nodeGpu[name])
-					if runCount < info.count {
-						info.remainNum = info.count - runCount
+					if count := int(gpuSummary[name]); runCount < count {
+						info.remainNum = count - runCount
 					} else {
 						info.remainNum = 0
 					}
@@ -395,9 +608,11 @@ func (s *K8sService) StatisticalSources(ctx context.Context) ([]*models.NodeReso
 				nodeResource.Gpu = models.Gpu{
 					DriverVersion: gpu.Gpu.DriverVersion,
 					CudaVersion:   gpu.Gpu.CudaVersion,
+					RocmVersion:   gpu.Gpu.RocmVersion,
 					AttachedGpus:  gpu.Gpu.AttachedGpus,
 					Details:       newGpu,
 				}
+				describeGpuVendors(&nodeResource.Gpu)
 			}
 		}
 
@@ -472,6 +687,22 @@ func (s *K8sService) AddNodeLabel(nodeName, key string) error {
 	return nil
 }
 
+// RemoveNodeLabel removes the label from the node, a node without it is left unchanged
+func (s *K8sService) RemoveNodeLabel(nodeName, key string) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		node, err := s.k8sClient.CoreV1().Nodes().Get(context.Background(), nodeName, metaV1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if _, ok := node.Labels[key]; !ok {
+			return nil
+		}
+		delete(node.Labels, key)
+		_, err = s.k8sClient.CoreV1().Nodes().Update(context.Background(), node, metaV1.UpdateOptions{})
+		return err
+	})
+}
+
 func (s *K8sService) WaitForPodRunningByHttp(namespace, spaceUuid, serviceIp string) (string, error) {
 	var podName string
 	var podErr = errors.New("get pod status failed")
@@ -0,0 +1,560 @@
+package computing
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
+	"github.com/swanchain/go-computing-provider/conf"
+	"github.com/swanchain/go-computing-provider/internal/models"
+	"gorm.io/gorm"
+	coreV1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"net"
+	"net/netip"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// modelCacheNamespace runs the pods filling and emptying the caches of the nodes
+	modelCacheNamespace = "model-cache"
+	// modelCacheMountPath is where the cache of a Hugging Face model is mounted in a space
+	modelCacheMountPath = "/model-cache"
+	// modelCacheFile is the file of a model downloaded from a url in its cache directory
+	modelCacheFile = "model"
+	// modelCacheLabelPrefix labels the nodes caching a model, the spaces using the model are pinned to them
+	modelCacheLabelPrefix = "lad_model_"
+	// modelFetchTimeout is how long a model has to be downloaded into the cache
+	modelFetchTimeout = time.Hour
+)
+
+// modelFetchScript downloads a model into the cache: a file at a url is checked against its sha256, a Hugging Face
+// model is stored as a hub cache. The model is only moved in place once complete, and its size and sha256 are
+// printed on the last line.
+const modelFetchScript = `
+import hashlib, json, os, shutil, subprocess, sys, urllib.request
+kind, source, dest, want = sys.argv[1:5]
+tmp = dest + ".part"
+shutil.rmtree(tmp, ignore_errors=True)
+os.makedirs(tmp)
+digest = ""
+if kind == "hf":
+    subprocess.check_call([sys.executable, "-m", "pip", "install", "-q", "huggingface_hub"])
+    from huggingface_hub import snapshot_download
+    snapshot_download(repo_id=source, cache_dir=tmp)
+else:
+    h = hashlib.sha256()
+    with urllib.request.urlopen(source) as r, open(os.path.join(tmp, "model"), "wb") as f:
+        for chunk in iter(lambda: r.read(1 << 20), b""):
+            h.update(chunk)
+            f.write(chunk)
+    digest = h.hexdigest()
+    if want and digest != want:
+        shutil.rmtree(tmp)
+        sys.exit("sha256 mismatch, got %s, expected %s" % (digest, want))
+size = sum(os.lstat(os.path.join(d, f)).st_size for d, _, files in os.walk(tmp) for f in files)
+shutil.rmtree(dest, ignore_errors=True)
+os.rename(tmp, dest)
+print(json.dumps({"size": size, "sha256": digest}))
+`
+
+var sha256Pattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// nonPublicCidrs are the networks a model is never fetched from besides the loopback, private and link-local ones:
+// the shared address space of carrier-grade NAT and the networks reserved for benchmarks and documentation
+var nonPublicCidrs = []netip.Prefix{
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("2001:db8::/32"),
+}
+
+// modelCacheLocks serializes the fetches of a model on a node
+var modelCacheLocks sync.Map
+
+// ModelSource is a model of a space: a file at a url, or a model of Hugging Face
+type ModelSource struct {
+	Url     string // the url of a model file
+	Sha256  string // the sha256 of a model file, optional
+	ModelId string // the id of a Hugging Face model
+}
+
+// ParseModelSource parses a model given as a url, or hf:<model id> for a model of Hugging Face
+func ParseModelSource(source, sha256 string) (ModelSource, error) {
+	if modelId, ok := strings.CutPrefix(source, "hf:"); ok {
+		if modelId == "" || sha256 != "" {
+			return ModelSource{}, fmt.Errorf("a Hugging Face model is hf:<model id>, without a sha256")
+		}
+		return ModelSource{ModelId: modelId}, nil
+	}
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		return ModelSource{}, fmt.Errorf("%q is neither a url nor hf:<model id>", source)
+	}
+	model := ModelSource{Url: source, Sha256: strings.ToLower(sha256)}
+	if model.Sha256 != "" && !sha256Pattern.MatchString(model.Sha256) {
+		return ModelSource{}, fmt.Errorf("%q is not a sha256", sha256)
+	}
+	return model, nil
+}
+
+func (m ModelSource) String() string {
+	if m.ModelId != "" {
+		return "hf:" + m.ModelId
+	}
+	return m.Url
+}
+
+// CacheKey addresses the model in the cache: a file with a known sha256 by its content, so the same file from
+// different urls is cached once, else by its source
+func (m ModelSource) CacheKey() string {
+	if m.Sha256 != "" {
+		return m.Sha256
+	}
+	sum := sha256.Sum256([]byte(m.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+func modelCacheLabel(cacheKey string) string {
+	return modelCacheLabelPrefix + cacheKey[:32]
+}
+
+func modelCacheDir(cacheKey string) string {
+	return path.Join(conf.GetConfig().MODEL_CACHE.Path, cacheKey)
+}
+
+// modelCacheEnabled tells if the models of the spaces are cached on the nodes
+func modelCacheEnabled() bool {
+	return conf.GetConfig() != nil && conf.GetConfig().MODEL_CACHE.Enable
+}
+
+// EnsureModelCache puts the model in the cache of the node unless it is there already, the model is marked as used
+func EnsureModelCache(ctx context.Context, nodeName string, model ModelSource) (*models.ModelCacheEntity, error) {
+	key := model.CacheKey()
+	lock, _ := modelCacheLocks.LoadOrStore(nodeName+"/"+key, new(sync.Mutex))
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	cacheService := NewModelCacheService()
+	entry, err := cacheService.GetModelCache(nodeName, key)
+	if err == nil {
+		entry.LastUsed = time.Now().Unix()
+		return entry, cacheService.TouchModelCache(entry.Id, entry.LastUsed)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if model.Url != "" {
+		if err = checkModelUrl(ctx, model.Url, net.DefaultResolver.LookupNetIP); err != nil {
+			return nil, err
+		}
+	}
+	logs.GetLogger().Infof("node: %s, fetching model %s into the cache", nodeName, model)
+	ctx, cancel := context.WithTimeout(ctx, modelFetchTimeout)
+	defer cancel()
+	kind, source := "url", model.Url
+	if model.ModelId != "" {
+		kind, source = "hf", model.ModelId
+	}
+	command := []string{"python3", "-c", modelFetchScript, kind, source, path.Join("/cache", key), model.Sha256}
+
+	entry = &models.ModelCacheEntity{
+		CacheKey:   key,
+		NodeName:   nodeName,
+		Source:     model.String(),
+		LastUsed:   time.Now().Unix(),
+		CreateTime: time.Now().Unix(),
+	}
+	created, err := runModelCachePod(ctx, nodeName, "fetch", key, command, func(output string) error {
+		var result struct {
+			Size   int64  `json:"size"`
+			Sha256 string `json:"sha256"`
+		}
+		if err := json.Unmarshal([]byte(output), &result); err != nil {
+			return fmt.Errorf("unexpected output of the fetch: %s", output)
+		}
+		entry.Size, entry.Sha256 = result.Size, result.Sha256
+		// the label goes first, a cached model is only used on the nodes labelled with it
+		if err := NewK8sService().AddNodeLabel(nodeName, modelCacheLabel(key)); err != nil {
+			return err
+		}
+		return cacheService.SaveModelCache(entry)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fetch model %s on node %s, error: %v", model, nodeName, err)
+	}
+	if !created {
+		// fetched by another process, e.g. a prefetch of the cli
+		if entry, err = cacheService.GetModelCache(nodeName, key); err != nil {
+			return nil, fmt.Errorf("model %s was fetched on node %s by another process but is not cached, error: %v", model, nodeName, err)
+		}
+	}
+
+	evictModelCache(ctx, nodeName, key)
+	return entry, nil
+}
+
+// checkModelUrl refuses a model url whose host is not public, so that the fetch pods, which run outside the
+// namespaces of the spaces, cannot reach the services of the cluster or of the node. The host is resolved with
+// lookup; the egress policy of the model-cache namespace also covers the redirects of the fetch.
+func checkModelUrl(ctx context.Context, rawUrl string, lookup func(ctx context.Context, network, host string) ([]netip.Addr, error)) error {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return fmt.Errorf("invalid model url %q, error: %v", rawUrl, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("model url %q is not http or https", rawUrl)
+	}
+	host := u.Hostname()
+	var addrs []netip.Addr
+	if addr, err := netip.ParseAddr(host); err == nil {
+		addrs = append(addrs, addr)
+	} else if addrs, err = lookup(ctx, "ip", host); err != nil {
+		return fmt.Errorf("resolve the host of model url %q, error: %v", rawUrl, err)
+	}
+	for _, addr := range addrs {
+		if !publicAddr(addr) {
+			return fmt.Errorf("model url %q resolves to %s, which is not a public address", rawUrl, addr)
+		}
+	}
+	return nil
+}
+
+// publicAddr tells if the address is a public unicast address
+func publicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, prefix := range nonPublicCidrs {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// RemoveModelCache removes the model from the cache of its node: the node loses the label of the model first, so
+// that no new space is scheduled for it
+func RemoveModelCache(ctx context.Context, entry *models.ModelCacheEntity) error {
+	if err := NewK8sService().RemoveNodeLabel(entry.NodeName, modelCacheLabel(entry.CacheKey)); err != nil && !k8sErrors.IsNotFound(err) {
+		return err
+	}
+	command := []string{"rm", "-rf", path.Join("/cache", entry.CacheKey), path.Join("/cache", entry.CacheKey+".part")}
+	if _, err := runModelCachePod(ctx, entry.NodeName, "remove", entry.CacheKey, command, nil); err != nil {
+		return err
+	}
+	return NewModelCacheService().DeleteModelCache(entry.Id)
+}
+
+// evictModelCache removes the least recently used models not in use from the cache of the node until it fits in
+// MODEL_CACHE.MaxSize, the model just fetched is kept for the space about to use it
+func evictModelCache(ctx context.Context, nodeName, keepKey string) {
+	entries, err := NewModelCacheService().GetModelCacheList(nodeName)
+	if err != nil {
+		logs.GetLogger().Errorf("node: %s, get the model cache failed, error: %v", nodeName, err)
+		return
+	}
+	inUse, err := modelCacheInUse(ctx, nodeName)
+	if err != nil {
+		logs.GetLogger().Errorf("node: %s, get the models in use failed, error: %v", nodeName, err)
+		return
+	}
+
+	inUse[modelCacheLabel(keepKey)] = true
+
+	maxSize := conf.GetConfig().MODEL_CACHE.MaxSize * 1024 * 1024 * 1024
+	evictions, size := modelCacheEvictions(entries, inUse, maxSize)
+	for _, entry := range evictions {
+		if err = RemoveModelCache(ctx, entry); err != nil {
+			logs.GetLogger().Errorf("node: %s, evict model %s failed, error: %v", nodeName, entry.Source, err)
+			continue
+		}
+		logs.GetLogger().Infof("node: %s, evicted model %s from the cache", nodeName, entry.Source)
+	}
+	if size > maxSize {
+		logs.GetLogger().Warnf("node: %s, the model cache holds %s, more than MODEL_CACHE.MaxSize, its models are in use", nodeName, formatGiB(size))
+	}
+}
+
+// modelCacheEvictions picks the models to evict for the cache to fit in maxSize, the least recently used first, and
+// returns the size of the cache after their eviction. inUse holds the labels of the models in use.
+func modelCacheEvictions(entries []*models.ModelCacheEntity, inUse map[string]bool, maxSize int64) ([]*models.ModelCacheEntity, int64) {
+	var size int64
+	for _, entry := range entries {
+		size += entry.Size
+	}
+	var evictions []*models.ModelCacheEntity
+	for _, entry := range entries {
+		if size <= maxSize {
+			break
+		}
+		if inUse[modelCacheLabel(entry.CacheKey)] {
+			continue
+		}
+		evictions = append(evictions, entry)
+		size -= entry.Size
+	}
+	return evictions, size
+}
+
+// modelCacheInUse returns the labels of the models used by the pods of the node, and by the pods waiting to be
+// scheduled on a node caching them
+func modelCacheInUse(ctx context.Context, nodeName string) (map[string]bool, error) {
+	podList, err := NewK8sService().k8sClient.CoreV1().Pods("").List(ctx, metaV1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	cachePath := path.Clean(conf.GetConfig().MODEL_CACHE.Path)
+	inUse := make(map[string]bool)
+	for _, pod := range podList.Items {
+		if pod.Spec.NodeName == "" {
+			for label := range pod.Spec.NodeSelector {
+				if strings.HasPrefix(label, modelCacheLabelPrefix) {
+					inUse[label] = true
+				}
+			}
+			continue
+		}
+		if pod.Spec.NodeName != nodeName {
+			continue
+		}
+		for _, volume := range pod.Spec.Volumes {
+			if volume.HostPath == nil || path.Dir(volume.HostPath.Path) != cachePath {
+				continue
+			}
+			if key := path.Base(volume.HostPath.Path); sha256Pattern.MatchString(key) {
+				inUse[modelCacheLabel(key)] = true
+			}
+		}
+	}
+	return inUse, nil
+}
+
+// runModelCachePod runs the command in a pod on the node with the cache mounted at /cache, done is called with the
+// last line of its output before the pod is removed. A pod already running for the same action and model is waited
+// for instead, and false is returned.
+func runModelCachePod(ctx context.Context, nodeName, action, cacheKey string, command []string, done func(output string) error) (bool, error) {
+	k8sService := NewK8sService()
+	if _, err := k8sService.GetNameSpace(ctx, modelCacheNamespace, metaV1.GetOptions{}); err != nil {
+		if !k8sErrors.IsNotFound(err) {
+			return false, err
+		}
+		namespace := &coreV1.Namespace{ObjectMeta: metaV1.ObjectMeta{Name: modelCacheNamespace}}
+		if _, err = k8sService.CreateNameSpace(ctx, namespace, metaV1.CreateOptions{}); err != nil && !k8sErrors.IsAlreadyExists(err) {
+			return false, err
+		}
+	}
+	// the fetches download what the spaces ask for, they get the egress of the spaces
+	if err := applyNamespaceEgress(ctx, modelCacheNamespace); err != nil {
+		return false, err
+	}
+
+	hostPathType := coreV1.HostPathDirectoryOrCreate
+	pod := &coreV1.Pod{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      modelCachePodName(action, nodeName, cacheKey),
+			Namespace: modelCacheNamespace,
+		},
+		Spec: coreV1.PodSpec{
+			NodeName:      nodeName,
+			RestartPolicy: coreV1.RestartPolicyNever,
+			Containers: []coreV1.Container{{
+				Name:         action,
+				Image:        conf.GetConfig().MODEL_CACHE.Image,
+				Command:      command,
+				VolumeMounts: []coreV1.VolumeMount{{Name: "cache", MountPath: "/cache"}},
+			}},
+			Volumes: []coreV1.Volume{{
+				Name: "cache",
+				VolumeSource: coreV1.VolumeSource{
+					HostPath: &coreV1.HostPathVolumeSource{Path: conf.GetConfig().MODEL_CACHE.Path, Type: &hostPathType},
+				},
+			}},
+		},
+	}
+	pods := k8sService.k8sClient.CoreV1().Pods(modelCacheNamespace)
+	created := true
+	if _, err := pods.Create(ctx, pod, metaV1.CreateOptions{}); err != nil {
+		if !k8sErrors.IsAlreadyExists(err) {
+			return false, err
+		}
+		created = false
+	}
+
+	var phase coreV1.PodPhase
+	err := wait.PollUntilContextCancel(ctx, 5*time.Second, true, func(ctx context.Context) (bool, error) {
+		current, err := pods.Get(ctx, pod.Name, metaV1.GetOptions{})
+		if err != nil {
+			if k8sErrors.IsNotFound(err) && !created {
+				return true, nil
+			}
+			return false, err
+		}
+		phase = current.Status.Phase
+		// the pod of another process is removed by it once done
+		return created && (phase == coreV1.PodSucceeded || phase == coreV1.PodFailed), nil
+	})
+	if !created {
+		return false, err
+	}
+	defer pods.Delete(context.Background(), pod.Name, metaV1.DeleteOptions{})
+	if err != nil {
+		return true, err
+	}
+
+	var tailLines int64 = 1
+	output, err := k8sService.GetPodLogByPodName(modelCacheNamespace, pod.Name, &coreV1.PodLogOptions{TailLines: &tailLines})
+	if err != nil {
+		return true, err
+	}
+	output = strings.TrimSpace(output)
+	if phase == coreV1.PodFailed {
+		return true, fmt.Errorf("%s failed: %s", action, output)
+	}
+	if done != nil {
+		return true, done(output)
+	}
+	return true, nil
+}
+
+// modelCachePodName is the same for the same action and model on a node, a second fetch waits for the first one
+func modelCachePodName(action, nodeName, cacheKey string) string {
+	sum := sha256.Sum256([]byte(nodeName))
+	return fmt.Sprintf("%s-%s-%s", action, cacheKey[:16], hex.EncodeToString(sum[:])[:8])
+}
+
+// modelCacheVolume mounts the directory of the cached model in a space pod
+func modelCacheVolume(entry *models.ModelCacheEntity) coreV1.Volume {
+	hostPathType := coreV1.HostPathDirectory
+	return coreV1.Volume{
+		Name: "model-" + entry.CacheKey[:16],
+		VolumeSource: coreV1.VolumeSource{
+			HostPath: &coreV1.HostPathVolumeSource{Path: modelCacheDir(entry.CacheKey), Type: &hostPathType},
+		},
+	}
+}
+
+// cacheModel puts the model in the cache of the node of the space. It returns nil when the model is not cached: the
+// cache is off, the space has no node or the fetch failed, the space then downloads the model itself.
+func (d *Deploy) cacheModel(source, sha256 string) *models.ModelCacheEntity {
+	if !modelCacheEnabled() || d.nodeName == "" {
+		return nil
+	}
+	model, err := ParseModelSource(source, sha256)
+	if err == nil {
+		var entry *models.ModelCacheEntity
+		if entry, err = EnsureModelCache(d.context(), d.nodeName, model); err == nil {
+			return entry
+		}
+	}
+	logs.GetLogger().Warnf("space_uuid: %s, model %s is not cached, the space downloads it, error: %v", d.spaceUuid, source, err)
+	return nil
+}
+
+// cachedModels collects the cached models of a space pod: their volumes, their read-only mounts in the space
+// container, and the labels pinning the pod to the nodes caching them
+type cachedModels struct {
+	volumes      []coreV1.Volume
+	mounts       []coreV1.VolumeMount
+	nodeSelector map[string]string
+}
+
+func (c *cachedModels) add(entry *models.ModelCacheEntity, mount coreV1.VolumeMount) {
+	volume := modelCacheVolume(entry)
+	if c.nodeSelector == nil {
+		c.nodeSelector = make(map[string]string)
+	}
+	if _, ok := c.nodeSelector[modelCacheLabel(entry.CacheKey)]; !ok {
+		c.volumes = append(c.volumes, volume)
+		c.nodeSelector[modelCacheLabel(entry.CacheKey)] = "true"
+	}
+	mount.Name = volume.Name
+	mount.ReadOnly = true
+	c.mounts = append(c.mounts, mount)
+}
+
+// withNodeSelector adds the labels of the cached models to the node selector of the pod
+func (c *cachedModels) withNodeSelector(nodeSelector map[string]string) map[string]string {
+	for label, value := range c.nodeSelector {
+		nodeSelector[label] = value
+	}
+	return nodeSelector
+}
+
+// PrefetchModel puts the model in the cache of the nodes, or of all the ready nodes when none is given
+func PrefetchModel(ctx context.Context, model ModelSource, nodeNames []string) error {
+	if len(nodeNames) == 0 {
+		nodeList, err := NewK8sService().k8sClient.CoreV1().Nodes().List(ctx, metaV1.ListOptions{})
+		if err != nil {
+			return err
+		}
+		for _, node := range nodeList.Items {
+			if !node.Spec.Unschedulable && nodeReady(&node) {
+				nodeNames = append(nodeNames, node.Name)
+			}
+		}
+	}
+
+	var failed []string
+	for _, nodeName := range nodeNames {
+		if _, err := EnsureModelCache(ctx, nodeName, model); err != nil {
+			logs.GetLogger().Error(err)
+			failed = append(failed, nodeName)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("model %s is not cached on the nodes: %s", model, strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// PurgeModelCache removes the cached models of the node, or of all the nodes for an empty node name, only those of
+// the source when one is given. The models in use are kept and returned.
+func PurgeModelCache(ctx context.Context, nodeName, source string) (removed, kept []*models.ModelCacheEntity, err error) {
+	entries, err := NewModelCacheService().GetModelCacheList(nodeName)
+	if err != nil {
+		return nil, nil, err
+	}
+	inUse := make(map[string]map[string]bool)
+	for _, entry := range entries {
+		if source != "" && entry.Source != source {
+			continue
+		}
+		if _, ok := inUse[entry.NodeName]; !ok {
+			if inUse[entry.NodeName], err = modelCacheInUse(ctx, entry.NodeName); err != nil {
+				return removed, kept, err
+			}
+		}
+		if inUse[entry.NodeName][modelCacheLabel(entry.CacheKey)] {
+			kept = append(kept, entry)
+			continue
+		}
+		if err = RemoveModelCache(ctx, entry); err != nil {
+			return removed, kept, fmt.Errorf("remove model %s from node %s, error: %v", entry.Source, entry.NodeName, err)
+		}
+		removed = append(removed, entry)
+	}
+	return removed, kept, nil
+}
+
+func nodeReady(node *coreV1.Node) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == coreV1.NodeReady {
+			return condition.Status == coreV1.ConditionTrue
+		}
+	}
+	return false
+}
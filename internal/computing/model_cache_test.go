@@ -0,0 +1,117 @@
+package computing
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/swanchain/go-computing-provider/internal/models"
+)
+
+func TestParseModelSource(t *testing.T) {
+	sha := strings.Repeat("ab", 32)
+	a, err := ParseModelSource("https://a.example/model.bin", strings.ToUpper(sha))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ParseModelSource("https://b.example/weights.bin", sha)
+	if a.CacheKey() != sha || b.CacheKey() != sha {
+		t.Errorf("expected a file with a sha256 to be cached by its content, got %s and %s", a.CacheKey(), b.CacheKey())
+	}
+
+	c, _ := ParseModelSource("https://a.example/model.bin", "")
+	d, _ := ParseModelSource("https://b.example/model.bin", "")
+	if c.CacheKey() == d.CacheKey() || !sha256Pattern.MatchString(c.CacheKey()) {
+		t.Errorf("expected the files without a sha256 to be cached by their url, got %s and %s", c.CacheKey(), d.CacheKey())
+	}
+
+	hf, err := ParseModelSource("hf:openai/whisper-small", "")
+	if err != nil || hf.ModelId != "openai/whisper-small" || hf.String() != "hf:openai/whisper-small" {
+		t.Errorf("expected a Hugging Face model, got %+v, %v", hf, err)
+	}
+
+	for _, invalid := range [][2]string{{"hf:", ""}, {"hf:gpt2", sha}, {"ftp://a.example/model.bin", ""}, {"https://a.example/model.bin", "abc"}} {
+		if _, err = ParseModelSource(invalid[0], invalid[1]); err == nil {
+			t.Errorf("expected %q with sha256 %q to be refused", invalid[0], invalid[1])
+		}
+	}
+}
+
+func TestModelCacheEvictions(t *testing.T) {
+	const gib = 1024 * 1024 * 1024
+	entry := func(key string, size int64) *models.ModelCacheEntity {
+		return &models.ModelCacheEntity{CacheKey: strings.Repeat(key, 64), Size: size * gib}
+	}
+	// the least recently used first
+	entries := []*models.ModelCacheEntity{entry("a", 10), entry("b", 20), entry("c", 30), entry("d", 40)}
+
+	evictions, size := modelCacheEvictions(entries, map[string]bool{}, 100*gib)
+	if len(evictions) != 0 || size != 100*gib {
+		t.Errorf("expected a cache at its size to be kept, got %d evictions", len(evictions))
+	}
+
+	evictions, size = modelCacheEvictions(entries, map[string]bool{modelCacheLabel(entries[0].CacheKey): true}, 60*gib)
+	if len(evictions) != 2 || evictions[0] != entries[1] || evictions[1] != entries[2] || size != 50*gib {
+		t.Errorf("expected the least recently used models not in use to be evicted, got %d evictions, %d GiB left", len(evictions), size/gib)
+	}
+
+	inUse := map[string]bool{}
+	for _, e := range entries {
+		inUse[modelCacheLabel(e.CacheKey)] = true
+	}
+	if evictions, size = modelCacheEvictions(entries, inUse, 10*gib); len(evictions) != 0 || size != 100*gib {
+		t.Errorf("expected the models in use to be kept, got %d evictions", len(evictions))
+	}
+}
+
+func TestModelCacheNames(t *testing.T) {
+	sha := strings.Repeat("0f", 32)
+	if label := modelCacheLabel(sha); len(label) > 63 || !strings.HasPrefix(label, modelCacheLabelPrefix) {
+		t.Errorf("expected a node label of at most 63 characters, got %s", label)
+	}
+	name := modelCachePodName("remove", "a-very-long-node-name.example.internal", sha)
+	if len(name) > 63 || name == modelCachePodName("remove", "another-node", sha) {
+		t.Errorf("expected a pod name of at most 63 characters for each node, got %s", name)
+	}
+}
+
+func TestCheckModelUrl(t *testing.T) {
+	hosts := map[string]string{
+		"huggingface.co":       "18.164.174.17",
+		"metadata.internal":    "169.254.169.254",
+		"registry.cluster":     "10.96.0.10",
+		"rebind.example":       "::ffff:127.0.0.1",
+		"shared.example":       "100.64.1.1",
+		"dual-stack.example":   "2606:4700::6810:84e5",
+		"ipv6-private.example": "fd00::1",
+	}
+	lookup := func(_ context.Context, _, host string) ([]netip.Addr, error) {
+		addr, ok := hosts[host]
+		if !ok {
+			return nil, fmt.Errorf("no such host %s", host)
+		}
+		return []netip.Addr{netip.MustParseAddr(addr)}, nil
+	}
+
+	for rawUrl, allowed := range map[string]bool{
+		"https://huggingface.co/model.bin":           true,
+		"https://dual-stack.example/model.bin":       true,
+		"https://8.8.8.8/model.bin":                  true,
+		"http://metadata.internal/latest/meta-data/": false,
+		"http://registry.cluster:5000/v2/":           false,
+		"http://rebind.example/model.bin":            false,
+		"http://shared.example/model.bin":            false,
+		"http://ipv6-private.example/model.bin":      false,
+		"http://127.0.0.1:9085/api/v1/admin/wallets": false,
+		"http://[::1]/model.bin":                     false,
+		"http://unknown.example/model.bin":           false,
+		"file:///etc/passwd":                         false,
+		"ftp://huggingface.co/model.bin":             false,
+	} {
+		if err := checkModelUrl(context.Background(), rawUrl, lookup); (err == nil) != allowed {
+			t.Errorf("%s: expected allowed %t, got error %v", rawUrl, allowed, err)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package computing
+
+import (
+	"fmt"
+	"github.com/swanchain/go-computing-provider/conf"
+	"github.com/swanchain/go-computing-provider/internal/yaml"
+	coreV1 "k8s.io/api/core/v1"
+	"path"
+	"slices"
+	"strings"
+)
+
+// The hardening profiles of the space pods and UBI jobs, named after the Pod Security Standards levels
+const (
+	SecurityProfilePrivileged = "privileged"
+	SecurityProfileBaseline   = "baseline"
+	SecurityProfileRestricted = "restricted"
+)
+
+// The Pod Security Admission labels of the namespaces
+const (
+	podSecurityEnforceLabel        = "pod-security.kubernetes.io/enforce"
+	podSecurityEnforceVersionLabel = "pod-security.kubernetes.io/enforce-version"
+	podSecurityWarnLabel           = "pod-security.kubernetes.io/warn"
+	podSecurityAuditLabel          = "pod-security.kubernetes.io/audit"
+)
+
+// baselineCapabilities is the capabilities the baseline profile keeps: the defaults of the container runtimes but
+// those crafting raw packets, creating devices, writing the audit log, chrooting or setting file capabilities
+var baselineCapabilities = []coreV1.Capability{"CHOWN", "DAC_OVERRIDE", "FOWNER", "FSETID", "KILL", "NET_BIND_SERVICE", "SETGID", "SETPCAP", "SETUID"}
+
+// admittedBaselineCapabilities is the capabilities the baseline level of Pod Security Admission allows to add
+var admittedBaselineCapabilities = map[string]bool{
+	"AUDIT_WRITE": true, "CHOWN": true, "DAC_OVERRIDE": true, "FOWNER": true, "FSETID": true, "KILL": true, "MKNOD": true,
+	"NET_BIND_SERVICE": true, "SETFCAP": true, "SETGID": true, "SETPCAP": true, "SETUID": true, "SYS_CHROOT": true,
+}
+
+// securityProfile is the hardening profile of the config, baseline when it is not set
+func securityProfile() string {
+	if profile := conf.GetConfig().SECURITY.Profile; profile != "" {
+		return profile
+	}
+	return SecurityProfileBaseline
+}
+
+// checkExceptions returns an error naming the exceptions requested by a service the profile does not allow
+func checkExceptions(profile, service string, requested []string) error {
+	if profile == SecurityProfilePrivileged {
+		return nil
+	}
+	allowed := conf.GetConfig().SECURITY.Exceptions[profile]
+	var denied []string
+	for _, exception := range requested {
+		if !slices.Contains(allowed, exception) {
+			denied = append(denied, exception)
+		}
+	}
+	if len(denied) > 0 {
+		return fmt.Errorf("service %s requests %s, not allowed under the %s profile", service, strings.Join(denied, ", "), profile)
+	}
+	return nil
+}
+
+// profileExceptions is the exceptions granted to the container of a Dockerfile or model space, which cannot request
+// its own: all those the profile allows
+func profileExceptions(profile, container string) map[string][]string {
+	return map[string][]string{container: conf.GetConfig().SECURITY.Exceptions[profile]}
+}
+
+// hardenPodSpec applies the profile to the pod and its containers. exceptions is the exceptions granted to the
+// containers by name. A container with a read-only root filesystem writes to /tmp and its writableDirs, they are
+// mounted from emptyDir volumes unless a volume is already mounted there.
+func hardenPodSpec(spec *coreV1.PodSpec, profile string, exceptions map[string][]string, writableDirs map[string][]string) {
+	if profile == SecurityProfilePrivileged {
+		return
+	}
+	spec.SecurityContext = &coreV1.PodSecurityContext{
+		SeccompProfile: &coreV1.SeccompProfile{Type: coreV1.SeccompProfileTypeRuntimeDefault},
+	}
+
+	for i := range spec.Containers {
+		container := &spec.Containers[i]
+		container.SecurityContext = containerSecurityContext(profile, exceptions[container.Name])
+		if readOnly := container.SecurityContext.ReadOnlyRootFilesystem; readOnly == nil || !*readOnly {
+			continue
+		}
+		for _, dir := range append([]string{"/tmp"}, writableDirs[container.Name]...) {
+			if mountedUnder(container.VolumeMounts, dir) {
+				continue
+			}
+			name := fmt.Sprintf("scratch-%d-%d", i, len(container.VolumeMounts))
+			spec.Volumes = append(spec.Volumes, coreV1.Volume{
+				Name:         name,
+				VolumeSource: coreV1.VolumeSource{EmptyDir: &coreV1.EmptyDirVolumeSource{}},
+			})
+			container.VolumeMounts = append(container.VolumeMounts, coreV1.VolumeMount{Name: name, MountPath: dir})
+		}
+	}
+}
+
+// containerSecurityContext is the security context of a container under the profile with the exceptions granted.
+// Both profiles forbid privilege escalation and drop the capabilities but their own, restricted also runs the
+// container as non-root on a read-only root filesystem.
+func containerSecurityContext(profile string, exceptions []string) *coreV1.SecurityContext {
+	allowPrivilegeEscalation := false
+	securityContext := &coreV1.SecurityContext{
+		AllowPrivilegeEscalation: &allowPrivilegeEscalation,
+		Capabilities:             &coreV1.Capabilities{Drop: []coreV1.Capability{"ALL"}},
+	}
+	capabilities := []coreV1.Capability{"NET_BIND_SERVICE"}
+	if profile == SecurityProfileBaseline {
+		capabilities = slices.Clone(baselineCapabilities)
+	}
+	for _, exception := range exceptions {
+		if yaml.IsCapability(exception) && !slices.Contains(capabilities, coreV1.Capability(exception)) {
+			capabilities = append(capabilities, coreV1.Capability(exception))
+		}
+	}
+	securityContext.Capabilities.Add = capabilities
+
+	if profile == SecurityProfileRestricted {
+		if !slices.Contains(exceptions, yaml.ExceptionRunAsRoot) {
+			runAsNonRoot := true
+			securityContext.RunAsNonRoot = &runAsNonRoot
+		}
+		readOnlyRootFilesystem := !slices.Contains(exceptions, yaml.ExceptionWritableRootFs)
+		securityContext.ReadOnlyRootFilesystem = &readOnlyRootFilesystem
+	}
+	return securityContext
+}
+
+// mountedUnder tells if the directory is on one of the volumes mounted
+func mountedUnder(mounts []coreV1.VolumeMount, dir string) bool {
+	dir = path.Clean(dir)
+	for _, mount := range mounts {
+		mountPath := path.Clean(mount.MountPath)
+		if dir == mountPath || strings.HasPrefix(dir, mountPath+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// podSecurityLabels is the Pod Security Admission labels of a namespace whose pods follow the profile with any of
+// the exceptions. The namespace warns and audits at the level of the profile, and enforces the level its pods
+// still meet: a lower one when an exception breaks the level, and privileged for pods mounting host paths.
+func podSecurityLabels(profile string, exceptions []string, hostPath bool) map[string]string {
+	enforce := profile
+	if hostPath {
+		enforce = SecurityProfilePrivileged
+	}
+	for _, exception := range exceptions {
+		switch {
+		case exception == yaml.ExceptionWritableRootFs, exception == "NET_BIND_SERVICE":
+			// both are admitted by the restricted level
+		case yaml.IsCapability(exception) && !admittedBaselineCapabilities[exception]:
+			enforce = SecurityProfilePrivileged
+		case enforce == SecurityProfileRestricted:
+			// running as root or a capability of the baseline level
+			enforce = SecurityProfileBaseline
+		}
+	}
+	return map[string]string{
+		podSecurityEnforceLabel:        enforce,
+		podSecurityEnforceVersionLabel: "latest",
+		podSecurityWarnLabel:           profile,
+		podSecurityAuditLabel:          profile,
+	}
+}
+
+// spaceNamespaceLabels is the Pod Security Admission labels of the namespace of the spaces of a wallet, the pods
+// mount the model cache from the nodes when it is enabled
+func spaceNamespaceLabels() map[string]string {
+	profile := securityProfile()
+	return podSecurityLabels(profile, conf.GetConfig().SECURITY.Exceptions[profile], conf.GetConfig().MODEL_CACHE.Enable)
+}
+
+// ubiNamespaceLabels is the Pod Security Admission labels of the namespace of a UBI task, its job mounts the proof
+// parameters from the node
+func ubiNamespaceLabels() map[string]string {
+	return podSecurityLabels(securityProfile(), conf.GetConfig().SECURITY.UbiExceptions, true)
+}
@@ -0,0 +1,92 @@
+package computing
+
+import (
+	"slices"
+	"testing"
+
+	coreV1 "k8s.io/api/core/v1"
+)
+
+func TestContainerSecurityContext(t *testing.T) {
+	baseline := containerSecurityContext(SecurityProfileBaseline, []string{"SYS_PTRACE", "CHOWN"})
+	if baseline.AllowPrivilegeEscalation == nil || *baseline.AllowPrivilegeEscalation {
+		t.Error("expected the privilege escalation to be forbidden")
+	}
+	if !slices.Equal(baseline.Capabilities.Drop, []coreV1.Capability{"ALL"}) {
+		t.Errorf("expected all the capabilities dropped, got %v", baseline.Capabilities.Drop)
+	}
+	if !slices.Contains(baseline.Capabilities.Add, "SYS_PTRACE") || len(baseline.Capabilities.Add) != len(baselineCapabilities)+1 {
+		t.Errorf("expected the baseline capabilities and SYS_PTRACE, got %v", baseline.Capabilities.Add)
+	}
+	if baseline.RunAsNonRoot != nil || baseline.ReadOnlyRootFilesystem != nil {
+		t.Error("expected the baseline profile to leave the user and the root filesystem")
+	}
+
+	restricted := containerSecurityContext(SecurityProfileRestricted, nil)
+	if !slices.Equal(restricted.Capabilities.Add, []coreV1.Capability{"NET_BIND_SERVICE"}) {
+		t.Errorf("expected only NET_BIND_SERVICE, got %v", restricted.Capabilities.Add)
+	}
+	if restricted.RunAsNonRoot == nil || !*restricted.RunAsNonRoot || restricted.ReadOnlyRootFilesystem == nil || !*restricted.ReadOnlyRootFilesystem {
+		t.Error("expected the restricted profile to run as non-root on a read-only root filesystem")
+	}
+
+	excepted := containerSecurityContext(SecurityProfileRestricted, []string{"run-as-root", "writable-root-fs"})
+	if excepted.RunAsNonRoot != nil || *excepted.ReadOnlyRootFilesystem {
+		t.Error("expected the exceptions to allow root on a writable root filesystem")
+	}
+}
+
+func TestHardenPodSpec(t *testing.T) {
+	spec := coreV1.PodSpec{Containers: []coreV1.Container{
+		{Name: "app", VolumeMounts: []coreV1.VolumeMount{{Name: "models", MountPath: "/models"}}},
+		{Name: "db"},
+	}}
+	hardenPodSpec(&spec, SecurityProfileRestricted, map[string][]string{"db": {"writable-root-fs"}},
+		map[string][]string{"app": {"/data", "/models/llama"}})
+
+	if spec.SecurityContext == nil || spec.SecurityContext.SeccompProfile.Type != coreV1.SeccompProfileTypeRuntimeDefault {
+		t.Error("expected the RuntimeDefault seccomp profile")
+	}
+	var mounted []string
+	for _, mount := range spec.Containers[0].VolumeMounts {
+		mounted = append(mounted, mount.MountPath)
+	}
+	if !slices.Equal(mounted, []string{"/models", "/tmp", "/data"}) {
+		t.Errorf("expected /tmp and /data mounted writable, got %v", mounted)
+	}
+	if len(spec.Volumes) != 2 || spec.Volumes[0].EmptyDir == nil {
+		t.Errorf("expected two emptyDir volumes, got %v", spec.Volumes)
+	}
+	if len(spec.Containers[1].VolumeMounts) != 0 {
+		t.Error("expected no volume for a writable root filesystem")
+	}
+
+	privileged := coreV1.PodSpec{Containers: []coreV1.Container{{Name: "app"}}}
+	hardenPodSpec(&privileged, SecurityProfilePrivileged, nil, nil)
+	if privileged.SecurityContext != nil || privileged.Containers[0].SecurityContext != nil {
+		t.Error("expected the privileged profile to leave the pod")
+	}
+}
+
+func TestPodSecurityLabels(t *testing.T) {
+	for _, tc := range []struct {
+		profile    string
+		exceptions []string
+		hostPath   bool
+		enforce    string
+	}{
+		{SecurityProfileRestricted, []string{"writable-root-fs"}, false, SecurityProfileRestricted},
+		{SecurityProfileRestricted, []string{"run-as-root"}, false, SecurityProfileBaseline},
+		{SecurityProfileRestricted, []string{"CHOWN"}, false, SecurityProfileBaseline},
+		{SecurityProfileBaseline, []string{"SYS_PTRACE"}, false, SecurityProfilePrivileged},
+		{SecurityProfileBaseline, nil, true, SecurityProfilePrivileged},
+	} {
+		labels := podSecurityLabels(tc.profile, tc.exceptions, tc.hostPath)
+		if labels[podSecurityEnforceLabel] != tc.enforce {
+			t.Errorf("%s with %v, expected to enforce %s, got %s", tc.profile, tc.exceptions, tc.enforce, labels[podSecurityEnforceLabel])
+		}
+		if labels[podSecurityWarnLabel] != tc.profile || labels[podSecurityAuditLabel] != tc.profile {
+			t.Errorf("expected to warn and audit at %s, got %v", tc.profile, labels)
+		}
+	}
+}
@@ -0,0 +1,333 @@
+package computing
+
+import (
+	"context"
+	"fmt"
+	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
+	"github.com/swanchain/go-computing-provider/conf"
+	"github.com/swanchain/go-computing-provider/constants"
+	"github.com/swanchain/go-computing-provider/internal/models"
+	"github.com/swanchain/go-computing-provider/internal/yaml"
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"strings"
+)
+
+const (
+	quotaSpaces = coreV1.ResourceName("count/deployments.apps")
+	quotaCpu    = coreV1.ResourceRequestsCPU
+	quotaMemory = coreV1.ResourceRequestsMemory
+	quotaStore  = coreV1.ResourceRequestsEphemeralStorage
+	// the persistent volumes of the spaces, their claims are counted by the ResourceQuota when they are created
+	quotaVolumes       = coreV1.ResourcePersistentVolumeClaims
+	quotaVolumeStorage = coreV1.ResourceRequestsStorage
+)
+
+// quotaGpu is the quota resource of the whole gpus of a vendor, e.g. requests.amd.com/gpu
+func quotaGpu(name coreV1.ResourceName) coreV1.ResourceName {
+	return coreV1.DefaultResourceRequestsPrefix + name
+}
+
+// QuotaExceededError is returned by checkSpaceQuota when the space does not fit in the quota of its wallet
+type QuotaExceededError struct {
+	WalletAddress string
+	Resource      coreV1.ResourceName
+	Used          resource.Quantity
+	Requested     resource.Quantity
+	Hard          resource.Quantity
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("the quota of wallet %s is exceeded, %s: used %s, requested %s, limit %s",
+		e.WalletAddress, e.Resource, e.Used.String(), e.Requested.String(), e.Hard.String())
+}
+
+// quotaTiers tells the QUOTA tier of a wallet from its membership of the wallet whitelist and blacklist
+type quotaTiers struct {
+	whiteList []string
+	blackList []string
+}
+
+func loadQuotaTiers() (*quotaTiers, error) {
+	whiteList, err := getWalletList(conf.GetConfig().API.WalletWhiteList)
+	if err != nil {
+		return nil, fmt.Errorf("get wallet whitelist failed, error: %v", err)
+	}
+	blackList, err := getWalletList(conf.GetConfig().API.WalletBlackList)
+	if err != nil {
+		return nil, fmt.Errorf("get wallet blacklist failed, error: %v", err)
+	}
+	return &quotaTiers{whiteList: whiteList, blackList: blackList}, nil
+}
+
+// hard returns the ResourceQuota limits of the wallet, the wallets on the blacklist get nothing
+func (t *quotaTiers) hard(quota conf.QUOTA, walletAddress string) coreV1.ResourceList {
+	if containsWallet(t.blackList, walletAddress) {
+		hard := coreV1.ResourceList{
+			quotaSpaces: resource.MustParse("0"),
+			quotaCpu:    resource.MustParse("0"),
+			quotaMemory: resource.MustParse("0"),
+			quotaStore:  resource.MustParse("0"),
+
+			quotaVolumes:       resource.MustParse("0"),
+			quotaVolumeStorage: resource.MustParse("0"),
+		}
+		for _, name := range gpuResourceNames() {
+			hard[quotaGpu(name)] = resource.MustParse("0")
+		}
+		return hard
+	}
+
+	tier := quota.Default
+	if containsWallet(t.whiteList, walletAddress) {
+		tier = quota.WhiteList
+	}
+	hard := coreV1.ResourceList{}
+	if tier.Spaces > 0 {
+		hard[quotaSpaces] = *resource.NewQuantity(tier.Spaces, resource.DecimalSI)
+	}
+	if tier.Cpu > 0 {
+		hard[quotaCpu] = *resource.NewQuantity(tier.Cpu, resource.DecimalSI)
+	}
+	if tier.Memory > 0 {
+		hard[quotaMemory] = resource.MustParse(fmt.Sprintf("%dGi", tier.Memory))
+	}
+	if tier.Storage > 0 {
+		hard[quotaStore] = resource.MustParse(fmt.Sprintf("%dGi", tier.Storage))
+	}
+	if tier.Volumes > 0 {
+		hard[quotaVolumes] = *resource.NewQuantity(tier.Volumes, resource.DecimalSI)
+	}
+	if tier.VolumeStorage > 0 {
+		hard[quotaVolumeStorage] = resource.MustParse(fmt.Sprintf("%dGi", tier.VolumeStorage))
+	}
+	if tier.Gpu > 0 {
+		// the limit applies to the gpus of every vendor on its own
+		for _, name := range gpuResourceNames() {
+			hard[quotaGpu(name)] = *resource.NewQuantity(tier.Gpu, resource.DecimalSI)
+		}
+	}
+	return hard
+}
+
+func containsWallet(list []string, walletAddress string) bool {
+	for _, address := range list {
+		if strings.EqualFold(strings.TrimSpace(address), walletAddress) {
+			return true
+		}
+	}
+	return false
+}
+
+func newResourceQuota(namespace string, hard coreV1.ResourceList) *coreV1.ResourceQuota {
+	return &coreV1.ResourceQuota{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      constants.K8S_QUOTA_NAME,
+			Namespace: namespace,
+		},
+		Spec: coreV1.ResourceQuotaSpec{Hard: hard},
+	}
+}
+
+// newLimitRange gives the containers deployed without resources a default that counts against the quota,
+// and keeps a single container below the quota of the wallet
+func newLimitRange(namespace string, hard coreV1.ResourceList) *coreV1.LimitRange {
+	defaults := coreV1.ResourceList{
+		coreV1.ResourceCPU:              resource.MustParse("1"),
+		coreV1.ResourceMemory:           resource.MustParse("1Gi"),
+		coreV1.ResourceEphemeralStorage: resource.MustParse("1Gi"),
+	}
+	item := coreV1.LimitRangeItem{
+		Type:           coreV1.LimitTypeContainer,
+		Default:        defaults,
+		DefaultRequest: defaults,
+	}
+
+	maxList := coreV1.ResourceList{}
+	for name, quotaName := range map[coreV1.ResourceName]coreV1.ResourceName{
+		coreV1.ResourceCPU:              quotaCpu,
+		coreV1.ResourceMemory:           quotaMemory,
+		coreV1.ResourceEphemeralStorage: quotaStore,
+	} {
+		if limit, ok := hard[quotaName]; ok && limit.Cmp(defaults[name]) >= 0 {
+			maxList[name] = limit
+		}
+	}
+	if len(maxList) > 0 {
+		item.Max = maxList
+	}
+
+	return &coreV1.LimitRange{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      constants.K8S_LIMIT_RANGE_NAME,
+			Namespace: namespace,
+		},
+		Spec: coreV1.LimitRangeSpec{Limits: []coreV1.LimitRangeItem{item}},
+	}
+}
+
+// applyNamespaceQuota creates or updates the ResourceQuota and LimitRange of the namespace of a wallet
+func applyNamespaceQuota(tiers *quotaTiers, namespace, walletAddress string) error {
+	k8sService := NewK8sService()
+	hard := tiers.hard(conf.GetConfig().QUOTA, walletAddress)
+	if err := k8sService.ApplyResourceQuota(context.TODO(), newResourceQuota(namespace, hard)); err != nil {
+		return fmt.Errorf("apply resource quota failed, namespace: %s, error: %v", namespace, err)
+	}
+	if err := k8sService.ApplyLimitRange(context.TODO(), newLimitRange(namespace, hard)); err != nil {
+		return fmt.Errorf("apply limit range failed, namespace: %s, error: %v", namespace, err)
+	}
+	return nil
+}
+
+// checkSpaceQuota returns a *QuotaExceededError when deploying the space with its persistent volumes would exceed
+// the quota of the wallet. A space that is already deployed is replaced by its redeploy, so it is not checked.
+func checkSpaceQuota(walletAddress, spaceUuid, hardwareDesc string, volumes []yaml.VolumeResource) error {
+	if !conf.GetConfig().QUOTA.Enable {
+		return nil
+	}
+	tiers, err := loadQuotaTiers()
+	if err != nil {
+		return err
+	}
+	hard := tiers.hard(conf.GetConfig().QUOTA, walletAddress)
+	if len(hard) == 0 {
+		return nil
+	}
+
+	k8sService := NewK8sService()
+	namespace := constants.K8S_NAMESPACE_NAME_PREFIX + strings.ToLower(walletAddress)
+	deployName := constants.K8S_DEPLOY_NAME_PREFIX + strings.ToLower(spaceUuid)
+	if _, err = k8sService.k8sClient.AppsV1().Deployments(namespace).Get(context.TODO(), deployName, metaV1.GetOptions{}); err == nil {
+		return nil
+	} else if !errors.IsNotFound(err) {
+		return fmt.Errorf("get deployment failed, namespace: %s, error: %v", namespace, err)
+	}
+
+	used := coreV1.ResourceList{}
+	quota, err := k8sService.GetResourceQuota(context.TODO(), namespace, constants.K8S_QUOTA_NAME)
+	if err == nil {
+		used = quota.Status.Used
+	} else if !errors.IsNotFound(err) {
+		return fmt.Errorf("get resource quota failed, namespace: %s, error: %v", namespace, err)
+	}
+
+	runtimeClass, err := getRuntimeClass(context.TODO(), tiers.runtimeClass(conf.GetConfig().RUNTIME, walletAddress))
+	if err != nil {
+		return fmt.Errorf("get the RuntimeClass of wallet %s failed, error: %v", walletAddress, err)
+	}
+	_, hardware := getHardwareDetail(hardwareDesc)
+	requested, err := quotaRequest(hardware, runtimeOverhead(runtimeClass), volumes)
+	if err != nil {
+		return err
+	}
+	for name, limit := range hard {
+		total := used[name].DeepCopy()
+		total.Add(requested[name])
+		if total.Cmp(limit) > 0 {
+			return &QuotaExceededError{
+				WalletAddress: walletAddress,
+				Resource:      name,
+				Used:          used[name],
+				Requested:     requested[name],
+				Hard:          limit,
+			}
+		}
+	}
+	return nil
+}
+
+// quotaRequest is what a space with the hardware counts against the quota, the overhead of the RuntimeClass of the
+// space and the claims of its persistent volumes count as well
+func quotaRequest(hardware models.Resource, overhead coreV1.ResourceList, volumes []yaml.VolumeResource) (coreV1.ResourceList, error) {
+	resources, err := hardwareResourceList(hardware)
+	if err != nil {
+		return nil, err
+	}
+	requested := coreV1.ResourceList{
+		quotaSpaces: resource.MustParse("1"),
+		quotaCpu:    resources[coreV1.ResourceCPU],
+		quotaMemory: resources[coreV1.ResourceMemory],
+		quotaStore:  resources[coreV1.ResourceEphemeralStorage],
+	}
+	for _, name := range gpuResourceNames() {
+		if quantity, ok := resources[name]; ok {
+			requested[quotaGpu(name)] = quantity
+		}
+	}
+	for name, quotaName := range map[coreV1.ResourceName]coreV1.ResourceName{coreV1.ResourceCPU: quotaCpu, coreV1.ResourceMemory: quotaMemory} {
+		if quantity, ok := overhead[name]; ok {
+			total := requested[quotaName].DeepCopy()
+			total.Add(quantity)
+			requested[quotaName] = total
+		}
+	}
+	if len(volumes) > 0 {
+		requested[quotaVolumes] = *resource.NewQuantity(int64(len(volumes)), resource.DecimalSI)
+		requested[quotaVolumeStorage] = volumeStorage(volumes)
+	}
+	return requested, nil
+}
+
+// syncNamespaceQuota applies the QUOTA to the namespaces of all the wallets, so that changes of the config and
+// of the wallet lists reach the spaces that are already deployed
+func syncNamespaceQuota() {
+	namespaces, err := NewK8sService().ListNamespace(context.TODO())
+	if err != nil {
+		logs.GetLogger().Errorf("Failed get all namespace, error: %+v", err)
+		return
+	}
+
+	if !conf.GetConfig().QUOTA.Enable {
+		removeNamespaceQuotas()
+		return
+	}
+	tiers, err := loadQuotaTiers()
+	if err != nil {
+		logs.GetLogger().Errorf("sync namespace quota failed, error: %v", err)
+		return
+	}
+	for _, namespace := range namespaces {
+		if !strings.HasPrefix(namespace, constants.K8S_NAMESPACE_NAME_PREFIX) {
+			continue
+		}
+		walletAddress := strings.TrimPrefix(namespace, constants.K8S_NAMESPACE_NAME_PREFIX)
+		if err = applyNamespaceQuota(tiers, namespace, walletAddress); err != nil {
+			logs.GetLogger().Errorf("sync namespace quota failed, error: %v", err)
+		}
+	}
+}
+
+// removeNamespaceQuotas removes the ResourceQuotas and LimitRanges left in the namespaces of the wallets once QUOTA is
+// disabled. They are listed first, so that nothing is deleted when there is none left.
+func removeNamespaceQuotas() {
+	k8sService := NewK8sService()
+	quotas, err := k8sService.ListResourceQuotas(context.TODO(), constants.K8S_QUOTA_NAME)
+	if err != nil {
+		logs.GetLogger().Errorf("list resource quotas failed, error: %v", err)
+		return
+	}
+	for _, quota := range quotas {
+		if !strings.HasPrefix(quota.Namespace, constants.K8S_NAMESPACE_NAME_PREFIX) {
+			continue
+		}
+		if err = k8sService.DeleteResourceQuota(context.TODO(), quota.Namespace, quota.Name); err != nil && !errors.IsNotFound(err) {
+			logs.GetLogger().Errorf("delete resource quota failed, namespace: %s, error: %v", quota.Namespace, err)
+		}
+	}
+
+	limitRanges, err := k8sService.ListLimitRanges(context.TODO(), constants.K8S_LIMIT_RANGE_NAME)
+	if err != nil {
+		logs.GetLogger().Errorf("list limit ranges failed, error: %v", err)
+		return
+	}
+	for _, limitRange := range limitRanges {
+		if !strings.HasPrefix(limitRange.Namespace, constants.K8S_NAMESPACE_NAME_PREFIX) {
+			continue
+		}
+		if err = k8sService.DeleteLimitRange(context.TODO(), limitRange.Namespace, limitRange.Name); err != nil && !errors.IsNotFound(err) {
+			logs.GetLogger().Errorf("delete limit range failed, namespace: %s, error: %v", limitRange.Namespace, err)
+		}
+	}
+}
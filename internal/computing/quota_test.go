@@ -0,0 +1,139 @@
+package computing
+
+import (
+	"testing"
+
+	"github.com/swanchain/go-computing-provider/conf"
+	"github.com/swanchain/go-computing-provider/internal/models"
+	"github.com/swanchain/go-computing-provider/internal/yaml"
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// assertResourceList fails the test unless the list holds exactly the expected quantities
+func assertResourceList(t *testing.T, name string, list coreV1.ResourceList, expected map[coreV1.ResourceName]string) {
+	t.Helper()
+	if len(list) != len(expected) {
+		t.Errorf("%s: expected %d resources, got %v", name, len(expected), list)
+	}
+	for resourceName, quantity := range expected {
+		got, ok := list[resourceName]
+		if !ok || got.Cmp(resource.MustParse(quantity)) != 0 {
+			t.Errorf("%s: expected %s of %s, got %v", name, quantity, resourceName, list)
+		}
+	}
+}
+
+func TestQuotaTiers_Hard(t *testing.T) {
+	tiers := &quotaTiers{whiteList: []string{"0xWhite"}, blackList: []string{"0xBlack"}}
+	quota := conf.QUOTA{
+		Default:   conf.QuotaTier{Spaces: 2, Cpu: 8, Memory: 16},
+		WhiteList: conf.QuotaTier{Spaces: 10, Cpu: 64, Memory: 256, Storage: 500, Gpu: 4, Volumes: 20, VolumeStorage: 1000},
+	}
+	nvidia, amd := quotaGpu(GpuResourceName), quotaGpu(AmdGpuResourceName)
+
+	for _, tc := range []struct {
+		name          string
+		quota         conf.QUOTA
+		walletAddress string
+		expected      map[coreV1.ResourceName]string
+	}{
+		{"default", quota, "0xOther", map[coreV1.ResourceName]string{
+			quotaSpaces: "2", quotaCpu: "8", quotaMemory: "16Gi",
+		}},
+		{"white list", quota, "0xwhite", map[coreV1.ResourceName]string{
+			quotaSpaces: "10", quotaCpu: "64", quotaMemory: "256Gi", quotaStore: "500Gi", nvidia: "4", amd: "4",
+			quotaVolumes: "20", quotaVolumeStorage: "1000Gi",
+		}},
+		{"black list", quota, "0xBLACK", map[coreV1.ResourceName]string{
+			quotaSpaces: "0", quotaCpu: "0", quotaMemory: "0", quotaStore: "0", nvidia: "0", amd: "0",
+			quotaVolumes: "0", quotaVolumeStorage: "0",
+		}},
+		{"no limits", conf.QUOTA{}, "0xOther", map[coreV1.ResourceName]string{}},
+	} {
+		assertResourceList(t, tc.name, tiers.hard(tc.quota, tc.walletAddress), tc.expected)
+	}
+}
+
+func TestNewLimitRange(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		hard     coreV1.ResourceList
+		expected map[coreV1.ResourceName]string
+	}{
+		{"no limits", coreV1.ResourceList{}, nil},
+		{"limits above the defaults", coreV1.ResourceList{
+			quotaCpu:    resource.MustParse("8"),
+			quotaMemory: resource.MustParse("16Gi"),
+			quotaStore:  resource.MustParse("1Gi"),
+		}, map[coreV1.ResourceName]string{
+			coreV1.ResourceCPU: "8", coreV1.ResourceMemory: "16Gi", coreV1.ResourceEphemeralStorage: "1Gi",
+		}},
+		// a Max below the default request would refuse every container without resources
+		{"limits below the defaults", coreV1.ResourceList{
+			quotaCpu:    resource.MustParse("500m"),
+			quotaMemory: resource.MustParse("4Gi"),
+		}, map[coreV1.ResourceName]string{
+			coreV1.ResourceMemory: "4Gi",
+		}},
+	} {
+		limitRange := newLimitRange("ns-0x01", tc.hard)
+		if len(limitRange.Spec.Limits) != 1 {
+			t.Fatalf("%s: expected one limit, got %d", tc.name, len(limitRange.Spec.Limits))
+		}
+		item := limitRange.Spec.Limits[0]
+		if item.Type != coreV1.LimitTypeContainer || item.Default.Cpu().Cmp(resource.MustParse("1")) != 0 {
+			t.Errorf("%s: expected the container defaults, got %+v", tc.name, item)
+		}
+		if tc.expected == nil {
+			if item.Max != nil {
+				t.Errorf("%s: expected no max, got %v", tc.name, item.Max)
+			}
+			continue
+		}
+		assertResourceList(t, tc.name, item.Max, tc.expected)
+	}
+}
+
+func TestQuotaRequest(t *testing.T) {
+	hardware := models.Resource{
+		Cpu:     models.Specification{Quantity: 4},
+		Memory:  models.Specification{Quantity: 8, Unit: "Gi"},
+		Storage: models.Specification{Quantity: 20, Unit: "Gi"},
+		Gpu:     models.Specification{Quantity: 1, Unit: "NVIDIA A100"},
+	}
+
+	for _, tc := range []struct {
+		name     string
+		overhead coreV1.ResourceList
+		volumes  []yaml.VolumeResource
+		expected map[coreV1.ResourceName]string
+	}{
+		{"no runtime overhead", nil, nil, map[coreV1.ResourceName]string{
+			quotaSpaces: "1", quotaCpu: "4", quotaMemory: "8Gi", quotaStore: "20Gi", quotaGpu(GpuResourceName): "1",
+		}},
+		{"runtime overhead", coreV1.ResourceList{
+			coreV1.ResourceCPU:    resource.MustParse("250m"),
+			coreV1.ResourceMemory: resource.MustParse("120Mi"),
+		}, nil, map[coreV1.ResourceName]string{
+			quotaSpaces: "1", quotaCpu: "4250m", quotaMemory: "8312Mi", quotaStore: "20Gi", quotaGpu(GpuResourceName): "1",
+		}},
+		{"persistent volumes", nil, []yaml.VolumeResource{
+			{Name: "data", Size: resource.MustParse("10Gi")},
+			{Name: "models", Size: resource.MustParse("5Gi")},
+		}, map[coreV1.ResourceName]string{
+			quotaSpaces: "1", quotaCpu: "4", quotaMemory: "8Gi", quotaStore: "20Gi", quotaGpu(GpuResourceName): "1",
+			quotaVolumes: "2", quotaVolumeStorage: "15Gi",
+		}},
+	} {
+		requested, err := quotaRequest(hardware, tc.overhead, tc.volumes)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		assertResourceList(t, tc.name, requested, tc.expected)
+	}
+
+	if _, err := quotaRequest(models.Resource{Memory: models.Specification{Quantity: 8, Unit: "GB!"}}, nil, nil); err == nil {
+		t.Fatal("expected an error for an invalid memory unit")
+	}
+}
@@ -4,11 +4,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
+	"github.com/swanchain/go-computing-provider/internal/metrics"
 	"github.com/swanchain/go-computing-provider/internal/models"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"sync"
 )
 
 const (
@@ -33,7 +37,8 @@ func GetNodeResource(allPods []corev1.Pod, node *corev1.Node) (map[string]int64,
 	var nodeResource = new(models.NodeResource)
 	nodeResource.MachineId = node.Status.NodeInfo.MachineID
 
-	for _, pod := range getPodsFromNode(allPods, node) {
+	nodePods := getPodsFromNode(allPods, node)
+	for _, pod := range nodePods {
 		usedCpu += cpuInPod(&pod)
 		usedMem += memInPod(&pod)
 		usedStorage += storageInPod(&pod)
@@ -46,11 +51,12 @@ func GetNodeResource(allPods []corev1.Pod, node *corev1.Node) (map[string]int64,
 		}
 	}
 
-	nodeResource.Cpu.Total = strconv.FormatInt(node.Status.Capacity.Cpu().Value(), 10)
+	// the pods are scheduled on the allocatable resources, the capacity includes what is reserved for the system
+	nodeResource.Cpu.Total = strconv.FormatInt(node.Status.Allocatable.Cpu().Value(), 10)
 	nodeResource.Cpu.Used = strconv.FormatInt(usedCpu, 10)
-	nodeResource.Cpu.Free = strconv.FormatInt(node.Status.Capacity.Cpu().Value()-usedCpu, 10)
-	nodeResource.Cpu.RemainderNum = node.Status.Capacity.Cpu().Value() - usedCpu
-	remainderResource[ResourceCpu] = node.Status.Capacity.Cpu().Value() - usedCpu
+	nodeResource.Cpu.Free = strconv.FormatInt(node.Status.Allocatable.Cpu().Value()-usedCpu, 10)
+	nodeResource.Cpu.RemainderNum = node.Status.Allocatable.Cpu().Value() - usedCpu
+	remainderResource[ResourceCpu] = node.Status.Allocatable.Cpu().Value() - usedCpu
 
 	nodeResource.Vcpu.Total = nodeResource.Cpu.Total
 	nodeResource.Vcpu.Used = nodeResource.Cpu.Used
@@ -58,7 +64,7 @@ func GetNodeResource(allPods []corev1.Pod, node *corev1.Node) (map[string]int64,
 
 	nodeResource.Memory.Total = fmt.Sprintf("%.2f GiB", float64(node.Status.Allocatable.Memory().Value()/1024/1024/1024))
 	nodeResource.Memory.Used = fmt.Sprintf("%.2f GiB", float64(usedMem/1024/1024/1024))
-	freeMemory := node.Status.Capacity.Memory().Value() - usedMem
+	freeMemory := node.Status.Allocatable.Memory().Value() - usedMem
 	nodeResource.Memory.Free = fmt.Sprintf("%.2f GiB", float64(freeMemory/1024/1024/1024))
 	nodeResource.Memory.RemainderNum = freeMemory
 	remainderResource[ResourceMem] = freeMemory
@@ -70,6 +76,8 @@ func GetNodeResource(allPods []corev1.Pod, node *corev1.Node) (map[string]int64,
 	nodeResource.Storage.RemainderNum = freeStorage
 	remainderResource[ResourceStorage] = freeStorage
 
+	nodeResource.SharedGpus = sharedGpus(node, nodePods)
+
 	return nodeGpu, remainderResource, nodeResource
 }
 
@@ -82,6 +90,8 @@ func getPodsFromNode(allPods []corev1.Pod, node *corev1.Node) (pods []corev1.Pod
 	return pods
 }
 
+// storageInPod is the ephemeral storage requested by the pod and the size of its persistent volumes, which a
+// node-local provisioner takes from the node storage
 func storageInPod(pod *corev1.Pod) (storageUsed int64) {
 	containers := pod.Spec.Containers
 	for _, container := range containers {
@@ -91,6 +101,9 @@ func storageInPod(pod *corev1.Pod) (storageUsed int64) {
 		}
 		storageUsed += val.Value()
 	}
+	if val, err := resource.ParseQuantity(pod.Annotations[spaceVolumeStorageAnnotation]); err == nil {
+		storageUsed += val.Value()
+	}
 	return storageUsed
 }
 
@@ -103,6 +116,10 @@ func cpuInPod(pod *corev1.Pod) (cpuCount int64) {
 		}
 		cpuCount += val.Value()
 	}
+	// the overhead of the RuntimeClass of the pod, e.g. the sandbox of gVisor
+	if val, ok := pod.Spec.Overhead[corev1.ResourceCPU]; ok {
+		cpuCount += val.Value()
+	}
 	return cpuCount
 }
 
@@ -115,19 +132,16 @@ func memInPod(pod *corev1.Pod) (memCount int64) {
 		}
 		memCount += val.Value()
 	}
+	if val, ok := pod.Spec.Overhead[corev1.ResourceMemory]; ok {
+		memCount += val.Value()
+	}
 	return memCount
 }
 
 func gpuInPod(pod *corev1.Pod) (gpuName string, gpuCount int64) {
-	containers := pod.Spec.Containers
-	for _, container := range containers {
-		val, ok := container.Resources.Requests["nvidia.com/gpu"]
-		if !ok {
-			continue
-		}
-		gpuCount += val.Value()
+	for _, name := range gpuResourceNames() {
+		gpuCount += gpuResourceInPod(pod, name)
 	}
-
 	if pod.Spec.NodeSelector != nil {
 		for k := range pod.Spec.NodeSelector {
 			if k != "" {
@@ -168,6 +182,68 @@ func checkClusterProviderStatus(nodeResources []*models.NodeResource) {
 	}
 }
 
+// nodeMetricLabels holds the label values of the node gauges set by the last record, the gauges of the nodes and
+// gpus gone since are deleted instead of resetting all of them, which would blank them for a scrape in between
+var nodeMetricLabels struct {
+	sync.Mutex
+	nodes map[string]struct{}
+	gpus  map[[2]string]struct{}
+}
+
+// recordNodeResourceMetrics exports the free resources of the nodes, the memory and storage in bytes
+func recordNodeResourceMetrics(nodeResources []*models.NodeResource) {
+	nodes := make(map[string]struct{})
+	gpus := make(map[[2]string]float64)
+	for _, node := range nodeResources {
+		nodes[node.MachineId] = struct{}{}
+		freeCpu, _ := strconv.ParseFloat(node.Cpu.Free, 64)
+		metrics.NodeFreeCpu.WithLabelValues(node.MachineId).Set(freeCpu)
+		metrics.NodeFreeMemory.WithLabelValues(node.MachineId).Set(parseFreeBytes(node.Memory.Free))
+		metrics.NodeFreeStorage.WithLabelValues(node.MachineId).Set(parseFreeBytes(node.Storage.Free))
+
+		for _, gpu := range node.Gpu.Details {
+			labels := [2]string{node.MachineId, gpu.ProductName}
+			free := gpus[labels]
+			if gpu.Status == models.Available {
+				free++
+			}
+			gpus[labels] = free
+		}
+	}
+	for labels, free := range gpus {
+		metrics.NodeFreeGpu.WithLabelValues(labels[0], labels[1]).Set(free)
+	}
+
+	nodeMetricLabels.Lock()
+	defer nodeMetricLabels.Unlock()
+	for machineId := range nodeMetricLabels.nodes {
+		if _, ok := nodes[machineId]; !ok {
+			metrics.NodeFreeCpu.DeleteLabelValues(machineId)
+			metrics.NodeFreeMemory.DeleteLabelValues(machineId)
+			metrics.NodeFreeStorage.DeleteLabelValues(machineId)
+		}
+	}
+	for labels := range nodeMetricLabels.gpus {
+		if _, ok := gpus[labels]; !ok {
+			metrics.NodeFreeGpu.DeleteLabelValues(labels[0], labels[1])
+		}
+	}
+	nodeMetricLabels.nodes = nodes
+	nodeMetricLabels.gpus = make(map[[2]string]struct{}, len(gpus))
+	for labels := range gpus {
+		nodeMetricLabels.gpus[labels] = struct{}{}
+	}
+}
+
+// parseFreeBytes parses the free memory or storage of a node, formatted like "12.50 GiB", into bytes
+func parseFreeBytes(free string) float64 {
+	quantity, err := resource.ParseQuantity(strings.TrimSuffix(strings.ReplaceAll(free, " ", ""), "B"))
+	if err != nil {
+		return 0
+	}
+	return quantity.AsApproximateFloat64()
+}
+
 func defaultResourcePolicy() models.ResourcePolicy {
 	return models.ResourcePolicy{
 		Cpu: models.CpuQuota{
@@ -0,0 +1,64 @@
+package computing
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/swanchain/go-computing-provider/internal/metrics"
+	"github.com/swanchain/go-computing-provider/internal/models"
+)
+
+func TestParseFreeBytes(t *testing.T) {
+	for free, expected := range map[string]float64{
+		"12.50 GiB": 12.5 * 1024 * 1024 * 1024,
+		"0.00 GiB":  0,
+		"512 MiB":   512 * 1024 * 1024,
+		"":          0,
+		"unknown":   0,
+	} {
+		if bytes := parseFreeBytes(free); bytes != expected {
+			t.Errorf("parseFreeBytes(%q) = %v, expected %v", free, bytes, expected)
+		}
+	}
+}
+
+func TestRecordNodeResourceMetrics(t *testing.T) {
+	gpu := func(status models.GpuStatus) models.GpuDetail {
+		return models.GpuDetail{ProductName: "NVIDIA A100", Status: status}
+	}
+	node := func(machineId string, gpus ...models.GpuDetail) *models.NodeResource {
+		return &models.NodeResource{
+			MachineId: machineId,
+			Cpu:       models.Common{Free: "6"},
+			Memory:    models.Common{Free: "2.00 GiB"},
+			Storage:   models.Common{Free: "100.00 GiB"},
+			Gpu:       models.Gpu{Details: gpus},
+		}
+	}
+
+	recordNodeResourceMetrics([]*models.NodeResource{
+		node("node-1", gpu(models.Available), gpu(models.Available), gpu(models.Occupied)),
+		node("node-2", gpu(models.Occupied)),
+	})
+	if free := testutil.ToFloat64(metrics.NodeFreeGpu.WithLabelValues("node-1", "NVIDIA A100")); free != 2 {
+		t.Fatalf("expected 2 free gpus on node-1, got %v", free)
+	}
+	if free := testutil.ToFloat64(metrics.NodeFreeGpu.WithLabelValues("node-2", "NVIDIA A100")); free != 0 {
+		t.Fatalf("expected no free gpu on node-2, got %v", free)
+	}
+	if free := testutil.ToFloat64(metrics.NodeFreeMemory.WithLabelValues("node-1")); free != 2*1024*1024*1024 {
+		t.Fatalf("expected 2 GiB of free memory on node-1, got %v", free)
+	}
+
+	// node-2 has left the cluster
+	recordNodeResourceMetrics([]*models.NodeResource{node("node-1", gpu(models.Available))})
+	if count := testutil.CollectAndCount(metrics.NodeFreeCpu); count != 1 {
+		t.Fatalf("expected the cpu gauge of node-1 only, got %d gauges", count)
+	}
+	if count := testutil.CollectAndCount(metrics.NodeFreeGpu); count != 1 {
+		t.Fatalf("expected the gpu gauge of node-1 only, got %d gauges", count)
+	}
+	if free := testutil.ToFloat64(metrics.NodeFreeGpu.WithLabelValues("node-1", "NVIDIA A100")); free != 1 {
+		t.Fatalf("expected 1 free gpu on node-1, got %v", free)
+	}
+}
@@ -0,0 +1,134 @@
+package computing
+
+import (
+	"context"
+	"fmt"
+	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
+	"github.com/swanchain/go-computing-provider/conf"
+	coreV1 "k8s.io/api/core/v1"
+	nodeV1 "k8s.io/api/node/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+)
+
+func init() {
+	conf.OnReload(recheckRuntimeClasses)
+}
+
+// recheckRuntimeClasses reports the RuntimeClasses of a reloaded config missing from the cluster, the spaces of
+// their tier fail to deploy until they are created
+func recheckRuntimeClasses(old, cfg *conf.ComputeNode) {
+	if old == nil || old.RUNTIME == cfg.RUNTIME {
+		return
+	}
+	if err := checkRuntimeClasses(cfg.RUNTIME); err != nil {
+		logs.GetLogger().Errorf("reload config, %v", err)
+	}
+}
+
+// CheckRuntimeClasses returns an error naming the RuntimeClasses of [RUNTIME] missing from the cluster
+func CheckRuntimeClasses() error {
+	return checkRuntimeClasses(conf.GetConfig().RUNTIME)
+}
+
+func checkRuntimeClasses(runtime conf.RUNTIME) error {
+	for _, tier := range []struct {
+		field, class string
+	}{{"RUNTIME.Default", runtime.Default}, {"RUNTIME.WhiteList", runtime.WhiteList}} {
+		if tier.class == "" {
+			continue
+		}
+		if _, err := getRuntimeClass(context.TODO(), tier.class); errors.IsNotFound(err) {
+			return fmt.Errorf("%s: the RuntimeClass %s does not exist in the cluster, create it or leave %s empty for the default runtime", tier.field, tier.class, tier.field)
+		} else if err != nil {
+			return fmt.Errorf("%s: get the RuntimeClass %s failed, error: %v", tier.field, tier.class, err)
+		}
+	}
+	return nil
+}
+
+// runtimeClass is the RuntimeClass of the spaces of the wallet by its tier, "" for the default runtime
+func (t *quotaTiers) runtimeClass(runtime conf.RUNTIME, walletAddress string) string {
+	if containsWallet(t.whiteList, walletAddress) {
+		return runtime.WhiteList
+	}
+	return runtime.Default
+}
+
+// spaceRuntimeClass returns the RuntimeClass the spaces of the wallet run with, nil for the default runtime. The
+// wallet lists are only fetched when the tiers use different RuntimeClasses.
+func spaceRuntimeClass(ctx context.Context, walletAddress string) (*nodeV1.RuntimeClass, error) {
+	runtime := conf.GetConfig().RUNTIME
+	class := runtime.Default
+	if runtime.WhiteList != runtime.Default {
+		tiers, err := loadQuotaTiers()
+		if err != nil {
+			return nil, err
+		}
+		class = tiers.runtimeClass(runtime, walletAddress)
+	}
+	return getRuntimeClass(ctx, class)
+}
+
+// getRuntimeClass returns the RuntimeClass of the name, nil for ""
+func getRuntimeClass(ctx context.Context, name string) (*nodeV1.RuntimeClass, error) {
+	if name == "" {
+		return nil, nil
+	}
+	return NewK8sService().GetRuntimeClass(ctx, name)
+}
+
+// runtimeOverhead is the resources the runtime of the RuntimeClass takes in every pod besides its containers,
+// e.g. the sandbox of gVisor or the VM of Kata. k8s adds it to the requests of the pods it schedules.
+func runtimeOverhead(runtimeClass *nodeV1.RuntimeClass) coreV1.ResourceList {
+	if runtimeClass == nil || runtimeClass.Overhead == nil {
+		return nil
+	}
+	return runtimeClass.Overhead.PodFixed
+}
+
+// withRuntimeOverhead adds the overhead of the runtime to what the request needs from a node, the cpu is rounded up
+// to whole cores like the requests of the pods on the nodes
+func withRuntimeOverhead(req PlacementRequest, overhead coreV1.ResourceList) PlacementRequest {
+	if cpu, ok := overhead[coreV1.ResourceCPU]; ok {
+		req.Cpu = (req.Cpu*1000 + cpu.MilliValue() + 999) / 1000
+	}
+	if memory, ok := overhead[coreV1.ResourceMemory]; ok {
+		req.Memory += memory.Value()
+	}
+	return req
+}
+
+// runtimeClassNodes is the nodes the pods of the RuntimeClass can run on, those with the labels of its scheduling
+func runtimeClassNodes(nodes []NodeCapacity, runtimeClass *nodeV1.RuntimeClass) []NodeCapacity {
+	if runtimeClass == nil || runtimeClass.Scheduling == nil || len(runtimeClass.Scheduling.NodeSelector) == 0 {
+		return nodes
+	}
+	var supported []NodeCapacity
+	for _, node := range nodes {
+		matches := true
+		for key, value := range runtimeClass.Scheduling.NodeSelector {
+			if node.Labels[key] != value {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			supported = append(supported, node)
+		} else {
+			logs.GetLogger().Infof("placement: node %s skipped, no runtime %s", node.Name, runtimeClass.Name)
+		}
+	}
+	return supported
+}
+
+// applyRuntimeClass runs the pods of the space with the RuntimeClass of the tier of its wallet
+func (d *Deploy) applyRuntimeClass(spec *coreV1.PodSpec) error {
+	runtimeClass, err := spaceRuntimeClass(d.context(), d.walletAddress)
+	if err != nil {
+		return fmt.Errorf("get the RuntimeClass of wallet %s failed, error: %v", d.walletAddress, err)
+	}
+	if runtimeClass != nil {
+		spec.RuntimeClassName = &runtimeClass.Name
+	}
+	return nil
+}
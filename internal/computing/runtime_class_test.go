@@ -0,0 +1,75 @@
+package computing
+
+import (
+	"testing"
+
+	"github.com/swanchain/go-computing-provider/conf"
+	coreV1 "k8s.io/api/core/v1"
+	nodeV1 "k8s.io/api/node/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRuntimeClassTier(t *testing.T) {
+	tiers := &quotaTiers{whiteList: []string{"0xAbC"}}
+	runtime := conf.RUNTIME{Default: "gvisor", WhiteList: "kata"}
+	if class := tiers.runtimeClass(runtime, "0xabc"); class != "kata" {
+		t.Errorf("expected the whitelisted wallet to run with kata, got %q", class)
+	}
+	if class := tiers.runtimeClass(runtime, "0xdef"); class != "gvisor" {
+		t.Errorf("expected another wallet to run with gvisor, got %q", class)
+	}
+}
+
+func TestWithRuntimeOverhead(t *testing.T) {
+	req := PlacementRequest{Cpu: 2, Memory: 4 << 30}
+	overhead := coreV1.ResourceList{
+		coreV1.ResourceCPU:    resource.MustParse("250m"),
+		coreV1.ResourceMemory: resource.MustParse("160Mi"),
+	}
+	got := withRuntimeOverhead(req, overhead)
+	if got.Cpu != 3 || got.Memory != 4<<30+160<<20 {
+		t.Errorf("expected 3 cores and 4 GiB + 160 MiB, got %d cores and %d bytes", got.Cpu, got.Memory)
+	}
+	if got = withRuntimeOverhead(req, nil); got != req {
+		t.Errorf("expected the request unchanged without overhead, got %+v", got)
+	}
+}
+
+func TestRuntimeClassNodes(t *testing.T) {
+	nodes := []NodeCapacity{
+		{Name: "node-1", Labels: map[string]string{"runtime": "gvisor"}},
+		{Name: "node-2", Labels: map[string]string{"runtime": "runc"}},
+		{Name: "node-3"},
+	}
+	gvisor := &nodeV1.RuntimeClass{
+		ObjectMeta: metaV1.ObjectMeta{Name: "gvisor"},
+		Handler:    "runsc",
+		Scheduling: &nodeV1.Scheduling{NodeSelector: map[string]string{"runtime": "gvisor"}},
+	}
+	if supported := runtimeClassNodes(nodes, gvisor); len(supported) != 1 || supported[0].Name != "node-1" {
+		t.Errorf("expected only node-1 to support gvisor, got %v", supported)
+	}
+	if supported := runtimeClassNodes(nodes, nil); len(supported) != len(nodes) {
+		t.Errorf("expected all the nodes for the default runtime, got %v", supported)
+	}
+}
+
+func TestPodOverheadCounted(t *testing.T) {
+	pod := coreV1.Pod{Spec: coreV1.PodSpec{
+		Containers: []coreV1.Container{{Resources: coreV1.ResourceRequirements{Requests: coreV1.ResourceList{
+			coreV1.ResourceCPU:    resource.MustParse("2"),
+			coreV1.ResourceMemory: resource.MustParse("4Gi"),
+		}}}},
+		Overhead: coreV1.ResourceList{
+			coreV1.ResourceCPU:    resource.MustParse("1"),
+			coreV1.ResourceMemory: resource.MustParse("512Mi"),
+		},
+	}}
+	if cpu := cpuInPod(&pod); cpu != 3 {
+		t.Errorf("expected 3 cores with the overhead, got %d", cpu)
+	}
+	if memory := memInPod(&pod); memory != 4<<30+512<<20 {
+		t.Errorf("expected 4.5 GiB with the overhead, got %d", memory)
+	}
+}
@@ -0,0 +1,345 @@
+package computing
+
+import (
+	"fmt"
+	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
+	"github.com/swanchain/go-computing-provider/conf"
+	"github.com/swanchain/go-computing-provider/internal/models"
+	corev1 "k8s.io/api/core/v1"
+	"sort"
+	"strings"
+)
+
+// the placement strategies of SCHEDULER.Strategy
+const (
+	StrategyBinPack     = "binpack"
+	StrategySpread      = "spread"
+	StrategyGpuAffinity = "gpu-affinity"
+)
+
+// PlacementRequest is what a space or an ubi task needs from a node
+type PlacementRequest struct {
+	Cpu     int64 // cores
+	Memory  int64 // bytes
+	Storage int64 // bytes
+	Gpu     int64
+	// GpuName is the gpu model with the spaces replaced by "-", "" for a task without gpu
+	GpuName string
+	// GpuExact requires the gpu model of the node to be GpuName, otherwise a model containing GpuName fits,
+	// e.g. NVIDIA-A100 fits NVIDIA-A100-PCIE-40GB
+	GpuExact bool
+	// GpuResource is the gpu resource class, a MIG profile or a shared gpu, "" or GpuResourceName for whole gpus
+	GpuResource string
+}
+
+// needsGpu tells if the request asks for a gpu, a shared gpu can be asked for without a gpu model
+func (r PlacementRequest) needsGpu() bool {
+	return r.GpuName != "" || isSharedGpuResource(r.GpuResource)
+}
+
+// NodeCapacity is the total and free resources of a node, see newNodeCapacity
+type NodeCapacity struct {
+	Name   string
+	Labels map[string]string
+
+	Cpu, FreeCpu         int64
+	Memory, FreeMemory   int64
+	Storage, FreeStorage int64
+	// Gpus is the total and used count of the whole gpus by gpu model
+	Gpus map[string]GpuCapacity
+	// SharedGpus is the total and used count of the MIG profiles and time-sliced gpus by resource class
+	SharedGpus map[string]GpuCapacity
+}
+
+// gpu is the capacity of the gpus the request uses on the node
+func (n NodeCapacity) gpu(gpuName string, req PlacementRequest) GpuCapacity {
+	if isSharedGpuResource(req.GpuResource) {
+		return n.SharedGpus[req.GpuResource]
+	}
+	return n.Gpus[gpuName]
+}
+
+type GpuCapacity struct {
+	Total int64
+	Used  int64
+}
+
+func (g GpuCapacity) Free() int64 {
+	return g.Total - g.Used
+}
+
+// Placement is the node chosen for a request, GpuProductName is the gpu model of the node used by the request
+type Placement struct {
+	NodeName       string
+	GpuProductName string
+	Score          float64
+}
+
+// PlacementStrategy scores the nodes a request fits on, the node with the highest score is chosen. Score also
+// returns the terms of the score, which are logged to explain the choice.
+type PlacementStrategy interface {
+	Name() string
+	Score(node NodeCapacity, gpuName string, req PlacementRequest) (float64, string)
+}
+
+// Scheduler chooses the node of a request by a PlacementStrategy
+type Scheduler struct {
+	strategy PlacementStrategy
+}
+
+// NewScheduler returns the scheduler of the named strategy, "" is StrategyBinPack
+func NewScheduler(strategy string) (*Scheduler, error) {
+	switch strategy {
+	case "", StrategyBinPack:
+		return &Scheduler{strategy: binPack{}}, nil
+	case StrategySpread:
+		return &Scheduler{strategy: spread{}}, nil
+	case StrategyGpuAffinity:
+		return &Scheduler{strategy: gpuAffinity{}}, nil
+	default:
+		return nil, fmt.Errorf("unknown placement strategy %q, use %s, %s or %s", strategy, StrategyBinPack, StrategySpread, StrategyGpuAffinity)
+	}
+}
+
+// newConfiguredScheduler returns the scheduler of SCHEDULER.Strategy
+func newConfiguredScheduler() (*Scheduler, error) {
+	return NewScheduler(conf.GetConfig().SCHEDULER.Strategy)
+}
+
+// Place returns the node with the highest score among the nodes the request fits on, or nil when it fits on
+// none. Every node is logged with its score or the reason it does not fit.
+func (s *Scheduler) Place(req PlacementRequest, nodes []NodeCapacity) *Placement {
+	name := s.strategy.Name()
+	logs.GetLogger().Infof("placement[%s]: need cpu: %d, memory: %s, storage: %s, gpu: %d %s",
+		name, req.Cpu, formatGiB(req.Memory), formatGiB(req.Storage), req.Gpu, req.GpuName)
+
+	var best *Placement
+	for _, node := range nodes {
+		gpuName, reason := fits(node, req)
+		if reason != "" {
+			logs.GetLogger().Infof("placement[%s]: node %s skipped, %s", name, node.Name, reason)
+			continue
+		}
+		score, terms := s.strategy.Score(node, gpuName, req)
+		logs.GetLogger().Infof("placement[%s]: node %s score %.3f (%s)", name, node.Name, score, terms)
+		if best == nil || score > best.Score || (score == best.Score && node.Name < best.NodeName) {
+			best = &Placement{NodeName: node.Name, GpuProductName: gpuName, Score: score}
+		}
+	}
+
+	if best == nil {
+		logs.GetLogger().Infof("placement[%s]: no node fits", name)
+	} else {
+		logs.GetLogger().Infof("placement[%s]: chose node %s, gpu: %s", name, best.NodeName, best.GpuProductName)
+	}
+	return best
+}
+
+// fits returns the gpu model of the node the request fits on, or the reason it does not fit. Of the gpu models
+// that fit, the one with the fewest free gpus is used.
+func fits(node NodeCapacity, req PlacementRequest) (string, string) {
+	if req.Cpu > node.FreeCpu {
+		return "", fmt.Sprintf("cpu: %d free, %d needed", node.FreeCpu, req.Cpu)
+	}
+	if req.Memory > node.FreeMemory {
+		return "", fmt.Sprintf("memory: %s free, %s needed", formatGiB(node.FreeMemory), formatGiB(req.Memory))
+	}
+	if req.Storage > node.FreeStorage {
+		return "", fmt.Sprintf("storage: %s free, %s needed", formatGiB(node.FreeStorage), formatGiB(req.Storage))
+	}
+	if isSharedGpuResource(req.GpuResource) {
+		return fitsSharedGpu(node, req)
+	}
+	if req.GpuName == "" {
+		return "", ""
+	}
+
+	var gpuName string
+	var free int64
+	for _, name := range sortedGpuNames(node.Gpus) {
+		if !matchGpu(name, req) {
+			continue
+		}
+		if f := node.Gpus[name].Free(); f >= req.Gpu && (gpuName == "" || f < free) {
+			gpuName, free = name, f
+		}
+	}
+	if gpuName == "" {
+		return "", fmt.Sprintf("gpu: no %d free %s", req.Gpu, req.GpuName)
+	}
+	return gpuName, ""
+}
+
+// fitsSharedGpu returns the gpu model of the node the shared gpu request fits on, the model is "" for a request
+// without a gpu model
+func fitsSharedGpu(node NodeCapacity, req PlacementRequest) (string, string) {
+	if free := node.SharedGpus[req.GpuResource].Free(); free < req.Gpu {
+		return "", fmt.Sprintf("gpu: %d %s free, %d needed", free, req.GpuResource, req.Gpu)
+	}
+	if req.GpuName == "" {
+		return "", ""
+	}
+	for _, name := range sortedGpuNames(node.Gpus) {
+		if matchGpu(name, req) {
+			return name, ""
+		}
+	}
+	return "", fmt.Sprintf("gpu: no %s of %s", req.GpuResource, req.GpuName)
+}
+
+func matchGpu(name string, req PlacementRequest) bool {
+	if req.GpuExact {
+		return strings.EqualFold(name, req.GpuName)
+	}
+	return strings.Contains(strings.ToUpper(name), strings.ToUpper(req.GpuName))
+}
+
+func sortedGpuNames(gpus map[string]GpuCapacity) []string {
+	var names []string
+	for name := range gpus {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// utilization is the share of the node in use after placing the request, by resource. The gpu is counted
+// for the requests with a gpu only.
+func utilization(node NodeCapacity, gpuName string, req PlacementRequest) map[string]float64 {
+	used := map[string]float64{
+		"cpu":    share(node.Cpu-node.FreeCpu+req.Cpu, node.Cpu),
+		"memory": share(node.Memory-node.FreeMemory+req.Memory, node.Memory),
+	}
+	if req.needsGpu() {
+		gpu := node.gpu(gpuName, req)
+		used["gpu"] = share(gpu.Used+req.Gpu, gpu.Total)
+	}
+	return used
+}
+
+func share(part, total int64) float64 {
+	if total <= 0 {
+		return 1
+	}
+	return float64(part) / float64(total)
+}
+
+func mean(values map[string]float64) (float64, string) {
+	var sum float64
+	var terms []string
+	for _, name := range []string{"cpu", "memory", "gpu"} {
+		if v, ok := values[name]; ok {
+			sum += v
+			terms = append(terms, fmt.Sprintf("%s %.2f", name, v))
+		}
+	}
+	return sum / float64(len(values)), strings.Join(terms, ", ")
+}
+
+// binPack fills the busiest nodes first, keeping whole nodes free for large requests
+type binPack struct{}
+
+func (binPack) Name() string {
+	return StrategyBinPack
+}
+
+func (binPack) Score(node NodeCapacity, gpuName string, req PlacementRequest) (float64, string) {
+	score, terms := mean(utilization(node, gpuName, req))
+	return score, "used after placement: " + terms
+}
+
+// spread places on the least busy nodes first, spreading the load over the cluster
+type spread struct{}
+
+func (spread) Name() string {
+	return StrategySpread
+}
+
+func (spread) Score(node NodeCapacity, gpuName string, req PlacementRequest) (float64, string) {
+	free := make(map[string]float64)
+	for name, used := range utilization(node, gpuName, req) {
+		free[name] = 1 - used
+	}
+	score, terms := mean(free)
+	return score, "free after placement: " + terms
+}
+
+// gpuAffinity keeps the gpu nodes for gpu requests: requests without gpu go to the nodes without gpu first,
+// and a gpu request goes to the gpu model with the fewest gpus left, so that the gpus are not fragmented
+type gpuAffinity struct{}
+
+func (gpuAffinity) Name() string {
+	return StrategyGpuAffinity
+}
+
+func (gpuAffinity) Score(node NodeCapacity, gpuName string, req PlacementRequest) (float64, string) {
+	packed, terms := binPack{}.Score(node, gpuName, req)
+	if !req.needsGpu() {
+		var gpus int64
+		for _, gpu := range node.Gpus {
+			gpus += gpu.Total
+		}
+		for _, gpu := range node.SharedGpus {
+			gpus += gpu.Total
+		}
+		if gpus > 0 {
+			return packed - 1, fmt.Sprintf("%d gpus kept free, %s", gpus, terms)
+		}
+		return packed, "no gpu, " + terms
+	}
+	gpu := node.gpu(gpuName, req)
+	left := gpu.Free() - req.Gpu
+	if isSharedGpuResource(req.GpuResource) {
+		gpuName = req.GpuResource
+	}
+	return share(gpu.Total-left, gpu.Total) + packed/10, fmt.Sprintf("%d %s left, %s", left, gpuName, terms)
+}
+
+// newNodeCapacity builds the capacity of a node from the output of GetNodeResource, and the total gpus by
+// model of the node from GetNodeGpuSummary
+func newNodeCapacity(node *corev1.Node, nodeGpu map[string]int64, remainderResource map[string]int64, shared []models.SharedGpu, gpuSummary map[string]int64) NodeCapacity {
+	capacity := NodeCapacity{
+		Name:        node.Name,
+		Labels:      node.Labels,
+		Cpu:         node.Status.Allocatable.Cpu().Value(),
+		FreeCpu:     remainderResource[ResourceCpu],
+		Memory:      node.Status.Allocatable.Memory().Value(),
+		FreeMemory:  remainderResource[ResourceMem],
+		Storage:     node.Status.Allocatable.StorageEphemeral().Value(),
+		FreeStorage: remainderResource[ResourceStorage],
+		Gpus:        make(map[string]GpuCapacity),
+		SharedGpus:  make(map[string]GpuCapacity),
+	}
+	for name, total := range wholeGpus(node, gpuSummary) {
+		capacity.Gpus[name] = GpuCapacity{Total: total, Used: usedGpu(nodeGpu, name)}
+	}
+	for _, gpu := range shared {
+		capacity.SharedGpus[gpu.ResourceName] = GpuCapacity{Total: gpu.Total, Used: gpu.Used}
+	}
+	return capacity
+}
+
+// usedGpu is the count of the gpu model in use, the pods are counted by the gpu label of their node selector
+func usedGpu(nodeGpu map[string]int64, gpuName string) int64 {
+	var used int64
+	for name, count := range nodeGpu {
+		if name != "" && strings.EqualFold(strings.ReplaceAll(name, " ", "-"), gpuName) {
+			used += count
+		}
+	}
+	return used
+}
+
+// nodeCapacities builds the capacity of the nodes with the pods running on them
+func nodeCapacities(nodes []corev1.Node, activePods []corev1.Pod, nodeGpuSummary map[string]map[string]int64) []NodeCapacity {
+	var capacities []NodeCapacity
+	for i := range nodes {
+		nodeGpu, remainderResource, nodeResource := GetNodeResource(activePods, &nodes[i])
+		capacities = append(capacities, newNodeCapacity(&nodes[i], nodeGpu, remainderResource, nodeResource.SharedGpus, nodeGpuSummary[nodes[i].Name]))
+	}
+	return capacities
+}
+
+func formatGiB(bytes int64) string {
+	return fmt.Sprintf("%.2f GiB", float64(bytes)/1024/1024/1024)
+}
@@ -0,0 +1,165 @@
+package computing
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const gib = 1024 * 1024 * 1024
+
+func newTestNode(name string, cpu, memoryGiB int64, labels map[string]string) corev1.Node {
+	resources := corev1.ResourceList{
+		corev1.ResourceCPU:              *resource.NewQuantity(cpu, resource.DecimalSI),
+		corev1.ResourceMemory:           *resource.NewQuantity(memoryGiB*gib, resource.BinarySI),
+		corev1.ResourceEphemeralStorage: *resource.NewQuantity(500*gib, resource.BinarySI),
+	}
+	return corev1.Node{
+		ObjectMeta: metaV1.ObjectMeta{Name: name, Labels: labels},
+		Status:     corev1.NodeStatus{Capacity: resources, Allocatable: resources},
+	}
+}
+
+func newTestPod(nodeName string, cpu, memoryGiB int64, gpuName string, gpu int64) corev1.Pod {
+	requests := corev1.ResourceList{
+		corev1.ResourceCPU:    *resource.NewQuantity(cpu, resource.DecimalSI),
+		corev1.ResourceMemory: *resource.NewQuantity(memoryGiB*gib, resource.BinarySI),
+		"nvidia.com/gpu":      *resource.NewQuantity(gpu, resource.DecimalSI),
+	}
+	return corev1.Pod{
+		Spec: corev1.PodSpec{
+			NodeName:     nodeName,
+			NodeSelector: generateLabel(gpuName),
+			Containers:   []corev1.Container{{Resources: corev1.ResourceRequirements{Requests: requests}}},
+		},
+	}
+}
+
+// newTestCluster has an idle cpu node, a busy cpu node and a gpu node with 4 A100 of which 1 is used and
+// 2 RTX 4090 of which 1 is used
+func newTestCluster() []NodeCapacity {
+	nodes := []corev1.Node{
+		newTestNode("cpu-idle", 32, 128, nil),
+		newTestNode("cpu-busy", 32, 128, nil),
+		newTestNode("gpu", 64, 256, nil),
+	}
+	pods := []corev1.Pod{
+		newTestPod("cpu-busy", 24, 96, "", 0),
+		newTestPod("gpu", 8, 32, "NVIDIA-A100-PCIE-40GB", 1),
+		newTestPod("gpu", 8, 32, "NVIDIA-GeForce-RTX-4090", 1),
+	}
+	gpuSummary := map[string]map[string]int64{
+		"gpu": {"NVIDIA-A100-PCIE-40GB": 4, "NVIDIA-GeForce-RTX-4090": 2},
+	}
+	return nodeCapacities(nodes, pods, gpuSummary)
+}
+
+func place(t *testing.T, strategy string, req PlacementRequest) *Placement {
+	scheduler, err := NewScheduler(strategy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return scheduler.Place(req, newTestCluster())
+}
+
+func TestNodeCapacities(t *testing.T) {
+	for _, node := range newTestCluster() {
+		if node.Name != "gpu" {
+			continue
+		}
+		if node.FreeCpu != 48 || node.FreeMemory != 192*gib {
+			t.Fatalf("expected 48 cpu and 192 GiB free, got %d cpu and %d memory", node.FreeCpu, node.FreeMemory)
+		}
+		if a100 := node.Gpus["NVIDIA-A100-PCIE-40GB"]; a100.Total != 4 || a100.Used != 1 {
+			t.Fatalf("expected 1 of 4 A100 used, got %+v", a100)
+		}
+	}
+}
+
+func TestScheduler_BinPackFillsBusiestNode(t *testing.T) {
+	placement := place(t, StrategyBinPack, PlacementRequest{Cpu: 4, Memory: 16 * gib})
+	if placement == nil || placement.NodeName != "cpu-busy" {
+		t.Fatalf("expected cpu-busy, got %+v", placement)
+	}
+}
+
+func TestScheduler_SpreadPicksIdleNode(t *testing.T) {
+	placement := place(t, StrategySpread, PlacementRequest{Cpu: 4, Memory: 16 * gib})
+	if placement == nil || placement.NodeName != "cpu-idle" {
+		t.Fatalf("expected cpu-idle, got %+v", placement)
+	}
+}
+
+func TestScheduler_SkipsNodesThatDoNotFit(t *testing.T) {
+	placement := place(t, StrategyBinPack, PlacementRequest{Cpu: 16, Memory: 16 * gib})
+	if placement == nil || placement.NodeName == "cpu-busy" {
+		t.Fatalf("expected a node with 16 free cpu, got %+v", placement)
+	}
+
+	if placement = place(t, StrategyBinPack, PlacementRequest{Cpu: 128}); placement != nil {
+		t.Fatalf("expected no node to fit, got %+v", placement)
+	}
+}
+
+func TestScheduler_GpuAffinityKeepsGpuNodesFree(t *testing.T) {
+	// the gpu node is the busiest by share once the request is placed, binpack would choose it
+	req := PlacementRequest{Cpu: 8, Memory: 32 * gib}
+	if placement := place(t, StrategyGpuAffinity, req); placement == nil || placement.NodeName != "cpu-busy" {
+		t.Fatalf("expected cpu-busy, got %+v", placement)
+	}
+}
+
+func TestScheduler_GpuModelMatch(t *testing.T) {
+	placement := place(t, StrategyGpuAffinity, PlacementRequest{Cpu: 4, Memory: 16 * gib, Gpu: 1, GpuName: "NVIDIA-A100"})
+	if placement == nil || placement.NodeName != "gpu" || placement.GpuProductName != "NVIDIA-A100-PCIE-40GB" {
+		t.Fatalf("expected an A100 of the gpu node, got %+v", placement)
+	}
+
+	if placement = place(t, StrategyBinPack, PlacementRequest{Gpu: 1, GpuName: "NVIDIA-A100", GpuExact: true}); placement != nil {
+		t.Fatalf("expected no exact match of NVIDIA-A100, got %+v", placement)
+	}
+
+	if placement = place(t, StrategyBinPack, PlacementRequest{Gpu: 2, GpuName: "NVIDIA-GeForce-RTX-4090", GpuExact: true}); placement != nil {
+		t.Fatalf("expected 1 free RTX 4090 not to fit 2, got %+v", placement)
+	}
+}
+
+func TestScheduler_GpuAffinityPrefersFewestFreeGpus(t *testing.T) {
+	// both models fit, the RTX 4090 has 1 left and the A100 3, so the 4090 is used up first
+	placement := place(t, StrategyGpuAffinity, PlacementRequest{Cpu: 4, Gpu: 1, GpuName: "NVIDIA"})
+	if placement == nil || placement.GpuProductName != "NVIDIA-GeForce-RTX-4090" {
+		t.Fatalf("expected the RTX 4090, got %+v", placement)
+	}
+}
+
+func TestNewScheduler_UnknownStrategy(t *testing.T) {
+	if _, err := NewScheduler("random"); err == nil {
+		t.Fatal("expected an error for an unknown strategy")
+	}
+}
+
+func TestNodeCapacities_Allocatable(t *testing.T) {
+	node := newTestNode("reserved", 32, 128, nil)
+	node.Status.Allocatable = corev1.ResourceList{
+		corev1.ResourceCPU:              *resource.NewQuantity(30, resource.DecimalSI),
+		corev1.ResourceMemory:           *resource.NewQuantity(120*gib, resource.BinarySI),
+		corev1.ResourceEphemeralStorage: *resource.NewQuantity(450*gib, resource.BinarySI),
+	}
+
+	capacities := nodeCapacities([]corev1.Node{node}, []corev1.Pod{newTestPod("reserved", 8, 32, "", 0)}, nil)
+	if len(capacities) != 1 {
+		t.Fatalf("expected one node, got %d", len(capacities))
+	}
+	capacity := capacities[0]
+	if capacity.Cpu != 30 || capacity.FreeCpu != 22 {
+		t.Fatalf("expected 22 of the 30 allocatable cpus free, got %d of %d", capacity.FreeCpu, capacity.Cpu)
+	}
+	if capacity.Memory != 120*gib || capacity.FreeMemory != 88*gib {
+		t.Fatalf("expected 88 GiB of the 120 GiB allocatable memory free, got %s of %s", formatGiB(capacity.FreeMemory), formatGiB(capacity.Memory))
+	}
+	if capacity.Storage != 450*gib {
+		t.Fatalf("expected 450 GiB of allocatable storage, got %s", formatGiB(capacity.Storage))
+	}
+}
@@ -0,0 +1,115 @@
+package computing
+
+import (
+	"context"
+	"fmt"
+	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
+	"github.com/swanchain/go-computing-provider/conf"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// spaceBuilderName is the buildx builder of the space images, a BuildKit container bounded by the [BUILD] limits,
+// the build cache is kept in its volume
+const spaceBuilderName = "swan-space-builder"
+
+// the defaults of the [BUILD] limits left at 0
+const (
+	defaultBuildCpu       = 4
+	defaultBuildMemory    = 8
+	defaultBuildTimeout   = 30
+	defaultBuildCacheSize = 50
+)
+
+var (
+	// spaceBuilderLk is held for reading by the builds, and for writing while the builder is created
+	spaceBuilderLk    sync.RWMutex
+	spaceBuilderReady atomic.Bool
+)
+
+func init() {
+	conf.OnReload(resetSpaceBuilder)
+}
+
+// resetSpaceBuilder has the next build create the builder again when its limits changed
+func resetSpaceBuilder(old, cfg *conf.ComputeNode) {
+	if old != nil && spaceBuildLimits(old.BUILD) == spaceBuildLimits(cfg.BUILD) {
+		return
+	}
+	spaceBuilderReady.Store(false)
+}
+
+// spaceBuildLimits is the [BUILD] limits with the defaults of those left at 0
+func spaceBuildLimits(limits conf.BUILD) conf.BUILD {
+	if limits.Cpu <= 0 {
+		limits.Cpu = defaultBuildCpu
+	}
+	if limits.Memory <= 0 {
+		limits.Memory = defaultBuildMemory
+	}
+	if limits.Timeout <= 0 {
+		limits.Timeout = defaultBuildTimeout
+	}
+	if limits.CacheSize <= 0 {
+		limits.CacheSize = defaultBuildCacheSize
+	}
+	return limits
+}
+
+// spaceBuilderDriverOpts is the options of the builder container, its cpu and memory limits. The memory limit
+// includes the swap, a build running out of memory fails rather than swapping.
+func spaceBuilderDriverOpts(limits conf.BUILD) string {
+	limits = spaceBuildLimits(limits)
+	return fmt.Sprintf("cpu-period=100000,cpu-quota=%d,memory=%dg,memory-swap=%dg", limits.Cpu*100000, limits.Memory, limits.Memory)
+}
+
+// acquireSpaceBuilder makes sure the builder runs with the current limits, it is created once per run of the
+// computing provider and again after a change of the limits. The builds hold it until released, so that the
+// builder is not replaced under a running build.
+func acquireSpaceBuilder(ctx context.Context) (release func(), err error) {
+	for {
+		spaceBuilderLk.RLock()
+		if spaceBuilderReady.Load() {
+			return spaceBuilderLk.RUnlock, nil
+		}
+		spaceBuilderLk.RUnlock()
+
+		spaceBuilderLk.Lock()
+		if !spaceBuilderReady.Load() {
+			if err = createSpaceBuilder(ctx); err != nil {
+				spaceBuilderLk.Unlock()
+				return nil, err
+			}
+			spaceBuilderReady.Store(true)
+		}
+		spaceBuilderLk.Unlock()
+	}
+}
+
+// createSpaceBuilder replaces the builder of an earlier run, which may have other limits, keeping its build cache
+func createSpaceBuilder(ctx context.Context) error {
+	exec.CommandContext(ctx, "docker", "buildx", "rm", "--keep-state", spaceBuilderName).Run()
+
+	output, err := exec.CommandContext(ctx, "docker", "buildx", "create", "--name", spaceBuilderName,
+		"--driver", "docker-container", "--driver-opt", spaceBuilderDriverOpts(conf.GetConfig().BUILD), "--bootstrap").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("create the builder %s failed, output: %s, error: %v", spaceBuilderName, strings.TrimSpace(string(output)), err)
+	}
+	logs.GetLogger().Infof("created the builder %s, %s", spaceBuilderName, spaceBuilderDriverOpts(conf.GetConfig().BUILD))
+	return nil
+}
+
+// pruneBuildCache removes the build cache least recently used above [BUILD].CacheSize, it is left alone until the
+// builder is created by a build
+func pruneBuildCache() {
+	if !spaceBuilderReady.Load() {
+		return
+	}
+	keepStorage := fmt.Sprintf("%dgb", spaceBuildLimits(conf.GetConfig().BUILD).CacheSize)
+	output, err := exec.Command("docker", "buildx", "prune", "--builder", spaceBuilderName, "--force", "--keep-storage", keepStorage).CombinedOutput()
+	if err != nil {
+		logs.GetLogger().Errorf("prune the build cache failed, output: %s, error: %v", strings.TrimSpace(string(output)), err)
+	}
+}
@@ -0,0 +1,72 @@
+package computing
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/swanchain/go-computing-provider/conf"
+)
+
+func TestSpaceSourceHash(t *testing.T) {
+	writeFiles := func(files map[string]string) string {
+		dir := t.TempDir()
+		for name, content := range files {
+			if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return dir
+	}
+	hash := func(files map[string]string) string {
+		sourceHash, err := spaceSourceHash(writeFiles(files))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sourceHash
+	}
+
+	space := map[string]string{"Dockerfile": "FROM python:3.11\nCOPY . .\n", "app/main.py": "print('hello')\n"}
+	sourceHash := hash(space)
+	if len(sourceHash) != 64 {
+		t.Fatalf("expected a sha256, got %q", sourceHash)
+	}
+	if again := hash(space); again != sourceHash {
+		t.Errorf("expected the same files to give the same hash, got %s and %s", sourceHash, again)
+	}
+
+	withLog := map[string]string{BuildFileName: "Step 1/2"}
+	for name, content := range space {
+		withLog[name] = content
+	}
+	if logged := hash(withLog); logged != sourceHash {
+		t.Errorf("expected the build log to be left out, got %s and %s", sourceHash, logged)
+	}
+
+	for _, changed := range []map[string]string{
+		{"Dockerfile": "FROM python:3.12\nCOPY . .\n", "app/main.py": "print('hello')\n"},
+		{"Dockerfile": "FROM python:3.11\nCOPY . .\n", "app/app.py": "print('hello')\n"},
+		{"Dockerfile": "FROM python:3.11\nCOPY . .\n", "app/main.py": "print('hello')\n", "app/__init__.py": ""},
+	} {
+		if changedHash := hash(changed); changedHash == sourceHash {
+			t.Errorf("expected changed files %v to give another hash", changed)
+		}
+	}
+}
+
+func TestSpaceBuilderDriverOpts(t *testing.T) {
+	if opts := spaceBuilderDriverOpts(conf.BUILD{}); opts != "cpu-period=100000,cpu-quota=400000,memory=8g,memory-swap=8g" {
+		t.Errorf("expected the default limits, got %s", opts)
+	}
+	if opts := spaceBuilderDriverOpts(conf.BUILD{Cpu: 2, Memory: 16}); opts != "cpu-period=100000,cpu-quota=200000,memory=16g,memory-swap=16g" {
+		t.Errorf("expected 2 cores and 16 GiB, got %s", opts)
+	}
+
+	limits := spaceBuildLimits(conf.BUILD{Timeout: 90})
+	if limits.Timeout != 90 || limits.CacheSize != defaultBuildCacheSize {
+		t.Errorf("expected the timeout kept and the default cache size, got %+v", limits)
+	}
+}
@@ -0,0 +1,315 @@
+package computing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
+	"github.com/swanchain/go-computing-provider/conf"
+	"github.com/swanchain/go-computing-provider/constants"
+	"github.com/swanchain/go-computing-provider/internal/models"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// spaceStageAttempts is the attempts of a deploy stage before the deployment of the space fails
+	spaceStageAttempts = 3
+	// spaceStageRetryDelay is the wait before the next attempt of a stage, it grows with the attempts
+	spaceStageRetryDelay = 10 * time.Second
+)
+
+// spaceDeployStage is a stage of the deployment of a space, named by its DEPLOY_* status. Each attempt of the
+// stage is bounded by its timeout, or by its own limit without one.
+type spaceDeployStage struct {
+	status  int
+	timeout time.Duration
+	skip    func(sd *spaceDeployment) bool
+	run     func(sd *spaceDeployment, ctx context.Context) error
+}
+
+// spaceDeployStages is the stages of the deployment of a space in order. The last stage done is saved in the
+// CompletedStage of the job, so that a restart resumes after it, e.g. with the image already built. The stages are
+// ordered by their place in the list, not by their status.
+var spaceDeployStages = []spaceDeployStage{
+	{status: models.DEPLOY_DOWNLOAD_SOURCE, timeout: 10 * time.Minute, run: (*spaceDeployment).downloadSource},
+	// the build is bounded by the [BUILD].Timeout of the config
+	{status: models.DEPLOY_BUILD_IMAGE, skip: (*spaceDeployment).skipBuild, run: (*spaceDeployment).buildImage},
+	{status: models.DEPLOY_PUSH_IMAGE, timeout: 20 * time.Minute, skip: (*spaceDeployment).skipPush, run: (*spaceDeployment).pushImage},
+	{status: models.DEPLOY_CHECK_IMAGE, timeout: 30 * time.Minute, skip: (*spaceDeployment).skipImageCheck, run: (*spaceDeployment).checkImages},
+	// the model spaces build their image while deploying
+	{status: models.DEPLOY_TO_K8S, timeout: 30 * time.Minute, run: (*spaceDeployment).deployToK8s},
+}
+
+// spaceDeployments is the jobs being deployed, so that a job is not deployed twice at once
+var spaceDeployments sync.Map
+
+type spaceDeployment struct {
+	job    *models.JobEntity
+	space  models.SpaceJSON
+	deploy *Deploy
+
+	imagePath         string
+	yamlPath          string
+	modelsSettingFile string
+}
+
+// ResumeSpaceDeployments deploys again the spaces whose deployment was interrupted by a restart, from the stage
+// after the last one done
+func ResumeSpaceDeployments() {
+	jobs, err := NewJobService().GetJobListByDeployState(models.DEPLOY_STATE_DEPLOYING)
+	if err != nil {
+		logs.GetLogger().Errorf("get the deploying jobs failed, error: %v", err)
+		return
+	}
+	for _, job := range jobs {
+		logs.GetLogger().Infof("jobUuid: %s, resume the deployment of the space, completed stage: %s",
+			job.JobUuid, models.GetDeployStatusStr(job.CompletedStage))
+		go runSpaceDeployment(job)
+	}
+}
+
+// runSpaceDeployment runs the stages of the deployment of the job. The job is marked deployed or failed at the
+// end, a failed job keeps the error of its last attempt and its k8s resources but its volumes are removed.
+func runSpaceDeployment(job *models.JobEntity) {
+	if _, running := spaceDeployments.LoadOrStore(job.JobUuid, true); running {
+		return
+	}
+	defer spaceDeployments.Delete(job.JobUuid)
+
+	sd := &spaceDeployment{job: job}
+	err := sd.run()
+	if err == nil {
+		sd.save(map[string]any{"deploy_state": models.DEPLOY_STATE_DEPLOYED, "error": ""})
+		recordHubEvent(job.JobUuid, models.HUB_EVENT_JOB_STATE, models.DEPLOY_STATE_DEPLOYED, "")
+		return
+	}
+
+	logs.GetLogger().Errorf("jobUuid: %s, deploy space failed, error: %v", job.JobUuid, err)
+	columns := map[string]any{"deploy_state": models.DEPLOY_STATE_FAILED, "error": err.Error()}
+	k8sNameSpace := constants.K8S_NAMESPACE_NAME_PREFIX + strings.ToLower(job.WalletAddress)
+	spaceUuid := strings.ToLower(job.SpaceUuid)
+	if job.WalletAddress != "" && (errors.Is(err, errSpaceRolledBack) || keepsPreviousDeployment(k8sNameSpace, spaceUuid, job.JobUuid)) {
+		// the previous deployment keeps serving, e.g. after a failed build or a rolled back revision, the job
+		// follows it so that it still expires
+		columns["k8s_deploy_name"] = constants.K8S_DEPLOY_NAME_PREFIX + spaceUuid
+		columns["name_space"] = k8sNameSpace
+		columns["k8s_resource_type"] = "deployment"
+	} else if job.WalletAddress != "" {
+		// a first deploy failed, the volumes keep their data until the job expires
+		deleteSpaceWorkload(k8sNameSpace, spaceUuid, "deploy space failed")
+	}
+	sd.save(columns)
+	recordHubEvent(job.JobUuid, models.HUB_EVENT_JOB_STATE, models.DEPLOY_STATE_FAILED, err.Error())
+}
+
+func (sd *spaceDeployment) run() (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("deploy space task panic: %v", r)
+		}
+	}()
+
+	for attempt := 1; ; attempt++ {
+		if err = sd.prepare(); err == nil {
+			break
+		}
+		if attempt >= spaceStageAttempts {
+			return err
+		}
+		time.Sleep(time.Duration(attempt) * spaceStageRetryDelay)
+	}
+
+	attempts := make(map[int]int)
+	for i := sd.resumeStage(); i < len(spaceDeployStages); {
+		stage := spaceDeployStages[i]
+		if stage.skip != nil && stage.skip(sd) {
+			sd.complete(stage)
+			i++
+			continue
+		}
+
+		attempts[stage.status]++
+		updateJobStatus(sd.job.JobUuid, stage.status)
+		sd.save(map[string]any{"stage_attempts": attempts[stage.status]})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		if stage.timeout > 0 {
+			ctx, cancel = context.WithTimeout(context.Background(), stage.timeout)
+		}
+		err = stage.run(sd, ctx)
+		cancel()
+		if err == nil {
+			sd.complete(stage)
+			i++
+			continue
+		}
+
+		err = fmt.Errorf("%s attempt %d failed, error: %w", models.GetDeployStatusStr(stage.status), attempts[stage.status], err)
+		logs.GetLogger().Errorf("jobUuid: %s, %v", sd.job.JobUuid, err)
+		sd.save(map[string]any{"error": err.Error()})
+		// a revision rolled back is not ready by itself and a rejected image is rejected again, they are not tried again
+		if attempts[stage.status] >= spaceStageAttempts || errors.Is(err, errSpaceRolledBack) || errors.Is(err, errImageRejected) {
+			return err
+		}
+		time.Sleep(time.Duration(attempts[stage.status]) * spaceStageRetryDelay)
+		// the attempt may have removed the output of an earlier stage, e.g. the image removed with the deployment
+		i = sd.resumeStage()
+	}
+	return nil
+}
+
+// prepare gets the space of the job and sets up its deployment
+func (sd *spaceDeployment) prepare() error {
+	spaceDetail, err := getSpaceDetail(sd.job.SourceUrl)
+	if err != nil {
+		return err
+	}
+
+	walletAddress := spaceDetail.Data.Owner.PublicAddress
+	spaceName := spaceDetail.Data.Space.Name
+	spaceUuid := strings.ToLower(spaceDetail.Data.Space.Uuid)
+	spaceHardware := spaceDetail.Data.Space.ActiveOrder.Config
+
+	logs.GetLogger().Infof("uuid: %s, spaceName: %s, hardwareName: %s", spaceUuid, spaceName, spaceHardware.Description)
+	if len(spaceHardware.Description) == 0 {
+		return fmt.Errorf("the space %s has no hardware", spaceUuid)
+	}
+
+	var job = new(models.JobEntity)
+	job.JobUuid = sd.job.JobUuid
+	job.WalletAddress = walletAddress
+	job.Name = spaceName
+	job.SpaceUuid = spaceDetail.Data.Space.Uuid
+	job.Hardware = spaceHardware.Description
+	if err = NewJobService().UpdateJobEntityByJobUuid(job); err != nil {
+		return fmt.Errorf("update job info failed, error: %v", err)
+	}
+	sd.job.WalletAddress, sd.job.Name, sd.job.SpaceUuid, sd.job.Hardware = job.WalletAddress, job.Name, job.SpaceUuid, job.Hardware
+
+	hostName := strings.TrimPrefix(sd.job.RealUrl, "https://")
+	sd.deploy = NewDeploy(sd.job.JobUuid, hostName, walletAddress, spaceHardware.Description, int64(sd.job.Duration), sd.job.TaskUuid, constants.SPACE_TYPE_PUBLIC)
+	sd.deploy.WithSpaceInfo(spaceUuid, spaceName)
+	sd.deploy.WithGpuProductName(sd.job.GpuProductName)
+	sd.deploy.WithNodeName(sd.job.NodeName)
+
+	sd.space = spaceDetail
+	sd.imagePath, sd.yamlPath, sd.modelsSettingFile = spaceSourceLayout(spaceDetail.Data.Files)
+	sd.deploy.WithSpacePath(sd.imagePath)
+	return nil
+}
+
+// resumeStage is the index of the first stage to run: the stage after the last one done, or an earlier one
+// when the output of a stage done is gone
+func (sd *spaceDeployment) resumeStage() int {
+	if sd.job.CompletedStage == 0 {
+		return 0
+	}
+	if _, err := os.Stat(sd.imagePath); err != nil {
+		return 0
+	}
+	completed := spaceStageIndex(sd.job.CompletedStage)
+	for i, stage := range spaceDeployStages {
+		if stage.status == models.DEPLOY_BUILD_IMAGE && i <= completed && sd.needsLocalImage() &&
+			!NewDockerService().checkImageExists(sd.job.ImageName) {
+			return i
+		}
+		if i > completed {
+			return i
+		}
+	}
+	return len(spaceDeployStages)
+}
+
+// spaceStageIndex is the place of the stage with the status in spaceDeployStages, -1 for none
+func spaceStageIndex(status int) int {
+	for i, stage := range spaceDeployStages {
+		if stage.status == status {
+			return i
+		}
+	}
+	return -1
+}
+
+func (sd *spaceDeployment) complete(stage spaceDeployStage) {
+	sd.job.CompletedStage = stage.status
+	sd.save(map[string]any{"completed_stage": stage.status, "stage_attempts": 0})
+}
+
+func (sd *spaceDeployment) save(columns map[string]any) {
+	if err := NewJobService().UpdateJobColumnsByJobUuid(sd.job.JobUuid, columns); err != nil {
+		logs.GetLogger().Errorf("jobUuid: %s, update job state failed, error: %v", sd.job.JobUuid, err)
+	}
+}
+
+// hasDockerfile tells if the space is built from its Dockerfile, the spaces with a deploy yaml or a model
+// setting file are not
+func (sd *spaceDeployment) hasDockerfile() bool {
+	return sd.yamlPath == "" && sd.modelsSettingFile == ""
+}
+
+func (sd *spaceDeployment) hasRegistry() bool {
+	return conf.GetConfig().Registry.ServerAddress != ""
+}
+
+// needsLocalImage tells if the deployment runs the image built on this machine, rather than the image pushed to
+// the registry
+func (sd *spaceDeployment) needsLocalImage() bool {
+	return sd.hasDockerfile() && (!sd.hasRegistry() || spaceStageIndex(sd.job.CompletedStage) < spaceStageIndex(models.DEPLOY_PUSH_IMAGE))
+}
+
+func (sd *spaceDeployment) skipBuild() bool {
+	return !sd.hasDockerfile()
+}
+
+func (sd *spaceDeployment) skipPush() bool {
+	return !sd.hasDockerfile() || !sd.hasRegistry()
+}
+
+func (sd *spaceDeployment) downloadSource(ctx context.Context) error {
+	return downloadSpaceFiles(ctx, sd.deploy.spaceUuid, sd.space.Data.Files)
+}
+
+// buildImage builds the image of the space, tagged with the hash of its files: the image of a space redeployed
+// unchanged is already there and is not built again
+func (sd *spaceDeployment) buildImage(ctx context.Context) error {
+	sourceHash, err := spaceSourceHash(sd.imagePath)
+	if err != nil {
+		return fmt.Errorf("hash the files of the space, error: %v", err)
+	}
+	imageName := spaceImageName(sd.deploy.spaceUuid, sd.deploy.spaceName, sourceHash)
+
+	dockerService := NewDockerService()
+	if dockerService.checkImageExists(imageName) {
+		logs.GetLogger().Infof("jobUuid: %s, the files of the space are unchanged, reuse image %s", sd.job.JobUuid, imageName)
+	} else {
+		logs.GetLogger().Infof("jobUuid: %s, build image %s from %s", sd.job.JobUuid, imageName, sd.imagePath)
+		if err = dockerService.BuildImage(ctx, sd.imagePath, imageName); err != nil {
+			return fmt.Errorf("error building Docker image: %v", err)
+		}
+	}
+	sd.job.ImageName = imageName
+	sd.save(map[string]any{"image_name": imageName})
+	return nil
+}
+
+func (sd *spaceDeployment) pushImage(ctx context.Context) error {
+	if err := NewDockerService().PushImage(ctx, sd.job.ImageName); err != nil {
+		return fmt.Errorf("error Docker push image: %v", err)
+	}
+	return nil
+}
+
+func (sd *spaceDeployment) deployToK8s(ctx context.Context) error {
+	deploy := sd.deploy.WithContext(ctx).WithImageDigests(sd.imageDigests())
+	switch {
+	case sd.modelsSettingFile != "":
+		return deploy.WithModelSettingFile(sd.modelsSettingFile).ModelInferenceToK8s()
+	case sd.yamlPath != "":
+		return deploy.WithYamlInfo(sd.yamlPath).YamlToK8s()
+	default:
+		return deploy.WithDockerfile(sd.job.ImageName, spaceDockerfilePath(sd.imagePath)).DockerfileToK8s()
+	}
+}
@@ -0,0 +1,58 @@
+package computing
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/swanchain/go-computing-provider/internal/models"
+)
+
+func TestSpaceSourceLayout(t *testing.T) {
+	t.Setenv("CP_PATH", "/cp")
+	imagePath, yamlPath, modelsSettingFile := spaceSourceLayout([]models.SpaceFile{
+		{Name: "space/Dockerfile"},
+		{Name: "space/deploy.yaml"},
+	})
+	if imagePath != filepath.Join("/cp", "build", "space") {
+		t.Fatalf("unexpected image path %q", imagePath)
+	}
+	if yamlPath != filepath.Join("/cp", "build", "space", "deploy.yaml") || modelsSettingFile != "" {
+		t.Fatalf("expected the deploy yaml only, got %q %q", yamlPath, modelsSettingFile)
+	}
+}
+
+func TestSpaceDeployment_ResumeStage(t *testing.T) {
+	sd := &spaceDeployment{
+		job:       &models.JobEntity{},
+		imagePath: t.TempDir(),
+		yamlPath:  "deploy.yaml",
+	}
+	if stage := sd.resumeStage(); stage != 0 {
+		t.Fatalf("expected a new deployment to start with the download, got stage %d", stage)
+	}
+
+	sd.job.CompletedStage = models.DEPLOY_DOWNLOAD_SOURCE
+	if stage := spaceDeployStages[sd.resumeStage()]; stage.status != models.DEPLOY_BUILD_IMAGE || !stage.skip(sd) {
+		t.Fatalf("expected to resume at the skipped build, got %s", models.GetDeployStatusStr(stage.status))
+	}
+
+	sd.job.CompletedStage = models.DEPLOY_PUSH_IMAGE
+	if stage := spaceDeployStages[sd.resumeStage()]; stage.status != models.DEPLOY_CHECK_IMAGE {
+		t.Fatalf("expected to resume at the image check, got %s", models.GetDeployStatusStr(stage.status))
+	}
+
+	sd.job.CompletedStage = models.DEPLOY_CHECK_IMAGE
+	if stage := spaceDeployStages[sd.resumeStage()]; stage.status != models.DEPLOY_TO_K8S {
+		t.Fatalf("expected to resume at the deploy, got %s", models.GetDeployStatusStr(stage.status))
+	}
+
+	sd.job.CompletedStage = models.DEPLOY_TO_K8S
+	if stage := sd.resumeStage(); stage != len(spaceDeployStages) {
+		t.Fatalf("expected nothing left to run, got stage %d", stage)
+	}
+
+	sd.imagePath = filepath.Join(sd.imagePath, "removed")
+	if stage := sd.resumeStage(); stage != 0 {
+		t.Fatalf("expected the download again when the source is gone, got stage %d", stage)
+	}
+}
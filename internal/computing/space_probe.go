@@ -0,0 +1,149 @@
+package computing
+
+import (
+	"context"
+	"fmt"
+	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
+	"github.com/robfig/cron/v3"
+	"github.com/swanchain/go-computing-provider/constants"
+	"github.com/swanchain/go-computing-provider/internal/models"
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"strings"
+	"time"
+)
+
+// The health of a space from the probes of its pods
+const (
+	SpaceHealthy    = "Healthy"
+	SpaceUnready    = "Unready"    // running, but failing its readiness probe
+	SpaceRestarting = "Restarting" // its containers are restarted, e.g. killed by a failed liveness probe
+)
+
+const (
+	// spaceRestartWindow is how long a space whose container was restarted counts as restarting
+	spaceRestartWindow = 10 * time.Minute
+	// spaceStartupTimeout is how long a space without a health-check has to open its port before it is restarted,
+	// e.g. while it downloads its models
+	spaceStartupTimeout = 30 * time.Minute
+	// spaceStartupPeriod is the period of the startup probe of a space without a health-check
+	spaceStartupPeriod = 10 * time.Second
+)
+
+// spaceReadinessProbe is the readiness probe of a space without a health-check: ready once its port accepts
+// connections
+func spaceReadinessProbe(containerPort int32) *coreV1.Probe {
+	return &coreV1.Probe{
+		ProbeHandler: coreV1.ProbeHandler{
+			TCPSocket: &coreV1.TCPSocketAction{Port: intstr.FromInt32(containerPort)},
+		},
+		InitialDelaySeconds: 5,
+		PeriodSeconds:       5,
+	}
+}
+
+// spaceStartupProbe is the startup probe of a space without a health-check: the liveness probe only starts once
+// its port accepts connections, a space that does not open it within spaceStartupTimeout is restarted
+func spaceStartupProbe(containerPort int32) *coreV1.Probe {
+	return &coreV1.Probe{
+		ProbeHandler: coreV1.ProbeHandler{
+			TCPSocket: &coreV1.TCPSocketAction{Port: intstr.FromInt32(containerPort)},
+		},
+		PeriodSeconds:    int32(spaceStartupPeriod.Seconds()),
+		TimeoutSeconds:   5,
+		FailureThreshold: int32(spaceStartupTimeout / spaceStartupPeriod),
+	}
+}
+
+// spaceLivenessProbe is the liveness probe of a space without a health-check: restarted once its port stops
+// accepting connections for a minute after it has started, see spaceStartupProbe
+func spaceLivenessProbe(containerPort int32) *coreV1.Probe {
+	return &coreV1.Probe{
+		ProbeHandler: coreV1.ProbeHandler{
+			TCPSocket: &coreV1.TCPSocketAction{Port: intstr.FromInt32(containerPort)},
+		},
+		PeriodSeconds:    20,
+		TimeoutSeconds:   5,
+		FailureThreshold: 3,
+	}
+}
+
+// spaceHealth is the health of a space from its pods: healthy while a pod has all its containers ready, else
+// restarting or unready, and "" while no pod runs yet
+func spaceHealth(pods []coreV1.Pod, now time.Time) string {
+	var health string
+	for _, pod := range pods {
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+		ready := len(pod.Status.ContainerStatuses) > 0
+		for _, status := range pod.Status.ContainerStatuses {
+			ready = ready && status.Ready
+			waiting := status.State.Waiting
+			terminated := status.LastTerminationState.Terminated
+			if waiting != nil && waiting.Reason == "CrashLoopBackOff" ||
+				terminated != nil && now.Sub(terminated.FinishedAt.Time) < spaceRestartWindow {
+				health = SpaceRestarting
+			}
+		}
+		if ready {
+			return SpaceHealthy
+		}
+		if health == "" && pod.Status.Phase == coreV1.PodRunning {
+			health = SpaceUnready
+		}
+	}
+	return health
+}
+
+// GetSpaceHealth is the health of the pods of the space in the namespace
+func (s *K8sService) GetSpaceHealth(ctx context.Context, namespace, spaceUuid string) (string, error) {
+	podList, err := s.k8sClient.CoreV1().Pods(namespace).List(ctx, metaV1.ListOptions{
+		LabelSelector: fmt.Sprintf("lad_app=%s", strings.ToLower(spaceUuid)),
+	})
+	if err != nil {
+		return "", err
+	}
+	return spaceHealth(podList.Items, time.Now()), nil
+}
+
+// watchSpaceHealth follows the health of the deployed spaces, a change is saved and reported to the orchestrator
+func (task *CronTask) watchSpaceHealth() {
+	c := cron.New(cron.WithSeconds())
+	c.AddFunc("0 * * * * ?", func() {
+		defer func() {
+			if err := recover(); err != nil {
+				logs.GetLogger().Errorf("watchSpaceHealth catch panic error: %+v", err)
+			}
+		}()
+
+		jobs, err := NewJobService().GetJobListByDeployState(models.DEPLOY_STATE_DEPLOYED)
+		if err != nil {
+			logs.GetLogger().Errorf("Failed watchSpaceHealth get job data, error: %+v", err)
+			return
+		}
+
+		k8sService := NewK8sService()
+		for _, job := range jobs {
+			namespace := constants.K8S_NAMESPACE_NAME_PREFIX + strings.ToLower(job.WalletAddress)
+			health, err := k8sService.GetSpaceHealth(context.TODO(), namespace, job.SpaceUuid)
+			if err != nil {
+				logs.GetLogger().Errorf("space_uuid: %s, get space health failed, error: %v", job.SpaceUuid, err)
+				continue
+			}
+			if health == job.Health {
+				continue
+			}
+
+			logs.GetLogger().Infof("space_uuid: %s, health changed from %q to %q", job.SpaceUuid, job.Health, health)
+			if err = NewJobService().UpdateJobColumnsByJobUuid(job.JobUuid, map[string]any{"health": health}); err != nil {
+				logs.GetLogger().Errorf("space_uuid: %s, update space health failed, error: %v", job.SpaceUuid, err)
+				continue
+			}
+			// the same health may come back, each change is an event of its own
+			recordHubEvent(job.JobUuid, models.HUB_EVENT_JOB_HEALTH, health, "")
+		}
+	})
+	c.Start()
+}
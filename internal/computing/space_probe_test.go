@@ -0,0 +1,120 @@
+package computing
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/swanchain/go-computing-provider/internal/yaml"
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const probeDeployYaml = `version: "2.0"
+services:
+  db:
+    image: redis
+    expose:
+      - port: 6379
+    ready-cmd: ["redis-cli", "ping"]
+  web:
+    image: space
+    depends-on: ["db"]
+    expose:
+      - port: 7860
+    health-check:
+      readiness:
+        http:
+          path: /healthz
+      liveness:
+        tcp: {}
+        initial-delay: 30
+deployment:
+  web:
+    lagrange:
+      count: 1
+`
+
+func TestYamlProbes(t *testing.T) {
+	yamlPath := filepath.Join(t.TempDir(), "deploy.yaml")
+	if err := os.WriteFile(yamlPath, []byte(probeDeployYaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+	resources, err := yaml.HandlerYaml(yamlPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resources) != 1 || len(resources[0].Depends) != 1 {
+		t.Fatalf("expected the web service with its depend, got %+v", resources)
+	}
+
+	web := resources[0]
+	if web.ReadinessProbe == nil || web.ReadinessProbe.HTTPGet == nil ||
+		web.ReadinessProbe.HTTPGet.Path != "/healthz" || web.ReadinessProbe.HTTPGet.Port.IntValue() != 7860 {
+		t.Errorf("expected an http readiness probe on the exposed port, got %+v", web.ReadinessProbe)
+	}
+	if web.LivenessProbe == nil || web.LivenessProbe.TCPSocket == nil ||
+		web.LivenessProbe.TCPSocket.Port.IntValue() != 7860 || web.LivenessProbe.InitialDelaySeconds != 30 {
+		t.Errorf("expected a tcp liveness probe on the exposed port, got %+v", web.LivenessProbe)
+	}
+
+	db := web.Depends[0]
+	if db.ReadinessProbe == nil || db.ReadinessProbe.Exec == nil || db.ReadinessProbe.Exec.Command[0] != "redis-cli" {
+		t.Errorf("expected ready-cmd as the readiness probe, got %+v", db.ReadinessProbe)
+	}
+	if db.LivenessProbe != nil {
+		t.Errorf("expected no liveness probe without a health-check, got %+v", db.LivenessProbe)
+	}
+}
+
+func TestSpaceHealth(t *testing.T) {
+	now := time.Now()
+	pod := func(phase coreV1.PodPhase, status coreV1.ContainerStatus) coreV1.Pod {
+		return coreV1.Pod{Status: coreV1.PodStatus{Phase: phase, ContainerStatuses: []coreV1.ContainerStatus{status}}}
+	}
+	ready := pod(coreV1.PodRunning, coreV1.ContainerStatus{Ready: true})
+	unready := pod(coreV1.PodRunning, coreV1.ContainerStatus{})
+	crashing := pod(coreV1.PodRunning, coreV1.ContainerStatus{State: coreV1.ContainerState{
+		Waiting: &coreV1.ContainerStateWaiting{Reason: "CrashLoopBackOff"},
+	}})
+	restarted := pod(coreV1.PodRunning, coreV1.ContainerStatus{LastTerminationState: coreV1.ContainerState{
+		Terminated: &coreV1.ContainerStateTerminated{FinishedAt: metaV1.NewTime(now.Add(-time.Minute))},
+	}})
+	restartedLongAgo := pod(coreV1.PodRunning, coreV1.ContainerStatus{Ready: true, LastTerminationState: coreV1.ContainerState{
+		Terminated: &coreV1.ContainerStateTerminated{FinishedAt: metaV1.NewTime(now.Add(-time.Hour))},
+	}})
+
+	for _, tc := range []struct {
+		name   string
+		pods   []coreV1.Pod
+		health string
+	}{
+		{"no pod", nil, ""},
+		{"pending", []coreV1.Pod{pod(coreV1.PodPending, coreV1.ContainerStatus{})}, ""},
+		{"ready", []coreV1.Pod{ready}, SpaceHealthy},
+		{"unready", []coreV1.Pod{unready}, SpaceUnready},
+		{"crash loop", []coreV1.Pod{crashing}, SpaceRestarting},
+		{"restarted", []coreV1.Pod{restarted}, SpaceRestarting},
+		{"restarted long ago", []coreV1.Pod{restartedLongAgo}, SpaceHealthy},
+		{"rolling out", []coreV1.Pod{crashing, ready}, SpaceHealthy},
+	} {
+		if health := spaceHealth(tc.pods, now); health != tc.health {
+			t.Errorf("%s: expected %q, got %q", tc.name, tc.health, health)
+		}
+	}
+}
+
+func TestSpaceStartupProbe(t *testing.T) {
+	startup, liveness := spaceStartupProbe(7860), spaceLivenessProbe(7860)
+	if startup.TCPSocket == nil || startup.TCPSocket.Port.IntValue() != 7860 {
+		t.Fatalf("expected a tcp startup probe on the space port, got %+v", startup)
+	}
+	if window := time.Duration(startup.PeriodSeconds*startup.FailureThreshold) * time.Second; window != spaceStartupTimeout {
+		t.Fatalf("expected the startup probe to wait %v, got %v", spaceStartupTimeout, window)
+	}
+	// the liveness probe only runs after the startup probe succeeded, so it needs no initial delay
+	if liveness.InitialDelaySeconds != 0 {
+		t.Fatalf("expected no initial delay of the liveness probe, got %d", liveness.InitialDelaySeconds)
+	}
+}
@@ -0,0 +1,190 @@
+package computing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
+	"github.com/swanchain/go-computing-provider/constants"
+	appV1 "k8s.io/api/apps/v1"
+	coreV1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/util/retry"
+	"time"
+)
+
+// errSpaceRolledBack is the error of a redeployed space whose new revision never became ready, the previous
+// revision is put back
+var errSpaceRolledBack = errors.New("the new revision is not ready, rolled back to the previous one")
+
+const (
+	// spaceJobAnnotation is the annotation of a space deployment naming the job that created it
+	spaceJobAnnotation = "lad_job_uuid"
+	// spaceRolloutTimeout is how long the new revision of a redeployed space has to become ready
+	spaceRolloutTimeout = 10 * time.Minute
+	// spaceProgressDeadline is the progress deadline of the deployments of the spaces, in seconds
+	spaceProgressDeadline = int32(600)
+)
+
+// withRollingUpdate makes the deployment replace its pods one at a time, an old pod is only removed once the new
+// one is ready. A space with persistent volumes is recreated instead, its volumes are mounted by one pod at a time.
+// The space container, the last one after the depends of a yaml space, is ready once its port accepts connections
+// unless it has its own readiness probe.
+func withRollingUpdate(deployment *appV1.Deployment, containerPort int32) {
+	maxUnavailable := intstr.FromInt32(0)
+	maxSurge := intstr.FromInt32(1)
+	progressDeadline := spaceProgressDeadline
+	deployment.Spec.Strategy = appV1.DeploymentStrategy{
+		Type: appV1.RollingUpdateDeploymentStrategyType,
+		RollingUpdate: &appV1.RollingUpdateDeployment{
+			MaxUnavailable: &maxUnavailable,
+			MaxSurge:       &maxSurge,
+		},
+	}
+	if hasVolumeClaims(&deployment.Spec.Template.Spec) {
+		deployment.Spec.Strategy = appV1.DeploymentStrategy{Type: appV1.RecreateDeploymentStrategyType}
+	}
+	deployment.Spec.ProgressDeadlineSeconds = &progressDeadline
+
+	containers := deployment.Spec.Template.Spec.Containers
+	if len(containers) == 0 || containers[len(containers)-1].ReadinessProbe != nil {
+		return
+	}
+	containers[len(containers)-1].ReadinessProbe = spaceReadinessProbe(containerPort)
+}
+
+// rolloutDeployment applies the service and the ingress of the space and creates its deployment, or rolls the
+// running one out to the new revision: the old pods keep serving until the new ones are ready, and a revision not
+// ready within spaceRolloutTimeout is rolled back, with the port of its service. The service and the ingress are
+// applied before the rollout, so that the new pods are reachable as soon as they are ready. It returns the
+// deployment and the address of the service.
+func (d *Deploy) rolloutDeployment(deployment *appV1.Deployment, containerPort int32) (*appV1.Deployment, string, error) {
+	withRollingUpdate(deployment, containerPort)
+
+	k8sService := NewK8sService()
+	current, err := k8sService.GetDeployment(d.context(), d.k8sNameSpace, deployment.Name)
+	exists := err == nil
+	if err != nil && !k8sErrors.IsNotFound(err) {
+		return nil, "", err
+	}
+	previousPort := d.servicePort()
+	serviceHost, err := d.deployK8sResource(containerPort)
+	if err != nil {
+		return nil, "", err
+	}
+	if !exists {
+		metaV1.SetMetaDataAnnotation(&deployment.ObjectMeta, spaceJobAnnotation, d.jobUuid)
+		created, err := k8sService.CreateDeployment(d.context(), d.k8sNameSpace, deployment)
+		return created, serviceHost, err
+	}
+
+	previous := current.Spec.Template.DeepCopy()
+	generation := current.Generation
+	current.Spec.Template = deployment.Spec.Template
+	current.Spec.Strategy = deployment.Spec.Strategy
+	current.Spec.ProgressDeadlineSeconds = deployment.Spec.ProgressDeadlineSeconds
+	updated, err := k8sService.UpdateDeployment(d.context(), d.k8sNameSpace, current)
+	if err != nil {
+		return nil, "", err
+	}
+	if updated.Generation == generation {
+		// the same revision, e.g. a retry of a deployment whose service failed
+		return updated, serviceHost, nil
+	}
+	logs.GetLogger().Infof("space_uuid: %s, rolling out the new revision of deployment %s", d.spaceUuid, updated.Name)
+
+	ctx, cancel := context.WithTimeout(d.context(), spaceRolloutTimeout)
+	defer cancel()
+	if err = k8sService.WaitForDeploymentRollout(ctx, d.k8sNameSpace, updated.Name); err != nil {
+		if rollbackErr := d.rollbackDeployment(updated.Name, previous); rollbackErr != nil {
+			return nil, "", fmt.Errorf("rollout failed, error: %v, rollback failed, error: %v", err, rollbackErr)
+		}
+		if previousPort != 0 && previousPort != containerPort {
+			if _, serviceErr := d.deployK8sResource(previousPort); serviceErr != nil {
+				logs.GetLogger().Errorf("space_uuid: %s, restore the service port %d failed, error: %v", d.spaceUuid, previousPort, serviceErr)
+			}
+		}
+		err = rolledBackError(updated.Spec.Strategy.Type, err)
+		logs.GetLogger().Warnf("space_uuid: %s, deployment %s: %v", d.spaceUuid, updated.Name, err)
+		return nil, "", err
+	}
+
+	d.removePreviousImages(previous, &updated.Spec.Template)
+	return updated, serviceHost, nil
+}
+
+// keepsPreviousDeployment tells if the deployment of the space was created by an earlier job, it keeps serving
+// when the redeploy by the job fails. A lookup failure keeps it too, rather than taking the space down.
+func keepsPreviousDeployment(namespace, spaceUuid, jobUuid string) bool {
+	deployment, err := NewK8sService().GetDeployment(context.TODO(), namespace, constants.K8S_DEPLOY_NAME_PREFIX+spaceUuid)
+	if k8sErrors.IsNotFound(err) {
+		return false
+	}
+	if err != nil {
+		logs.GetLogger().Errorf("space_uuid: %s, get the deployment failed, error: %v", spaceUuid, err)
+		return true
+	}
+	return createdByOtherJob(deployment, jobUuid)
+}
+
+// createdByOtherJob tells if the deployment was created by another job, those created before the annotation was
+// set were too
+func createdByOtherJob(deployment *appV1.Deployment, jobUuid string) bool {
+	return deployment.Annotations[spaceJobAnnotation] != jobUuid
+}
+
+// rolledBackError is the error of a rollout rolled back. With a rolling update the replica set of the previous
+// revision still runs its pods, so the space is back at once; with Recreate its pods were removed before the
+// rollout and are only started again, the space is down until they are ready.
+func rolledBackError(strategy appV1.DeploymentStrategyType, err error) error {
+	if strategy == appV1.RecreateDeploymentStrategyType {
+		return fmt.Errorf("%w, its pods are being started again, error: %v", errSpaceRolledBack, err)
+	}
+	return fmt.Errorf("%w, error: %v", errSpaceRolledBack, err)
+}
+
+// servicePort is the port of the service of the space, 0 when it has none yet
+func (d *Deploy) servicePort() int32 {
+	service, err := NewK8sService().GetServiceByName(d.context(), d.k8sNameSpace, constants.K8S_SERVICE_NAME_PREFIX+d.spaceUuid, metaV1.GetOptions{})
+	if err != nil || len(service.Spec.Ports) == 0 {
+		return 0
+	}
+	return service.Spec.Ports[0].Port
+}
+
+// rollbackDeployment puts the previous pod template back, the replica set of the previous revision takes over:
+// at once after a rolling update, which kept its pods, or once its pods are started again after a Recreate
+func (d *Deploy) rollbackDeployment(deploymentName string, previous *coreV1.PodTemplateSpec) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	k8sService := NewK8sService()
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		current, err := k8sService.GetDeployment(ctx, d.k8sNameSpace, deploymentName)
+		if err != nil {
+			return err
+		}
+		current.Spec.Template = *previous
+		_, err = k8sService.UpdateDeployment(ctx, d.k8sNameSpace, current)
+		return err
+	})
+}
+
+// removePreviousImages removes the images of the previous revision the new one no longer runs
+func (d *Deploy) removePreviousImages(previous, current *coreV1.PodTemplateSpec) {
+	inUse := make(map[string]bool)
+	for _, container := range current.Spec.Containers {
+		inUse[container.Image] = true
+	}
+	dockerService := NewDockerService()
+	for _, container := range previous.Spec.Containers {
+		if inUse[container.Image] {
+			continue
+		}
+		if err := dockerService.RemoveImage(container.Image); err != nil {
+			logs.GetLogger().Warnf("space_uuid: %s, remove previous image %s failed, error: %v", d.spaceUuid, container.Image, err)
+		}
+	}
+}
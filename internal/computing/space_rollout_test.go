@@ -0,0 +1,84 @@
+package computing
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	appV1 "k8s.io/api/apps/v1"
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestWithRollingUpdate(t *testing.T) {
+	deployment := &appV1.Deployment{}
+	deployment.Spec.Template.Spec.Containers = []coreV1.Container{
+		{Name: "depend", ReadinessProbe: &coreV1.Probe{}},
+		{Name: "space"},
+	}
+	withRollingUpdate(deployment, 7860)
+
+	rolling := deployment.Spec.Strategy.RollingUpdate
+	if rolling == nil || rolling.MaxUnavailable.IntValue() != 0 || rolling.MaxSurge.IntValue() != 1 {
+		t.Fatalf("expected a rolling update keeping the old pods until the new ones are ready, got %+v", deployment.Spec.Strategy)
+	}
+	probe := deployment.Spec.Template.Spec.Containers[1].ReadinessProbe
+	if probe == nil || probe.TCPSocket == nil || probe.TCPSocket.Port.IntValue() != 7860 {
+		t.Fatalf("expected a tcp readiness probe on the space port, got %+v", probe)
+	}
+}
+
+func TestDeploymentRolledOut(t *testing.T) {
+	one := int32(1)
+	for _, tc := range []struct {
+		name   string
+		status appV1.DeploymentStatus
+		done   bool
+		err    bool
+	}{
+		{"not observed", appV1.DeploymentStatus{ObservedGeneration: 1, Replicas: 1, UpdatedReplicas: 1, AvailableReplicas: 1}, false, false},
+		{"new pod not ready", appV1.DeploymentStatus{ObservedGeneration: 2, Replicas: 2, UpdatedReplicas: 1, AvailableReplicas: 1}, false, false},
+		{"old pod terminating", appV1.DeploymentStatus{ObservedGeneration: 2, Replicas: 2, UpdatedReplicas: 1, AvailableReplicas: 2}, false, false},
+		{"rolled out", appV1.DeploymentStatus{ObservedGeneration: 2, Replicas: 1, UpdatedReplicas: 1, AvailableReplicas: 1}, true, false},
+		{"deadline exceeded", appV1.DeploymentStatus{ObservedGeneration: 2, Replicas: 2, UpdatedReplicas: 1, Conditions: []appV1.DeploymentCondition{
+			{Type: appV1.DeploymentProgressing, Reason: "ProgressDeadlineExceeded"},
+		}}, false, true},
+	} {
+		deployment := &appV1.Deployment{Spec: appV1.DeploymentSpec{Replicas: &one}, Status: tc.status}
+		deployment.Generation = 2
+		done, err := deploymentRolledOut(deployment)
+		if done != tc.done || (err != nil) != tc.err {
+			t.Errorf("%s: got %t, %v", tc.name, done, err)
+		}
+	}
+}
+
+func TestRolledBackError(t *testing.T) {
+	rolloutErr := fmt.Errorf("progress deadline exceeded")
+	rolling := rolledBackError(appV1.RollingUpdateDeploymentStrategyType, rolloutErr)
+	recreate := rolledBackError(appV1.RecreateDeploymentStrategyType, rolloutErr)
+	if !errors.Is(rolling, errSpaceRolledBack) || !errors.Is(recreate, errSpaceRolledBack) {
+		t.Fatalf("expected both errors to be a rollback, got %v and %v", rolling, recreate)
+	}
+	if strings.Contains(rolling.Error(), "started again") || !strings.Contains(recreate.Error(), "started again") {
+		t.Fatalf("expected only the recreated deployment to wait for its pods, got %q and %q", rolling, recreate)
+	}
+}
+
+func TestCreatedByOtherJob(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		annotations map[string]string
+		expected    bool
+	}{
+		{"created by the job", map[string]string{spaceJobAnnotation: "job-2"}, false},
+		{"created by an earlier job", map[string]string{spaceJobAnnotation: "job-1"}, true},
+		{"created before the annotation", nil, true},
+	} {
+		deployment := &appV1.Deployment{ObjectMeta: metaV1.ObjectMeta{Annotations: tc.annotations}}
+		if kept := createdByOtherJob(deployment, "job-2"); kept != tc.expected {
+			t.Errorf("%s: expected %t, got %t", tc.name, tc.expected, kept)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package computing
+
+import (
+	"fmt"
+	"github.com/swanchain/go-computing-provider/conf"
+	"github.com/swanchain/go-computing-provider/constants"
+	"github.com/swanchain/go-computing-provider/internal/models"
+	"github.com/swanchain/go-computing-provider/internal/yaml"
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"path"
+)
+
+// spaceVolumeStorageAnnotation holds the size of the persistent volumes of a space pod, it is counted by
+// storageInPod as the claims are not part of the pod spec
+const spaceVolumeStorageAnnotation = "lad_volume_storage"
+
+// spaceVolumeClaimName is the name of the persistent volume claim of a volume of the space
+func spaceVolumeClaimName(spaceUuid, volumeName string) string {
+	return constants.K8S_PVC_NAME_PREFIX + spaceUuid + "-" + volumeName
+}
+
+// podVolumeName is the name of a volume in the space pod
+func podVolumeName(volumeName string) string {
+	return constants.K8S_PVC_NAME_PREFIX + volumeName
+}
+
+// spaceVolumes collects the volumes of the containers of the space pod, a volume declared by several services is
+// shared by them with the largest of their sizes
+func spaceVolumes(resources ...yaml.ContainerResource) []yaml.VolumeResource {
+	var volumes []yaml.VolumeResource
+	index := make(map[string]int)
+	for _, cr := range resources {
+		for _, volume := range cr.Volumes {
+			i, ok := index[volume.Name]
+			if !ok {
+				index[volume.Name] = len(volumes)
+				volumes = append(volumes, volume)
+				continue
+			}
+			if volume.Size.Cmp(volumes[i].Size) > 0 {
+				volumes[i].Size = volume.Size
+			}
+		}
+	}
+	return volumes
+}
+
+// volumeStorage is the total size of the volumes
+func volumeStorage(volumes []yaml.VolumeResource) resource.Quantity {
+	var total resource.Quantity
+	for _, volume := range volumes {
+		total.Add(volume.Size)
+	}
+	return total
+}
+
+// volumeAnnotations annotates the space pod with the size of its volumes, nil without volume
+func volumeAnnotations(volumes []yaml.VolumeResource) map[string]string {
+	if len(volumes) == 0 {
+		return nil
+	}
+	storage := volumeStorage(volumes)
+	return map[string]string{spaceVolumeStorageAnnotation: storage.String()}
+}
+
+// checkVolumeStorage refuses the volumes of a space larger in total than the storage ordered for it
+func checkVolumeStorage(volumes []yaml.VolumeResource, hardware models.Resource) error {
+	if len(volumes) == 0 {
+		return nil
+	}
+	resources, err := hardwareResourceList(hardware)
+	if err != nil {
+		return err
+	}
+	storage, ordered := volumeStorage(volumes), resources[coreV1.ResourceEphemeralStorage]
+	if storage.Cmp(ordered) > 0 {
+		return fmt.Errorf("the volumes of the space request %s of storage, more than the %s ordered", storage.String(), ordered.String())
+	}
+	return nil
+}
+
+// volumeMounts mounts the volumes in a container of the space pod
+func volumeMounts(volumes []yaml.VolumeResource) []coreV1.VolumeMount {
+	var mounts []coreV1.VolumeMount
+	for _, volume := range volumes {
+		mounts = append(mounts, coreV1.VolumeMount{
+			Name:      podVolumeName(volume.Name),
+			MountPath: volume.Path,
+		})
+	}
+	return mounts
+}
+
+// applyVolumes provisions a persistent volume claim for each volume of the space, the claims of a redeployed space
+// are reused with their data. It returns the volumes of the pod.
+func (d *Deploy) applyVolumes(volumes []yaml.VolumeResource) ([]coreV1.Volume, error) {
+	var storageClass *string
+	if class := conf.GetConfig().STORAGE.StorageClass; class != "" {
+		storageClass = &class
+	}
+
+	k8sService := NewK8sService()
+	var podVolumes []coreV1.Volume
+	for _, volume := range volumes {
+		claim := &coreV1.PersistentVolumeClaim{
+			ObjectMeta: metaV1.ObjectMeta{
+				Name:      spaceVolumeClaimName(d.spaceUuid, volume.Name),
+				Namespace: d.k8sNameSpace,
+				Labels:    map[string]string{"lad_app": d.spaceUuid},
+			},
+			Spec: coreV1.PersistentVolumeClaimSpec{
+				AccessModes:      []coreV1.PersistentVolumeAccessMode{coreV1.ReadWriteOnce},
+				StorageClassName: storageClass,
+				Resources: coreV1.VolumeResourceRequirements{
+					Requests: coreV1.ResourceList{coreV1.ResourceStorage: volume.Size},
+				},
+			},
+		}
+		if _, err := k8sService.ApplyPersistentVolumeClaim(d.context(), d.k8sNameSpace, claim); err != nil {
+			return nil, fmt.Errorf("apply the volume %s, error: %v", volume.Name, err)
+		}
+
+		podVolumes = append(podVolumes, coreV1.Volume{
+			Name: podVolumeName(volume.Name),
+			VolumeSource: coreV1.VolumeSource{
+				PersistentVolumeClaim: &coreV1.PersistentVolumeClaimVolumeSource{ClaimName: claim.Name},
+			},
+		})
+	}
+	return podVolumes, nil
+}
+
+// modelDownloadCmd downloads a model into the space container, a model kept on a volume is not downloaded again
+// after a restart or a redeploy. An interrupted download is started over, it goes to a temporary file first.
+func modelDownloadCmd(model yaml.ModelResource) []string {
+	const script = `test -s "$2" || { wget "$1" -O "$2.part" && mv "$2.part" "$2"; }`
+	return []string{"sh", "-c", script, "sh", model.Url, path.Join(model.Dir, model.Name)}
+}
+
+// hasVolumeClaims tells if the pod mounts a persistent volume claim
+func hasVolumeClaims(spec *coreV1.PodSpec) bool {
+	for _, volume := range spec.Volumes {
+		if volume.PersistentVolumeClaim != nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+package computing
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/swanchain/go-computing-provider/internal/models"
+	"github.com/swanchain/go-computing-provider/internal/yaml"
+	appV1 "k8s.io/api/apps/v1"
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const volumeDeployYaml = `version: "2.0"
+services:
+  db:
+    image: postgres
+    expose:
+      - port: 5432
+    volumes:
+      - name: data
+        size: 5Gi
+        path: /var/lib/postgresql/data
+  web:
+    image: space
+    depends-on: ["db"]
+    expose:
+      - port: 7860
+    volumes:
+      - name: data
+        size: 10Gi
+        path: /data
+      - name: models
+        size: 20Gi
+        path: /models/
+deployment:
+  web:
+    lagrange:
+      count: 1
+`
+
+func writeDeployYaml(t *testing.T, content string) string {
+	yamlPath := filepath.Join(t.TempDir(), "deploy.yaml")
+	if err := os.WriteFile(yamlPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return yamlPath
+}
+
+func TestYamlVolumes(t *testing.T) {
+	resources, err := yaml.HandlerYaml(writeDeployYaml(t, volumeDeployYaml))
+	if err != nil {
+		t.Fatal(err)
+	}
+	web := resources[0]
+	if len(web.Volumes) != 2 || web.Volumes[1].Path != "/models" || len(web.Depends[0].Volumes) != 1 {
+		t.Fatalf("expected the volumes of both services, got %+v and %+v", web.Volumes, web.Depends[0].Volumes)
+	}
+
+	volumes := spaceVolumes(append(web.Depends, web)...)
+	if len(volumes) != 2 || volumes[0].Name != "data" || volumes[0].Size.String() != "10Gi" {
+		t.Fatalf("expected the shared volume with the largest size, got %+v", volumes)
+	}
+	if storage := volumeStorage(volumes); storage.String() != "30Gi" {
+		t.Errorf("expected 30Gi of volumes, got %s", storage.String())
+	}
+
+	for _, invalid := range []string{"size: 0", "size: lots", "path: data", "path: /"} {
+		content := strings.Replace(volumeDeployYaml, "size: 20Gi", invalid, 1)
+		if invalid[:4] == "path" {
+			content = strings.Replace(volumeDeployYaml, "path: /models/", invalid, 1)
+		}
+		if _, err = yaml.HandlerYaml(writeDeployYaml(t, content)); err == nil {
+			t.Errorf("expected %q to be refused", invalid)
+		}
+	}
+}
+
+func TestStorageInPod(t *testing.T) {
+	pod := &coreV1.Pod{}
+	pod.Spec.Containers = []coreV1.Container{{Resources: coreV1.ResourceRequirements{
+		Requests: coreV1.ResourceList{coreV1.ResourceEphemeralStorage: resource.MustParse("1Gi")},
+	}}}
+	pod.Annotations = volumeAnnotations([]yaml.VolumeResource{{Name: "data", Size: resource.MustParse("10Gi")}})
+	if used := storageInPod(pod); used != 11*1024*1024*1024 {
+		t.Errorf("expected the ephemeral storage and the volumes to be counted, got %d", used)
+	}
+}
+
+func TestCheckVolumeStorage(t *testing.T) {
+	hardware := models.Resource{
+		Memory:  models.Specification{Quantity: 8, Unit: "Gi"},
+		Storage: models.Specification{Quantity: 20, Unit: "Gi"},
+	}
+	for _, tc := range []struct {
+		name    string
+		volumes []yaml.VolumeResource
+		err     bool
+	}{
+		{"no volume", nil, false},
+		{"within the storage ordered", []yaml.VolumeResource{
+			{Name: "data", Size: resource.MustParse("10Gi")},
+			{Name: "models", Size: resource.MustParse("10Gi")},
+		}, false},
+		{"larger than the storage ordered", []yaml.VolumeResource{
+			{Name: "data", Size: resource.MustParse("10Gi")},
+			{Name: "models", Size: resource.MustParse("11Gi")},
+		}, true},
+	} {
+		if err := checkVolumeStorage(tc.volumes, hardware); (err != nil) != tc.err {
+			t.Errorf("%s: expected error %t, got %v", tc.name, tc.err, err)
+		}
+	}
+}
+
+func TestWithRollingUpdate_Volumes(t *testing.T) {
+	deployment := &appV1.Deployment{}
+	deployment.Spec.Template.Spec.Containers = []coreV1.Container{{Name: "space"}}
+	deployment.Spec.Template.Spec.Volumes = []coreV1.Volume{{Name: podVolumeName("data"), VolumeSource: coreV1.VolumeSource{
+		PersistentVolumeClaim: &coreV1.PersistentVolumeClaimVolumeSource{ClaimName: spaceVolumeClaimName("space", "data")},
+	}}}
+	withRollingUpdate(deployment, 7860)
+	if deployment.Spec.Strategy.Type != appV1.RecreateDeploymentStrategyType || deployment.Spec.Strategy.RollingUpdate != nil {
+		t.Fatalf("expected a space with volumes to be recreated, got %+v", deployment.Spec.Strategy)
+	}
+}
+
+func TestModelDownloadCmd(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no shell")
+	}
+	dir := t.TempDir()
+	model := yaml.ModelResource{Name: "model.bin", Url: "http://127.0.0.1:1/model.bin", Dir: dir}
+	if err := os.WriteFile(filepath.Join(dir, model.Name), []byte("weights"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := modelDownloadCmd(model)
+	// the url is unreachable, the model already on the volume is kept without a download
+	if out, err := exec.Command(cmd[0], cmd[1:]...).CombinedOutput(); err != nil {
+		t.Fatalf("expected the model on the volume to be kept, error: %v, output: %s", err, out)
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/network"
-	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/filswan/go-swan-lib/logs"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -16,6 +15,7 @@ import (
 	"github.com/swanchain/go-computing-provider/internal/contract"
 	account2 "github.com/swanchain/go-computing-provider/internal/contract/account"
 	"github.com/swanchain/go-computing-provider/internal/contract/ecp"
+	"github.com/swanchain/go-computing-provider/internal/metrics"
 	"github.com/swanchain/go-computing-provider/internal/models"
 	"github.com/swanchain/go-computing-provider/util"
 	"github.com/swanchain/go-computing-provider/wallet"
@@ -36,6 +36,8 @@ import (
 	"time"
 )
 
+var errNoUbiResource = fmt.Errorf("no resources available")
+
 func DoUbiTaskForK8s(c *gin.Context) {
 
 	var ubiTask models.UBITaskReq
@@ -76,6 +78,10 @@ func DoUbiTaskForK8s(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, util.CreateErrorResponse(util.UbiTaskParamError, "missing required field: contract_addr"))
 		return
 	}
+	if ubiTask.Resource == nil {
+		c.JSON(http.StatusBadRequest, util.CreateErrorResponse(util.UbiTaskParamError, "missing required field: resource"))
+		return
+	}
 
 	cpRepoPath, _ := os.LookupEnv("CP_PATH")
 	nodeID := GetNodeId(cpRepoPath)
@@ -93,9 +99,47 @@ func DoUbiTaskForK8s(c *gin.Context) {
 		return
 	}
 
-	var gpuFlag = "0"
-	if ubiTask.ResourceType == 1 {
-		gpuFlag = "1"
+	taskEntity, err := newUbiTaskEntity(ubiTask)
+	if err != nil {
+		logs.GetLogger().Errorf("save task entity failed, error: %v", err)
+		c.JSON(http.StatusInternalServerError, util.CreateErrorResponse(util.SaveTaskEntityError))
+		return
+	}
+	metrics.UbiTaskReceived.WithLabelValues(models.GetSourceTypeStr(taskEntity.ResourceType)).Inc()
+
+	job, err := newUbiTaskJob(taskEntity)
+	if err != nil {
+		taskEntity.Status = models.TASK_FAILED_STATUS
+		if err == errNoUbiResource {
+			taskEntity.Error = "No resources available"
+			NewTaskService().SaveTaskEntity(taskEntity)
+			countUbiTaskFailed(taskEntity, metrics.ReasonNoResource)
+			logs.GetLogger().Warnf("ubi task id: %d, type: %s, not found a resources available", ubiTask.ID, models.GetSourceTypeStr(ubiTask.ResourceType))
+			c.JSON(http.StatusInternalServerError, util.CreateErrorResponse(util.NoAvailableResourcesError))
+			return
+		}
+		taskEntity.Error = err.Error()
+		NewTaskService().SaveTaskEntity(taskEntity)
+		countUbiTaskFailed(taskEntity, metrics.ReasonCheckResource)
+		logs.GetLogger().Errorf("check resource failed, error: %v", err)
+		c.JSON(http.StatusInternalServerError, util.CreateErrorResponse(util.CheckResourcesError))
+		return
+	}
+	if err = saveUbiTaskSpec(taskEntity, job); err != nil {
+		logs.GetLogger().Errorf("ubi task id: %d, save the job of the task failed, error: %v", taskEntity.Id, err)
+		c.JSON(http.StatusInternalServerError, util.CreateErrorResponse(util.SaveTaskEntityError))
+		return
+	}
+
+	EnqueueUbiTask(taskEntity.Id)
+	c.JSON(http.StatusOK, util.CreateSuccessResponse("success"))
+}
+
+// newUbiTaskEntity saves the received task, with everything needed to run it again after a restart
+func newUbiTaskEntity(ubiTask models.UBITaskReq) (*models.TaskEntity, error) {
+	taskResource, err := json.Marshal(ubiTask.Resource)
+	if err != nil {
+		return nil, err
 	}
 
 	var taskEntity = new(models.TaskEntity)
@@ -105,40 +149,78 @@ func DoUbiTaskForK8s(c *gin.Context) {
 	taskEntity.Contract = ubiTask.ContractAddr
 	taskEntity.ResourceType = ubiTask.ResourceType
 	taskEntity.InputParam = ubiTask.InputParam
+	taskEntity.Resource = string(taskResource)
 	taskEntity.Status = models.TASK_RECEIVED_STATUS
 	taskEntity.CreateTime = time.Now().Unix()
-	err = NewTaskService().SaveTaskEntity(taskEntity)
+	return taskEntity, NewTaskService().SaveTaskEntity(taskEntity)
+}
+
+// ubiTaskResource returns the resource requested by the task. The tasks received before the resource was saved
+// with them cannot be run again.
+func ubiTaskResource(task *models.TaskEntity) (models.TaskResource, error) {
+	var taskResource models.TaskResource
+	if task.Resource == "" {
+		return taskResource, fmt.Errorf("the resource of ubi task was not saved, the task cannot be run again")
+	}
+	if err := json.Unmarshal([]byte(task.Resource), &taskResource); err != nil {
+		return taskResource, fmt.Errorf("parse the resource of ubi task failed, error: %v", err)
+	}
+	return taskResource, nil
+}
+
+// saveUbiTaskSpec saves the job or container built for the task, the task runs it as built once it is accepted,
+// on the node its resources were checked on
+func saveUbiTaskSpec(task *models.TaskEntity, spec any) error {
+	data, err := json.Marshal(spec)
 	if err != nil {
-		logs.GetLogger().Errorf("save task entity failed, error: %v", err)
-		return
+		return err
+	}
+	task.Spec = string(data)
+	return NewTaskService().SaveTaskEntity(task)
+}
+
+// ubiTaskJob is the job saved with the task, or a new one for the tasks accepted before it was saved
+func ubiTaskJob(task *models.TaskEntity) (*batchv1.Job, error) {
+	if task.Spec == "" {
+		return newUbiTaskJob(task)
 	}
+	job := new(batchv1.Job)
+	if err := json.Unmarshal([]byte(task.Spec), job); err != nil {
+		return nil, fmt.Errorf("parse the job of ubi task failed, error: %v", err)
+	}
+	return job, nil
+}
 
-	var envFilePath string
-	envFilePath = filepath.Join(os.Getenv("CP_PATH"), "fil-c2.env")
+// newUbiTaskJob checks that the cluster has the resources for the task and builds the job running it
+func newUbiTaskJob(task *models.TaskEntity) (*batchv1.Job, error) {
+	taskResource, err := ubiTaskResource(task)
+	if err != nil {
+		return nil, err
+	}
+
+	var gpuFlag = "0"
+	if task.ResourceType == 1 {
+		gpuFlag = "1"
+	}
+
+	envFilePath := filepath.Join(os.Getenv("CP_PATH"), "fil-c2.env")
 	envVars, err := godotenv.Read(envFilePath)
 	if err != nil {
-		logs.GetLogger().Errorf("reading fil-c2-env.env failed, error: %v", err)
-		return
+		return nil, fmt.Errorf("reading fil-c2-env.env failed, error: %v", err)
 	}
 
 	c2GpuConfig := envVars["RUST_GPU_TOOLS_CUSTOM_GPU"]
 	c2GpuName := convertGpuName(strings.TrimSpace(c2GpuConfig))
-	nodeName, architecture, needCpu, needMemory, needStorage, err := checkResourceAvailableForUbi(ubiTask.ResourceType, c2GpuName, ubiTask.Resource)
+	gpuResource := gpuVendorResource(c2GpuName)
+	if _, name := parseGpuResource(taskResource.GPU); task.ResourceType == 1 && isSharedGpuResource(name) {
+		gpuResource = coreV1.ResourceName(name)
+	}
+	nodeName, architecture, needCpu, needMemory, needStorage, err := checkResourceAvailableForUbi(task.ResourceType, c2GpuName, &taskResource)
 	if err != nil {
-		taskEntity.Status = models.TASK_FAILED_STATUS
-		NewTaskService().SaveTaskEntity(taskEntity)
-		logs.GetLogger().Errorf("check resource failed, error: %v", err)
-		c.JSON(http.StatusInternalServerError, util.CreateErrorResponse(util.CheckResourcesError))
-		return
+		return nil, err
 	}
-
 	if nodeName == "" {
-		taskEntity.Status = models.TASK_FAILED_STATUS
-		taskEntity.Error = "No resources available"
-		NewTaskService().SaveTaskEntity(taskEntity)
-		logs.GetLogger().Warnf("ubi task id: %d, type: %s, not found a resources available", ubiTask.ID, models.GetSourceTypeStr(ubiTask.ResourceType))
-		c.JSON(http.StatusInternalServerError, util.CreateErrorResponse(util.NoAvailableResourcesError))
-		return
+		return nil, errNoUbiResource
 	}
 
 	var ubiTaskImage string
@@ -153,41 +235,32 @@ func DoUbiTaskForK8s(c *gin.Context) {
 			ubiTaskImage = build.UBITaskImageIntelGpu
 		}
 	}
+	if ubiTaskImage == "" {
+		return nil, fmt.Errorf("unknown cpu architecture %q, please check the log output of the resource-exporter pod to see if cpu_name is intel or amd", architecture)
+	}
 
-	mem := strings.Split(strings.TrimSpace(ubiTask.Resource.Memory), " ")[1]
+	mem := strings.Split(strings.TrimSpace(taskResource.Memory), " ")[1]
 	memUnit := strings.ReplaceAll(mem, "B", "")
-	disk := strings.Split(strings.TrimSpace(ubiTask.Resource.Storage), " ")[1]
+	disk := strings.Split(strings.TrimSpace(taskResource.Storage), " ")[1]
 	diskUnit := strings.ReplaceAll(disk, "B", "")
 	memQuantity, err := resource.ParseQuantity(fmt.Sprintf("%d%s", needMemory, memUnit))
 	if err != nil {
-		taskEntity.Status = models.TASK_FAILED_STATUS
-		NewTaskService().SaveTaskEntity(taskEntity)
-		logs.GetLogger().Error("get memory failed, error: %+v", err)
-		return
+		return nil, fmt.Errorf("get memory failed, error: %v", err)
 	}
 
 	storageQuantity, err := resource.ParseQuantity(fmt.Sprintf("%d%s", needStorage, diskUnit))
 	if err != nil {
-		taskEntity.Status = models.TASK_FAILED_STATUS
-		NewTaskService().SaveTaskEntity(taskEntity)
-		logs.GetLogger().Error("get storage failed, error: %+v", err)
-		return
+		return nil, fmt.Errorf("get storage failed, error: %v", err)
 	}
 
 	maxMemQuantity, err := resource.ParseQuantity(fmt.Sprintf("%d%s", needMemory*2, memUnit))
 	if err != nil {
-		taskEntity.Status = models.TASK_FAILED_STATUS
-		NewTaskService().SaveTaskEntity(taskEntity)
-		logs.GetLogger().Error("get memory failed, error: %+v", err)
-		return
+		return nil, fmt.Errorf("get memory failed, error: %v", err)
 	}
 
 	maxStorageQuantity, err := resource.ParseQuantity(fmt.Sprintf("%d%s", needStorage*2, diskUnit))
 	if err != nil {
-		taskEntity.Status = models.TASK_FAILED_STATUS
-		NewTaskService().SaveTaskEntity(taskEntity)
-		logs.GetLogger().Error("get storage failed, error: %+v", err)
-		return
+		return nil, fmt.Errorf("get storage failed, error: %v", err)
 	}
 
 	resourceRequirements := coreV1.ResourceRequirements{
@@ -195,216 +268,227 @@ func DoUbiTaskForK8s(c *gin.Context) {
 			coreV1.ResourceCPU:              *resource.NewQuantity(needCpu*2, resource.DecimalSI),
 			coreV1.ResourceMemory:           maxMemQuantity,
 			coreV1.ResourceEphemeralStorage: maxStorageQuantity,
-			"nvidia.com/gpu":                resource.MustParse(gpuFlag),
+			gpuResource:                     resource.MustParse(gpuFlag),
 		},
 		Requests: coreV1.ResourceList{
 			coreV1.ResourceCPU:              *resource.NewQuantity(needCpu, resource.DecimalSI),
 			coreV1.ResourceMemory:           memQuantity,
 			coreV1.ResourceEphemeralStorage: storageQuantity,
-			"nvidia.com/gpu":                resource.MustParse(gpuFlag),
+			gpuResource:                     resource.MustParse(gpuFlag),
 		},
 	}
 
-	go func() {
-		var namespace = "ubi-task-" + strconv.Itoa(ubiTask.ID)
-		var err error
-		defer func() {
-			if err := recover(); err != nil {
-				logs.GetLogger().Errorf("do zk task painc, error: %+v", err)
-				return
-			}
+	namespace := ubiTaskNamespace(task.Id)
+	receiveUrl := fmt.Sprintf("%s:%d/api/v1/computing/cp/receive/ubi", NewK8sService().GetAPIServerEndpoint(), conf.GetConfig().API.Port)
+	execCommand := []string{"ubi-bench", "c2"}
+	JobName := ubiTaskJobName(task)
+	filC2Param := envVars["FIL_PROOFS_PARAMETER_CACHE"]
+	if gpuFlag == "0" {
+		delete(envVars, "RUST_GPU_TOOLS_CUSTOM_GPU")
+		envVars["BELLMAN_NO_GPU"] = "1"
+	}
 
-			ubiTaskRun, err := NewTaskService().GetTaskEntity(int64(ubiTask.ID))
-			if err != nil {
-				logs.GetLogger().Errorf("get ubi task detail from db failed, ubiTaskId: %d, error: %+v", ubiTask.ID, err)
-				return
-			}
+	delete(envVars, "FIL_PROOFS_PARAMETER_CACHE")
+	var useEnvVars []v1.EnvVar
+	for k, v := range envVars {
+		useEnvVars = append(useEnvVars, v1.EnvVar{
+			Name:  k,
+			Value: v,
+		})
+	}
 
-			if ubiTaskRun.TxHash != "" {
-				ubiTaskRun.Status = models.TASK_SUCCESS_STATUS
-			} else {
-				ubiTaskRun.Status = models.TASK_FAILED_STATUS
-				k8sService := NewK8sService()
-				k8sService.k8sClient.CoreV1().Namespaces().Delete(context.TODO(), namespace, metaV1.DeleteOptions{})
-			}
-			err = NewTaskService().SaveTaskEntity(ubiTaskRun)
-		}()
+	useEnvVars = append(useEnvVars, v1.EnvVar{
+		Name:  "RECEIVE_PROOF_URL",
+		Value: receiveUrl,
+	},
+		v1.EnvVar{
+			Name:  "TASKID",
+			Value: strconv.FormatInt(task.Id, 10),
+		},
+		v1.EnvVar{
+			Name:  "NAME_SPACE",
+			Value: namespace,
+		},
+		v1.EnvVar{
+			Name:  "PARAM_URL",
+			Value: task.InputParam,
+		},
+	)
 
-		if ubiTaskImage == "" {
-			logs.GetLogger().Errorf("please check the log output of the resource-exporter pod to see if cpu_name is intel or amd")
-			return
+	job := &batchv1.Job{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      JobName,
+			Namespace: namespace,
+		},
+		Spec: batchv1.JobSpec{
+			Template: v1.PodTemplateSpec{
+				Spec: v1.PodSpec{
+					NodeName:     nodeName,
+					NodeSelector: generateLabel(strings.ReplaceAll(c2GpuName, " ", "-")),
+					Containers: []v1.Container{
+						{
+							Name:  JobName + generateString(5),
+							Image: ubiTaskImage,
+							Env:   useEnvVars,
+							VolumeMounts: []v1.VolumeMount{
+								{
+									Name:      "proof-params",
+									MountPath: "/var/tmp/filecoin-proof-parameters",
+								},
+							},
+							Command:         execCommand,
+							Resources:       resourceRequirements,
+							ImagePullPolicy: coreV1.PullIfNotPresent,
+						},
+					},
+					Volumes: []v1.Volume{
+						{
+							Name: "proof-params",
+							VolumeSource: v1.VolumeSource{
+								HostPath: &v1.HostPathVolumeSource{
+									Path: filC2Param,
+								},
+							},
+						},
+					},
+					RestartPolicy: "Never",
+				},
+			},
+			BackoffLimit:            new(int32),
+			TTLSecondsAfterFinished: new(int32),
+		},
+	}
+
+	*job.Spec.BackoffLimit = 1
+	*job.Spec.TTLSecondsAfterFinished = 120
+
+	podSpec := &job.Spec.Template.Spec
+	hardenPodSpec(podSpec, securityProfile(), map[string][]string{podSpec.Containers[0].Name: conf.GetConfig().SECURITY.UbiExceptions}, nil)
+	return job, nil
+}
+
+// startUbiTaskForK8s creates the job saved with a received task, or reattaches to the job of a running one
+func startUbiTaskForK8s(task *models.TaskEntity) error {
+	namespace := ubiTaskNamespace(task.Id)
+	JobName := ubiTaskJobName(task)
+
+	k8sService := NewK8sService()
+	_, err := k8sService.k8sClient.BatchV1().Jobs(namespace).Get(context.TODO(), JobName, metaV1.GetOptions{})
+	if err == nil {
+		logs.GetLogger().Infof("ubi task id: %d, reattach to the running job %s", task.Id, JobName)
+	} else if !errors.IsNotFound(err) {
+		return fmt.Errorf("get ubi task job failed, error: %v", err)
+	} else if task.Status == models.TASK_RUNNING_STATUS {
+		return fmt.Errorf("the job of ubi task is gone")
+	} else {
+		job, err := ubiTaskJob(task)
+		if err != nil {
+			return err
 		}
 
-		k8sService := NewK8sService()
 		if _, err = k8sService.GetNameSpace(context.TODO(), namespace, metaV1.GetOptions{}); err != nil {
 			if errors.IsNotFound(err) {
 				k8sNamespace := &v1.Namespace{
 					ObjectMeta: metaV1.ObjectMeta{
-						Name: namespace,
+						Name:   namespace,
+						Labels: ubiNamespaceLabels(),
 					},
 				}
 				_, err = k8sService.CreateNameSpace(context.TODO(), k8sNamespace, metaV1.CreateOptions{})
 				if err != nil {
-					logs.GetLogger().Errorf("create namespace failed, error: %v", err)
-					return
+					return fmt.Errorf("create namespace failed, error: %v", err)
 				}
 			}
 		}
 
-		receiveUrl := fmt.Sprintf("%s:%d/api/v1/computing/cp/receive/ubi", k8sService.GetAPIServerEndpoint(), conf.GetConfig().API.Port)
-		execCommand := []string{"ubi-bench", "c2"}
-		JobName := strings.ToLower(models.UbiTaskTypeStr(ubiTask.Type)) + "-" + strconv.Itoa(ubiTask.ID)
-		filC2Param := envVars["FIL_PROOFS_PARAMETER_CACHE"]
-		if gpuFlag == "0" {
-			delete(envVars, "RUST_GPU_TOOLS_CUSTOM_GPU")
-			envVars["BELLMAN_NO_GPU"] = "1"
-		}
-
-		delete(envVars, "FIL_PROOFS_PARAMETER_CACHE")
-		var useEnvVars []v1.EnvVar
-		for k, v := range envVars {
-			useEnvVars = append(useEnvVars, v1.EnvVar{
-				Name:  k,
-				Value: v,
-			})
-		}
-
-		useEnvVars = append(useEnvVars, v1.EnvVar{
-			Name:  "RECEIVE_PROOF_URL",
-			Value: receiveUrl,
-		},
-			v1.EnvVar{
-				Name:  "TASKID",
-				Value: strconv.Itoa(ubiTask.ID),
-			},
-			v1.EnvVar{
-				Name:  "NAME_SPACE",
-				Value: namespace,
-			},
-			v1.EnvVar{
-				Name:  "PARAM_URL",
-				Value: ubiTask.InputParam,
-			},
-		)
-
-		job := &batchv1.Job{
-			ObjectMeta: metaV1.ObjectMeta{
-				Name:      JobName,
-				Namespace: namespace,
-			},
-			Spec: batchv1.JobSpec{
-				Template: v1.PodTemplateSpec{
-					Spec: v1.PodSpec{
-						NodeName:     nodeName,
-						NodeSelector: generateLabel(strings.ReplaceAll(c2GpuName, " ", "-")),
-						Containers: []v1.Container{
-							{
-								Name:  JobName + generateString(5),
-								Image: ubiTaskImage,
-								Env:   useEnvVars,
-								VolumeMounts: []v1.VolumeMount{
-									{
-										Name:      "proof-params",
-										MountPath: "/var/tmp/filecoin-proof-parameters",
-									},
-								},
-								Command:         execCommand,
-								Resources:       resourceRequirements,
-								ImagePullPolicy: coreV1.PullIfNotPresent,
-							},
-						},
-						Volumes: []v1.Volume{
-							{
-								Name: "proof-params",
-								VolumeSource: v1.VolumeSource{
-									HostPath: &v1.HostPathVolumeSource{
-										Path: filC2Param,
-									},
-								},
-							},
-						},
-						RestartPolicy: "Never",
-					},
-				},
-				BackoffLimit:            new(int32),
-				TTLSecondsAfterFinished: new(int32),
-			},
-		}
-
-		*job.Spec.BackoffLimit = 1
-		*job.Spec.TTLSecondsAfterFinished = 120
-
 		if _, err = k8sService.k8sClient.BatchV1().Jobs(namespace).Create(context.TODO(), job, metaV1.CreateOptions{}); err != nil {
-			logs.GetLogger().Errorf("Failed creating ubi task job: %v", err)
-			return
+			return fmt.Errorf("failed creating ubi task job: %v", err)
 		}
 
-		err = wait.PollImmediate(2*time.Second, 60*time.Second, func() (bool, error) {
-			pods, err := k8sService.k8sClient.CoreV1().Pods(namespace).List(context.TODO(), metaV1.ListOptions{
-				LabelSelector: fmt.Sprintf("job-name=%s", JobName),
-			})
-			if err != nil {
-				return false, err
-			}
-
-			for _, p := range pods.Items {
-				for _, condition := range p.Status.Conditions {
-					if condition.Type != coreV1.PodReady && condition.Status != coreV1.ConditionTrue {
-						return false, nil
-					}
-				}
-			}
-			return true, nil
-		})
-		if err != nil {
-			logs.GetLogger().Errorf("Failed waiting pods create: %v", err)
-			return
+		task.Status = models.TASK_RUNNING_STATUS
+		task.Workload = JobName
+		if err = NewTaskService().SaveTaskEntity(task); err != nil {
+			logs.GetLogger().Errorf("ubi task id: %d, save task status failed, error: %v", task.Id, err)
 		}
+	}
+	return nil
+}
+
+// followUbiTaskForK8s follows the log of the job of a started task until the job ends. The proof comes back
+// through ReceiveUbiProofForK8s.
+func followUbiTaskForK8s(task *models.TaskEntity) error {
+	namespace := ubiTaskNamespace(task.Id)
+	JobName := ubiTaskJobName(task)
 
+	k8sService := NewK8sService()
+	err := wait.PollImmediate(2*time.Second, 60*time.Second, func() (bool, error) {
 		pods, err := k8sService.k8sClient.CoreV1().Pods(namespace).List(context.TODO(), metaV1.ListOptions{
 			LabelSelector: fmt.Sprintf("job-name=%s", JobName),
 		})
 		if err != nil {
-			logs.GetLogger().Errorf("Failed list ubi pods: %v", err)
-			return
+			return false, err
 		}
 
-		var podName string
-		for _, pod := range pods.Items {
-			podName = pod.Name
-			break
-		}
-		if podName == "" {
-			return
+		for _, p := range pods.Items {
+			for _, condition := range p.Status.Conditions {
+				if condition.Type != coreV1.PodReady && condition.Status != coreV1.ConditionTrue {
+					return false, nil
+				}
+			}
 		}
+		return true, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed waiting pods create: %v", err)
+	}
 
-		req := k8sService.k8sClient.CoreV1().Pods(namespace).GetLogs(podName, &v1.PodLogOptions{
-			Container: "",
-			Follow:    true,
-		})
+	pods, err := k8sService.k8sClient.CoreV1().Pods(namespace).List(context.TODO(), metaV1.ListOptions{
+		LabelSelector: fmt.Sprintf("job-name=%s", JobName),
+	})
+	if err != nil {
+		return fmt.Errorf("failed list ubi pods: %v", err)
+	}
 
-		podLogs, err := req.Stream(context.Background())
-		if err != nil {
-			logs.GetLogger().Errorf("Error opening log stream: %v", err)
-			return
-		}
-		defer podLogs.Close()
+	var podName string
+	for _, pod := range pods.Items {
+		podName = pod.Name
+		break
+	}
+	if podName == "" {
+		return nil
+	}
 
-		ubiLogFileName := filepath.Join(cpRepoPath, "ubi-fcp.log")
-		logFile, err := os.OpenFile(ubiLogFileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-		if err != nil {
-			logs.GetLogger().Errorf("opening ubi-fcp log file failed, error: %v", err)
-			return
-		}
-		defer logFile.Close()
+	req := k8sService.k8sClient.CoreV1().Pods(namespace).GetLogs(podName, &v1.PodLogOptions{
+		Container: "",
+		Follow:    true,
+	})
 
-		if _, err = io.Copy(logFile, podLogs); err != nil {
-			logs.GetLogger().Errorf("write ubi-fcp log to file failed, error: %v", err)
-			return
-		}
-	}()
+	podLogs, err := req.Stream(context.Background())
+	if err != nil {
+		return fmt.Errorf("error opening log stream: %v", err)
+	}
+	defer podLogs.Close()
 
-	c.JSON(http.StatusOK, util.CreateSuccessResponse("success"))
+	cpRepoPath, _ := os.LookupEnv("CP_PATH")
+	ubiLogFileName := filepath.Join(cpRepoPath, "ubi-fcp.log")
+	logFile, err := os.OpenFile(ubiLogFileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("opening ubi-fcp log file failed, error: %v", err)
+	}
+	defer logFile.Close()
+
+	if _, err = io.Copy(logFile, podLogs); err != nil {
+		return fmt.Errorf("write ubi-fcp log to file failed, error: %v", err)
+	}
+	return nil
+}
+
+func ubiTaskNamespace(taskId int64) string {
+	return "ubi-task-" + strconv.FormatInt(taskId, 10)
+}
+
+func ubiTaskJobName(task *models.TaskEntity) string {
+	return strings.ToLower(models.UbiTaskTypeStr(task.Type)) + "-" + strconv.FormatInt(task.Id, 10)
 }
 
 func ReceiveUbiProofForK8s(c *gin.Context) {
@@ -427,7 +511,8 @@ func ReceiveUbiProofForK8s(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, util.CreateErrorResponse(util.JsonError))
 		return
 	}
-	err = submitUBIProof(c2Proof, ubiTask)
+	saveUbiProof(ubiTask, c2Proof.Proof)
+	err = submitUbiTaskProof(ubiTask)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, util.CreateErrorResponse(util.JsonError))
 		return
@@ -435,6 +520,14 @@ func ReceiveUbiProofForK8s(c *gin.Context) {
 	c.JSON(http.StatusOK, util.CreateSuccessResponse("success"))
 }
 
+// saveUbiProof keeps the proof with the task before it is submitted, so that a restart in between does not lose it
+func saveUbiProof(task *models.TaskEntity, proof string) {
+	task.Proof = proof
+	if err := NewTaskService().SaveTaskEntity(task); err != nil {
+		logs.GetLogger().Warnf("ubi task id: %d, save proof failed, error: %v", task.Id, err)
+	}
+}
+
 func DoUbiTaskForDocker(c *gin.Context) {
 	var ubiTask models.UBITaskReq
 	if err := c.ShouldBindJSON(&ubiTask); err != nil {
@@ -476,6 +569,10 @@ func DoUbiTaskForDocker(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, util.CreateErrorResponse(util.UbiTaskParamError, "missing required field: contract_addr"))
 		return
 	}
+	if ubiTask.Resource == nil {
+		c.JSON(http.StatusBadRequest, util.CreateErrorResponse(util.UbiTaskParamError, "missing required field: resource"))
+		return
+	}
 
 	if _, err := GetTaskInfoOnChain(ubiTask.ContractAddr); err != nil {
 		c.JSON(http.StatusBadRequest, util.CreateErrorResponse(util.UbiTaskContractError))
@@ -498,39 +595,54 @@ func DoUbiTaskForDocker(c *gin.Context) {
 		return
 	}
 
-	var gpuFlag = "0"
-	if ubiTask.ResourceType == 1 {
-		gpuFlag = "1"
+	taskEntity, err := newUbiTaskEntity(ubiTask)
+	if err != nil {
+		logs.GetLogger().Errorf("save task entity failed, error: %v", err)
+		c.JSON(http.StatusInternalServerError, util.CreateErrorResponse(util.SaveTaskEntityError))
+		return
 	}
+	metrics.UbiTaskReceived.WithLabelValues(models.GetSourceTypeStr(taskEntity.ResourceType)).Inc()
 
-	var taskEntity = new(models.TaskEntity)
-	taskEntity.Id = int64(ubiTask.ID)
-	taskEntity.Type = ubiTask.Type
-	taskEntity.Name = ubiTask.Name
-	taskEntity.Contract = ubiTask.ContractAddr
-	taskEntity.ResourceType = ubiTask.ResourceType
-	taskEntity.InputParam = ubiTask.InputParam
-	taskEntity.Status = models.TASK_RECEIVED_STATUS
-	taskEntity.CreateTime = time.Now().Unix()
-	err = NewTaskService().SaveTaskEntity(taskEntity)
+	containerConfig, hostConfig, err := newUbiTaskContainer(taskEntity)
 	if err != nil {
-		logs.GetLogger().Errorf("save task entity failed, error: %v", err)
+		taskEntity.Status = models.TASK_FAILED_STATUS
+		taskEntity.Error = err.Error()
+		NewTaskService().SaveTaskEntity(taskEntity)
+		countUbiTaskFailed(taskEntity, metrics.ReasonCheckResource)
+		c.JSON(http.StatusInternalServerError, util.CreateErrorResponse(util.CheckResourcesError))
+		return
+	}
+	if err = saveUbiTaskSpec(taskEntity, ubiContainerSpec{Config: containerConfig, HostConfig: hostConfig}); err != nil {
+		logs.GetLogger().Errorf("ubi task id: %d, save the container of the task failed, error: %v", taskEntity.Id, err)
 		c.JSON(http.StatusInternalServerError, util.CreateErrorResponse(util.SaveTaskEntityError))
 		return
 	}
 
+	EnqueueUbiTask(taskEntity.Id)
+	c.JSON(http.StatusOK, util.CreateSuccessResponse("success"))
+}
+
+// newUbiTaskContainer checks the resources of the host and builds the container running the task
+func newUbiTaskContainer(task *models.TaskEntity) (*container.Config, *container.HostConfig, error) {
+	taskResource, err := ubiTaskResource(task)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var gpuFlag = "0"
+	if task.ResourceType == 1 {
+		gpuFlag = "1"
+	}
+
 	var gpuName string
 	gpuConfig, ok := os.LookupEnv("RUST_GPU_TOOLS_CUSTOM_GPU")
 	if ok {
 		gpuName = convertGpuName(strings.TrimSpace(gpuConfig))
 	}
 
-	_, architecture, _, needMemory, err := checkResourceForUbi(ubiTask.Resource, gpuName, ubiTask.ResourceType)
+	_, architecture, _, needMemory, err := checkResourceForUbi(&taskResource, gpuName, task.ResourceType)
 	if err != nil {
-		taskEntity.Status = models.TASK_FAILED_STATUS
-		NewTaskService().SaveTaskEntity(taskEntity)
-		c.JSON(http.StatusInternalServerError, util.CreateErrorResponse(util.CheckResourcesError))
-		return
+		return nil, nil, err
 	}
 
 	var ubiTaskImage string
@@ -545,127 +657,155 @@ func DoUbiTaskForDocker(c *gin.Context) {
 			ubiTaskImage = build.UBITaskImageIntelGpu
 		}
 	}
+	if ubiTaskImage == "" {
+		return nil, nil, fmt.Errorf("unknown cpu architecture %q, please check the log output of the resource-exporter container to see if cpu_name is intel or amd", architecture)
+	}
 
-	go func() {
-		defer func() {
-			if err := recover(); err != nil {
-				logs.GetLogger().Errorf("do zk task painc, error: %+v", err)
-				return
-			}
+	receiveUrl := fmt.Sprintf("http://127.0.0.1:%d/api/v1/computing/cp/docker/receive/ubi", conf.GetConfig().API.Port)
+	execCommand := []string{"ubi-bench", "c2"}
 
-			ubiTaskRun, err := NewTaskService().GetTaskEntity(int64(ubiTask.ID))
-			if err != nil {
-				logs.GetLogger().Errorf("get ubi task detail from db failed, ubiTaskId: %d, error: %+v", ubiTask.ID, err)
-				return
-			}
-			if ubiTaskRun.Id == 0 {
-				ubiTaskRun = new(models.TaskEntity)
-				ubiTaskRun.Id = int64(ubiTask.ID)
-				ubiTaskRun.Type = ubiTask.Type
-				ubiTaskRun.Name = ubiTask.Name
-				ubiTaskRun.Contract = ubiTask.ContractAddr
-				ubiTaskRun.ResourceType = ubiTask.ResourceType
-				ubiTaskRun.InputParam = ubiTask.InputParam
-				ubiTaskRun.CreateTime = time.Now().Unix()
-				ubiTaskRun.Contract = ubiTask.ContractAddr
-				NewTaskService().SaveTaskEntity(ubiTaskRun)
-			}
-		}()
+	var env = []string{"RECEIVE_PROOF_URL=" + receiveUrl}
+	env = append(env, "TASKID="+strconv.FormatInt(task.Id, 10))
+	env = append(env, "PARAM_URL="+task.InputParam)
 
-		if ubiTaskImage == "" {
-			logs.GetLogger().Errorf("please check the log output of the resource-exporter container to see if cpu_name is intel or amd")
-			return
+	var needResource container.Resources
+	var groupAdd []string
+	if gpuFlag == "0" {
+		env = append(env, "BELLMAN_NO_GPU=1")
+		needResource = container.Resources{
+			Memory: needMemory * 1024 * 1024 * 1024,
 		}
-
-		if err := NewDockerService().PullImage(ubiTaskImage); err != nil {
-			logs.GetLogger().Errorf("pull %s image failed, error: %v", ubiTaskImage, err)
-			return
+	} else {
+		gpuEnv, ok := os.LookupEnv("RUST_GPU_TOOLS_CUSTOM_GPU")
+		if ok {
+			env = append(env, "RUST_GPU_TOOLS_CUSTOM_GPU="+gpuEnv)
 		}
-
-		receiveUrl := fmt.Sprintf("http://127.0.0.1:%d/api/v1/computing/cp/docker/receive/ubi", conf.GetConfig().API.Port)
-		execCommand := []string{"ubi-bench", "c2"}
-		JobName := strings.ToLower(models.UbiTaskTypeStr(ubiTask.Type)) + "-" + strconv.Itoa(ubiTask.ID)
-
-		var env = []string{"RECEIVE_PROOF_URL=" + receiveUrl}
-		env = append(env, "TASKID="+strconv.Itoa(ubiTask.ID))
-		env = append(env, "PARAM_URL="+ubiTask.InputParam)
-
-		var needResource container.Resources
-		if gpuFlag == "0" {
-			env = append(env, "BELLMAN_NO_GPU=1")
-			needResource = container.Resources{
-				Memory: needMemory * 1024 * 1024 * 1024,
+		needResource = container.Resources{
+			Memory: needMemory * 1024 * 1024 * 1024,
+		}
+		if gpuVendor(gpuName) == GpuVendorAmd {
+			// the ROCm containers reach the gpus through the kfd and dri devices
+			needResource.Devices = []container.DeviceMapping{
+				{PathOnHost: "/dev/kfd", PathInContainer: "/dev/kfd", CgroupPermissions: "rwm"},
+				{PathOnHost: "/dev/dri", PathInContainer: "/dev/dri", CgroupPermissions: "rwm"},
 			}
+			groupAdd = []string{"video"}
 		} else {
-			gpuEnv, ok := os.LookupEnv("RUST_GPU_TOOLS_CUSTOM_GPU")
-			if ok {
-				env = append(env, "RUST_GPU_TOOLS_CUSTOM_GPU="+gpuEnv)
-			}
-			needResource = container.Resources{
-				Memory: needMemory * 1024 * 1024 * 1024,
-				DeviceRequests: []container.DeviceRequest{
-					{
-						Driver:       "nvidia",
-						Count:        -1,
-						Capabilities: [][]string{{"gpu"}},
-						Options:      nil,
-					},
+			needResource.DeviceRequests = []container.DeviceRequest{
+				{
+					Driver:       "nvidia",
+					Count:        -1,
+					Capabilities: [][]string{{"gpu"}},
+					Options:      nil,
 				},
 			}
 		}
+	}
 
-		filC2Param, ok := os.LookupEnv("FIL_PROOFS_PARAMETER_CACHE")
-		if !ok {
-			filC2Param = "/var/tmp/filecoin-proof-parameters"
-		}
+	filC2Param, ok := os.LookupEnv("FIL_PROOFS_PARAMETER_CACHE")
+	if !ok {
+		filC2Param = "/var/tmp/filecoin-proof-parameters"
+	}
+
+	hostConfig := &container.HostConfig{
+		Binds:       []string{fmt.Sprintf("%s:/var/tmp/filecoin-proof-parameters", filC2Param)},
+		Resources:   needResource,
+		NetworkMode: network.NetworkHost,
+		GroupAdd:    groupAdd,
+	}
+	containerConfig := &container.Config{
+		Image:        ubiTaskImage,
+		Cmd:          execCommand,
+		Env:          env,
+		AttachStdout: true,
+		AttachStderr: true,
+		Tty:          true,
+	}
+	return containerConfig, hostConfig, nil
+}
+
+// ubiContainerSpec is the container built for a task on the docker runtime
+type ubiContainerSpec struct {
+	Config     *container.Config
+	HostConfig *container.HostConfig
+}
+
+// ubiTaskContainer is the container saved with the task, or a new one for the tasks accepted before it was saved
+func ubiTaskContainer(task *models.TaskEntity) (*container.Config, *container.HostConfig, error) {
+	if task.Spec == "" {
+		return newUbiTaskContainer(task)
+	}
+	var spec ubiContainerSpec
+	if err := json.Unmarshal([]byte(task.Spec), &spec); err != nil {
+		return nil, nil, fmt.Errorf("parse the container of ubi task failed, error: %v", err)
+	}
+	return spec.Config, spec.HostConfig, nil
+}
+
+// startUbiTaskForDocker starts the container saved with a received task, or reattaches to the container of a
+// running one
+func startUbiTaskForDocker(task *models.TaskEntity) error {
+	dockerService := NewDockerService()
+	containerName := task.Workload
 
-		hostConfig := &container.HostConfig{
-			Binds:       []string{fmt.Sprintf("%s:/var/tmp/filecoin-proof-parameters", filC2Param)},
-			Resources:   needResource,
-			NetworkMode: network.NetworkHost,
+	if task.Status == models.TASK_RUNNING_STATUS {
+		if containerName == "" || !dockerService.IsExistContainer(containerName) {
+			return fmt.Errorf("the container of ubi task is gone")
 		}
-		containerConfig := &container.Config{
-			Image:        ubiTaskImage,
-			Cmd:          execCommand,
-			Env:          env,
-			AttachStdout: true,
-			AttachStderr: true,
-			Tty:          true,
+		logs.GetLogger().Infof("ubi task id: %d, reattach to the container %s", task.Id, containerName)
+	} else {
+		containerConfig, hostConfig, err := ubiTaskContainer(task)
+		if err != nil {
+			return err
 		}
 
-		containerName := JobName + generateString(5)
-		dockerService := NewDockerService()
-		if err = dockerService.ContainerCreateAndStart(containerConfig, hostConfig, containerName); err != nil {
-			logs.GetLogger().Errorf("create ubi task container failed, error: %v", err)
-			return
+		if err = dockerService.PullImage(containerConfig.Image); err != nil {
+			return fmt.Errorf("pull %s image failed, error: %v", containerConfig.Image, err)
 		}
 
-		time.Sleep(3 * time.Second)
-		if !dockerService.IsExistContainer(containerName) {
-			return
+		containerName = ubiTaskJobName(task) + generateString(5)
+		if err = dockerService.ContainerCreateAndStart(containerConfig, hostConfig, containerName); err != nil {
+			return fmt.Errorf("create ubi task container failed, error: %v", err)
 		}
 
-		containerLogStream, err := dockerService.GetContainerLogStream(containerName)
-		if err != nil {
-			logs.GetLogger().Errorf("get docker container log stream failed, error: %v", err)
-			return
+		task.Status = models.TASK_RUNNING_STATUS
+		task.Workload = containerName
+		if err = NewTaskService().SaveTaskEntity(task); err != nil {
+			logs.GetLogger().Errorf("ubi task id: %d, save task status failed, error: %v", task.Id, err)
 		}
-		defer containerLogStream.Close()
 
-		ubiLogFileName := filepath.Join(cpRepoPath, "ubi-ecp.log")
-		logFile, err := os.OpenFile(ubiLogFileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-		if err != nil {
-			logs.GetLogger().Errorf("opening ubi-ecp log file failed, error: %v", err)
-			return
-		}
-		defer logFile.Close()
+		time.Sleep(3 * time.Second)
+	}
+	return nil
+}
 
-		if _, err = io.Copy(logFile, containerLogStream); err != nil {
-			logs.GetLogger().Errorf("write ubi-ecp log to file failed, error: %v", err)
-			return
-		}
-	}()
-	c.JSON(http.StatusOK, util.CreateSuccessResponse("success"))
+// followUbiTaskForDocker follows the log of the container of a started task until the container exits. The proof
+// comes back through ReceiveUbiProofForDocker.
+func followUbiTaskForDocker(task *models.TaskEntity) error {
+	dockerService := NewDockerService()
+	containerName := task.Workload
+	if !dockerService.IsExistContainer(containerName) {
+		return nil
+	}
+
+	containerLogStream, err := dockerService.GetContainerLogStream(containerName)
+	if err != nil {
+		return fmt.Errorf("get docker container log stream failed, error: %v", err)
+	}
+	defer containerLogStream.Close()
+
+	cpRepoPath, _ := os.LookupEnv("CP_PATH")
+	ubiLogFileName := filepath.Join(cpRepoPath, "ubi-ecp.log")
+	logFile, err := os.OpenFile(ubiLogFileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("opening ubi-ecp log file failed, error: %v", err)
+	}
+	defer logFile.Close()
+
+	if _, err = io.Copy(logFile, containerLogStream); err != nil {
+		return fmt.Errorf("write ubi-ecp log to file failed, error: %v", err)
+	}
+	return nil
 }
 
 func checkResourceForUbi(resource *models.TaskResource, gpuName string, resourceType int) (bool, string, int64, int64, error) {
@@ -756,7 +896,8 @@ func ReceiveUbiProofForDocker(c *gin.Context) {
 		return
 	}
 
-	err = submitUBIProof(c2Proof, ubiTask)
+	saveUbiProof(ubiTask, c2Proof.Proof)
+	err = submitUbiTaskProof(ubiTask)
 	if err != nil {
 		logs.GetLogger().Warnf("ubi task id: %d, submit proof failed, error: %v", taskId, err)
 		c.JSON(http.StatusBadRequest, util.CreateErrorResponse(util.SubmitProofError))
@@ -789,6 +930,7 @@ func GetCpResource(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, util.CreateErrorResponse(util.JsonError))
 		return
 	}
+	describeGpuVendors(&nodeResource.Gpu)
 
 	cpAccountAddress, err := contract.GetCpAccountAddress()
 	if err != nil {
@@ -807,17 +949,16 @@ func GetCpResource(c *gin.Context) {
 }
 
 func submitUBIProof(c2Proof models.UbiC2Proof, task *models.TaskEntity) error {
-	chainUrl, err := conf.GetRpcByNetWorkName()
-	if err != nil {
-		logs.GetLogger().Errorf("get rpc url failed, taskId: %s, error: %v", c2Proof.TaskId, err)
-		return err
-	}
-	client, err := ethclient.Dial(chainUrl)
+	start := time.Now()
+	defer func() {
+		metrics.ObserveProofSubmit(start, task.TxHash != "")
+	}()
+
+	client, err := contract.GetEthClient()
 	if err != nil {
 		logs.GetLogger().Errorf("dial rpc connect failed, taskId: %s, error: %v", c2Proof.TaskId, err)
 		return err
 	}
-	client.Close()
 
 	localWallet, err := wallet.SetupWallet(wallet.WalletRepo)
 	if err != nil {
@@ -831,15 +972,13 @@ func submitUBIProof(c2Proof models.UbiC2Proof, task *models.TaskEntity) error {
 		return err
 	}
 
-	ki, err := localWallet.FindKey(workerAddress)
-	if err != nil || ki == nil {
-		logs.GetLogger().Errorf("taskId: %s,the address: %s, private key %v", c2Proof.TaskId, workerAddress, wallet.ErrKeyInfoNotFound)
+	workerSigner, err := localWallet.GetSigner(context.TODO(), workerAddress)
+	if err != nil {
+		logs.GetLogger().Errorf("taskId: %s, the address: %s, get signer failed, error: %v", c2Proof.TaskId, workerAddress, err)
 		return err
 	}
-	var workerPrivateKey = ki.PrivateKey
-	ki = nil
 
-	taskStub, err := ecp.NewTaskStub(client, ecp.WithTaskContractAddress(task.Contract), ecp.WithTaskPrivateKey(workerPrivateKey))
+	taskStub, err := ecp.NewTaskStub(client, ecp.WithTaskContractAddress(task.Contract), ecp.WithTaskSigner(workerSigner))
 	if err != nil {
 		logs.GetLogger().Errorf("create ubi task client failed,  taskId: %s, contract: %s, error: %v", c2Proof.TaskId, task.Contract, err)
 		return err
@@ -873,6 +1012,7 @@ loopTask:
 		logs.GetLogger().Warnf("taskId: %s proof submission deadline has passed, receiveProofTime: %d, finallyTime: %d, deadlineTime: %d", c2Proof.TaskId, receiveProofTime, finallyTime, deadlineTime)
 		task.Status = models.TASK_FAILED_STATUS
 		task.Error = fmt.Sprintf("Proof submission deadline has passed")
+		countUbiTaskFailed(task, metrics.ReasonDeadline)
 		return NewTaskService().SaveTaskEntity(task)
 	}
 	submitUBIProofTx, err := taskStub.SubmitUBIProof(c2Proof.TaskId, c2Proof.Proof, deadlineTime)
@@ -884,23 +1024,37 @@ loopTask:
 	} else if err != nil {
 		task.Status = models.TASK_FAILED_STATUS
 		task.Error = fmt.Sprintf("%s", err.Error())
+		countUbiTaskFailed(task, metrics.ReasonSubmitProof)
 		logs.GetLogger().Errorf("taskId: %s, submitUBIProofTx failed, error: %v", c2Proof.TaskId, err)
 	}
 	return NewTaskService().SaveTaskEntity(task)
 }
 
+// GetWorkerSigner returns the signer of the worker address, which submits the ubi proofs
+func GetWorkerSigner() (contract.Signer, error) {
+	localWallet, err := wallet.SetupWallet(wallet.WalletRepo)
+	if err != nil {
+		return nil, fmt.Errorf("setup wallet failed, error: %v", err)
+	}
+	_, workerAddress, err := GetOwnerAddressAndWorkerAddress()
+	if err != nil {
+		return nil, fmt.Errorf("get worker address failed, error: %v", err)
+	}
+	return localWallet.GetSigner(context.TODO(), workerAddress)
+}
+
+// countUbiTaskFailed counts a task that has just failed in the metrics
+func countUbiTaskFailed(task *models.TaskEntity, reason string) {
+	metrics.UbiTaskFailed.WithLabelValues(models.GetSourceTypeStr(task.ResourceType), reason).Inc()
+}
+
 func GetTaskInfoOnChain(taskContract string) (ecp.ECPTaskTaskInfo, error) {
 	var taskInfo ecp.ECPTaskTaskInfo
 
-	chainRpc, err := conf.GetRpcByNetWorkName()
+	client, err := contract.GetEthClient()
 	if err != nil {
 		return taskInfo, err
 	}
-	client, err := ethclient.Dial(chainRpc)
-	if err != nil {
-		return taskInfo, err
-	}
-	defer client.Close()
 
 	taskStub, err := ecp.NewTaskStub(client, ecp.WithTaskContractAddress(taskContract))
 	if err != nil {
@@ -949,6 +1103,8 @@ func reportClusterResourceForDocker() {
 		return
 	}
 
+	recordNodeResourceMetrics([]*models.NodeResource{&nodeResource})
+
 	var freeGpuMap = make(map[string]int)
 	if nodeResource.Gpu.AttachedGpus > 0 {
 		for _, g := range nodeResource.Gpu.Details {
@@ -978,6 +1134,13 @@ func CronTaskForEcp() {
 		}
 	}()
 
+	go func() {
+		ticker := time.NewTicker(10 * time.Minute)
+		for range ticker.C {
+			recordEcpCollateralBalance()
+		}
+	}()
+
 	go func() {
 		ticker := time.NewTicker(5 * time.Minute)
 		for range ticker.C {
@@ -995,7 +1158,14 @@ func CronTaskForEcp() {
 				if ubiTask.TxHash != "" {
 					ubiTask.Status = models.TASK_SUCCESS_STATUS
 				} else {
+					if entity.Status != models.TASK_FAILED_STATUS {
+						countUbiTaskFailed(&ubiTask, metrics.ReasonTimeout)
+					}
 					ubiTask.Status = models.TASK_FAILED_STATUS
+					// stops the container, so that the worker of the task queue is released
+					if ubiTask.Workload != "" && IsUbiTaskQueued(ubiTask.Id) {
+						NewDockerService().RemoveContainerByName(ubiTask.Workload)
+					}
 				}
 				NewTaskService().SaveTaskEntity(&ubiTask)
 			}
@@ -1023,25 +1193,43 @@ func CronTaskForEcp() {
 	}()
 }
 
-func SyncCpAccountInfo() {
-	cpAccountAddress, err := contract.GetCpAccountAddress()
+func recordEcpCollateralBalance() {
+	client, err := contract.GetEthClient()
 	if err != nil {
-		logs.GetLogger().Fatalf("get cp account contract address failed, error: %v", err)
+		logs.GetLogger().Errorf("dial rpc connect failed, error: %v", err)
 		return
 	}
 
-	chainUrl, err := conf.GetRpcByNetWorkName()
+	ecpCollateral, err := ecp.NewCollateralStub(client)
 	if err != nil {
-		logs.GetLogger().Errorf("get rpc url failed, error: %v", err)
+		logs.GetLogger().Errorf("create ecp collateral client failed, error: %v", err)
 		return
 	}
+	cpCollateralInfo, err := ecpCollateral.CpInfo()
+	if err != nil {
+		logs.GetLogger().Errorf("get ecp collateral info failed, error: %v", err)
+		return
+	}
+	balance, err := strconv.ParseFloat(cpCollateralInfo.CollateralBalance, 64)
+	if err != nil {
+		logs.GetLogger().Errorf("parse collateral balance failed, error: %v", err)
+		return
+	}
+	metrics.CollateralBalance.WithLabelValues("ecp").Set(balance)
+}
 
-	client, err := ethclient.Dial(chainUrl)
+func SyncCpAccountInfo() {
+	cpAccountAddress, err := contract.GetCpAccountAddress()
+	if err != nil {
+		logs.GetLogger().Fatalf("get cp account contract address failed, error: %v", err)
+		return
+	}
+
+	client, err := contract.GetEthClient()
 	if err != nil {
 		logs.GetLogger().Errorf("dial rpc connect failed, error: %v", err)
 		return
 	}
-	defer client.Close()
 
 	cpStub, err := account2.NewAccountStub(client)
 	if err != nil {
@@ -1094,16 +1282,10 @@ func RestartResourceExporter() error {
 }
 
 func getReward(task *models.TaskEntity) error {
-	chainUrl, err := conf.GetRpcByNetWorkName()
-	if err != nil {
-		return fmt.Errorf("get rpc url failed, error: %s", err.Error())
-	}
-
-	client, err := ethclient.Dial(chainUrl)
+	client, err := contract.GetEthClient()
 	if err != nil {
 		return fmt.Errorf("dial rpc connect failed, error: %s", err.Error())
 	}
-	defer client.Close()
 
 	taskStub, err := ecp.NewTaskStub(client, ecp.WithTaskContractAddress(task.Contract))
 	if err != nil {
@@ -0,0 +1,295 @@
+package computing
+
+import (
+	"context"
+	"fmt"
+	"github.com/filswan/go-swan-lib/logs"
+	"github.com/swanchain/go-computing-provider/conf"
+	"github.com/swanchain/go-computing-provider/internal/contract/ecp"
+	"github.com/swanchain/go-computing-provider/internal/metrics"
+	"github.com/swanchain/go-computing-provider/internal/models"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	UbiRuntimeK8s    = "k8s"
+	UbiRuntimeDocker = "docker"
+
+	defaultUbiTaskWorkers = 4
+)
+
+var errUbiTaskDeadline = fmt.Errorf("proof submission deadline has passed")
+
+// UbiTaskQueue starts the ubi tasks on a fixed pool of workers. A task is handed to the workers once its
+// handler has checked the resources and saved it with its job or container. A worker only starts the task, its
+// log is followed outside the pool until it ends, so that a long task does not hold back the next ones. The
+// t_task table keeps the task received or running until it has finished, so the tasks interrupted by a restart
+// are found again and resumed.
+type UbiTaskQueue struct {
+	runtime string
+	tasks   chan int64
+	notify  chan struct{}
+	// queued holds the ids handed to the workers and not finished yet, including those being followed
+	queued sync.Map
+	// pending holds the ids enqueued and waiting for a free worker, in order
+	pending   []int64
+	pendingLk sync.Mutex
+
+	startTask     func(task *models.TaskEntity) error
+	followTask    func(task *models.TaskEntity) error
+	checkDeadline func(task *models.TaskEntity) error
+	submitProof   func(task *models.TaskEntity) error
+}
+
+var ubiTaskQueue *UbiTaskQueue
+
+// StartUbiTaskQueue starts the workers and resumes the unfinished tasks, runtime is UbiRuntimeK8s or UbiRuntimeDocker
+func StartUbiTaskQueue(runtime string) {
+	workers := conf.GetConfig().UBI.TaskWorkers
+	if workers <= 0 {
+		workers = defaultUbiTaskWorkers
+	}
+
+	queue := newUbiTaskQueue(runtime)
+	queue.start(workers)
+	queue.resume()
+	ubiTaskQueue = queue
+	logs.GetLogger().Infof("ubi task queue started, runtime: %s, workers: %d", runtime, workers)
+}
+
+func newUbiTaskQueue(runtime string) *UbiTaskQueue {
+	queue := &UbiTaskQueue{
+		runtime: runtime,
+		tasks:   make(chan int64),
+		notify:  make(chan struct{}, 1),
+		checkDeadline: func(task *models.TaskEntity) error {
+			return checkUbiTaskDeadline(task, GetTaskInfoOnChain)
+		},
+		submitProof: submitUbiTaskProof,
+	}
+	switch runtime {
+	case UbiRuntimeK8s:
+		queue.startTask, queue.followTask = startUbiTaskForK8s, followUbiTaskForK8s
+	case UbiRuntimeDocker:
+		queue.startTask, queue.followTask = startUbiTaskForDocker, followUbiTaskForDocker
+	default:
+		queue.startTask = func(task *models.TaskEntity) error {
+			return fmt.Errorf("unknown ubi task runtime: %s", runtime)
+		}
+	}
+	return queue
+}
+
+func (q *UbiTaskQueue) start(workers int) {
+	for i := 0; i < workers; i++ {
+		go q.work()
+	}
+	go q.dispatch()
+}
+
+// resume enqueues the tasks left received or running by the last run
+func (q *UbiTaskQueue) resume() {
+	taskList, err := NewTaskService().GetUnfinishedTaskList()
+	if err != nil {
+		logs.GetLogger().Errorf("get unfinished ubi task list failed, error: %v", err)
+		return
+	}
+	for _, task := range taskList {
+		q.enqueue(task.Id)
+	}
+}
+
+// EnqueueUbiTask hands a task to the workers, once its handler has checked the resources and saved it
+func EnqueueUbiTask(taskId int64) {
+	if ubiTaskQueue == nil {
+		logs.GetLogger().Warnf("ubi task id: %d, the task queue is not started", taskId)
+		return
+	}
+	ubiTaskQueue.enqueue(taskId)
+}
+
+// IsUbiTaskQueued reports whether the queue owns the task, until it has finished
+func IsUbiTaskQueued(taskId int64) bool {
+	if ubiTaskQueue == nil {
+		return false
+	}
+	_, ok := ubiTaskQueue.queued.Load(taskId)
+	return ok
+}
+
+func (q *UbiTaskQueue) enqueue(taskId int64) {
+	if _, loaded := q.queued.LoadOrStore(taskId, struct{}{}); loaded {
+		return
+	}
+	q.pendingLk.Lock()
+	q.pending = append(q.pending, taskId)
+	q.pendingLk.Unlock()
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (q *UbiTaskQueue) dispatch() {
+	for range q.notify {
+		for {
+			q.pendingLk.Lock()
+			if len(q.pending) == 0 {
+				q.pendingLk.Unlock()
+				break
+			}
+			taskId := q.pending[0]
+			q.pending = q.pending[1:]
+			q.pendingLk.Unlock()
+
+			q.tasks <- taskId
+		}
+	}
+}
+
+func (q *UbiTaskQueue) work() {
+	for taskId := range q.tasks {
+		if !q.process(taskId) {
+			q.queued.Delete(taskId)
+		}
+	}
+}
+
+// process starts the task, it returns true when the task is left to follow, which releases it once it ends
+func (q *UbiTaskQueue) process(taskId int64) (following bool) {
+	defer func() {
+		if err := recover(); err != nil {
+			logs.GetLogger().Errorf("do ubi task panic, taskId: %d, error: %+v", taskId, err)
+		}
+	}()
+
+	task, err := NewTaskService().GetTaskEntity(taskId)
+	if err != nil {
+		logs.GetLogger().Errorf("get ubi task detail from db failed, ubiTaskId: %d, error: %+v", taskId, err)
+		return
+	}
+	if task.Status != models.TASK_RECEIVED_STATUS && task.Status != models.TASK_RUNNING_STATUS {
+		return
+	}
+
+	// the proof arrived before a restart but was never submitted, finish submits it
+	if task.Proof != "" && task.TxHash == "" {
+		logs.GetLogger().Infof("ubi task id: %d, submitting the proof received before the restart", taskId)
+		q.finish(taskId, nil)
+		return
+	}
+
+	if err = q.checkDeadline(task); err != nil {
+		q.finish(taskId, err)
+		return
+	}
+
+	if err = q.startTask(task); err != nil {
+		logs.GetLogger().Errorf("ubi task id: %d, start task failed, error: %v", taskId, err)
+		q.finish(taskId, err)
+		return false
+	}
+	go q.follow(task)
+	return true
+}
+
+// follow follows a started task until its job or container ends, without holding a worker
+func (q *UbiTaskQueue) follow(task *models.TaskEntity) {
+	defer q.queued.Delete(task.Id)
+	defer func() {
+		if err := recover(); err != nil {
+			logs.GetLogger().Errorf("follow ubi task panic, taskId: %d, error: %+v", task.Id, err)
+		}
+	}()
+
+	err := q.followTask(task)
+	if err != nil {
+		logs.GetLogger().Errorf("ubi task id: %d, run task failed, error: %v", task.Id, err)
+	}
+	q.finish(task.Id, err)
+}
+
+// finish settles the status of a task whose job or container has ended. A proof received and not
+// submitted yet is submitted first, whatever the job ended with.
+func (q *UbiTaskQueue) finish(taskId int64, runErr error) {
+	task, err := NewTaskService().GetTaskEntity(taskId)
+	if err != nil {
+		logs.GetLogger().Errorf("get ubi task detail from db failed, ubiTaskId: %d, error: %+v", taskId, err)
+		return
+	}
+
+	if task.Proof != "" && task.TxHash == "" && task.Status != models.TASK_FAILED_STATUS {
+		if err = q.submitProof(task); err != nil {
+			logs.GetLogger().Errorf("ubi task id: %d, submit proof failed, error: %v", taskId, err)
+		}
+		if task, err = NewTaskService().GetTaskEntity(taskId); err != nil {
+			logs.GetLogger().Errorf("get ubi task detail from db failed, ubiTaskId: %d, error: %+v", taskId, err)
+			return
+		}
+	}
+
+	if task.TxHash != "" {
+		task.Status = models.TASK_SUCCESS_STATUS
+	} else {
+		// a failed proof submission has counted the task already
+		if task.Status != models.TASK_FAILED_STATUS {
+			reason := metrics.ReasonRun
+			if runErr == errUbiTaskDeadline {
+				reason = metrics.ReasonDeadline
+			}
+			countUbiTaskFailed(task, reason)
+		}
+		task.Status = models.TASK_FAILED_STATUS
+		if task.Error == "" && runErr != nil {
+			task.Error = runErr.Error()
+		}
+		if q.runtime == UbiRuntimeK8s {
+			NewK8sService().DeleteNameSpace(context.TODO(), ubiTaskNamespace(task.Id))
+		}
+	}
+	if err = NewTaskService().SaveTaskEntity(task); err != nil {
+		logs.GetLogger().Errorf("ubi task id: %d, save task status failed, error: %v", taskId, err)
+	}
+}
+
+// ubiProofSubmissions holds a channel closed once the proof of the task is submitted, by task id, so that
+// the proof handler and the queue submit a proof only once
+var ubiProofSubmissions sync.Map
+
+// submitUbiTaskProof submits the proof saved with the task, or waits for the submission already going on
+func submitUbiTaskProof(task *models.TaskEntity) error {
+	done := make(chan struct{})
+	if submitting, loaded := ubiProofSubmissions.LoadOrStore(task.Id, done); loaded {
+		<-submitting.(chan struct{})
+		submitted, err := NewTaskService().GetTaskEntity(task.Id)
+		if err != nil {
+			return err
+		}
+		if submitted.TxHash == "" {
+			return fmt.Errorf("the proof was not submitted: %s", submitted.Error)
+		}
+		return nil
+	}
+	defer func() {
+		ubiProofSubmissions.Delete(task.Id)
+		close(done)
+	}()
+	return submitUBIProof(models.UbiC2Proof{TaskId: strconv.FormatInt(task.Id, 10), Proof: task.Proof}, task)
+}
+
+// checkUbiTaskDeadline fails the tasks whose proof can no longer be submitted, e.g. after a long downtime.
+// When the chain cannot be reached the task goes on, submitUBIProof checks the deadline again.
+func checkUbiTaskDeadline(task *models.TaskEntity, getTaskInfo func(taskContract string) (ecp.ECPTaskTaskInfo, error)) error {
+	taskInfo, err := getTaskInfo(task.Contract)
+	if err != nil || taskInfo.Deadline == nil {
+		logs.GetLogger().Warnf("ubi task id: %d, get task info on chain failed, error: %v", task.Id, err)
+		return nil
+	}
+	if finallyTime := task.CreateTime + taskInfo.Deadline.Int64()*2; time.Now().Unix() > finallyTime {
+		return errUbiTaskDeadline
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package computing
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/swanchain/go-computing-provider/internal/contract/ecp"
+	"github.com/swanchain/go-computing-provider/internal/db"
+	"github.com/swanchain/go-computing-provider/internal/models"
+	batchv1 "k8s.io/api/batch/v1"
+	coreV1 "k8s.io/api/core/v1"
+)
+
+func newTestUbiTaskQueue(t *testing.T, tasks ...*models.TaskEntity) *UbiTaskQueue {
+	db.InitDb(t.TempDir())
+	for _, task := range tasks {
+		if err := NewTaskService().SaveTaskEntity(task); err != nil {
+			t.Fatal(err)
+		}
+	}
+	queue := newUbiTaskQueue(UbiRuntimeDocker)
+	queue.checkDeadline = func(*models.TaskEntity) error { return nil }
+	queue.submitProof = func(*models.TaskEntity) error { return fmt.Errorf("unexpected proof submission") }
+	return queue
+}
+
+func testTaskStatus(t *testing.T, taskId int64) *models.TaskEntity {
+	task, err := NewTaskService().GetTaskEntity(taskId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return task
+}
+
+func TestUbiTaskQueue_RunsEnqueuedTasks(t *testing.T) {
+	queue := newTestUbiTaskQueue(t,
+		&models.TaskEntity{Id: 1, Status: models.TASK_RECEIVED_STATUS},
+		&models.TaskEntity{Id: 2, Status: models.TASK_RECEIVED_STATUS})
+
+	ran := make(chan int64, 4)
+	release := make(chan struct{})
+	queue.startTask = func(task *models.TaskEntity) error {
+		ran <- task.Id
+		return nil
+	}
+	queue.followTask = func(*models.TaskEntity) error {
+		<-release
+		return nil
+	}
+	queue.start(1)
+
+	// task 1 is saved by its handler but not enqueued, e.g. its resources are being checked
+	queue.enqueue(2)
+	queue.enqueue(2)
+	if taskId := <-ran; taskId != 2 {
+		t.Fatalf("expected the enqueued task 2 to run, got %d", taskId)
+	}
+	if _, ok := queue.queued.Load(int64(2)); !ok {
+		t.Fatal("expected task 2 to be owned by the queue while it is followed")
+	}
+	select {
+	case taskId := <-ran:
+		t.Fatalf("expected only the enqueued task to run once, got %d", taskId)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	// after a restart the unfinished tasks are resumed, the only worker is free while task 2 is followed
+	queue.resume()
+	if taskId := <-ran; taskId != 1 {
+		t.Fatalf("expected task 1 to be resumed, got %d", taskId)
+	}
+	close(release)
+}
+
+func TestUbiTaskQueue_DeadlinePassed(t *testing.T) {
+	queue := newTestUbiTaskQueue(t, &models.TaskEntity{Id: 1, Status: models.TASK_RECEIVED_STATUS})
+	queue.checkDeadline = func(*models.TaskEntity) error { return errUbiTaskDeadline }
+	queue.startTask = func(*models.TaskEntity) error {
+		t.Fatal("expected a task past its deadline not to start")
+		return nil
+	}
+
+	queue.process(1)
+	if task := testTaskStatus(t, 1); task.Status != models.TASK_FAILED_STATUS || task.Error != errUbiTaskDeadline.Error() {
+		t.Fatalf("expected the task to fail on its deadline, got status %d error %q", task.Status, task.Error)
+	}
+}
+
+func TestUbiTaskQueue_FinishSubmitsReceivedProof(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		submitted bool
+		status    int
+	}{
+		{"submitted", true, models.TASK_SUCCESS_STATUS},
+		{"submission failed", false, models.TASK_FAILED_STATUS},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			queue := newTestUbiTaskQueue(t, &models.TaskEntity{Id: 1, Status: models.TASK_RUNNING_STATUS, Proof: "proof"})
+			var submissions int
+			queue.submitProof = func(task *models.TaskEntity) error {
+				submissions++
+				if tc.submitted {
+					task.TxHash = "0x01"
+					task.Status = models.TASK_SUCCESS_STATUS
+				} else {
+					task.Error = "execution reverted"
+					task.Status = models.TASK_FAILED_STATUS
+				}
+				return NewTaskService().SaveTaskEntity(task)
+			}
+
+			// the job may end with an error after it has posted its proof
+			queue.finish(1, fmt.Errorf("log stream closed"))
+			task := testTaskStatus(t, 1)
+			if submissions != 1 || task.Status != tc.status {
+				t.Fatalf("expected the proof submitted once and status %d, got %d submissions and status %d", tc.status, submissions, task.Status)
+			}
+			if !tc.submitted && task.Error != "execution reverted" {
+				t.Fatalf("expected the error of the submission, got %q", task.Error)
+			}
+		})
+	}
+}
+
+func TestUbiTaskQueue_FinishWithoutProof(t *testing.T) {
+	queue := newTestUbiTaskQueue(t, &models.TaskEntity{Id: 1, Status: models.TASK_RUNNING_STATUS})
+
+	queue.finish(1, fmt.Errorf("the container of ubi task is gone"))
+	if task := testTaskStatus(t, 1); task.Status != models.TASK_FAILED_STATUS || task.Error != "the container of ubi task is gone" {
+		t.Fatalf("expected the task to fail with the run error, got status %d error %q", task.Status, task.Error)
+	}
+}
+
+func TestUbiTaskResource(t *testing.T) {
+	if _, err := ubiTaskResource(&models.TaskEntity{}); err == nil {
+		t.Fatal("expected an error for a task saved without its resource")
+	}
+	resource, err := ubiTaskResource(&models.TaskEntity{Resource: `{"cpu":"2","memory":"4.00 GiB"}`})
+	if err != nil || resource.CPU != "2" || resource.Memory != "4.00 GiB" {
+		t.Fatalf("unexpected resource %+v, error: %v", resource, err)
+	}
+}
+
+func TestUbiTaskJob_Saved(t *testing.T) {
+	db.InitDb(t.TempDir())
+	task := &models.TaskEntity{Id: 1, Status: models.TASK_RECEIVED_STATUS}
+	job := &batchv1.Job{Spec: batchv1.JobSpec{Template: coreV1.PodTemplateSpec{Spec: coreV1.PodSpec{NodeName: "node-1"}}}}
+	if err := saveUbiTaskSpec(task, job); err != nil {
+		t.Fatal(err)
+	}
+
+	// the job runs on the node its resources were checked on, it is not placed again
+	saved, err := ubiTaskJob(testTaskStatus(t, 1))
+	if err != nil || saved.Spec.Template.Spec.NodeName != "node-1" {
+		t.Fatalf("expected the saved job on node-1, got %+v, error: %v", saved, err)
+	}
+}
+
+func TestCheckUbiTaskDeadline(t *testing.T) {
+	now := time.Now().Unix()
+	for _, tc := range []struct {
+		name       string
+		createTime int64
+		taskInfo   ecp.ECPTaskTaskInfo
+		infoErr    error
+		err        error
+	}{
+		{"within the deadline", now - 100, ecp.ECPTaskTaskInfo{Deadline: big.NewInt(60)}, nil, nil},
+		{"deadline passed", now - 200, ecp.ECPTaskTaskInfo{Deadline: big.NewInt(60)}, nil, errUbiTaskDeadline},
+		{"chain unreachable", now - 200, ecp.ECPTaskTaskInfo{}, fmt.Errorf("dial failed"), nil},
+		{"no deadline", now - 200, ecp.ECPTaskTaskInfo{}, nil, nil},
+	} {
+		task := &models.TaskEntity{Id: 1, Contract: "0x01", CreateTime: tc.createTime}
+		err := checkUbiTaskDeadline(task, func(string) (ecp.ECPTaskTaskInfo, error) {
+			return tc.taskInfo, tc.infoErr
+		})
+		if err != tc.err {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.err, err)
+		}
+	}
+}
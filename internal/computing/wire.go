@@ -21,3 +21,13 @@ func NewCpInfoService() CpInfoService {
 	wire.Build(cpInfoSet)
 	return CpInfoService{}
 }
+
+func NewHubEventService() HubEventService {
+	wire.Build(hubEventSet)
+	return HubEventService{}
+}
+
+func NewModelCacheService() ModelCacheService {
+	wire.Build(modelCacheSet)
+	return ModelCacheService{}
+}
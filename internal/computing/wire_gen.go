@@ -39,3 +39,19 @@ func NewCpInfoService() CpInfoService {
 	}
 	return cpInfoService
 }
+
+func NewHubEventService() HubEventService {
+	gormDB := db.NewDbService()
+	hubEventService := HubEventService{
+		DB: gormDB,
+	}
+	return hubEventService
+}
+
+func NewModelCacheService() ModelCacheService {
+	gormDB := db.NewDbService()
+	modelCacheService := ModelCacheService{
+		DB: gormDB,
+	}
+	return modelCacheService
+}
@@ -2,31 +2,29 @@ package account
 
 import (
 	"context"
-	"crypto/ecdsa"
 	"fmt"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/swanchain/go-computing-provider/internal/contract"
 	"github.com/swanchain/go-computing-provider/internal/models"
-	"math/big"
 	"strings"
 )
 
 type CpStub struct {
 	client          *ethclient.Client
 	account         *Account
-	privateK        string
+	signer          contract.Signer
 	publicK         string
 	ContractAddress string
 }
 
 type CpOption func(*CpStub)
 
-func WithCpPrivateKey(pk string) CpOption {
+func WithCpSigner(signer contract.Signer) CpOption {
 	return func(obj *CpStub) {
-		obj.privateK = pk
+		obj.signer = signer
 	}
 }
 
@@ -62,7 +60,7 @@ func NewAccountStub(client *ethclient.Client, options ...CpOption) (*CpStub, err
 }
 
 func (s *CpStub) ChangeMultiAddress(newMultiAddress []string) (string, error) {
-	publicAddress, err := s.privateKeyToPublicKey()
+	publicAddress, err := s.senderAddress()
 	if err != nil {
 		return "", err
 	}
@@ -72,7 +70,9 @@ func (s *CpStub) ChangeMultiAddress(newMultiAddress []string) (string, error) {
 		return "", fmt.Errorf("address: %s, cpAccount client create transaction, error: %+v", publicAddress, err)
 	}
 
-	transaction, err := s.account.ChangeMultiaddrs(txOptions, newMultiAddress)
+	transaction, err := contract.SendTx(s.client, txOptions, 0, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return s.account.ChangeMultiaddrs(opts, newMultiAddress)
+	})
 	if err != nil {
 		return "", fmt.Errorf("address: %s, cpAccount client ChangeMultiaddrs tx error: %+v", publicAddress, err)
 	}
@@ -80,7 +80,7 @@ func (s *CpStub) ChangeMultiAddress(newMultiAddress []string) (string, error) {
 }
 
 func (s *CpStub) ChangeOwnerAddress(newOwner common.Address) (string, error) {
-	publicAddress, err := s.privateKeyToPublicKey()
+	publicAddress, err := s.senderAddress()
 	if err != nil {
 		return "", err
 	}
@@ -90,7 +90,9 @@ func (s *CpStub) ChangeOwnerAddress(newOwner common.Address) (string, error) {
 		return "", fmt.Errorf("address: %s, cpAccount client create transaction, error: %+v", publicAddress, err)
 	}
 
-	transaction, err := s.account.ChangeOwnerAddress(txOptions, newOwner)
+	transaction, err := contract.SendTx(s.client, txOptions, 0, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return s.account.ChangeOwnerAddress(opts, newOwner)
+	})
 	if err != nil {
 		return "", fmt.Errorf("address: %s, cpAccount client create ChangeOwnerAddress tx error: %+v", publicAddress, err)
 	}
@@ -98,7 +100,7 @@ func (s *CpStub) ChangeOwnerAddress(newOwner common.Address) (string, error) {
 }
 
 func (s *CpStub) ChangeBeneficiary(newBeneficiary common.Address) (string, error) {
-	publicAddress, err := s.privateKeyToPublicKey()
+	publicAddress, err := s.senderAddress()
 	if err != nil {
 		return "", err
 	}
@@ -108,7 +110,9 @@ func (s *CpStub) ChangeBeneficiary(newBeneficiary common.Address) (string, error
 		return "", fmt.Errorf("address: %s, cpAccount client create transaction, error: %+v", publicAddress, err)
 	}
 
-	transaction, err := s.account.ChangeBeneficiary(txOptions, newBeneficiary)
+	transaction, err := contract.SendTx(s.client, txOptions, 0, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return s.account.ChangeBeneficiary(opts, newBeneficiary)
+	})
 	if err != nil {
 		return "", fmt.Errorf("address: %s, cpAccount client create ChangeBeneficiary tx error: %+v", publicAddress, err)
 	}
@@ -116,7 +120,7 @@ func (s *CpStub) ChangeBeneficiary(newBeneficiary common.Address) (string, error
 }
 
 func (s *CpStub) ChangeTaskTypes(newTaskTypes []uint8) (string, error) {
-	publicAddress, err := s.privateKeyToPublicKey()
+	publicAddress, err := s.senderAddress()
 	if err != nil {
 		return "", err
 	}
@@ -126,7 +130,9 @@ func (s *CpStub) ChangeTaskTypes(newTaskTypes []uint8) (string, error) {
 		return "", fmt.Errorf("address: %s, cpAccount client create transaction, error: %+v", publicAddress, err)
 	}
 
-	transaction, err := s.account.ChangeTaskTypes(txOptions, newTaskTypes)
+	transaction, err := contract.SendTx(s.client, txOptions, 0, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return s.account.ChangeTaskTypes(opts, newTaskTypes)
+	})
 	if err != nil {
 		return "", fmt.Errorf("address: %s, cpAccount client create ChangeTaskTypes tx error: %+v", publicAddress, err)
 	}
@@ -134,7 +140,7 @@ func (s *CpStub) ChangeTaskTypes(newTaskTypes []uint8) (string, error) {
 }
 
 func (s *CpStub) ChangeWorkerAddress(newWorkerAddress common.Address) (string, error) {
-	publicAddress, err := s.privateKeyToPublicKey()
+	publicAddress, err := s.senderAddress()
 	if err != nil {
 		return "", err
 	}
@@ -144,7 +150,9 @@ func (s *CpStub) ChangeWorkerAddress(newWorkerAddress common.Address) (string, e
 		return "", fmt.Errorf("address: %s, cpAccount client create transaction, error: %+v", publicAddress, err)
 	}
 
-	transaction, err := s.account.ChangeWorker(txOptions, newWorkerAddress)
+	transaction, err := contract.SendTx(s.client, txOptions, 0, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return s.account.ChangeWorker(opts, newWorkerAddress)
+	})
 	if err != nil {
 		return "", fmt.Errorf("address: %s, cpAccount client create ChangeWorkerAddress tx error: %+v", publicAddress, err)
 	}
@@ -168,58 +176,31 @@ func (s *CpStub) GetCpAccountInfo() (models.Account, error) {
 	return account, nil
 }
 
-func (s *CpStub) privateKeyToPublicKey() (common.Address, error) {
-	if len(strings.TrimSpace(s.privateK)) == 0 {
-		return common.Address{}, fmt.Errorf("wallet address private key must be not empty")
+func (s *CpStub) senderAddress() (common.Address, error) {
+	if s.signer == nil {
+		return common.Address{}, fmt.Errorf("wallet address signer must be not empty")
 	}
-
-	privateKey, err := crypto.HexToECDSA(s.privateK)
-	if err != nil {
-		return common.Address{}, fmt.Errorf("parses private key error: %+v", err)
-	}
-
-	publicKey := privateKey.Public()
-	publicKeyECDSA, ok := publicKey.(*ecdsa.PublicKey)
-	if !ok {
-		return common.Address{}, fmt.Errorf("cannot assert type: publicKey is not of type *ecdsa.PublicKey")
-	}
-	return crypto.PubkeyToAddress(*publicKeyECDSA), nil
+	return s.signer.Address(), nil
 }
 
 func (s *CpStub) createTransactOpts() (*bind.TransactOpts, error) {
-	publicAddress, err := s.privateKeyToPublicKey()
+	publicAddress, err := s.senderAddress()
 	if err != nil {
 		return nil, err
 	}
 
-	nonce, err := s.client.PendingNonceAt(context.Background(), publicAddress)
-	if err != nil {
-		return nil, fmt.Errorf("address: %s, collateral client get nonce error: %+v", publicAddress, err)
-	}
-
-	suggestGasPrice, err := s.client.SuggestGasPrice(context.Background())
-	if err != nil {
-		return nil, fmt.Errorf("address: %s, collateral client retrieves the currently suggested gas price, error: %+v", publicAddress, err)
-	}
-
 	chainId, err := s.client.ChainID(context.Background())
 	if err != nil {
 		return nil, fmt.Errorf("address: %s, collateral client get networkId, error: %+v", publicAddress, err)
 	}
 
-	privateKey, err := crypto.HexToECDSA(s.privateK)
-	if err != nil {
-		return nil, fmt.Errorf("parses private key error: %+v", err)
-	}
-
-	txOptions, err := bind.NewKeyedTransactorWithChainID(privateKey, chainId)
+	txOptions, err := contract.NewTransactOpts(s.signer, chainId)
 	if err != nil {
 		return nil, fmt.Errorf("address: %s, collateral client create transaction, error: %+v", publicAddress, err)
 	}
-	txOptions.Nonce = big.NewInt(int64(nonce))
-	suggestGasPrice = suggestGasPrice.Mul(suggestGasPrice, big.NewInt(3))
-	suggestGasPrice = suggestGasPrice.Div(suggestGasPrice, big.NewInt(2))
-	txOptions.GasFeeCap = suggestGasPrice
+	if err = contract.SetGasFees(s.client, txOptions); err != nil {
+		return nil, err
+	}
 	txOptions.Context = context.Background()
 	return txOptions, nil
 }
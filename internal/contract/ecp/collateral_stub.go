@@ -2,11 +2,10 @@ package ecp
 
 import (
 	"context"
-	"crypto/ecdsa"
 	"fmt"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/swanchain/go-computing-provider/conf"
 	"github.com/swanchain/go-computing-provider/internal/contract"
@@ -18,16 +17,16 @@ import (
 type Stub struct {
 	client           *ethclient.Client
 	collateral       *Collaternal
-	privateK         string
+	signer           contract.Signer
 	publicK          string
 	cpAccountAddress string
 }
 
 type Option func(*Stub)
 
-func WithPrivateKey(pk string) Option {
+func WithSigner(signer contract.Signer) Option {
 	return func(obj *Stub) {
-		obj.privateK = pk
+		obj.signer = signer
 	}
 }
 
@@ -61,7 +60,7 @@ func NewCollateralStub(client *ethclient.Client, options ...Option) (*Stub, erro
 }
 
 func (s *Stub) Deposit(cpAccountAddress string, amount *big.Int) (string, error) {
-	publicAddress, err := s.privateKeyToPublicKey()
+	publicAddress, err := s.senderAddress()
 	if err != nil {
 		return "", err
 	}
@@ -78,7 +77,9 @@ func (s *Stub) Deposit(cpAccountAddress string, amount *big.Int) (string, error)
 		}
 	}
 
-	transaction, err := s.collateral.Deposit(txOptions, common.HexToAddress(cpAccountAddress))
+	transaction, err := contract.SendTx(s.client, txOptions, 0, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return s.collateral.Deposit(opts, common.HexToAddress(cpAccountAddress))
+	})
 	if err != nil {
 		return "", fmt.Errorf("address: %s, ECP collateral client create deposit tx error: %+v", publicAddress, err)
 	}
@@ -86,7 +87,7 @@ func (s *Stub) Deposit(cpAccountAddress string, amount *big.Int) (string, error)
 }
 
 func (s *Stub) Withdraw(cpAccountAddress string, amount *big.Int) (string, error) {
-	publicAddress, err := s.privateKeyToPublicKey()
+	publicAddress, err := s.senderAddress()
 	if err != nil {
 		return "", err
 	}
@@ -103,7 +104,9 @@ func (s *Stub) Withdraw(cpAccountAddress string, amount *big.Int) (string, error
 		}
 	}
 
-	transaction, err := s.collateral.Withdraw(txOptions, common.HexToAddress(cpAccountAddress), amount)
+	transaction, err := contract.SendTx(s.client, txOptions, 0, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return s.collateral.Withdraw(opts, common.HexToAddress(cpAccountAddress), amount)
+	})
 	if err != nil {
 		return "", fmt.Errorf("address: %s, ECP collateral client create withdraw tx error: %+v", publicAddress, err)
 	}
@@ -133,51 +136,25 @@ func (s *Stub) CpInfo() (models.EcpCollateralInfo, error) {
 	return cpInfo, nil
 }
 
-func (s *Stub) privateKeyToPublicKey() (common.Address, error) {
-	if len(strings.TrimSpace(s.privateK)) == 0 {
-		return common.Address{}, fmt.Errorf("wallet address private key must be not empty")
+func (s *Stub) senderAddress() (common.Address, error) {
+	if s.signer == nil {
+		return common.Address{}, fmt.Errorf("wallet address signer must be not empty")
 	}
-
-	privateKey, err := crypto.HexToECDSA(s.privateK)
-	if err != nil {
-		return common.Address{}, fmt.Errorf("parses private key error: %+v", err)
-	}
-
-	publicKey := privateKey.Public()
-	publicKeyECDSA, ok := publicKey.(*ecdsa.PublicKey)
-	if !ok {
-		return common.Address{}, fmt.Errorf("cannot assert type: publicKey is not of type *ecdsa.PublicKey")
-	}
-	return crypto.PubkeyToAddress(*publicKeyECDSA), nil
+	return s.signer.Address(), nil
 }
 
 func (s *Stub) createTransactOpts(amount *big.Int, isDeposit bool) (*bind.TransactOpts, error) {
-	publicAddress, err := s.privateKeyToPublicKey()
+	publicAddress, err := s.senderAddress()
 	if err != nil {
 		return nil, err
 	}
 
-	nonce, err := s.client.PendingNonceAt(context.Background(), publicAddress)
-	if err != nil {
-		return nil, fmt.Errorf("address: %s, collateral client get nonce error: %+v", publicAddress, err)
-	}
-
-	suggestGasPrice, err := s.client.SuggestGasPrice(context.Background())
-	if err != nil {
-		return nil, fmt.Errorf("address: %s, collateral client retrieves the currently suggested gas price, error: %+v", publicAddress, err)
-	}
-
 	chainId, err := s.client.ChainID(context.Background())
 	if err != nil {
 		return nil, fmt.Errorf("address: %s, collateral client get networkId, error: %+v", publicAddress, err)
 	}
 
-	privateKey, err := crypto.HexToECDSA(s.privateK)
-	if err != nil {
-		return nil, fmt.Errorf("parses private key error: %+v", err)
-	}
-
-	txOptions, err := bind.NewKeyedTransactorWithChainID(privateKey, chainId)
+	txOptions, err := contract.NewTransactOpts(s.signer, chainId)
 	if isDeposit {
 		txOptions.Value = amount
 	}
@@ -185,10 +162,9 @@ func (s *Stub) createTransactOpts(amount *big.Int, isDeposit bool) (*bind.Transa
 	if err != nil {
 		return nil, fmt.Errorf("address: %s, collateral client create transaction, error: %+v", publicAddress, err)
 	}
-	txOptions.Nonce = big.NewInt(int64(nonce))
-	suggestGasPrice = suggestGasPrice.Mul(suggestGasPrice, big.NewInt(3))
-	suggestGasPrice = suggestGasPrice.Div(suggestGasPrice, big.NewInt(2))
-	txOptions.GasFeeCap = suggestGasPrice
+	if err = contract.SetGasFees(s.client, txOptions); err != nil {
+		return nil, err
+	}
 	txOptions.Context = context.Background()
 	return txOptions, nil
 }
@@ -2,38 +2,35 @@ package ecp
 
 import (
 	"context"
-	"crypto/ecdsa"
 	"errors"
 	"fmt"
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/filswan/go-swan-lib/logs"
 	"github.com/swanchain/go-computing-provider/internal/contract"
 	"github.com/swanchain/go-computing-provider/internal/contract/token"
 	"math/big"
+	"strconv"
 	"strings"
-	"sync"
 	"time"
 )
 
 type TaskStub struct {
 	client          *ethclient.Client
 	task            *Task
-	privateK        string
+	signer          contract.Signer
 	publicK         string
 	ContractAddress string
-	nonceX          uint64
-	taskL           sync.Mutex
 }
 
 type TaskOption func(*TaskStub)
 
-func WithTaskPrivateKey(pk string) TaskOption {
+func WithTaskSigner(signer contract.Signer) TaskOption {
 	return func(obj *TaskStub) {
-		obj.privateK = pk
+		obj.signer = signer
 	}
 }
 
@@ -67,7 +64,7 @@ func NewTaskStub(client *ethclient.Client, options ...TaskOption) (*TaskStub, er
 func (s *TaskStub) SubmitUBIProof(taskId, proof string, timeOut int64) (string, error) {
 	var err error
 	var submitProofTxHash string
-	var flag bool
+	ubiTaskId, _ := strconv.ParseInt(taskId, 10, 64)
 
 	timeOutCh := time.After(time.Second * time.Duration(timeOut))
 outerLoop:
@@ -78,35 +75,18 @@ outerLoop:
 			break outerLoop
 		default:
 			time.Sleep(3 * time.Second)
-			if !flag {
-				err = s.getNonce()
-				if err != nil {
-					logs.GetLogger().Warnf("taskId: %s, get nonce: %s, retrying", taskId, ParseError(err))
-					continue
-				}
-			}
 
-			txOptions, err := s.createTransactOpts(int64(s.nonceX))
+			txOptions, err := s.createTransactOpts()
 			if err != nil {
 				logs.GetLogger().Warnf("taskId: %s, create transaction opts failed, error: %s", taskId, ParseError(err))
 				continue
 			}
-			transaction, err := s.task.SubmitProof(txOptions, proof)
+			transaction, err := contract.SendTx(s.client, txOptions, ubiTaskId, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+				return s.task.SubmitProof(opts, proof)
+			})
 			if err != nil {
-				if err.Error() == "replacement transaction underpriced" {
-					s.IncrementNonce()
-					flag = true
-				} else if strings.Contains(err.Error(), "next nonce") {
-					err = s.getNonce()
-					if err != nil {
-						logs.GetLogger().Warnf("taskId: %s, get nonce: %s, retrying", taskId, ParseError(err))
-						flag = false
-						continue
-					}
-				} else {
-					logs.GetLogger().Warnf("taskId: %s SubmitUBIProof failed, error: %s", taskId, ParseError(err))
-					continue
-				}
+				logs.GetLogger().Warnf("taskId: %s SubmitUBIProof failed, error: %s", taskId, ParseError(err))
+				continue
 			}
 			if transaction != nil {
 				submitProofTxHash = transaction.Hash().String()
@@ -123,74 +103,30 @@ func (s *TaskStub) GetTaskInfo() (ECPTaskTaskInfo, error) {
 	return s.task.GetTaskInfo(&bind.CallOpts{})
 }
 
-func (s *TaskStub) privateKeyToPublicKey() (common.Address, error) {
-	if len(strings.TrimSpace(s.privateK)) == 0 {
-		return common.Address{}, fmt.Errorf("wallet address private key must be not empty")
-	}
-
-	privateKey, err := crypto.HexToECDSA(s.privateK)
-	if err != nil {
-		return common.Address{}, fmt.Errorf("parses private key error: %+v", err)
-	}
-
-	publicKey := privateKey.Public()
-	publicKeyECDSA, ok := publicKey.(*ecdsa.PublicKey)
-	if !ok {
-		return common.Address{}, fmt.Errorf("cannot assert type: publicKey is not of type *ecdsa.PublicKey")
+func (s *TaskStub) senderAddress() (common.Address, error) {
+	if s.signer == nil {
+		return common.Address{}, fmt.Errorf("wallet address signer must be not empty")
 	}
-
-	return crypto.PubkeyToAddress(*publicKeyECDSA), nil
+	return s.signer.Address(), nil
 }
 
-func (s *TaskStub) createTransactOpts(nonce int64) (*bind.TransactOpts, error) {
-	suggestGasPrice, err := s.client.SuggestGasPrice(context.Background())
-	if err != nil {
-		return nil, fmt.Errorf("task client retrieves the currently suggested gas price, error: %+v", err)
-	}
-
+func (s *TaskStub) createTransactOpts() (*bind.TransactOpts, error) {
 	chainId, err := s.client.ChainID(context.Background())
 	if err != nil {
 		return nil, fmt.Errorf("task client get networkId, error: %+v", err)
 	}
 
-	privateKey, err := crypto.HexToECDSA(s.privateK)
-	if err != nil {
-		return nil, fmt.Errorf("parses private key error: %+v", err)
-	}
-
-	txOptions, err := bind.NewKeyedTransactorWithChainID(privateKey, chainId)
+	txOptions, err := contract.NewTransactOpts(s.signer, chainId)
 	if err != nil {
 		return nil, fmt.Errorf("collateral client create transaction, error: %+v", err)
 	}
-	txOptions.Nonce = big.NewInt(nonce)
-	suggestGasPrice = suggestGasPrice.Mul(suggestGasPrice, big.NewInt(3))
-	suggestGasPrice = suggestGasPrice.Div(suggestGasPrice, big.NewInt(2))
-	txOptions.GasFeeCap = suggestGasPrice
+	if err = contract.SetGasFees(s.client, txOptions); err != nil {
+		return nil, err
+	}
 	txOptions.Context = context.Background()
 	return txOptions, nil
 }
 
-func (s *TaskStub) getNonce() error {
-	publicAddress, err := s.privateKeyToPublicKey()
-	if err != nil {
-		return err
-	}
-	nonce, err := s.client.NonceAt(context.Background(), publicAddress, nil)
-	if err != nil {
-		return fmt.Errorf("address: %s, collateral client get nonce error: %s", publicAddress, ParseError(err))
-	}
-	s.taskL.Lock()
-	defer s.taskL.Unlock()
-	s.nonceX = nonce
-	return nil
-}
-
-func (s *TaskStub) IncrementNonce() {
-	s.taskL.Lock()
-	defer s.taskL.Unlock()
-	s.nonceX++
-}
-
 // GetReward  status: 1: Challenged  2: Slashed  3: rewarded
 func (s *TaskStub) GetReward() (status int, rewardTx string, challengeTx string, slashTx string, reward string, err error) {
 	reward = "0.0"
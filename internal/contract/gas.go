@@ -0,0 +1,129 @@
+package contract
+
+import (
+	"context"
+	"fmt"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/swanchain/go-computing-provider/conf"
+	"math/big"
+)
+
+// FeeCaps are the highest fees the computing provider pays per gas, nil means no cap
+type FeeCaps struct {
+	MaxFeePerGas         *big.Int
+	MaxPriorityFeePerGas *big.Int
+}
+
+// GetFeeCaps reads the caps configured in the [GAS] section, in gwei
+func GetFeeCaps() FeeCaps {
+	var caps FeeCaps
+	if conf.GetConfig() == nil {
+		return caps
+	}
+	gas := conf.GetConfig().GAS
+	if gas.MaxFeePerGas > 0 {
+		caps.MaxFeePerGas = gweiToWei(gas.MaxFeePerGas)
+	}
+	if gas.MaxPriorityFeePerGas > 0 {
+		caps.MaxPriorityFeePerGas = gweiToWei(gas.MaxPriorityFeePerGas)
+	}
+	return caps
+}
+
+func gweiToWei(gwei float64) *big.Int {
+	wei, _ := new(big.Float).Mul(big.NewFloat(gwei), big.NewFloat(params.GWei)).Int(nil)
+	return wei
+}
+
+// SetGasFees prices the transaction of opts. On London chains it becomes a dynamic fee transaction
+// paying the suggested tip on top of the base fee, elsewhere a legacy one at the suggested gas price.
+// The transaction is refused when the current fees are above the configured caps.
+func SetGasFees(client *ethclient.Client, opts *bind.TransactOpts) error {
+	head, err := client.HeaderByNumber(context.Background(), nil)
+	if err != nil {
+		return fmt.Errorf("get the latest block header failed, error: %v", err)
+	}
+
+	gasPrice, gasTipCap, gasFeeCap, err := suggestGasFees(client, head.BaseFee, GetFeeCaps())
+	if err != nil {
+		return err
+	}
+	if head.BaseFee == nil {
+		opts.GasPrice = gasPrice
+	} else {
+		opts.GasTipCap = gasTipCap
+		opts.GasFeeCap = gasFeeCap
+	}
+	return nil
+}
+
+// suggestGasFees returns the gas price for a legacy transaction when baseFee is nil, otherwise the
+// tip and fee caps for a dynamic fee transaction, all within the configured caps
+func suggestGasFees(client *ethclient.Client, baseFee *big.Int, caps FeeCaps) (gasPrice, gasTipCap, gasFeeCap *big.Int, err error) {
+	if baseFee == nil {
+		gasPrice, err = client.SuggestGasPrice(context.Background())
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("retrieves the currently suggested gas price, error: %v", err)
+		}
+		gasPrice = gasPrice.Mul(gasPrice, big.NewInt(3))
+		gasPrice = gasPrice.Div(gasPrice, big.NewInt(2))
+		if caps.MaxFeePerGas != nil && gasPrice.Cmp(caps.MaxFeePerGas) > 0 {
+			return nil, nil, nil, fmt.Errorf("the suggested gas price %s is above the cap of %s", gweiStr(gasPrice), gweiStr(caps.MaxFeePerGas))
+		}
+		return gasPrice, nil, nil, nil
+	}
+
+	gasTipCap, err = client.SuggestGasTipCap(context.Background())
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("retrieves the currently suggested gas tip cap, error: %v", err)
+	}
+	// a lower tip only makes the transaction slower, so it is capped rather than refused
+	if caps.MaxPriorityFeePerGas != nil && gasTipCap.Cmp(caps.MaxPriorityFeePerGas) > 0 {
+		gasTipCap = new(big.Int).Set(caps.MaxPriorityFeePerGas)
+	}
+
+	minFee := new(big.Int).Add(baseFee, gasTipCap)
+	if caps.MaxFeePerGas != nil && minFee.Cmp(caps.MaxFeePerGas) > 0 {
+		return nil, nil, nil, fmt.Errorf("the base fee %s plus the tip %s is above the cap of %s", gweiStr(baseFee), gweiStr(gasTipCap), gweiStr(caps.MaxFeePerGas))
+	}
+
+	// leaves room for the base fee to double before the transaction is mined
+	gasFeeCap = new(big.Int).Add(gasTipCap, new(big.Int).Mul(baseFee, big.NewInt(2)))
+	if caps.MaxFeePerGas != nil && gasFeeCap.Cmp(caps.MaxFeePerGas) > 0 {
+		gasFeeCap = new(big.Int).Set(caps.MaxFeePerGas)
+	}
+	return nil, gasTipCap, gasFeeCap, nil
+}
+
+// NewTx builds a plain transaction priced by SetGasFees, for the transfers that do not go through the contract bindings
+func NewTx(opts *bind.TransactOpts, chainID *big.Int, to common.Address, value *big.Int, gas uint64, data []byte) *types.Transaction {
+	if opts.GasFeeCap != nil {
+		return types.NewTx(&types.DynamicFeeTx{
+			ChainID:   chainID,
+			Nonce:     opts.Nonce.Uint64(),
+			GasTipCap: opts.GasTipCap,
+			GasFeeCap: opts.GasFeeCap,
+			Gas:       gas,
+			To:        &to,
+			Value:     value,
+			Data:      data,
+		})
+	}
+	return types.NewTx(&types.LegacyTx{
+		Nonce:    opts.Nonce.Uint64(),
+		GasPrice: opts.GasPrice,
+		Gas:      gas,
+		To:       &to,
+		Value:    value,
+		Data:     data,
+	})
+}
+
+func gweiStr(wei *big.Int) string {
+	gwei, _ := new(big.Float).Quo(new(big.Float).SetInt(wei), big.NewFloat(params.GWei)).Float64()
+	return fmt.Sprintf("%.4f gwei", gwei)
+}
@@ -0,0 +1,54 @@
+package contract
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func gwei(n int64) *big.Int {
+	return new(big.Int).Mul(big.NewInt(n), big.NewInt(params.GWei))
+}
+
+func TestSuggestGasFees_DynamicFee(t *testing.T) {
+	client := newFakeEthClient(t, &fakeEth{tip: gwei(2).Int64()})
+
+	_, tip, feeCap, err := suggestGasFees(client, gwei(10), FeeCaps{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tip.Cmp(gwei(2)) != 0 || feeCap.Cmp(gwei(22)) != 0 {
+		t.Fatalf("unexpected tip %s and fee cap %s", tip, feeCap)
+	}
+
+	// the tip is lowered to its cap and the fee cap is clamped
+	_, tip, feeCap, err = suggestGasFees(client, gwei(10), FeeCaps{MaxFeePerGas: gwei(15), MaxPriorityFeePerGas: gwei(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tip.Cmp(gwei(1)) != 0 || feeCap.Cmp(gwei(15)) != 0 {
+		t.Fatalf("unexpected capped tip %s and fee cap %s", tip, feeCap)
+	}
+
+	// refused when the base fee alone is above the cap
+	if _, _, _, err = suggestGasFees(client, gwei(20), FeeCaps{MaxFeePerGas: gwei(15)}); err == nil {
+		t.Fatal("expected the transaction to be refused above the fee cap")
+	}
+}
+
+func TestSuggestGasFees_Legacy(t *testing.T) {
+	client := newFakeEthClient(t, &fakeEth{gasPrice: gwei(10).Int64()})
+
+	gasPrice, _, _, err := suggestGasFees(client, nil, FeeCaps{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gasPrice.Cmp(gwei(15)) != 0 {
+		t.Fatalf("unexpected gas price %s", gasPrice)
+	}
+
+	if _, _, _, err = suggestGasFees(client, nil, FeeCaps{MaxFeePerGas: gwei(12)}); err == nil {
+		t.Fatal("expected the transaction to be refused above the gas price cap")
+	}
+}
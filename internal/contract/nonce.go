@@ -0,0 +1,141 @@
+package contract
+
+import (
+	"context"
+	"fmt"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"math/big"
+	"strings"
+	"sync"
+)
+
+// SendFunc sends a transaction built with opts, usually a method of the contract bindings
+type SendFunc func(opts *bind.TransactOpts) (*types.Transaction, error)
+
+// NonceManager hands out the nonces of the sending addresses. The transactions of one address are sent
+// one at a time, so concurrent senders such as several ubi proofs submitted at once never share a nonce.
+type NonceManager struct {
+	lk      sync.Mutex
+	senders map[common.Address]*sync.Mutex
+	nonces  map[common.Address]uint64
+	signers map[common.Address]bind.SignerFn
+	// registered holds the signers known from the start, for the addresses that have not sent since
+	registered map[common.Address]Signer
+}
+
+var nonceManager = NewNonceManager()
+
+func NewNonceManager() *NonceManager {
+	return &NonceManager{
+		senders:    make(map[common.Address]*sync.Mutex),
+		nonces:     make(map[common.Address]uint64),
+		signers:    make(map[common.Address]bind.SignerFn),
+		registered: make(map[common.Address]Signer),
+	}
+}
+
+// SendTx sends a transaction from opts.From with the next nonce of the address and tracks it until it is mined.
+// taskId is the ubi task whose proof the transaction submits, its TxHash follows the transaction, 0 means none.
+func SendTx(client *ethclient.Client, opts *bind.TransactOpts, taskId int64, send SendFunc) (*types.Transaction, error) {
+	return nonceManager.Send(client, opts, taskId, send)
+}
+
+func (m *NonceManager) Send(client *ethclient.Client, opts *bind.TransactOpts, taskId int64, send SendFunc) (*types.Transaction, error) {
+	sender := m.sender(opts.From)
+	sender.Lock()
+	defer sender.Unlock()
+
+	nonce, err := m.next(client, opts.From)
+	if err != nil {
+		return nil, fmt.Errorf("address: %s, get nonce error: %v", opts.From, err)
+	}
+	opts.Nonce = new(big.Int).SetUint64(nonce)
+
+	tx, err := send(opts)
+	if err != nil {
+		m.onError(opts.From, nonce, err)
+		return nil, err
+	}
+
+	m.lk.Lock()
+	m.nonces[opts.From] = nonce + 1
+	if opts.Signer != nil {
+		m.signers[opts.From] = opts.Signer
+	}
+	m.lk.Unlock()
+
+	trackTx(opts.From, tx, taskId)
+	return tx, nil
+}
+
+func (m *NonceManager) sender(address common.Address) *sync.Mutex {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+	sender, ok := m.senders[address]
+	if !ok {
+		sender = new(sync.Mutex)
+		m.senders[address] = sender
+	}
+	return sender
+}
+
+// next returns the highest of the pending nonce of the node, the nonce after the last transaction sent
+// by this process and the nonce after the transactions still tracked in the db
+func (m *NonceManager) next(client *ethclient.Client, address common.Address) (uint64, error) {
+	nonce, err := client.PendingNonceAt(context.Background(), address)
+	if err != nil {
+		return 0, err
+	}
+
+	m.lk.Lock()
+	if cached, ok := m.nonces[address]; ok && cached > nonce {
+		nonce = cached
+	}
+	m.lk.Unlock()
+
+	if tracked, ok := nextTrackedNonce(address); ok && tracked > nonce {
+		nonce = tracked
+	}
+	return nonce, nil
+}
+
+func (m *NonceManager) onError(address common.Address, nonce uint64, err error) {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+	msg := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(msg, "replacement transaction underpriced") || strings.Contains(msg, "already known"):
+		// the nonce is taken by a transaction this process does not know about
+		m.nonces[address] = nonce + 1
+	case strings.Contains(msg, "nonce too low") || strings.Contains(msg, "next nonce"):
+		delete(m.nonces, address)
+	}
+}
+
+func (m *NonceManager) register(signer Signer) {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+	m.registered[signer.Address()] = signer
+}
+
+// signer returns the signer of the last transaction sent by the address, or the registered one signing for chainID
+func (m *NonceManager) signer(address common.Address, chainID *big.Int) bind.SignerFn {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+	if signerFn, ok := m.signers[address]; ok {
+		return signerFn
+	}
+	signer, ok := m.registered[address]
+	if !ok {
+		return nil
+	}
+	return func(from common.Address, tx *types.Transaction) (*types.Transaction, error) {
+		if from != address {
+			return nil, bind.ErrNotAuthorized
+		}
+		return signer.SignTx(context.Background(), tx, chainID)
+	}
+}
@@ -0,0 +1,132 @@
+package contract
+
+import (
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// fakeEth answers the eth methods used to price and number the transactions with fixed values
+type fakeEth struct {
+	pending  uint64
+	gasPrice int64
+	tip      int64
+}
+
+func (f *fakeEth) GetTransactionCount(address common.Address, block string) hexutil.Uint64 {
+	return hexutil.Uint64(f.pending)
+}
+
+func (f *fakeEth) GasPrice() *hexutil.Big {
+	return (*hexutil.Big)(big.NewInt(f.gasPrice))
+}
+
+func (f *fakeEth) MaxPriorityFeePerGas() *hexutil.Big {
+	return (*hexutil.Big)(big.NewInt(f.tip))
+}
+
+func newFakeEthClient(t *testing.T, eth *fakeEth) *ethclient.Client {
+	server := rpc.NewServer()
+	if err := server.RegisterName("eth", eth); err != nil {
+		t.Fatal(err)
+	}
+	client := ethclient.NewClient(rpc.DialInProc(server))
+	t.Cleanup(func() {
+		client.Close()
+		server.Stop()
+	})
+	return client
+}
+
+func TestNonceManager_ConcurrentSenders(t *testing.T) {
+	client := newFakeEthClient(t, &fakeEth{pending: 5})
+	manager := NewNonceManager()
+	from := common.HexToAddress("0x7791f48931DB81668854921fA70bFf0eB85B8211")
+
+	var lk sync.Mutex
+	used := make(map[uint64]bool)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := manager.Send(client, &bind.TransactOpts{From: from}, 0, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+				lk.Lock()
+				defer lk.Unlock()
+				if used[opts.Nonce.Uint64()] {
+					t.Errorf("nonce %d handed out twice", opts.Nonce.Uint64())
+				}
+				used[opts.Nonce.Uint64()] = true
+				return types.NewTransaction(opts.Nonce.Uint64(), from, big.NewInt(0), 21000, big.NewInt(1), nil), nil
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for nonce := uint64(5); nonce < 15; nonce++ {
+		if !used[nonce] {
+			t.Fatalf("nonce %d was skipped", nonce)
+		}
+	}
+}
+
+func TestNonceManager_ResetOnNonceTooLow(t *testing.T) {
+	client := newFakeEthClient(t, &fakeEth{pending: 3})
+	manager := NewNonceManager()
+	from := common.HexToAddress("0x7791f48931DB81668854921fA70bFf0eB85B8211")
+	manager.nonces[from] = 9
+
+	_, err := manager.Send(client, &bind.TransactOpts{From: from}, 0, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return nil, errors.New("nonce too low")
+	})
+	if err == nil {
+		t.Fatal("expected the send error")
+	}
+
+	nonce, err := manager.next(client, from)
+	if err != nil || nonce != 3 {
+		t.Fatalf("expected the pending nonce of the node after a reset, got %d, error: %v", nonce, err)
+	}
+}
+
+func TestNonceManager_RegisteredSigner(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := &KeySigner{privateKey: key, address: crypto.PubkeyToAddress(key.PublicKey)}
+	manager := NewNonceManager()
+	chainID := big.NewInt(2024)
+	if manager.signer(signer.address, chainID) != nil {
+		t.Fatal("expected no signer before the registration")
+	}
+
+	manager.register(signer)
+	signerFn := manager.signer(signer.address, chainID)
+	if signerFn == nil {
+		t.Fatal("expected the registered signer")
+	}
+	tx := types.NewTransaction(1, signer.address, big.NewInt(0), 21000, big.NewInt(1), nil)
+	signedTx, err := signerFn(signer.address, tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signedTx.ChainId().Cmp(chainID) != 0 {
+		t.Fatalf("expected the transaction signed for chain %s, got %s", chainID, signedTx.ChainId())
+	}
+	if _, err = signerFn(common.HexToAddress("0x7791f48931DB81668854921fA70bFf0eB85B8211"), tx); err == nil {
+		t.Fatal("expected the signer to refuse another address")
+	}
+}
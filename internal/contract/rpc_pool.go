@@ -0,0 +1,309 @@
+package contract
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/filswan/go-swan-lib/logs"
+	"github.com/swanchain/go-computing-provider/conf"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	rpcHealthCheckInterval = 30 * time.Second
+	rpcHealthCheckTimeout  = 5 * time.Second
+	// rpcMaxBlockLag is how many blocks an endpoint may be behind the highest one and still be used
+	rpcMaxBlockLag = 3
+)
+
+// RpcEndpoint is the last known state of one chain RPC endpoint
+type RpcEndpoint struct {
+	Url       string
+	Height    uint64
+	Latency   time.Duration
+	Healthy   bool
+	Active    bool
+	Error     string
+	CheckTime time.Time
+}
+
+// RpcPool spreads the calls of one shared client over the configured chain RPC endpoints. The calls go to the
+// active endpoint and move on to the next one when it fails, and a health check picks the fastest endpoint
+// that is in sync with the chain as the active one.
+type RpcPool struct {
+	lk        sync.RWMutex
+	endpoints []*RpcEndpoint
+	urls      []*url.URL
+	active    int
+
+	transport http.RoundTripper
+	client    *ethclient.Client
+	checkers  []*ethclient.Client
+	stop      chan struct{}
+}
+
+var (
+	rpcPoolLk sync.Mutex
+	rpcPool   *RpcPool
+)
+
+func init() {
+	conf.OnReload(resetRpcPool)
+}
+
+// resetRpcPool drops the pool when SWAN_CHAIN_RPC changed, the next call builds one on the new endpoints.
+// The clients taken from the old pool keep working on the old endpoints.
+func resetRpcPool(old, cfg *conf.ComputeNode) {
+	if old != nil && slices.Equal(old.RPC.SwanChainRpc, cfg.RPC.SwanChainRpc) {
+		return
+	}
+	rpcPoolLk.Lock()
+	defer rpcPoolLk.Unlock()
+	if rpcPool != nil {
+		close(rpcPool.stop)
+		rpcPool = nil
+	}
+}
+
+// GetEthClient returns the client shared by all the chain calls, built on the SWAN_CHAIN_RPC endpoints
+func GetEthClient() (*ethclient.Client, error) {
+	pool, err := GetRpcPool()
+	if err != nil {
+		return nil, err
+	}
+	return pool.Client(), nil
+}
+
+// GetRpcPool returns the pool of the SWAN_CHAIN_RPC endpoints, its health check starts with the first call
+func GetRpcPool() (*RpcPool, error) {
+	rpcPoolLk.Lock()
+	defer rpcPoolLk.Unlock()
+	if rpcPool != nil {
+		return rpcPool, nil
+	}
+
+	rpcList, err := conf.GetRpcList()
+	if err != nil {
+		return nil, err
+	}
+	pool, err := NewRpcPool(rpcList)
+	if err != nil {
+		return nil, err
+	}
+	pool.CheckHealth()
+	go pool.run()
+	rpcPool = pool
+	return rpcPool, nil
+}
+
+// NewRpcPool creates a pool over the http(s) endpoints in rpcList, the first one is active until checked
+func NewRpcPool(rpcList []string) (*RpcPool, error) {
+	if len(rpcList) == 0 {
+		return nil, fmt.Errorf("no rpc endpoint configured")
+	}
+
+	pool := &RpcPool{
+		transport: http.DefaultTransport,
+		stop:      make(chan struct{}),
+	}
+	for _, rawUrl := range rpcList {
+		u, err := url.Parse(rawUrl)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rpc url: %s, error: %v", rawUrl, err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return nil, fmt.Errorf("invalid rpc url: %s, only http and https endpoints are supported", rawUrl)
+		}
+		pool.urls = append(pool.urls, u)
+		pool.endpoints = append(pool.endpoints, &RpcEndpoint{Url: rawUrl, Healthy: true})
+
+		checker, err := rpc.DialOptions(context.Background(), rawUrl, rpc.WithHTTPClient(&http.Client{Timeout: rpcHealthCheckTimeout}))
+		if err != nil {
+			return nil, fmt.Errorf("dial rpc: %s failed, error: %v", rawUrl, err)
+		}
+		pool.checkers = append(pool.checkers, ethclient.NewClient(checker))
+	}
+
+	client, err := rpc.DialOptions(context.Background(), rpcList[0], rpc.WithHTTPClient(&http.Client{Transport: pool}))
+	if err != nil {
+		return nil, fmt.Errorf("dial rpc: %s failed, error: %v", rpcList[0], err)
+	}
+	pool.client = ethclient.NewClient(client)
+	return pool, nil
+}
+
+// Client returns the client whose calls fail over between the endpoints of the pool
+func (p *RpcPool) Client() *ethclient.Client {
+	return p.client
+}
+
+// Active returns the url of the endpoint the calls go to
+func (p *RpcPool) Active() string {
+	p.lk.RLock()
+	defer p.lk.RUnlock()
+	return p.endpoints[p.active].Url
+}
+
+// Status returns a copy of the endpoints in the configured order
+func (p *RpcPool) Status() []RpcEndpoint {
+	p.lk.RLock()
+	defer p.lk.RUnlock()
+	var status []RpcEndpoint
+	for i, endpoint := range p.endpoints {
+		e := *endpoint
+		e.Active = i == p.active
+		status = append(status, e)
+	}
+	return status
+}
+
+func (p *RpcPool) run() {
+	ticker := time.NewTicker(rpcHealthCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			p.CheckHealth()
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// CheckHealth reads the block height of every endpoint and makes the fastest one that is at most
+// rpcMaxBlockLag blocks behind the highest the active endpoint
+func (p *RpcPool) CheckHealth() {
+	results := make([]RpcEndpoint, len(p.checkers))
+	var wg sync.WaitGroup
+	for i, checker := range p.checkers {
+		wg.Add(1)
+		go func(i int, checker *ethclient.Client) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), rpcHealthCheckTimeout)
+			defer cancel()
+
+			start := time.Now()
+			height, err := checker.BlockNumber(ctx)
+			results[i] = RpcEndpoint{Height: height, Latency: time.Since(start), Healthy: err == nil, CheckTime: time.Now()}
+			if err != nil {
+				results[i].Error = err.Error()
+			}
+		}(i, checker)
+	}
+	wg.Wait()
+
+	var maxHeight uint64
+	for _, result := range results {
+		if result.Healthy && result.Height > maxHeight {
+			maxHeight = result.Height
+		}
+	}
+
+	p.lk.Lock()
+	defer p.lk.Unlock()
+	best := -1
+	for i, result := range results {
+		endpoint := p.endpoints[i]
+		endpoint.Height, endpoint.Latency, endpoint.CheckTime = result.Height, result.Latency, result.CheckTime
+		endpoint.Healthy, endpoint.Error = result.Healthy, result.Error
+		if endpoint.Healthy && endpoint.Height+rpcMaxBlockLag < maxHeight {
+			endpoint.Healthy = false
+			endpoint.Error = fmt.Sprintf("block height %d is behind %d", endpoint.Height, maxHeight)
+		}
+		if endpoint.Healthy && (best < 0 || endpoint.Latency < p.endpoints[best].Latency) {
+			best = i
+		}
+	}
+	if best >= 0 && best != p.active {
+		logs.GetLogger().Infof("switch rpc endpoint from %s to %s, height: %d, latency: %s",
+			p.endpoints[p.active].Url, p.endpoints[best].Url, p.endpoints[best].Height, p.endpoints[best].Latency)
+		p.active = best
+	}
+}
+
+// RoundTrip sends the request to the active endpoint, and to the next ones in turn while it gets no answer.
+// An endpoint that fails is marked unhealthy until the next health check.
+func (p *RpcPool) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+	}
+
+	var resp *http.Response
+	var err error
+	for _, i := range p.order() {
+		if resp != nil {
+			resp.Body.Close()
+		}
+		resp, err = p.transport.RoundTrip(p.endpointRequest(req, i, body))
+		if req.Context().Err() != nil {
+			return resp, err
+		}
+		if err == nil && resp.StatusCode < http.StatusInternalServerError && resp.StatusCode != http.StatusTooManyRequests {
+			p.markActive(i)
+			return resp, nil
+		}
+		if err == nil {
+			p.markFailed(i, fmt.Sprintf("%d %s", resp.StatusCode, http.StatusText(resp.StatusCode)))
+		} else {
+			p.markFailed(i, err.Error())
+		}
+	}
+	return resp, err
+}
+
+// order returns the active endpoint first, then the healthy ones and the unhealthy ones last
+func (p *RpcPool) order() []int {
+	p.lk.RLock()
+	defer p.lk.RUnlock()
+	var order []int
+	for i := range p.endpoints {
+		order = append(order, (p.active+i)%len(p.endpoints))
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return p.endpoints[order[a]].Healthy && !p.endpoints[order[b]].Healthy
+	})
+	return order
+}
+
+func (p *RpcPool) endpointRequest(req *http.Request, i int, body []byte) *http.Request {
+	u := p.urls[i]
+	r := req.Clone(req.Context())
+	r.URL = &url.URL{Scheme: u.Scheme, Host: u.Host, Path: u.Path, RawQuery: u.RawQuery}
+	r.Host = u.Host
+	if u.User != nil && r.Header.Get("Authorization") == "" {
+		password, _ := u.User.Password()
+		r.SetBasicAuth(u.User.Username(), password)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	return r
+}
+
+func (p *RpcPool) markActive(i int) {
+	p.lk.Lock()
+	defer p.lk.Unlock()
+	if p.active != i {
+		logs.GetLogger().Warnf("rpc endpoint %s failed, switch to %s", p.endpoints[p.active].Url, p.endpoints[i].Url)
+		p.active = i
+	}
+}
+
+func (p *RpcPool) markFailed(i int, reason string) {
+	p.lk.Lock()
+	defer p.lk.Unlock()
+	p.endpoints[i].Healthy = false
+	p.endpoints[i].Error = reason
+}
@@ -0,0 +1,133 @@
+package contract
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/swanchain/go-computing-provider/conf"
+)
+
+// fakeChain answers eth_blockNumber with a fixed height
+type fakeChain struct {
+	height uint64
+}
+
+func (f *fakeChain) BlockNumber() hexutil.Uint64 {
+	return hexutil.Uint64(f.height)
+}
+
+func newFakeRpcServer(t *testing.T, height uint64) *httptest.Server {
+	server := rpc.NewServer()
+	if err := server.RegisterName("eth", &fakeChain{height: height}); err != nil {
+		t.Fatal(err)
+	}
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(func() {
+		httpServer.Close()
+		server.Stop()
+	})
+	return httpServer
+}
+
+func TestRpcPool_FailOver(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	up := newFakeRpcServer(t, 100)
+
+	pool, err := NewRpcPool([]string{down.URL, up.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	height, err := pool.Client().BlockNumber(context.Background())
+	if err != nil || height != 100 {
+		t.Fatalf("expected the height of the second endpoint, got %d, error: %v", height, err)
+	}
+	if pool.Active() != up.URL {
+		t.Fatalf("expected %s to be active after the failover, got %s", up.URL, pool.Active())
+	}
+	if status := pool.Status(); status[0].Healthy {
+		t.Fatalf("expected %s to be marked unhealthy", down.URL)
+	}
+}
+
+func TestRpcPool_CheckHealthSkipsLaggingEndpoint(t *testing.T) {
+	lagging := newFakeRpcServer(t, 90)
+	synced := newFakeRpcServer(t, 100)
+
+	pool, err := NewRpcPool([]string{lagging.URL, synced.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.CheckHealth()
+
+	if pool.Active() != synced.URL {
+		t.Fatalf("expected %s to be active, got %s", synced.URL, pool.Active())
+	}
+	status := pool.Status()
+	if status[0].Healthy || status[0].Height != 90 {
+		t.Fatalf("expected the lagging endpoint at height 90 to be unhealthy, got %+v", status[0])
+	}
+}
+
+func TestRpcPool_FailOverWhenActiveStops(t *testing.T) {
+	first := newFakeRpcServer(t, 100)
+	second := newFakeRpcServer(t, 101)
+
+	pool, err := NewRpcPool([]string{first.URL, second.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	height, err := pool.Client().BlockNumber(context.Background())
+	if err != nil || height != 100 {
+		t.Fatalf("expected the height of the first endpoint, got %d, error: %v", height, err)
+	}
+
+	first.Close()
+	height, err = pool.Client().BlockNumber(context.Background())
+	if err != nil || height != 101 {
+		t.Fatalf("expected the height of the second endpoint once the first stopped, got %d, error: %v", height, err)
+	}
+	if pool.Active() != second.URL {
+		t.Fatalf("expected %s to be active after the failover, got %s", second.URL, pool.Active())
+	}
+
+	// the health check keeps the stopped endpoint out
+	pool.CheckHealth()
+	if status := pool.Status(); status[0].Healthy || !status[1].Active {
+		t.Fatalf("expected the second endpoint to stay active, got %+v", status)
+	}
+}
+
+func TestResetRpcPool(t *testing.T) {
+	endpoint := newFakeRpcServer(t, 100)
+	old := &conf.ComputeNode{RPC: conf.RPC{SwanChainRpc: conf.RpcList{endpoint.URL}}}
+
+	pool, err := NewRpcPool(old.RPC.SwanChainRpc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rpcPool = pool
+	t.Cleanup(func() { rpcPool = nil })
+
+	resetRpcPool(old, &conf.ComputeNode{RPC: conf.RPC{SwanChainRpc: conf.RpcList{endpoint.URL}}})
+	if rpcPool != pool {
+		t.Fatal("expected the pool to be kept when the endpoints did not change")
+	}
+
+	resetRpcPool(old, &conf.ComputeNode{RPC: conf.RPC{SwanChainRpc: conf.RpcList{endpoint.URL, "https://rpc.example.com"}}})
+	if rpcPool != nil {
+		t.Fatal("expected the pool to be dropped when the endpoints changed")
+	}
+	select {
+	case <-pool.stop:
+	default:
+		t.Fatal("expected the health check of the dropped pool to be stopped")
+	}
+}
@@ -0,0 +1,214 @@
+package contract
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"math/big"
+	"strings"
+)
+
+const (
+	// SignMethodClef is the signing method of clef, the default one of the external signer
+	SignMethodClef = "account_signTransaction"
+	// SignMethodEth is the signing method of nodes and signers that speak the eth namespace
+	SignMethodEth = "eth_signTransaction"
+)
+
+// Signer signs the transactions sent by the contract stubs. The key stays in the
+// local keystore or in an external signing process, the stubs never see it.
+type Signer interface {
+	// Address returns the address whose transactions this signer signs
+	Address() common.Address
+	// SignTx returns the signed copy of tx
+	SignTx(ctx context.Context, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error)
+}
+
+// NewTransactOpts creates the transaction options that sign through signer
+func NewTransactOpts(signer Signer, chainID *big.Int) (*bind.TransactOpts, error) {
+	if signer == nil {
+		return nil, fmt.Errorf("wallet address signer must be not empty")
+	}
+	from := signer.Address()
+	return &bind.TransactOpts{
+		From: from,
+		Signer: func(address common.Address, tx *types.Transaction) (*types.Transaction, error) {
+			if address != from {
+				return nil, bind.ErrNotAuthorized
+			}
+			return signer.SignTx(context.Background(), tx, chainID)
+		},
+		Context: context.Background(),
+	}, nil
+}
+
+// KeySigner signs with a private key held in memory, it backs the local keystore
+type KeySigner struct {
+	privateKey *ecdsa.PrivateKey
+	address    common.Address
+}
+
+func NewKeySigner(privateK string) (*KeySigner, error) {
+	if len(strings.TrimSpace(privateK)) == 0 {
+		return nil, fmt.Errorf("wallet address private key must be not empty")
+	}
+	privateKey, err := crypto.HexToECDSA(privateK)
+	if err != nil {
+		return nil, fmt.Errorf("parses private key error: %+v", err)
+	}
+	return &KeySigner{
+		privateKey: privateKey,
+		address:    crypto.PubkeyToAddress(privateKey.PublicKey),
+	}, nil
+}
+
+func (s *KeySigner) Address() common.Address {
+	return s.address
+}
+
+func (s *KeySigner) SignTx(ctx context.Context, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return types.SignTx(tx, types.LatestSignerForChainID(chainID), s.privateKey)
+}
+
+// ExternalSigner asks a separate signing process over JSON-RPC to sign the transactions,
+// using either the clef `account_signTransaction` or the `eth_signTransaction` method
+type ExternalSigner struct {
+	client  *rpc.Client
+	address common.Address
+	method  string
+}
+
+func NewExternalSigner(endpoint string, address string, method string) (*ExternalSigner, error) {
+	if !common.IsHexAddress(address) {
+		return nil, fmt.Errorf("invalid signer address: %s", address)
+	}
+	if method == "" {
+		method = SignMethodClef
+	}
+	if method != SignMethodClef && method != SignMethodEth {
+		return nil, fmt.Errorf("unsupported signer method: %s, only supports %s and %s", method, SignMethodClef, SignMethodEth)
+	}
+
+	client, err := rpc.Dial(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("dial external signer %s failed, error: %v", endpoint, err)
+	}
+	return &ExternalSigner{
+		client:  client,
+		address: common.HexToAddress(address),
+		method:  method,
+	}, nil
+}
+
+func (s *ExternalSigner) Address() common.Address {
+	return s.address
+}
+
+// signTxArgs is the transaction object accepted by both signing methods
+type signTxArgs struct {
+	From                 common.MixedcaseAddress  `json:"from"`
+	To                   *common.MixedcaseAddress `json:"to,omitempty"`
+	Gas                  hexutil.Uint64           `json:"gas"`
+	GasPrice             *hexutil.Big             `json:"gasPrice,omitempty"`
+	MaxFeePerGas         *hexutil.Big             `json:"maxFeePerGas,omitempty"`
+	MaxPriorityFeePerGas *hexutil.Big             `json:"maxPriorityFeePerGas,omitempty"`
+	Value                hexutil.Big              `json:"value"`
+	Nonce                hexutil.Uint64           `json:"nonce"`
+	Data                 hexutil.Bytes            `json:"data"`
+	ChainID              *hexutil.Big             `json:"chainId,omitempty"`
+}
+
+type signTxResult struct {
+	Raw hexutil.Bytes `json:"raw"`
+}
+
+func (s *ExternalSigner) SignTx(ctx context.Context, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	args := signTxArgs{
+		From:    common.NewMixedcaseAddress(s.address),
+		Gas:     hexutil.Uint64(tx.Gas()),
+		Nonce:   hexutil.Uint64(tx.Nonce()),
+		Data:    tx.Data(),
+		ChainID: (*hexutil.Big)(chainID),
+	}
+	if tx.To() != nil {
+		to := common.NewMixedcaseAddress(*tx.To())
+		args.To = &to
+	}
+	if tx.Value() != nil {
+		args.Value = hexutil.Big(*tx.Value())
+	}
+	if tx.Type() == types.DynamicFeeTxType {
+		args.MaxFeePerGas = (*hexutil.Big)(tx.GasFeeCap())
+		args.MaxPriorityFeePerGas = (*hexutil.Big)(tx.GasTipCap())
+	} else {
+		args.GasPrice = (*hexutil.Big)(tx.GasPrice())
+	}
+
+	var result signTxResult
+	if err := s.client.CallContext(ctx, &result, s.method, &args); err != nil {
+		return nil, fmt.Errorf("external signer %s failed, error: %v", s.method, err)
+	}
+
+	signedTx := new(types.Transaction)
+	if err := signedTx.UnmarshalBinary(result.Raw); err != nil {
+		return nil, fmt.Errorf("decode signed transaction failed, error: %v", err)
+	}
+
+	sender, err := types.Sender(types.LatestSignerForChainID(chainID), signedTx)
+	if err != nil {
+		return nil, fmt.Errorf("recover signed transaction sender failed, error: %v", err)
+	}
+	if sender != s.address {
+		return nil, fmt.Errorf("external signer signed with %s, expected %s", sender.Hex(), s.address.Hex())
+	}
+	if field := modifiedTxField(signedTx, tx, chainID); field != "" {
+		return nil, fmt.Errorf("external signer modified the %s of the transaction", field)
+	}
+	return signedTx, nil
+}
+
+// modifiedTxField names the first field of the signed transaction that differs from the one asked to be signed,
+// empty when none does. The fees are compared too, so that a signer cannot raise them above the configured caps.
+func modifiedTxField(signedTx, tx *types.Transaction, chainID *big.Int) string {
+	switch {
+	case signedTx.Type() != tx.Type():
+		return "type"
+	case signedTx.ChainId().Cmp(chainID) != 0:
+		return "chain id"
+	case signedTx.Nonce() != tx.Nonce():
+		return "nonce"
+	case signedTx.Gas() != tx.Gas():
+		return "gas limit"
+	case signedTx.GasPrice().Cmp(tx.GasPrice()) != 0:
+		return "gas price"
+	case signedTx.GasFeeCap().Cmp(tx.GasFeeCap()) != 0:
+		return "fee cap"
+	case signedTx.GasTipCap().Cmp(tx.GasTipCap()) != 0:
+		return "tip cap"
+	case signedTx.Value().Cmp(tx.Value()) != 0:
+		return "value"
+	case !bytes.Equal(signedTx.Data(), tx.Data()):
+		return "data"
+	case !sameRecipient(signedTx.To(), tx.To()):
+		return "recipient"
+	}
+	return ""
+}
+
+func sameRecipient(a, b *common.Address) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func (s *ExternalSigner) Close() {
+	s.client.Close()
+}
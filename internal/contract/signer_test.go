@@ -0,0 +1,130 @@
+package contract
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"math/big"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// fakeClef answers account_signTransaction with a local key, like clef would, after modify changes the transaction
+type fakeClef struct {
+	key    *ecdsa.PrivateKey
+	modify func(tx *types.LegacyTx)
+}
+
+func (f *fakeClef) SignTransaction(args signTxArgs) (signTxResult, error) {
+	to := args.To.Address()
+	legacyTx := &types.LegacyTx{
+		Nonce:    uint64(args.Nonce),
+		To:       &to,
+		Value:    args.Value.ToInt(),
+		Gas:      uint64(args.Gas),
+		GasPrice: args.GasPrice.ToInt(),
+		Data:     args.Data,
+	}
+	if f.modify != nil {
+		f.modify(legacyTx)
+	}
+	signedTx, err := types.SignTx(types.NewTx(legacyTx), types.LatestSignerForChainID(args.ChainID.ToInt()), f.key)
+	if err != nil {
+		return signTxResult{}, err
+	}
+	raw, err := signedTx.MarshalBinary()
+	return signTxResult{Raw: raw}, err
+}
+
+func newFakeClef(t *testing.T, key *ecdsa.PrivateKey) string {
+	return newModifyingFakeClef(t, key, nil)
+}
+
+func newModifyingFakeClef(t *testing.T, key *ecdsa.PrivateKey, modify func(tx *types.LegacyTx)) string {
+	server := rpc.NewServer()
+	if err := server.RegisterName("account", &fakeClef{key: key, modify: modify}); err != nil {
+		t.Fatal(err)
+	}
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(func() {
+		httpServer.Close()
+		server.Stop()
+	})
+	return httpServer.URL
+}
+
+func TestExternalSigner_SignTx(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	address := crypto.PubkeyToAddress(key.PublicKey)
+	chainID := big.NewInt(254)
+
+	signer, err := NewExternalSigner(newFakeClef(t, key), address.Hex(), SignMethodClef)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer signer.Close()
+
+	txOpts, err := NewTransactOpts(signer, chainID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	to := common.HexToAddress("0x7791f48931DB81668854921fA70bFf0eB85B8211")
+	tx := types.NewTransaction(7, to, big.NewInt(1), 21000, big.NewInt(1e9), []byte{0x01})
+	signedTx, err := txOpts.Signer(address, tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sender, err := types.Sender(types.LatestSignerForChainID(chainID), signedTx)
+	if err != nil || sender != address {
+		t.Fatalf("unexpected sender %s, error: %v", sender.Hex(), err)
+	}
+	if signedTx.Nonce() != 7 {
+		t.Fatalf("unexpected nonce %d", signedTx.Nonce())
+	}
+}
+
+func TestExternalSigner_RejectsOtherSender(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	other, _ := crypto.GenerateKey()
+
+	signer, err := NewExternalSigner(newFakeClef(t, other), crypto.PubkeyToAddress(key.PublicKey).Hex(), SignMethodClef)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer signer.Close()
+
+	to := common.HexToAddress("0x7791f48931DB81668854921fA70bFf0eB85B8211")
+	tx := types.NewTransaction(0, to, big.NewInt(1), 21000, big.NewInt(1e9), nil)
+	if _, err = signer.SignTx(context.Background(), tx, big.NewInt(254)); err == nil {
+		t.Fatal("expected an error when the signer signs with another key")
+	}
+}
+
+func TestExternalSigner_RejectsModifiedTx(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	address := crypto.PubkeyToAddress(key.PublicKey)
+
+	for name, modify := range map[string]func(tx *types.LegacyTx){
+		"gas limit": func(tx *types.LegacyTx) { tx.Gas = 100000 },
+		"gas price": func(tx *types.LegacyTx) { tx.GasPrice = big.NewInt(1e12) },
+		"value":     func(tx *types.LegacyTx) { tx.Value = big.NewInt(2) },
+	} {
+		signer, err := NewExternalSigner(newModifyingFakeClef(t, key, modify), address.Hex(), SignMethodClef)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		to := common.HexToAddress("0x7791f48931DB81668854921fA70bFf0eB85B8211")
+		tx := types.NewTransaction(0, to, big.NewInt(1), 21000, big.NewInt(1e9), nil)
+		if _, err = signer.SignTx(context.Background(), tx, big.NewInt(254)); err == nil || !strings.Contains(err.Error(), name) {
+			t.Errorf("%s: expected the modified %s to be rejected, got %v", name, name, err)
+		}
+		signer.Close()
+	}
+}
@@ -2,29 +2,29 @@ package token
 
 import (
 	"context"
-	"crypto/ecdsa"
 	"fmt"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/swanchain/go-computing-provider/conf"
+	"github.com/swanchain/go-computing-provider/internal/contract"
 	"math/big"
 	"strings"
 )
 
 type Stub struct {
-	client   *ethclient.Client
-	token    *Token
-	privateK string
-	publicK  string
+	client  *ethclient.Client
+	token   *Token
+	signer  contract.Signer
+	publicK string
 }
 
 type Option func(*Stub)
 
-func WithPrivateKey(pk string) Option {
+func WithSigner(signer contract.Signer) Option {
 	return func(obj *Stub) {
-		obj.privateK = pk
+		obj.signer = signer
 	}
 }
 
@@ -75,7 +75,7 @@ func (s *Stub) BalanceOf() (string, error) {
 }
 
 func (s *Stub) Approve(amount *big.Int) (string, error) {
-	publicAddress, err := s.privateKeyToPublicKey()
+	publicAddress, err := s.senderAddress()
 	if err != nil {
 		return "", err
 	}
@@ -87,7 +87,9 @@ func (s *Stub) Approve(amount *big.Int) (string, error) {
 
 	collateralAddress := common.HexToAddress(conf.GetConfig().CONTRACT.Collateral)
 
-	transaction, err := s.token.Approve(txOptions, collateralAddress, amount)
+	transaction, err := contract.SendTx(s.client, txOptions, 0, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return s.token.Approve(opts, collateralAddress, amount)
+	})
 	if err != nil {
 		return "", fmt.Errorf("address: %s, token contract approve, error: %+v", publicAddress, err)
 	}
@@ -95,7 +97,7 @@ func (s *Stub) Approve(amount *big.Int) (string, error) {
 }
 
 func (s *Stub) Transfer(to string, amount *big.Int) (string, error) {
-	publicAddress, err := s.privateKeyToPublicKey()
+	publicAddress, err := s.senderAddress()
 	if err != nil {
 		return "", err
 	}
@@ -107,65 +109,40 @@ func (s *Stub) Transfer(to string, amount *big.Int) (string, error) {
 
 	toAddress := common.HexToAddress(to)
 
-	transaction, err := s.token.Transfer(txOptions, toAddress, amount)
+	transaction, err := contract.SendTx(s.client, txOptions, 0, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return s.token.Transfer(opts, toAddress, amount)
+	})
 	if err != nil {
 		return "", fmt.Errorf("address: %s, token contract transfer, error: %+v", publicAddress, err)
 	}
 	return transaction.Hash().String(), nil
 }
 
-func (s *Stub) privateKeyToPublicKey() (common.Address, error) {
-	if len(strings.TrimSpace(s.privateK)) == 0 {
-		return common.Address{}, fmt.Errorf("wallet address private key must be not empty")
+func (s *Stub) senderAddress() (common.Address, error) {
+	if s.signer == nil {
+		return common.Address{}, fmt.Errorf("wallet address signer must be not empty")
 	}
-
-	privateKey, err := crypto.HexToECDSA(s.privateK)
-	if err != nil {
-		return common.Address{}, fmt.Errorf("parses private key error: %+v", err)
-	}
-
-	publicKey := privateKey.Public()
-	publicKeyECDSA, ok := publicKey.(*ecdsa.PublicKey)
-	if !ok {
-		return common.Address{}, fmt.Errorf("cannot assert type: publicKey is not of type *ecdsa.PublicKey")
-	}
-	return crypto.PubkeyToAddress(*publicKeyECDSA), nil
+	return s.signer.Address(), nil
 }
 
 func (s *Stub) createTransactOpts() (*bind.TransactOpts, error) {
-	publicAddress, err := s.privateKeyToPublicKey()
+	publicAddress, err := s.senderAddress()
 	if err != nil {
 		return nil, err
 	}
 
-	nonce, err := s.client.PendingNonceAt(context.Background(), publicAddress)
-	if err != nil {
-		return nil, fmt.Errorf("address: %s, collateral client get nonce error: %+v", publicAddress, err)
-	}
-
-	suggestGasPrice, err := s.client.SuggestGasPrice(context.Background())
-	if err != nil {
-		return nil, fmt.Errorf("address: %s, collateral client retrieves the currently suggested gas price, error: %+v", publicAddress, err)
-	}
-
 	chainId, err := s.client.ChainID(context.Background())
 	if err != nil {
 		return nil, fmt.Errorf("address: %s, collateral client get networkId, error: %+v", publicAddress, err)
 	}
 
-	privateKey, err := crypto.HexToECDSA(s.privateK)
-	if err != nil {
-		return nil, fmt.Errorf("parses private key error: %+v", err)
-	}
-
-	txOptions, err := bind.NewKeyedTransactorWithChainID(privateKey, chainId)
+	txOptions, err := contract.NewTransactOpts(s.signer, chainId)
 	if err != nil {
 		return nil, fmt.Errorf("address: %s, collateral client create transaction, error: %+v", publicAddress, err)
 	}
-	txOptions.Nonce = big.NewInt(int64(nonce))
-	suggestGasPrice = suggestGasPrice.Mul(suggestGasPrice, big.NewInt(3))
-	suggestGasPrice = suggestGasPrice.Div(suggestGasPrice, big.NewInt(2))
-	txOptions.GasFeeCap = suggestGasPrice
+	if err = contract.SetGasFees(s.client, txOptions); err != nil {
+		return nil, err
+	}
 	txOptions.Context = context.Background()
 	return txOptions, nil
 }
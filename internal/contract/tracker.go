@@ -0,0 +1,315 @@
+package contract
+
+import (
+	"context"
+	"fmt"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/filswan/go-swan-lib/logs"
+	"github.com/swanchain/go-computing-provider/internal/db"
+	"github.com/swanchain/go-computing-provider/internal/metrics"
+	"github.com/swanchain/go-computing-provider/internal/models"
+	"math/big"
+	"strings"
+	"time"
+)
+
+const (
+	txTrackInterval = 15 * time.Second
+	// txStuckTimeout is how long a transaction may stay pending before it is sent again with a higher gas price
+	txStuckTimeout = 3 * time.Minute
+	// txMaxSpeedUps limits how many times the gas price of one transaction is raised
+	txMaxSpeedUps = 5
+	// txGasBumpPercent is the gas price increase of a speed up, nodes require at least 10% to replace a transaction
+	txGasBumpPercent = 20
+	// txDropDepth is how many blocks deep the nonce of a transaction must be used by another one before the
+	// transaction counts as dropped, so that one of ours mined meanwhile or unknown to a lagging endpoint is found
+	txDropDepth = 6
+)
+
+// trackTx saves a sent transaction, so that it is followed until mined even across restarts
+func trackTx(from common.Address, tx *types.Transaction, taskId int64) {
+	if db.NewDbService() == nil {
+		return
+	}
+	raw, err := tx.MarshalBinary()
+	if err != nil {
+		logs.GetLogger().Warnf("tx: %s, encode transaction failed, error: %v", tx.Hash(), err)
+		return
+	}
+
+	now := time.Now().Unix()
+	entity := &models.TransactionEntity{
+		FromAddress: from.Hex(),
+		Nonce:       tx.Nonce(),
+		Hash:        tx.Hash().Hex(),
+		Hashes:      tx.Hash().Hex(),
+		RawTx:       hexutil.Encode(raw),
+		TaskId:      taskId,
+		Status:      models.TX_PENDING_STATUS,
+		CreateTime:  now,
+		UpdateTime:  now,
+	}
+	if err = db.NewDbService().Create(entity).Error; err != nil {
+		logs.GetLogger().Warnf("tx: %s, save transaction failed, error: %v", tx.Hash(), err)
+	}
+}
+
+// nextTrackedNonce returns the nonce after the pending transactions tracked for the address
+func nextTrackedNonce(address common.Address) (uint64, bool) {
+	if db.NewDbService() == nil {
+		return 0, false
+	}
+	var txList []models.TransactionEntity
+	err := db.NewDbService().Where("from_address=? and status=?", address.Hex(), models.TX_PENDING_STATUS).
+		Order("nonce desc").Limit(1).Find(&txList).Error
+	if err != nil || len(txList) == 0 {
+		return 0, false
+	}
+	return txList[0].Nonce + 1, true
+}
+
+// StartTxTracker follows the pending transactions until they are mined. A transaction the node has lost is
+// broadcast again, one pending for longer than txStuckTimeout is replaced by a copy with a higher gas price,
+// and the final hash and status are reported back to the ubi task that sent it. The signers are those of the
+// addresses whose transactions may need a speed up before they send again, e.g. after a restart.
+func StartTxTracker(signers ...Signer) {
+	for _, signer := range signers {
+		if signer != nil {
+			nonceManager.register(signer)
+		}
+	}
+	go func() {
+		ticker := time.NewTicker(txTrackInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			checkPendingTxs()
+		}
+	}()
+}
+
+func checkPendingTxs() {
+	defer func() {
+		if err := recover(); err != nil {
+			logs.GetLogger().Errorf("check pending transactions panic, error: %+v", err)
+		}
+	}()
+
+	var txList []*models.TransactionEntity
+	if err := db.NewDbService().Where("status=?", models.TX_PENDING_STATUS).Order("nonce").Find(&txList).Error; err != nil {
+		logs.GetLogger().Errorf("get pending transactions failed, error: %v", err)
+		return
+	}
+	if len(txList) == 0 {
+		return
+	}
+
+	client, err := GetEthClient()
+	if err != nil {
+		logs.GetLogger().Errorf("dial rpc connect failed, error: %v", err)
+		return
+	}
+
+	for _, entity := range txList {
+		if err = checkPendingTx(client, entity); err != nil {
+			logs.GetLogger().Warnf("tx: %s, check pending transaction failed, error: %v", entity.Hash, err)
+		}
+	}
+}
+
+func checkPendingTx(client *ethclient.Client, entity *models.TransactionEntity) error {
+	ctx := context.Background()
+	if mined, err := checkTxReceipts(ctx, client, entity); err != nil || mined {
+		return err
+	}
+
+	from := common.HexToAddress(entity.FromAddress)
+	latestNonce, err := client.NonceAt(ctx, from, nil)
+	if err != nil {
+		return err
+	}
+	if latestNonce > entity.Nonce {
+		deep, err := nonceUsedAtDepth(ctx, client, from, entity.Nonce)
+		if err != nil || !deep {
+			return err
+		}
+		// one of our transactions may have been mined since its receipt was looked up
+		if mined, err := checkTxReceipts(ctx, client, entity); err != nil || mined {
+			return err
+		}
+		entity.Status = models.TX_DROPPED_STATUS
+		entity.Error = fmt.Sprintf("the nonce %d of transaction %s was used by another transaction", entity.Nonce, entity.Hash)
+		return saveTrackedTx(entity)
+	}
+
+	tx := new(types.Transaction)
+	if err = tx.UnmarshalBinary(common.FromHex(entity.RawTx)); err != nil {
+		return fmt.Errorf("decode transaction failed, error: %v", err)
+	}
+
+	if _, _, err = client.TransactionByHash(ctx, tx.Hash()); err == ethereum.NotFound {
+		logs.GetLogger().Infof("tx: %s, nonce: %d, the node lost the transaction, broadcasting it again", entity.Hash, entity.Nonce)
+		if err = client.SendTransaction(ctx, tx); err != nil {
+			return err
+		}
+	}
+
+	if time.Since(time.Unix(entity.UpdateTime, 0)) < txStuckTimeout {
+		return nil
+	}
+	if len(strings.Split(entity.Hashes, ",")) > txMaxSpeedUps {
+		return nil
+	}
+	signerFn := nonceManager.signer(from, tx.ChainId())
+	if signerFn == nil {
+		// the signer is known once it is registered at the start or the address has sent a transaction
+		return nil
+	}
+
+	bumpedTx, err := bumpGasPrice(client, tx)
+	if err != nil {
+		return fmt.Errorf("speed up the transaction failed, error: %v", err)
+	}
+	newTx, err := signerFn(from, bumpedTx)
+	if err != nil {
+		return fmt.Errorf("sign the speed up transaction failed, error: %v", err)
+	}
+	if err = client.SendTransaction(ctx, newTx); err != nil {
+		return fmt.Errorf("send the speed up transaction failed, error: %v", err)
+	}
+	raw, err := newTx.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	logs.GetLogger().Infof("tx: %s, nonce: %d, stuck for %s, replaced by %s with a higher gas price", entity.Hash, entity.Nonce, txStuckTimeout, newTx.Hash())
+
+	entity.Hash = newTx.Hash().Hex()
+	entity.Hashes += "," + entity.Hash
+	entity.RawTx = hexutil.Encode(raw)
+	return saveTrackedTx(entity)
+}
+
+// checkTxReceipts looks up the receipts of the hashes sent for the transaction, and saves the outcome of the one
+// mined
+func checkTxReceipts(ctx context.Context, client *ethclient.Client, entity *models.TransactionEntity) (bool, error) {
+	for _, hash := range strings.Split(entity.Hashes, ",") {
+		receipt, err := client.TransactionReceipt(ctx, common.HexToHash(hash))
+		if err == ethereum.NotFound {
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+
+		entity.Hash = hash
+		if receipt.Status == types.ReceiptStatusSuccessful {
+			entity.Status = models.TX_CONFIRMED_STATUS
+		} else {
+			entity.Status = models.TX_FAILED_STATUS
+			entity.Error = fmt.Sprintf("transaction %s execution reverted", hash)
+		}
+		return true, saveTrackedTx(entity)
+	}
+	return false, nil
+}
+
+// nonceUsedAtDepth tells if the nonce of the address was already used txDropDepth blocks below the head
+func nonceUsedAtDepth(ctx context.Context, client *ethclient.Client, from common.Address, nonce uint64) (bool, error) {
+	head, err := client.BlockNumber(ctx)
+	if err != nil || head < txDropDepth {
+		return false, err
+	}
+	usedNonce, err := client.NonceAt(ctx, from, new(big.Int).SetUint64(head-txDropDepth))
+	if err != nil {
+		return false, err
+	}
+	return usedNonce > nonce, nil
+}
+
+// bumpGasPrice returns an unsigned copy of tx whose fees are raised by txGasBumpPercent, or set to the
+// current suggestion of the node when that is higher. It fails when the raise goes above the configured caps.
+func bumpGasPrice(client *ethclient.Client, tx *types.Transaction) (*types.Transaction, error) {
+	bump := func(price *big.Int) *big.Int {
+		bumped := new(big.Int).Mul(price, big.NewInt(100+txGasBumpPercent))
+		return bumped.Div(bumped, big.NewInt(100))
+	}
+	caps := GetFeeCaps()
+
+	if tx.Type() == types.DynamicFeeTxType {
+		gasTipCap, gasFeeCap := bump(tx.GasTipCap()), bump(tx.GasFeeCap())
+		if head, err := client.HeaderByNumber(context.Background(), nil); err == nil && head.BaseFee != nil {
+			if _, suggestedTip, suggestedFee, err := suggestGasFees(client, head.BaseFee, caps); err == nil {
+				gasTipCap, gasFeeCap = maxBig(gasTipCap, suggestedTip), maxBig(gasFeeCap, suggestedFee)
+			}
+		}
+		if caps.MaxPriorityFeePerGas != nil && gasTipCap.Cmp(caps.MaxPriorityFeePerGas) > 0 ||
+			caps.MaxFeePerGas != nil && gasFeeCap.Cmp(caps.MaxFeePerGas) > 0 {
+			return nil, fmt.Errorf("the raised fee cap %s or tip %s is above the configured caps", gweiStr(gasFeeCap), gweiStr(gasTipCap))
+		}
+		return types.NewTx(&types.DynamicFeeTx{
+			ChainID:   tx.ChainId(),
+			Nonce:     tx.Nonce(),
+			GasTipCap: gasTipCap,
+			GasFeeCap: gasFeeCap,
+			Gas:       tx.Gas(),
+			To:        tx.To(),
+			Value:     tx.Value(),
+			Data:      tx.Data(),
+		}), nil
+	}
+
+	gasPrice := bump(tx.GasPrice())
+	if suggested, err := client.SuggestGasPrice(context.Background()); err == nil {
+		gasPrice = maxBig(gasPrice, suggested)
+	}
+	if caps.MaxFeePerGas != nil && gasPrice.Cmp(caps.MaxFeePerGas) > 0 {
+		return nil, fmt.Errorf("the raised gas price %s is above the cap of %s", gweiStr(gasPrice), gweiStr(caps.MaxFeePerGas))
+	}
+	return types.NewTx(&types.LegacyTx{
+		Nonce:    tx.Nonce(),
+		GasPrice: gasPrice,
+		Gas:      tx.Gas(),
+		To:       tx.To(),
+		Value:    tx.Value(),
+		Data:     tx.Data(),
+	}), nil
+}
+
+func maxBig(a, b *big.Int) *big.Int {
+	if a.Cmp(b) >= 0 {
+		return a
+	}
+	return b
+}
+
+// saveTrackedTx saves the transaction and reports its hash and final status to the ubi task that sent it
+func saveTrackedTx(entity *models.TransactionEntity) error {
+	entity.UpdateTime = time.Now().Unix()
+	if err := db.NewDbService().Save(entity).Error; err != nil {
+		return err
+	}
+	if entity.TaskId == 0 {
+		return nil
+	}
+
+	// a task with a tx hash counts as succeeded, so the hash is cleared when the transaction fails
+	updates := map[string]interface{}{"tx_hash": entity.Hash}
+	switch entity.Status {
+	case models.TX_CONFIRMED_STATUS:
+		updates["status"] = models.TASK_SUCCESS_STATUS
+	case models.TX_FAILED_STATUS, models.TX_DROPPED_STATUS:
+		updates["tx_hash"] = ""
+		updates["status"] = models.TASK_FAILED_STATUS
+		updates["error"] = entity.Error
+		updates["end_time"] = time.Now().Unix()
+
+		var task models.TaskEntity
+		if err := db.NewDbService().First(&task, entity.TaskId).Error; err == nil {
+			metrics.UbiTaskFailed.WithLabelValues(models.GetSourceTypeStr(task.ResourceType), metrics.ReasonTxFailed).Inc()
+		}
+	}
+	return db.NewDbService().Model(&models.TaskEntity{}).Where("id=?", entity.TaskId).Updates(updates).Error
+}
@@ -0,0 +1,79 @@
+package contract
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/swanchain/go-computing-provider/internal/db"
+	"github.com/swanchain/go-computing-provider/internal/models"
+)
+
+// fakeTxChain answers the calls of the tracker: the nonce of the address is used from usedAt, and the receipt of
+// mined is only found once the nonce has been looked up, as if the transaction was mined in between
+type fakeTxChain struct {
+	height uint64
+	nonce  uint64
+	usedAt uint64
+	mined  common.Hash
+
+	nonceRead bool
+}
+
+func (f *fakeTxChain) BlockNumber() hexutil.Uint64 {
+	return hexutil.Uint64(f.height)
+}
+
+func (f *fakeTxChain) GetTransactionCount(address common.Address, block string) hexutil.Uint64 {
+	f.nonceRead = true
+	if block != "latest" && hexutil.MustDecodeUint64(block) < f.usedAt {
+		return hexutil.Uint64(f.nonce)
+	}
+	return hexutil.Uint64(f.nonce + 1)
+}
+
+func (f *fakeTxChain) GetTransactionReceipt(hash common.Hash) *types.Receipt {
+	if hash != f.mined || !f.nonceRead {
+		return nil
+	}
+	return &types.Receipt{Status: types.ReceiptStatusSuccessful, TxHash: hash, Logs: []*types.Log{}}
+}
+
+func TestCheckPendingTx_NonceUsed(t *testing.T) {
+	db.InitDb(t.TempDir())
+	ours, other := common.HexToHash("0x01"), common.HexToHash("0x02")
+
+	for _, tc := range []struct {
+		name   string
+		usedAt uint64
+		mined  common.Hash
+		status int
+	}{
+		{"nonce used at the head", 100, common.Hash{}, models.TX_PENDING_STATUS},
+		{"mined after the receipt lookup", 90, ours, models.TX_CONFIRMED_STATUS},
+		{"used by another transaction", 90, other, models.TX_DROPPED_STATUS},
+	} {
+		chain := &fakeTxChain{height: 100, nonce: 7, usedAt: tc.usedAt, mined: tc.mined}
+		server := rpc.NewServer()
+		if err := server.RegisterName("eth", chain); err != nil {
+			t.Fatal(err)
+		}
+		entity := &models.TransactionEntity{
+			FromAddress: common.HexToAddress("0x0a").Hex(),
+			Nonce:       7,
+			Hash:        ours.Hex(),
+			Hashes:      ours.Hex(),
+			Status:      models.TX_PENDING_STATUS,
+		}
+		if err := checkPendingTx(ethclient.NewClient(rpc.DialInProc(server)), entity); err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if entity.Status != tc.status {
+			t.Errorf("%s: expected status %d, got %d", tc.name, tc.status, entity.Status)
+		}
+		server.Stop()
+	}
+}
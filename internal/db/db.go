@@ -24,7 +24,10 @@ func InitDb(cpRepoPath string) {
 	DB.AutoMigrate(
 		&models.TaskEntity{},
 		&models.JobEntity{},
-		&models.CpInfoEntity{})
+		&models.CpInfoEntity{},
+		&models.TransactionEntity{},
+		&models.HubEventEntity{},
+		&models.ModelCacheEntity{})
 }
 
 func NewDbService() *gorm.DB {
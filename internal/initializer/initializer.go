@@ -1,17 +1,40 @@
 package initializer
 
 import (
+	"context"
 	"github.com/filswan/go-swan-lib/logs"
 	"github.com/swanchain/go-computing-provider/conf"
 	"github.com/swanchain/go-computing-provider/internal/computing"
+	"github.com/swanchain/go-computing-provider/internal/contract"
+	"github.com/swanchain/go-computing-provider/wallet"
 )
 
 func ProjectInit(cpRepoPath string) {
 	if err := conf.InitConfig(cpRepoPath, false); err != nil {
 		logs.GetLogger().Fatal(err)
 	}
+	if errs := conf.GetConfig().Validate(false); len(errs) > 0 {
+		logs.GetLogger().Fatalf("invalid config, run `computing-provider config validate` for the details:\n%v", errs)
+	}
+	if err := computing.CheckRuntimeClasses(); err != nil {
+		logs.GetLogger().Fatalf("invalid config, %v", err)
+	}
+	if err := conf.WatchConfig(cpRepoPath, false); err != nil {
+		logs.GetLogger().Warnf("the config file is not watched, send SIGHUP to reload it, error: %v", err)
+	}
+	if migrated, err := wallet.UnlockKeystore(context.Background()); err != nil {
+		logs.GetLogger().Fatalf("unlock keystore failed, error: %v", err)
+	} else if migrated > 0 {
+		logs.GetLogger().Infof("encrypted %d plaintext keys in the keystore", migrated)
+	}
 	nodeID := computing.InitComputingProvider(cpRepoPath)
 
 	computing.NewCronTask(nodeID).RunTask()
+	computing.StartUbiTaskQueue(computing.UbiRuntimeK8s)
+	workerSigner, err := computing.GetWorkerSigner()
+	if err != nil {
+		logs.GetLogger().Warnf("the stuck proof transactions are sped up once the worker has sent a transaction, error: %v", err)
+	}
+	contract.StartTxTracker(workerSigner)
 
 }
@@ -0,0 +1,135 @@
+package metrics
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/swanchain/go-computing-provider/internal/models"
+	"sync"
+	"time"
+)
+
+const namespace = "computing_provider"
+
+// the reasons an ubi task failed for, the reason label of UbiTaskFailed
+const (
+	ReasonNoResource    = "no_resource"
+	ReasonCheckResource = "check_resource"
+	ReasonRun           = "run"
+	ReasonDeadline      = "deadline"
+	ReasonSubmitProof   = "submit_proof"
+	ReasonTxFailed      = "tx_failed"
+	ReasonTimeout       = "timeout"
+)
+
+var (
+	UbiTaskReceived = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ubi_tasks_received_total",
+		Help:      "Number of ubi tasks received, by resource type.",
+	}, []string{"resource_type"})
+
+	UbiTaskFailed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ubi_tasks_failed_total",
+		Help:      "Number of ubi tasks that failed, by resource type and reason.",
+	}, []string{"resource_type", "reason"})
+
+	UbiProofSubmitDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "ubi_proof_submit_duration_seconds",
+		Help:      "Time taken to submit an ubi proof to the chain, by result.",
+		Buckets:   []float64{1, 5, 10, 30, 60, 120, 300, 600, 1800},
+	}, []string{"result"})
+
+	DeployStageDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "deploy_stage_duration_seconds",
+		Help:      "Time spent in each stage of a space deployment.",
+		Buckets:   []float64{1, 5, 15, 30, 60, 120, 300, 600, 1200, 1800, 3600},
+	}, []string{"stage"})
+
+	NodeFreeCpu = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "node_free_cpu_cores",
+		Help:      "Free cpu cores of a node.",
+	}, []string{"machine_id"})
+
+	NodeFreeMemory = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "node_free_memory_bytes",
+		Help:      "Free memory of a node.",
+	}, []string{"machine_id"})
+
+	NodeFreeStorage = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "node_free_storage_bytes",
+		Help:      "Free ephemeral storage of a node.",
+	}, []string{"machine_id"})
+
+	NodeFreeGpu = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "node_free_gpus",
+		Help:      "Free gpus of a node, by gpu model.",
+	}, []string{"machine_id", "gpu"})
+
+	CollateralBalance = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "collateral_balance_swanc",
+		Help:      "Available collateral balance of the cp account, by task type (fcp or ecp).",
+	}, []string{"type"})
+)
+
+// Handler serves the metrics in the Prometheus text format
+func Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
+
+// ObserveProofSubmit records the time taken by an ubi proof submission started at start
+func ObserveProofSubmit(start time.Time, success bool) {
+	result := "success"
+	if !success {
+		result = "failed"
+	}
+	UbiProofSubmitDuration.WithLabelValues(result).Observe(time.Since(start).Seconds())
+}
+
+// deployStageTimeout drops the stage of a deployment that stopped reporting, e.g. after it failed
+const deployStageTimeout = 6 * time.Hour
+
+type deployStage struct {
+	stage int
+	start time.Time
+}
+
+var deployStages sync.Map
+
+// ObserveDeployStage records that the deployment of a job entered a DEPLOY_* stage, and the time the job
+// spent in the previous stage. DEPLOY_TO_K8S ends the deployment.
+func ObserveDeployStage(jobUuid string, stage int) {
+	now := time.Now()
+	if v, ok := deployStages.Load(jobUuid); ok {
+		previous := v.(deployStage)
+		DeployStageDuration.WithLabelValues(deployStageName(previous.stage)).Observe(now.Sub(previous.start).Seconds())
+	}
+	if stage == models.DEPLOY_TO_K8S {
+		deployStages.Delete(jobUuid)
+	} else {
+		deployStages.Store(jobUuid, deployStage{stage: stage, start: now})
+	}
+
+	deployStages.Range(func(key, value any) bool {
+		if now.Sub(value.(deployStage).start) > deployStageTimeout {
+			deployStages.Delete(key)
+		}
+		return true
+	})
+}
+
+func deployStageName(stage int) string {
+	if stage == models.DEPLOY_RECEIVE_JOB {
+		return "receiveJob"
+	}
+	return models.GetDeployStatusStr(stage)
+}
@@ -80,6 +80,8 @@ type Resource struct {
 	Memory  Specification
 	Gpu     Specification
 	Storage Specification
+	// GpuResource is the resource class of the gpu: nvidia.com/gpu, a MIG profile or a shared gpu
+	GpuResource string
 }
 
 type Specification struct {
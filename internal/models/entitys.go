@@ -49,6 +49,13 @@ func TaskStatusStr(status int) string {
 	return statusStr
 }
 
+// The state of the deployment of a space job, a job still deploying is resumed after a restart
+const (
+	DEPLOY_STATE_DEPLOYING = "deploying"
+	DEPLOY_STATE_DEPLOYED  = "deployed"
+	DEPLOY_STATE_FAILED    = "failed"
+)
+
 const (
 	SOURCE_TYPE_CPU = 0
 	SOURCE_TYPE_GPU = 1
@@ -88,6 +95,10 @@ type TaskEntity struct {
 	CreateTime   int64  `json:"create_time" gorm:"create_time"`
 	EndTime      int64  `json:"end_time" gorm:"end_time"`
 	Error        string `json:"error" gorm:"error"`
+	Resource     string `json:"resource" gorm:"resource"` // the requested resource in json, needed to run the task again after a restart
+	Workload     string `json:"workload" gorm:"workload"` // the name of the k8s job or docker container running the task
+	Proof        string `json:"-" gorm:"proof"`           // the received proof, kept so that it can still be submitted after a restart
+	Spec         string `json:"-" gorm:"spec"`            // the k8s job or docker container built when the task was accepted, in json
 }
 
 func (task *TaskEntity) TableName() string {
@@ -102,6 +113,7 @@ const (
 	DEPLOY_PUSH_IMAGE
 	DEPLOY_PULL_IMAGE
 	DEPLOY_TO_K8S
+	DEPLOY_CHECK_IMAGE // runs before DEPLOY_TO_K8S, it is numbered last to keep the numbers of the saved jobs
 )
 
 func GetDeployStatusStr(deployStatus int) string {
@@ -119,6 +131,8 @@ func GetDeployStatusStr(deployStatus int) string {
 		statusStr = "pullImage"
 	case DEPLOY_TO_K8S:
 		statusStr = "deployToK8s"
+	case DEPLOY_CHECK_IMAGE:
+		statusStr = "checkImage"
 	}
 	return statusStr
 }
@@ -143,11 +157,18 @@ type JobEntity struct {
 	K8sResourceType string `json:"k8s_resource_type" gorm:"k8s_resource_type"`
 	NameSpace       string `json:"name_space" gorm:"name_space"`
 	ImageName       string `json:"image_name" gorm:"image_name"`
+	ImageDigests    string `json:"image_digests" gorm:"image_digests"` // the images checked by the image policy pinned to their digests, in json
 	BuildLog        string `json:"build_log" gorm:"build_log"`
 	ContainerLog    string `json:"container_log" gorm:"container_log"`
 	ExpireTime      int64  `json:"expire_time" gorm:"expire_time"`
 	CreateTime      int64  `json:"create_time" gorm:"create_time"`
 	Error           string `json:"error" gorm:"error"`
+	DeployState     string `json:"deploy_state" gorm:"deploy_state"`
+	CompletedStage  int    `json:"completed_stage" gorm:"completed_stage"` // the last DEPLOY_* stage done, where a restart resumes
+	StageAttempts   int    `json:"stage_attempts" gorm:"stage_attempts"`   // the attempts of the running stage
+	NodeName        string `json:"node_name" gorm:"node_name"`             // the placement of the job, kept so that it can be resumed
+	GpuProductName  string `json:"gpu_product_name" gorm:"gpu_product_name"`
+	Health          string `json:"health" gorm:"health"` // the health of the deployed space from the probes of its pods
 }
 
 func (*JobEntity) TableName() string {
@@ -230,3 +251,83 @@ func (c *CpInfoEntity) AfterFind(tx *gorm.DB) (err error) {
 	}
 	return nil
 }
+
+const (
+	TX_PENDING_STATUS = iota + 1
+	TX_CONFIRMED_STATUS
+	TX_FAILED_STATUS
+	TX_DROPPED_STATUS
+)
+
+// TransactionEntity is a transaction sent by the computing provider, tracked until it is mined.
+// All the transactions re-sent with a higher gas price for the same nonce share one entity.
+type TransactionEntity struct {
+	Id          int64  `json:"id" gorm:"primaryKey;autoIncrement"`
+	FromAddress string `json:"from_address" gorm:"from_address"`
+	Nonce       uint64 `json:"nonce" gorm:"nonce"`
+	Hash        string `json:"hash" gorm:"hash"`       // the latest transaction sent
+	Hashes      string `json:"hashes" gorm:"hashes"`   // all the transactions sent for the nonce, separated by commas
+	RawTx       string `json:"-" gorm:"raw_tx"`        // the latest signed transaction, broadcast again when the node loses it
+	TaskId      int64  `json:"task_id" gorm:"task_id"` // the ubi task whose proof the transaction submits, 0 means none
+	Status      int    `json:"status" gorm:"status"`
+	Error       string `json:"error" gorm:"error"`
+	CreateTime  int64  `json:"create_time" gorm:"create_time"`
+	UpdateTime  int64  `json:"update_time" gorm:"update_time"`
+}
+
+func (*TransactionEntity) TableName() string {
+	return "t_transaction"
+}
+
+const (
+	HUB_EVENT_PENDING_STATUS = iota + 1
+	HUB_EVENT_DELIVERED_STATUS
+	HUB_EVENT_DEAD_STATUS
+)
+
+// The events of a space job reported to the orchestrator
+const (
+	HUB_EVENT_JOB_STATUS     = "job_status"     // the job entered a DEPLOY_* stage
+	HUB_EVENT_JOB_STATE      = "job_state"      // the deployment ended, deployed or failed
+	HUB_EVENT_RESULT_URL     = "result_url"     // the job result was uploaded
+	HUB_EVENT_JOB_HEALTH     = "job_health"     // the health of the deployed space changed
+	HUB_EVENT_IMAGE_REJECTED = "image_rejected" // an image of the space broke the image policy, the error is the reason
+)
+
+// HubEventEntity is an event of a space job in the outbox, sent to the orchestrator until it is acknowledged.
+// An event is delivered at least once, the orchestrator drops the events whose key it has seen.
+type HubEventEntity struct {
+	Id          int64  `json:"id" gorm:"primaryKey;autoIncrement"`
+	EventKey    string `json:"event_key" gorm:"uniqueIndex"` // the idempotency key: job uuid, event type, value and sequence
+	JobUuid     string `json:"job_uuid" gorm:"index"`
+	TaskUuid    string `json:"task_uuid"`
+	EventType   string `json:"event_type"`
+	Value       string `json:"value"`
+	Error       string `json:"error"` // the error of a failed job
+	Status      int    `json:"status"`
+	Attempts    int    `json:"attempts"`
+	NextAttempt int64  `json:"next_attempt"`
+	LastError   string `json:"last_error"` // why the last delivery failed
+	CreateTime  int64  `json:"create_time"`
+	UpdateTime  int64  `json:"update_time"`
+}
+
+func (*HubEventEntity) TableName() string {
+	return "t_hub_event"
+}
+
+// ModelCacheEntity is a model in the cache of a node, the same model is cached once per node
+type ModelCacheEntity struct {
+	Id         int64  `json:"id" gorm:"primaryKey;autoIncrement"`
+	CacheKey   string `json:"cache_key" gorm:"uniqueIndex:idx_model_cache_node"` // the sha256 of the content, or of the source without one
+	NodeName   string `json:"node_name" gorm:"uniqueIndex:idx_model_cache_node"`
+	Source     string `json:"source"` // the url of a model file, or hf:<model id> for a model of Hugging Face
+	Sha256     string `json:"sha256"` // the sha256 of a model file
+	Size       int64  `json:"size"`   // in bytes
+	LastUsed   int64  `json:"last_used"`
+	CreateTime int64  `json:"create_time"`
+}
+
+func (*ModelCacheEntity) TableName() string {
+	return "t_model_cache"
+}
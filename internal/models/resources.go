@@ -16,6 +16,18 @@ type NodeResource struct {
 	Memory    Common `json:"memory"`
 	Gpu       Gpu    `json:"gpu"`
 	Storage   Common `json:"storage"`
+	// SharedGpus is the MIG profiles and time-sliced gpus of the node, separate from the whole gpus of Gpu
+	SharedGpus []SharedGpu `json:"shared_gpus,omitempty"`
+}
+
+// SharedGpu is a gpu resource class shared by several tasks, a MIG profile such as nvidia.com/mig-1g.10gb or
+// the time-sliced nvidia.com/gpu.shared
+type SharedGpu struct {
+	ResourceName string `json:"resource_name"`
+	ProductName  string `json:"product_name"`
+	Total        int64  `json:"total"`
+	Used         int64  `json:"used"`
+	Free         int64  `json:"free"`
 }
 
 type CollectNodeInfo struct {
@@ -23,14 +35,21 @@ type CollectNodeInfo struct {
 	CpuName string `json:"cpu_name"`
 }
 type Gpu struct {
-	DriverVersion string      `json:"driver_version"`
-	CudaVersion   string      `json:"cuda_version"`
-	AttachedGpus  int         `json:"attached_gpus"`
-	Details       []GpuDetail `json:"details"`
+	DriverVersion string `json:"driver_version"`
+	CudaVersion   string `json:"cuda_version"`
+	// RocmVersion is reported by the resource-exporter of the nodes with AMD gpus
+	RocmVersion string `json:"rocm_version,omitempty"`
+	// Runtime and RuntimeVersion are the gpu runtime of any vendor, e.g. cuda 12.2 or rocm 6.0
+	Runtime        string      `json:"runtime,omitempty"`
+	RuntimeVersion string      `json:"runtime_version,omitempty"`
+	AttachedGpus   int         `json:"attached_gpus"`
+	Details        []GpuDetail `json:"details"`
 }
 
 type GpuDetail struct {
 	ProductName     string    `json:"product_name"`
+	Vendor          string    `json:"vendor,omitempty"`
+	ResourceName    string    `json:"resource_name,omitempty"`
 	Status          GpuStatus `json:"status"`
 	FbMemoryUsage   Common    `json:"fb_memory_usage"`
 	Bar1MemoryUsage Common    `json:"bar1_memory_usage"`
@@ -0,0 +1,41 @@
+package yaml
+
+import (
+	"fmt"
+	"net/netip"
+)
+
+// Egress is a destination beyond the egress policy of the computing provider a service of deploy.yaml v2 needs to
+// reach, the computing provider approves or denies it:
+//
+//	egress:
+//	  - cidr: 203.0.113.10/32
+//	    ports: [5432]
+type Egress struct {
+	Cidr  string  `yaml:"cidr"`
+	Ports []int32 `yaml:"ports"`
+}
+
+// EgressResource is a validated egress request of a service, no ports means all of them
+type EgressResource struct {
+	Cidr  netip.Prefix
+	Ports []int32
+}
+
+// serviceEgress validates the egress the service requests
+func serviceEgress(name string, service Service) ([]EgressResource, error) {
+	var egress []EgressResource
+	for _, request := range service.Egress {
+		cidr, err := netip.ParsePrefix(request.Cidr)
+		if err != nil {
+			return nil, fmt.Errorf("service %s, egress %q is not a CIDR such as 203.0.113.0/24", name, request.Cidr)
+		}
+		for _, port := range request.Ports {
+			if port < 1 || port > 65535 {
+				return nil, fmt.Errorf("service %s, egress %s: port %d is out of range", name, request.Cidr, port)
+			}
+		}
+		egress = append(egress, EgressResource{Cidr: cidr.Masked(), Ports: request.Ports})
+	}
+	return egress, nil
+}
@@ -0,0 +1,118 @@
+package yaml
+
+import (
+	"fmt"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// HealthCheck is the health-check of a service in deploy.yaml v2:
+//
+//	health-check:
+//	  readiness:
+//	    http:
+//	      path: /healthz
+//	      port: 7860
+//	  liveness:
+//	    tcp:
+//	      port: 7860
+//	    initial-delay: 30
+//
+// A check is one of http, tcp or exec, the port defaults to the first port the service exposes.
+type HealthCheck struct {
+	Readiness *Check `yaml:"readiness"`
+	Liveness  *Check `yaml:"liveness"`
+}
+
+type Check struct {
+	Http *struct {
+		Path string `yaml:"path"`
+		Port int    `yaml:"port"`
+	} `yaml:"http"`
+	Tcp *struct {
+		Port int `yaml:"port"`
+	} `yaml:"tcp"`
+	Exec             []string `yaml:"exec"`
+	InitialDelay     int32    `yaml:"initial-delay"`
+	Period           int32    `yaml:"period"`
+	Timeout          int32    `yaml:"timeout"`
+	FailureThreshold int32    `yaml:"failure-threshold"`
+}
+
+// toProbe converts the check to a k8s probe, defaultPort is the port of an http or tcp check without one
+func (c *Check) toProbe(defaultPort int) (*corev1.Probe, error) {
+	probe := &corev1.Probe{
+		InitialDelaySeconds: c.InitialDelay,
+		PeriodSeconds:       c.Period,
+		TimeoutSeconds:      c.Timeout,
+		FailureThreshold:    c.FailureThreshold,
+	}
+
+	var handlers, port int
+	if c.Http != nil {
+		handlers++
+		port = c.Http.Port
+		path := c.Http.Path
+		if path == "" {
+			path = "/"
+		}
+		probe.HTTPGet = &corev1.HTTPGetAction{Path: path}
+	}
+	if c.Tcp != nil {
+		handlers++
+		port = c.Tcp.Port
+		probe.TCPSocket = &corev1.TCPSocketAction{}
+	}
+	if len(c.Exec) > 0 {
+		handlers++
+		probe.Exec = &corev1.ExecAction{Command: c.Exec}
+	}
+	if handlers != 1 {
+		return nil, fmt.Errorf("a check must be exactly one of http, tcp or exec")
+	}
+	if probe.Exec != nil {
+		return probe, nil
+	}
+
+	if port == 0 {
+		port = defaultPort
+	}
+	if port == 0 {
+		return nil, fmt.Errorf("the check has no port and the service exposes none")
+	}
+	if probe.HTTPGet != nil {
+		probe.HTTPGet.Port = intstr.FromInt(port)
+	} else {
+		probe.TCPSocket.Port = intstr.FromInt(port)
+	}
+	return probe, nil
+}
+
+// serviceProbes is the readiness and liveness probes of the service, ready-cmd is the readiness probe of a
+// service without a health-check
+func serviceProbes(name string, service Service) (readiness *corev1.Probe, liveness *corev1.Probe, err error) {
+	var defaultPort int
+	if len(service.Expose) > 0 {
+		defaultPort = service.Expose[0].Port
+	}
+
+	if service.HealthCheck.Readiness != nil {
+		if readiness, err = service.HealthCheck.Readiness.toProbe(defaultPort); err != nil {
+			return nil, nil, fmt.Errorf("service %s, readiness: %w", name, err)
+		}
+	} else if len(service.ReadyCmd) > 0 {
+		readiness = &corev1.Probe{
+			ProbeHandler: corev1.ProbeHandler{
+				Exec: &corev1.ExecAction{Command: service.ReadyCmd},
+			},
+			InitialDelaySeconds: 5,
+			PeriodSeconds:       5,
+		}
+	}
+	if service.HealthCheck.Liveness != nil {
+		if liveness, err = service.HealthCheck.Liveness.toProbe(defaultPort); err != nil {
+			return nil, nil, fmt.Errorf("service %s, liveness: %w", name, err)
+		}
+	}
+	return readiness, liveness, nil
+}
@@ -76,6 +76,21 @@ func (dy *DeployYamlV2) ServiceToK8sResource() ([]ContainerResource, error) {
 					if len(service.ReadyCmd) > 0 {
 						container.ReadyCmd = service.ReadyCmd
 					}
+					readiness, liveness, err := serviceProbes(depend, service)
+					if err != nil {
+						return nil, err
+					}
+					container.ReadinessProbe = readiness
+					container.LivenessProbe = liveness
+					if container.Volumes, err = serviceVolumes(depend, service); err != nil {
+						return nil, err
+					}
+					if container.Exceptions, err = serviceExceptions(depend, service); err != nil {
+						return nil, err
+					}
+					if container.Egress, err = serviceEgress(depend, service); err != nil {
+						return nil, err
+					}
 
 					if deployment.Akash.Count != 0 {
 						container.Count = deployment.Akash.Count
@@ -125,6 +140,23 @@ func (dy *DeployYamlV2) ServiceToK8sResource() ([]ContainerResource, error) {
 				}
 			}
 			containerNew.Models = service.Models
+
+			readiness, liveness, err := serviceProbes(name, service)
+			if err != nil {
+				return nil, err
+			}
+			containerNew.ReadinessProbe = readiness
+			containerNew.LivenessProbe = liveness
+
+			if containerNew.Volumes, err = serviceVolumes(name, service); err != nil {
+				return nil, err
+			}
+			if containerNew.Exceptions, err = serviceExceptions(name, service); err != nil {
+				return nil, err
+			}
+			if containerNew.Egress, err = serviceEgress(name, service); err != nil {
+				return nil, err
+			}
 		}
 
 		containerNew.ResourceLimit = make(corev1.ResourceList)
@@ -166,8 +198,12 @@ type Service struct {
 		Name string `yaml:"name"`
 		Path string `yaml:"path"`
 	} `yaml:"config"`
-	ReadyCmd []string        `yaml:"ready-cmd"`
-	Models   []ModelResource `yaml:"models"`
+	ReadyCmd    []string        `yaml:"ready-cmd"`
+	HealthCheck HealthCheck     `yaml:"health-check"`
+	Volumes     []Volume        `yaml:"volumes"`
+	Models      []ModelResource `yaml:"models"`
+	Security    Security        `yaml:"security"`
+	Egress      []Egress        `yaml:"egress"`
 }
 
 type Expose struct {
@@ -227,7 +263,8 @@ func getProtocol(proto string) corev1.Protocol {
 }
 
 type ModelResource struct {
-	Name string `yaml:"name"`
-	Url  string `yaml:"url"`
-	Dir  string `yaml:"dir"`
+	Name   string `yaml:"name"`
+	Url    string `yaml:"url"`
+	Dir    string `yaml:"dir"`
+	Sha256 string `yaml:"sha256"` // optional, the model is cached by its content when given
 }
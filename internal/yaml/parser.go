@@ -21,6 +21,17 @@ type ContainerResource struct {
 	ReadyCmd      []string
 	GpuModel      string
 	Models        []ModelResource
+
+	// ReadinessProbe and LivenessProbe are from the health-check of the service, or ready-cmd for readiness
+	ReadinessProbe *corev1.Probe
+	LivenessProbe  *corev1.Probe
+	// Volumes are provisioned as persistent volume claims, their data is kept across the redeploys of the space
+	Volumes []VolumeResource
+	// Exceptions are the exceptions to the pod hardening the service requests, run-as-root, writable-root-fs or
+	// capabilities
+	Exceptions []string
+	// Egress is the destinations beyond the egress policy of the computing provider the service requests
+	Egress []EgressResource
 }
 
 type ConfigFile struct {
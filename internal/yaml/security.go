@@ -0,0 +1,52 @@
+package yaml
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// The exceptions to the pod hardening a service can request besides capabilities
+const (
+	ExceptionRunAsRoot      = "run-as-root"
+	ExceptionWritableRootFs = "writable-root-fs"
+)
+
+var capabilityPattern = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+
+// Security is the exceptions to the hardening of the space pods a service of deploy.yaml v2 requests, the
+// computing provider grants those its profile allows:
+//
+//	security:
+//	  run-as-root: true
+//	  writable-root-fs: true
+//	  capabilities: [SYS_PTRACE]
+type Security struct {
+	RunAsRoot      bool     `yaml:"run-as-root"`
+	WritableRootFs bool     `yaml:"writable-root-fs"`
+	Capabilities   []string `yaml:"capabilities"`
+}
+
+// IsCapability tells if the exception is a capability rather than run-as-root or writable-root-fs
+func IsCapability(exception string) bool {
+	return capabilityPattern.MatchString(exception) && exception != "ALL"
+}
+
+// serviceExceptions validates the exceptions the service requests, a capability is named without its CAP_ prefix
+func serviceExceptions(name string, service Service) ([]string, error) {
+	var exceptions []string
+	if service.Security.RunAsRoot {
+		exceptions = append(exceptions, ExceptionRunAsRoot)
+	}
+	if service.Security.WritableRootFs {
+		exceptions = append(exceptions, ExceptionWritableRootFs)
+	}
+	for _, capability := range service.Security.Capabilities {
+		capability = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(capability)), "CAP_")
+		if !IsCapability(capability) {
+			return nil, fmt.Errorf("service %s, %q is not a capability", name, capability)
+		}
+		exceptions = append(exceptions, capability)
+	}
+	return exceptions, nil
+}
@@ -0,0 +1,58 @@
+package yaml
+
+import (
+	"fmt"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"path"
+)
+
+// maxVolumeNameLength keeps the name of the volume in the pod, which has a prefix, a valid label
+const maxVolumeNameLength = 32
+
+// Volume is a persistent volume of a service in deploy.yaml v2, its data outlives the pods of the space:
+//
+//	volumes:
+//	  - name: data
+//	    size: 10Gi
+//	    path: /data
+type Volume struct {
+	Name string `yaml:"name"`
+	Size string `yaml:"size"`
+	Path string `yaml:"path"`
+}
+
+// VolumeResource is a validated volume of a service
+type VolumeResource struct {
+	Name string
+	Size resource.Quantity
+	Path string
+}
+
+// serviceVolumes validates the volumes of the service
+func serviceVolumes(name string, service Service) ([]VolumeResource, error) {
+	var volumes []VolumeResource
+	names := make(map[string]bool)
+	for _, volume := range service.Volumes {
+		if errs := validation.IsDNS1123Label(volume.Name); len(errs) > 0 {
+			return nil, fmt.Errorf("service %s, volume name %q: %s", name, volume.Name, errs[0])
+		}
+		if len(volume.Name) > maxVolumeNameLength {
+			return nil, fmt.Errorf("service %s, volume name %q is longer than %d characters", name, volume.Name, maxVolumeNameLength)
+		}
+		if names[volume.Name] {
+			return nil, fmt.Errorf("service %s, volume %s is declared twice", name, volume.Name)
+		}
+		names[volume.Name] = true
+
+		size, err := resource.ParseQuantity(volume.Size)
+		if err != nil || size.Sign() <= 0 {
+			return nil, fmt.Errorf("service %s, volume %s: size %q is not a positive quantity such as 10Gi", name, volume.Name, volume.Size)
+		}
+		if !path.IsAbs(volume.Path) || path.Clean(volume.Path) == "/" {
+			return nil, fmt.Errorf("service %s, volume %s: path %q is not an absolute directory", name, volume.Name, volume.Path)
+		}
+		volumes = append(volumes, VolumeResource{Name: volume.Name, Size: size, Path: path.Clean(volume.Path)})
+	}
+	return volumes, nil
+}
@@ -40,6 +40,7 @@ const (
 	GetLocationError           = 3000
 	GetCpAccountError          = 3001
 	GeResourceError            = 3002
+	GetWalletBalanceError      = 3003
 	JsonError                  = 4000
 	BadParamError              = 4001
 	SignatureError             = 4002
@@ -53,6 +54,10 @@ const (
 	FoundWhiteListError        = 4010
 	FoundBlackListError        = 4011
 	SpaceCheckBlackListError   = 4012
+	UnauthorizedError          = 4013
+	DeleteJobError             = 4014
+	ReloadConfigError          = 4015
+	QuotaExceededError         = 4016
 
 	ProofParamError   = 7001
 	ProofReadLogError = 7002
@@ -70,6 +75,7 @@ var codeMsg = map[int]string{
 	GetLocationError:           "An error occurred while get location of cp",
 	GetCpAccountError:          "An error occurred while get cp account address",
 	GeResourceError:            "An error occurred while get cp account resource",
+	GetWalletBalanceError:      "An error occurred while get wallet balance",
 	JsonError:                  "An error occurred while converting to json",
 	BadParamError:              "The request parameter is not valid",
 	SignatureError:             "Verify signature failed",
@@ -83,6 +89,10 @@ var codeMsg = map[int]string{
 	SaveJobEntityError:         "An error occurred while save job info",
 	FoundWhiteListError:        "An error occurred while get whitelist",
 	FoundBlackListError:        "An error occurred while get blacklist",
+	UnauthorizedError:          "Missing or invalid admin credentials",
+	DeleteJobError:             "An error occurred while delete job",
+	ReloadConfigError:          "An error occurred while reload config",
+	QuotaExceededError:         "The job exceeds the resource quota of the wallet",
 
 	ProofReadLogError: "An error occurred while read the log of proof",
 	ProofError:        "An error occurred while executing the calculation task",
@@ -2,7 +2,10 @@ package util
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
+	"fmt"
 	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
 	"github.com/swanchain/go-computing-provider/conf"
 	"net/http"
@@ -60,6 +63,22 @@ func ServeHttp(h http.Handler, name string, addr string, ssl bool) (StopFunc, er
 		ReadHeaderTimeout: 60 * time.Second,
 	}
 
+	if ssl && conf.GetConfig().ADMIN.ClientCa != "" {
+		// the client certificate is optional, only the admin API requires one when no bearer token is sent
+		caCert, err := os.ReadFile(conf.GetConfig().ADMIN.ClientCa)
+		if err != nil {
+			return nil, fmt.Errorf("read the client ca file failed, error: %v", err)
+		}
+		clientCAs := x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("no certificate found in the client ca file: %s", conf.GetConfig().ADMIN.ClientCa)
+		}
+		srv.TLSConfig = &tls.Config{
+			ClientCAs:  clientCAs,
+			ClientAuth: tls.VerifyClientCertIfGiven,
+		}
+	}
+
 	go func() {
 		if ssl {
 			certFile := conf.GetConfig().LOG.CrtFile
@@ -1,8 +1,12 @@
 package wallet
 
 import (
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/uuid"
 	"github.com/syndtr/goleveldb/leveldb"
 	"sync"
 )
@@ -10,8 +14,47 @@ import (
 var diskKeyStore *DiskKeyStore
 var lock sync.Mutex
 
+// decryptedKeys caches the keys decrypted once the keystore is unlocked, by name with the keyfile they were
+// decrypted from, as a scrypt decryption takes about a second. The keystore is reopened for every operation, so the
+// cache outlives a DiskKeyStore.
+var (
+	decryptedKeys   = make(map[string]decryptedKey)
+	decryptedKeysLk sync.Mutex
+)
+
+type decryptedKey struct {
+	keyFile string
+	info    KeyInfo
+}
+
+func cachedKey(name string, keyFile []byte) (KeyInfo, bool) {
+	decryptedKeysLk.Lock()
+	defer decryptedKeysLk.Unlock()
+	cached, ok := decryptedKeys[name]
+	if !ok || cached.keyFile != string(keyFile) {
+		return KeyInfo{}, false
+	}
+	return cached.info, true
+}
+
+func cacheKey(name string, keyFile []byte, info KeyInfo) {
+	decryptedKeysLk.Lock()
+	defer decryptedKeysLk.Unlock()
+	decryptedKeys[name] = decryptedKey{keyFile: string(keyFile), info: info}
+}
+
+func uncacheKey(name string) {
+	decryptedKeysLk.Lock()
+	defer decryptedKeysLk.Unlock()
+	delete(decryptedKeys, name)
+}
+
+// PassphraseFunc supplies the keystore passphrase, confirm asks for it twice when it is being set for the first time
+type PassphraseFunc func(confirm bool) (string, error)
+
 type DiskKeyStore struct {
-	db *leveldb.DB
+	db         *leveldb.DB
+	passphrase PassphraseFunc
 }
 
 func OpenOrInitKeystore(p string) (*DiskKeyStore, error) {
@@ -21,7 +64,7 @@ func OpenOrInitKeystore(p string) (*DiskKeyStore, error) {
 	if err != nil {
 		return diskKeyStore, err
 	}
-	diskKeyStore = &DiskKeyStore{db}
+	diskKeyStore = &DiskKeyStore{db: db, passphrase: GetPassphrase}
 	return diskKeyStore, err
 }
 
@@ -41,22 +84,62 @@ func (dks *DiskKeyStore) List() ([]string, error) {
 func (dks *DiskKeyStore) Get(name string) (KeyInfo, error) {
 	value, err := dks.db.Get([]byte(name), nil)
 	if err != nil {
-		if err != nil {
-			return KeyInfo{}, fmt.Errorf("decoding key '%s': %w", name, err)
+		if err == leveldb.ErrNotFound {
+			return KeyInfo{}, ErrKeyInfoNotFound
 		}
+		return KeyInfo{}, fmt.Errorf("decoding key '%s': %w", name, err)
 	}
-	var res KeyInfo
-	if err = json.Unmarshal(value, &res); err != nil {
+
+	if !isEncryptedKey(value) {
+		var res KeyInfo
+		if err = json.Unmarshal(value, &res); err != nil {
+			return KeyInfo{}, err
+		}
+		return res, nil
+	}
+
+	if ki, ok := cachedKey(name, value); ok {
+		return ki, nil
+	}
+	passphrase, err := dks.passphrase(false)
+	if err != nil {
 		return KeyInfo{}, err
 	}
-	return res, nil
+	ki, err := decryptKeyInfo(value, passphrase)
+	if err != nil {
+		return KeyInfo{}, err
+	}
+	cacheKey(name, value, ki)
+	return ki, nil
+}
+
+// GetKeyFile returns the encrypted keyfile stored under the given name, in the go-ethereum keyfile format
+func (dks *DiskKeyStore) GetKeyFile(name string) ([]byte, error) {
+	value, err := dks.db.Get([]byte(name), nil)
+	if err != nil {
+		if err == leveldb.ErrNotFound {
+			return nil, ErrKeyInfoNotFound
+		}
+		return nil, fmt.Errorf("decoding key '%s': %w", name, err)
+	}
+	if !isEncryptedKey(value) {
+		return nil, fmt.Errorf("key '%s' is not encrypted yet, run `computing-provider wallet change-passphrase` first", name)
+	}
+	return value, nil
 }
 
-// Put saves key info under given name
+// Put encrypts the key info with the keystore passphrase and saves it under given name
 func (dks *DiskKeyStore) Put(key string, info KeyInfo) error {
-	bytes, _ := json.Marshal(info)
-	err := dks.db.Put([]byte(key), bytes, nil)
+	passphrase, err := dks.passphrase(!dks.Encrypted())
+	if err != nil {
+		return err
+	}
+
+	bytes, err := encryptKeyInfo(info, passphrase)
 	if err != nil {
+		return fmt.Errorf("encrypting key '%s': %w", key, err)
+	}
+	if err = dks.db.Put([]byte(key), bytes, nil); err != nil {
 		return fmt.Errorf("writing key '%s': %w", key, err)
 	}
 	return nil
@@ -67,6 +150,103 @@ func (dks *DiskKeyStore) Delete(key string) error {
 	if err != nil {
 		return fmt.Errorf("deleting key '%s': %w", key, err)
 	}
+	uncacheKey(key)
+	return nil
+}
+
+// Migrate encrypts every plaintext entry left by older versions and returns how many were converted
+func (dks *DiskKeyStore) Migrate() (int, error) {
+	plainKeys := make(map[string]KeyInfo)
+	iter := dks.db.NewIterator(nil, nil)
+	for iter.Next() {
+		if isEncryptedKey(iter.Value()) {
+			continue
+		}
+		var ki KeyInfo
+		if err := json.Unmarshal(iter.Value(), &ki); err != nil {
+			iter.Release()
+			return 0, fmt.Errorf("decoding key '%s': %w", string(iter.Key()), err)
+		}
+		plainKeys[string(iter.Key())] = ki
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return 0, err
+	}
+
+	if len(plainKeys) == 0 {
+		return 0, nil
+	}
+
+	passphrase, err := dks.passphrase(!dks.Encrypted())
+	if err != nil {
+		return 0, err
+	}
+
+	batch := new(leveldb.Batch)
+	for name, ki := range plainKeys {
+		data, err := encryptKeyInfo(ki, passphrase)
+		if err != nil {
+			return 0, fmt.Errorf("encrypting key '%s': %w", name, err)
+		}
+		batch.Put([]byte(name), data)
+	}
+	if err = dks.db.Write(batch, nil); err != nil {
+		return 0, fmt.Errorf("writing migrated keys: %w", err)
+	}
+	return len(plainKeys), nil
+}
+
+// Verify checks that the passphrase decrypts the stored keys, the key decrypted is cached for Get
+func (dks *DiskKeyStore) Verify(passphrase string) error {
+	iter := dks.db.NewIterator(nil, nil)
+	defer iter.Release()
+	for iter.Next() {
+		if isEncryptedKey(iter.Value()) {
+			ki, err := decryptKeyInfo(iter.Value(), passphrase)
+			if err != nil {
+				return err
+			}
+			cacheKey(string(iter.Key()), iter.Value(), ki)
+			return nil
+		}
+	}
+	return iter.Error()
+}
+
+// ChangePassphrase re-encrypts every entry with newPassphrase, plaintext entries are encrypted as well
+func (dks *DiskKeyStore) ChangePassphrase(oldPassphrase, newPassphrase string) error {
+	batch := new(leveldb.Batch)
+	iter := dks.db.NewIterator(nil, nil)
+	for iter.Next() {
+		name := string(iter.Key())
+		var ki KeyInfo
+		var err error
+		if isEncryptedKey(iter.Value()) {
+			ki, err = decryptKeyInfo(iter.Value(), oldPassphrase)
+		} else {
+			err = json.Unmarshal(iter.Value(), &ki)
+		}
+		if err != nil {
+			iter.Release()
+			return fmt.Errorf("decoding key '%s': %w", name, err)
+		}
+
+		data, err := encryptKeyInfo(ki, newPassphrase)
+		if err != nil {
+			iter.Release()
+			return fmt.Errorf("encrypting key '%s': %w", name, err)
+		}
+		batch.Put([]byte(name), data)
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return err
+	}
+
+	if err := dks.db.Write(batch, nil); err != nil {
+		return fmt.Errorf("writing re-encrypted keys: %w", err)
+	}
 	return nil
 }
 
@@ -74,6 +254,64 @@ func (dks *DiskKeyStore) Close() error {
 	return dks.db.Close()
 }
 
+// Encrypted reports whether the keystore already holds encrypted keys, i.e. a passphrase has been set
+func (dks *DiskKeyStore) Encrypted() bool {
+	iter := dks.db.NewIterator(nil, nil)
+	defer iter.Release()
+	for iter.Next() {
+		if isEncryptedKey(iter.Value()) {
+			return true
+		}
+	}
+	return false
+}
+
+// isEncryptedKey reports whether the stored value is a go-ethereum keyfile rather than a legacy plaintext KeyInfo
+func isEncryptedKey(value []byte) bool {
+	var probe struct {
+		Crypto json.RawMessage `json:"crypto"`
+	}
+	if err := json.Unmarshal(value, &probe); err != nil {
+		return false
+	}
+	return len(probe.Crypto) != 0
+}
+
+func encryptKeyInfo(info KeyInfo, passphrase string) ([]byte, error) {
+	privateKey, err := crypto.HexToECDSA(info.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("parses private key error: %v", err)
+	}
+	key := &keystore.Key{
+		Id:         uuid.New(),
+		Address:    crypto.PubkeyToAddress(privateKey.PublicKey),
+		PrivateKey: privateKey,
+	}
+	return keystore.EncryptKey(key, passphrase, keystore.StandardScryptN, keystore.StandardScryptP)
+}
+
+func decryptKeyInfo(keyJson []byte, passphrase string) (KeyInfo, error) {
+	key, err := keystore.DecryptKey(keyJson, passphrase)
+	if err != nil {
+		return KeyInfo{}, err
+	}
+	return KeyInfo{PrivateKey: hex.EncodeToString(crypto.FromECDSA(key.PrivateKey))}, nil
+}
+
+// IsKeyFile reports whether data is an encrypted go-ethereum keyfile
+func IsKeyFile(data []byte) bool {
+	return isEncryptedKey(data)
+}
+
+// KeyInfoFromKeyFile decrypts a go-ethereum keyfile with its passphrase
+func KeyInfoFromKeyFile(keyJson []byte, passphrase string) (*KeyInfo, error) {
+	ki, err := decryptKeyInfo(keyJson, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt keyfile failed, error: %v", err)
+	}
+	return &ki, nil
+}
+
 // KeyInfo is used for storing keys in KeyStore
 type KeyInfo struct {
 	PrivateKey string
@@ -85,9 +323,19 @@ type KeyStore interface {
 	List() ([]string, error)
 	// Get gets a key out of keystore and returns KeyInfo corresponding to named key
 	Get(string) (KeyInfo, error)
+	// GetKeyFile returns the encrypted keyfile of the named key
+	GetKeyFile(string) ([]byte, error)
 	// Put saves a key info under given name
 	Put(string, KeyInfo) error
 	// Delete removes a key from keystore
 	Delete(string) error
+	// Migrate encrypts the plaintext entries written by older versions
+	Migrate() (int, error)
+	// Verify checks the passphrase against the stored keys
+	Verify(string) error
+	// ChangePassphrase re-encrypts all keys with a new passphrase
+	ChangePassphrase(string, string) error
+	// Encrypted reports whether a passphrase has been set
+	Encrypted() bool
 	Close() error
 }
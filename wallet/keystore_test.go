@@ -0,0 +1,88 @@
+package wallet
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+const testPrivateKey = "b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291"
+
+func openTestKeystore(t *testing.T, passphrase string) *DiskKeyStore {
+	db, err := leveldb.OpenFile(filepath.Join(t.TempDir(), WalletRepo), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return &DiskKeyStore{db: db, passphrase: func(bool) (string, error) { return passphrase, nil }}
+}
+
+func TestDiskKeyStore_MigrateAndChangePassphrase(t *testing.T) {
+	ks := openTestKeystore(t, "first")
+
+	legacy, _ := json.Marshal(KeyInfo{PrivateKey: testPrivateKey})
+	if err := ks.db.Put([]byte(KNamePrefix+"legacy"), legacy, nil); err != nil {
+		t.Fatal(err)
+	}
+	if ks.Encrypted() {
+		t.Fatal("keystore with only plaintext keys reported as encrypted")
+	}
+
+	migrated, err := ks.Migrate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if migrated != 1 || !ks.Encrypted() {
+		t.Fatalf("expected 1 migrated key, got %d", migrated)
+	}
+
+	ki, err := ks.Get(KNamePrefix + "legacy")
+	if err != nil || ki.PrivateKey != testPrivateKey {
+		t.Fatalf("get migrated key: %v, %s", err, ki.PrivateKey)
+	}
+
+	if err = ks.ChangePassphrase("first", "second"); err != nil {
+		t.Fatal(err)
+	}
+	if err = ks.Verify("first"); err == nil {
+		t.Fatal("old passphrase still decrypts the keystore")
+	}
+	if err = ks.Verify("second"); err != nil {
+		t.Fatal(err)
+	}
+
+	keyJson, err := ks.GetKeyFile(KNamePrefix + "legacy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exported, err := KeyInfoFromKeyFile(keyJson, "second")
+	if err != nil || exported.PrivateKey != testPrivateKey {
+		t.Fatalf("decrypt exported keyfile: %v", err)
+	}
+}
+
+func TestDiskKeyStore_GetCachesDecryptedKey(t *testing.T) {
+	ks := openTestKeystore(t, "secret")
+	name := KNamePrefix + "cached"
+	if err := ks.Put(name, KeyInfo{PrivateKey: testPrivateKey}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ks.Get(name); err != nil {
+		t.Fatal(err)
+	}
+
+	ks.passphrase = func(bool) (string, error) { return "", ErrPassphraseRequired }
+	ki, err := ks.Get(name)
+	if err != nil || ki.PrivateKey != testPrivateKey {
+		t.Fatalf("expected the decrypted key from the cache, got %v", err)
+	}
+
+	if err = ks.ChangePassphrase("secret", "other"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ks.Get(name); err == nil {
+		t.Fatal("expected the re-encrypted key to be decrypted again")
+	}
+}
@@ -0,0 +1,99 @@
+package wallet
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/term"
+)
+
+const (
+	// PassphraseEnv holds the keystore passphrase for unattended daemons
+	PassphraseEnv = "CP_WALLET_PASSPHRASE"
+	// PassphraseFileEnv points to a file whose first line is the keystore passphrase
+	PassphraseFileEnv = "CP_WALLET_PASSPHRASE_FILE"
+)
+
+var (
+	ErrPassphraseRequired = fmt.Errorf("keystore passphrase required, set %s or %s, or run in a terminal", PassphraseEnv, PassphraseFileEnv)
+	ErrPassphraseMismatch = fmt.Errorf("passphrases do not match")
+)
+
+var (
+	cachedPassphrase string
+	passphraseOnce   bool
+	passphraseLock   sync.Mutex
+)
+
+// SetPassphrase caches the keystore passphrase for the lifetime of the process
+func SetPassphrase(passphrase string) {
+	passphraseLock.Lock()
+	defer passphraseLock.Unlock()
+	cachedPassphrase = passphrase
+	passphraseOnce = true
+}
+
+// GetPassphrase returns the keystore passphrase, looking it up in order from the
+// process cache, the CP_WALLET_PASSPHRASE env, the CP_WALLET_PASSPHRASE_FILE env
+// and finally an interactive prompt. When confirm is true the prompt asks twice.
+func GetPassphrase(confirm bool) (string, error) {
+	passphraseLock.Lock()
+	defer passphraseLock.Unlock()
+	if passphraseOnce {
+		return cachedPassphrase, nil
+	}
+
+	passphrase, err := lookupPassphrase(confirm)
+	if err != nil {
+		return "", err
+	}
+	cachedPassphrase = passphrase
+	passphraseOnce = true
+	return passphrase, nil
+}
+
+func lookupPassphrase(confirm bool) (string, error) {
+	if passphrase, ok := os.LookupEnv(PassphraseEnv); ok {
+		return passphrase, nil
+	}
+
+	if passphraseFile, ok := os.LookupEnv(PassphraseFileEnv); ok && strings.TrimSpace(passphraseFile) != "" {
+		data, err := os.ReadFile(passphraseFile)
+		if err != nil {
+			return "", fmt.Errorf("read passphrase file %s failed, error: %v", passphraseFile, err)
+		}
+		return strings.TrimRight(strings.SplitN(string(data), "\n", 2)[0], "\r"), nil
+	}
+
+	return PromptPassphrase("Enter keystore passphrase: ", confirm)
+}
+
+// PromptPassphrase reads a passphrase from the terminal without echoing it
+func PromptPassphrase(prompt string, confirm bool) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", ErrPassphraseRequired
+	}
+
+	fmt.Print(prompt)
+	passphrase, err := term.ReadPassword(fd)
+	fmt.Println()
+	if err != nil {
+		return "", fmt.Errorf("read passphrase failed, error: %v", err)
+	}
+
+	if confirm {
+		fmt.Print("Repeat passphrase: ")
+		repeat, err := term.ReadPassword(fd)
+		fmt.Println()
+		if err != nil {
+			return "", fmt.Errorf("read passphrase failed, error: %v", err)
+		}
+		if string(passphrase) != string(repeat) {
+			return "", ErrPassphraseMismatch
+		}
+	}
+	return string(passphrase), nil
+}
@@ -2,12 +2,12 @@ package wallet
 
 import (
 	"context"
-	"crypto/ecdsa"
+	"fmt"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
-	"log"
+	"github.com/swanchain/go-computing-provider/internal/contract"
 	"math/big"
 )
 
@@ -30,47 +30,39 @@ func Balance(ctx context.Context, client *ethclient.Client, addr string) (string
 	return ethValue, nil
 }
 
-func sendTransaction(client *ethclient.Client, privateK string, to string, amount *big.Int) (string, error) {
-	privateKey, err := crypto.HexToECDSA(privateK)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	publicKey := privateKey.Public()
-	publicKeyECDSA, ok := publicKey.(*ecdsa.PublicKey)
-	if !ok {
-		log.Fatal("cannot assert type: publicKey is not of type *ecdsa.PublicKey")
-	}
-
-	fromAddress := crypto.PubkeyToAddress(*publicKeyECDSA)
-	nonce, err := client.PendingNonceAt(context.Background(), fromAddress)
-	if err != nil {
-		log.Fatal(err)
-	}
+func sendTransaction(client *ethclient.Client, signer contract.Signer, to string, amount *big.Int) (string, error) {
+	fromAddress := signer.Address()
 
 	gasLimit := uint64(21000) // in units
-	gasPrice, err := client.SuggestGasPrice(context.Background())
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	toAddress := common.HexToAddress(to)
-	var data []byte
-	tx := types.NewTransaction(nonce, toAddress, amount, gasLimit, gasPrice, data)
 
 	chainID, err := client.NetworkID(context.Background())
 	if err != nil {
-		log.Fatal(err)
+		return "", fmt.Errorf("get networkId, error: %v", err)
 	}
 
-	signedTx, err := types.SignTx(tx, types.NewEIP155Signer(chainID), privateKey)
+	txOptions, err := contract.NewTransactOpts(signer, chainID)
 	if err != nil {
-		log.Fatal(err)
+		return "", err
+	}
+	if err = contract.SetGasFees(client, txOptions); err != nil {
+		return "", fmt.Errorf("address: %s, %v", fromAddress, err)
 	}
 
-	err = client.SendTransaction(context.Background(), signedTx)
+	toAddress := common.HexToAddress(to)
+	var data []byte
+	signedTx, err := contract.SendTx(client, txOptions, 0, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		tx := contract.NewTx(opts, chainID, toAddress, amount, gasLimit, data)
+		signedTx, err := opts.Signer(opts.From, tx)
+		if err != nil {
+			return nil, fmt.Errorf("address: %s, sign transaction failed, error: %v", fromAddress, err)
+		}
+		if err = client.SendTransaction(context.Background(), signedTx); err != nil {
+			return nil, fmt.Errorf("address: %s, send transaction failed, error: %v", fromAddress, err)
+		}
+		return signedTx, nil
+	})
 	if err != nil {
-		log.Fatal(err)
+		return "", err
 	}
 	return signedTx.Hash().String(), nil
 }
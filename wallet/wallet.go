@@ -9,9 +9,9 @@ import (
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
-	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/swanchain/go-computing-provider/conf"
+	"github.com/swanchain/go-computing-provider/internal/contract"
 	"github.com/swanchain/go-computing-provider/internal/contract/account"
 	"github.com/swanchain/go-computing-provider/internal/contract/ecp"
 	"github.com/swanchain/go-computing-provider/internal/contract/fcp"
@@ -67,6 +67,16 @@ loop:
 	return nil, resultErr
 }
 
+// UnlockKeystore is called by the long-running commands before they start serving, so the
+// passphrase is asked once and kept in memory for signing proofs later on
+func UnlockKeystore(ctx context.Context) (int, error) {
+	localWallet, err := SetupWallet(WalletRepo)
+	if err != nil {
+		return 0, fmt.Errorf("setup wallet failed, error: %v", err)
+	}
+	return localWallet.Unlock(ctx)
+}
+
 type LocalWallet struct {
 	keystore KeyStore
 	lk       sync.Mutex
@@ -121,6 +131,53 @@ func (w *LocalWallet) FindKey(addr string) (*KeyInfo, error) {
 	return &ki, nil
 }
 
+// externalSigners keeps the connections to the external signer by endpoint, method and address,
+// a wallet is set up for every proof so they are shared by the wallets instead of dialed each time
+var (
+	externalSigners   = make(map[string]*contract.ExternalSigner)
+	externalSignersLk sync.Mutex
+)
+
+func externalSigner(endpoint, addr, method string) (*contract.ExternalSigner, error) {
+	externalSignersLk.Lock()
+	defer externalSignersLk.Unlock()
+	key := strings.Join([]string{endpoint, method, strings.ToLower(addr)}, "|")
+	if signer, ok := externalSigners[key]; ok {
+		return signer, nil
+	}
+	signer, err := contract.NewExternalSigner(endpoint, addr, method)
+	if err != nil {
+		return nil, err
+	}
+	externalSigners[key] = signer
+	return signer, nil
+}
+
+// GetSigner returns the transaction signer of addr. It talks to the external signer when
+// SIGNER.Url is configured, otherwise it signs with the key in the local keystore.
+func (w *LocalWallet) GetSigner(ctx context.Context, addr string) (contract.Signer, error) {
+	if cfg := conf.GetConfig(); cfg != nil && strings.TrimSpace(cfg.SIGNER.Url) != "" {
+		signer, err := externalSigner(cfg.SIGNER.Url, addr, cfg.SIGNER.Method)
+		if err != nil {
+			return nil, err
+		}
+		return signer, nil
+	}
+
+	ki, err := w.FindKey(addr)
+	if err != nil {
+		return nil, err
+	}
+	if ki == nil {
+		return nil, xerrors.Errorf("the address: %s, private key %w,", addr, ErrKeyInfoNotFound)
+	}
+	signer, err := contract.NewKeySigner(ki.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	return signer, nil
+}
+
 func (w *LocalWallet) WalletExport(ctx context.Context, addr string) (*KeyInfo, error) {
 	defer w.keystore.Close()
 	k, err := w.FindKey(addr)
@@ -155,12 +212,81 @@ func (w *LocalWallet) WalletImport(ctx context.Context, ki *KeyInfo) (string, er
 	if err := w.keystore.Put(KNamePrefix+address, *ki); err != nil {
 		return "", xerrors.Errorf("saving to keystore: %w", err)
 	}
-	return "", nil
+	return address, nil
 }
 
-func (w *LocalWallet) WalletList(ctx context.Context, contractFlag bool) error {
+// WalletExportKeyFile returns the encrypted keyfile of addr, it can be imported by go-ethereum compatible wallets
+func (w *LocalWallet) WalletExportKeyFile(ctx context.Context, addr string) ([]byte, error) {
 	defer w.keystore.Close()
-	addressList, err := w.addressList(ctx)
+	w.lk.Lock()
+	defer w.lk.Unlock()
+
+	keyJson, err := w.keystore.GetKeyFile(KNamePrefix + addr)
+	if err != nil {
+		if xerrors.Is(err, ErrKeyInfoNotFound) {
+			return nil, xerrors.Errorf("private key not found for %s", addr)
+		}
+		return nil, xerrors.Errorf("failed to find key to export: %w", err)
+	}
+	return keyJson, nil
+}
+
+// Unlock resolves the keystore passphrase, checks it against the stored keys and
+// encrypts the plaintext keys left by older versions. It returns the number of migrated keys.
+func (w *LocalWallet) Unlock(ctx context.Context) (int, error) {
+	defer w.keystore.Close()
+	w.lk.Lock()
+	defer w.lk.Unlock()
+
+	keys, err := w.keystore.List()
+	if err != nil {
+		return 0, xerrors.Errorf("listing keystore: %w", err)
+	}
+	if len(keys) == 0 {
+		return 0, nil
+	}
+
+	passphrase, err := GetPassphrase(!w.keystore.Encrypted())
+	if err != nil {
+		return 0, err
+	}
+	if err = w.keystore.Verify(passphrase); err != nil {
+		return 0, xerrors.Errorf("unlock keystore: %w", err)
+	}
+	return w.keystore.Migrate()
+}
+
+// WalletChangePassphrase re-encrypts all keys of the keystore with a new passphrase
+func (w *LocalWallet) WalletChangePassphrase(ctx context.Context) error {
+	defer w.keystore.Close()
+	w.lk.Lock()
+	defer w.lk.Unlock()
+
+	var oldPassphrase string
+	if w.keystore.Encrypted() {
+		passphrase, err := GetPassphrase(false)
+		if err != nil {
+			return err
+		}
+		if err = w.keystore.Verify(passphrase); err != nil {
+			return xerrors.Errorf("verify current passphrase: %w", err)
+		}
+		oldPassphrase = passphrase
+	}
+
+	newPassphrase, err := PromptPassphrase("Enter new passphrase: ", true)
+	if err != nil {
+		return err
+	}
+	if err = w.keystore.ChangePassphrase(oldPassphrase, newPassphrase); err != nil {
+		return err
+	}
+	SetPassphrase(newPassphrase)
+	return nil
+}
+
+func (w *LocalWallet) WalletList(ctx context.Context, contractFlag bool) error {
+	balances, err := w.WalletBalances(ctx, contractFlag)
 	if err != nil {
 		return err
 	}
@@ -170,17 +296,45 @@ func (w *LocalWallet) WalletList(ctx context.Context, contractFlag bool) error {
 	nonceKey := "Nonce"
 	errorKey := "Error"
 
-	chainRpc, err := conf.GetRpcByNetWorkName()
+	tw := tablewriter.New(
+		tablewriter.Col(addressKey),
+		tablewriter.Col(balanceKey),
+		tablewriter.Col(nonceKey),
+		tablewriter.NewLineCol(errorKey))
+
+	for _, balance := range balances {
+		tw.Write(map[string]interface{}{
+			addressKey: balance.Address,
+			balanceKey: balance.Balance,
+			errorKey:   balance.Error,
+			nonceKey:   balance.Nonce,
+		})
+	}
+	return tw.Flush(os.Stdout)
+}
+
+// WalletBalance is the balance and the pending nonce of an address of the keystore
+type WalletBalance struct {
+	Address string `json:"address"`
+	Balance string `json:"balance"`
+	Nonce   uint64 `json:"nonce"`
+	Error   string `json:"error,omitempty"`
+}
+
+// WalletBalances returns the balances of all the addresses of the keystore, in SWANC when contractFlag is set, otherwise in sETH
+func (w *LocalWallet) WalletBalances(ctx context.Context, contractFlag bool) ([]WalletBalance, error) {
+	defer w.keystore.Close()
+	addressList, err := w.addressList(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	client, err := ethclient.Dial(chainRpc)
+
+	client, err := contract.GetEthClient()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer client.Close()
 
-	var wallets []map[string]interface{}
+	var balances []WalletBalance
 	for _, addr := range addressList {
 		var balance string
 		if contractFlag {
@@ -202,25 +356,14 @@ func (w *LocalWallet) WalletList(ctx context.Context, contractFlag bool) error {
 			errmsg = err.Error()
 		}
 
-		wallet := map[string]interface{}{
-			addressKey: addr,
-			balanceKey: balance,
-			errorKey:   errmsg,
-			nonceKey:   nonce,
-		}
-		wallets = append(wallets, wallet)
+		balances = append(balances, WalletBalance{
+			Address: addr,
+			Balance: balance,
+			Nonce:   nonce,
+			Error:   errmsg,
+		})
 	}
-
-	tw := tablewriter.New(
-		tablewriter.Col(addressKey),
-		tablewriter.Col(balanceKey),
-		tablewriter.Col(nonceKey),
-		tablewriter.NewLineCol(errorKey))
-
-	for _, wallet := range wallets {
-		tw.Write(wallet)
-	}
-	return tw.Flush(os.Stdout)
+	return balances, nil
 }
 
 func (w *LocalWallet) WalletNew(ctx context.Context) (string, error) {
@@ -280,30 +423,22 @@ func (w *LocalWallet) WalletDelete(ctx context.Context, addr string) error {
 
 func (w *LocalWallet) WalletSend(ctx context.Context, from, to string, amount string) (string, error) {
 	defer w.keystore.Close()
-	chainUrl, err := conf.GetRpcByNetWorkName()
+	signer, err := w.GetSigner(ctx, from)
 	if err != nil {
 		return "", err
 	}
-	ki, err := w.FindKey(from)
-	if err != nil {
-		return "", err
-	}
-	if ki == nil {
-		return "", xerrors.Errorf("the address: %s, private %w,", from, ErrKeyInfoNotFound)
-	}
 
-	client, err := ethclient.Dial(chainUrl)
+	client, err := contract.GetEthClient()
 	if err != nil {
 		return "", err
 	}
-	defer client.Close()
 
 	sendAmount, err := convertToWei(amount)
 	if err != nil {
 		return "", err
 	}
 
-	txHash, err := sendTransaction(client, ki.PrivateKey, to, sendAmount)
+	txHash, err := sendTransaction(client, signer, to, sendAmount)
 	if err != nil {
 		return "", err
 	}
@@ -317,23 +452,15 @@ func (w *LocalWallet) WalletCollateral(ctx context.Context, from string, amount
 		return "", err
 	}
 
-	chainUrl, err := conf.GetRpcByNetWorkName()
-	if err != nil {
-		return "", err
-	}
-	ki, err := w.FindKey(from)
+	signer, err := w.GetSigner(ctx, from)
 	if err != nil {
 		return "", err
 	}
-	if ki == nil {
-		return "", xerrors.Errorf("the address: %s, private key %w,", from, ErrKeyInfoNotFound)
-	}
 
-	client, err := ethclient.Dial(chainUrl)
+	client, err := contract.GetEthClient()
 	if err != nil {
 		return "", err
 	}
-	defer client.Close()
 
 	if len(cpAccountAddress) > 0 {
 		cpAccount := common.HexToAddress(cpAccountAddress)
@@ -356,7 +483,7 @@ func (w *LocalWallet) WalletCollateral(ctx context.Context, from string, amount
 	}
 
 	if collateralType == "fcp" {
-		tokenStub, err := token.NewTokenStub(client, token.WithPrivateKey(ki.PrivateKey))
+		tokenStub, err := token.NewTokenStub(client, token.WithSigner(signer))
 		if err != nil {
 			return "", err
 		}
@@ -383,7 +510,7 @@ func (w *LocalWallet) WalletCollateral(ctx context.Context, from string, amount
 
 				if receipt != nil && receipt.Status == types.ReceiptStatusSuccessful {
 					fmt.Printf("swan token approve TX: %s \n", swanTokenTxHash)
-					collateralStub, err := fcp.NewCollateralStub(client, fcp.WithPrivateKey(ki.PrivateKey), fcp.WithCpAccountAddress(cpAccountAddress))
+					collateralStub, err := fcp.NewCollateralStub(client, fcp.WithSigner(signer), fcp.WithCpAccountAddress(cpAccountAddress))
 					if err != nil {
 						return "", err
 					}
@@ -407,7 +534,7 @@ func (w *LocalWallet) WalletCollateral(ctx context.Context, from string, amount
 			return "", fmt.Errorf("cp account: %s does not exist on the chain", cpAccountAddress)
 		}
 
-		zkCollateral, err := ecp.NewCollateralStub(client, ecp.WithPrivateKey(ki.PrivateKey))
+		zkCollateral, err := ecp.NewCollateralStub(client, ecp.WithSigner(signer))
 		if err != nil {
 			return "", err
 		}
@@ -427,24 +554,15 @@ func (w *LocalWallet) CollateralWithdraw(ctx context.Context, address string, am
 		return "", err
 	}
 
-	chainUrl, err := conf.GetRpcByNetWorkName()
+	signer, err := w.GetSigner(ctx, address)
 	if err != nil {
 		return "", err
 	}
 
-	ki, err := w.FindKey(address)
-	if err != nil {
-		return "", err
-	}
-	if ki == nil {
-		return "", xerrors.Errorf("the address: %s, private key %w,", address, ErrKeyInfoNotFound)
-	}
-
-	client, err := ethclient.Dial(chainUrl)
+	client, err := contract.GetEthClient()
 	if err != nil {
 		return "", err
 	}
-	defer client.Close()
 
 	if len(cpAccountAddress) > 0 {
 		cpAccount := common.HexToAddress(cpAccountAddress)
@@ -459,13 +577,13 @@ func (w *LocalWallet) CollateralWithdraw(ctx context.Context, address string, am
 	}
 
 	if collateralType == "fcp" {
-		collateralStub, err := fcp.NewCollateralStub(client, fcp.WithPrivateKey(ki.PrivateKey))
+		collateralStub, err := fcp.NewCollateralStub(client, fcp.WithSigner(signer))
 		if err != nil {
 			return "", err
 		}
 		return collateralStub.Withdraw(cpAccountAddress, withDrawAmount)
 	} else {
-		zkCollateral, err := ecp.NewCollateralStub(client, ecp.WithPrivateKey(ki.PrivateKey))
+		zkCollateral, err := ecp.NewCollateralStub(client, ecp.WithSigner(signer))
 		if err != nil {
 			return "", err
 		}
@@ -480,26 +598,17 @@ func (w *LocalWallet) CollateralSend(ctx context.Context, from, to string, amoun
 		return "", err
 	}
 
-	chainUrl, err := conf.GetRpcByNetWorkName()
+	signer, err := w.GetSigner(ctx, from)
 	if err != nil {
 		return "", err
 	}
 
-	ki, err := w.FindKey(from)
-	if err != nil {
-		return "", err
-	}
-	if ki == nil {
-		return "", xerrors.Errorf("the address: %s, private key %w,", to, ErrKeyInfoNotFound)
-	}
-
-	client, err := ethclient.Dial(chainUrl)
+	client, err := contract.GetEthClient()
 	if err != nil {
 		return "", err
 	}
-	defer client.Close()
 
-	collateralStub, err := token.NewTokenStub(client, token.WithPrivateKey(ki.PrivateKey))
+	collateralStub, err := token.NewTokenStub(client, token.WithSigner(signer))
 	if err != nil {
 		return "", err
 	}
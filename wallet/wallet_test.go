@@ -0,0 +1,30 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/swanchain/go-computing-provider/internal/contract"
+)
+
+func TestExternalSigner_Shared(t *testing.T) {
+	const addr = "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"
+	first, err := externalSigner("http://127.0.0.1:8550", addr, contract.SignMethodClef)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := externalSigner("http://127.0.0.1:8550", addr, contract.SignMethodClef)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Fatal("expected the signer of the address to be dialed once")
+	}
+
+	other, err := externalSigner("http://127.0.0.1:8550", addr, contract.SignMethodEth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == first {
+		t.Fatal("expected another signer for another method")
+	}
+}